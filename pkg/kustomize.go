@@ -2,11 +2,16 @@ package kustomize
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/k8s-manifest-kit/engine/pkg/pipeline"
 	"github.com/k8s-manifest-kit/engine/pkg/types"
 	"github.com/k8s-manifest-kit/pkg/util/cache"
+	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/api/resmap"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -16,17 +21,37 @@ import (
 	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
 )
 
-const rendererType = "kustomize"
+const (
+	rendererType = "kustomize"
+
+	defaultValuesConfigMapName = "values"
+	defaultValuesFileName      = "values.yaml"
+)
 
 // Source represents the input for a Kustomize rendering operation.
 type Source struct {
 	// Path specifies the directory containing kustomization.yaml.
-	// Must be a valid filesystem path to a kustomization root.
+	// Must be a valid filesystem path to a kustomization root, or to the kustomization file
+	// of one (kustomization.yaml, kustomization.yml or Kustomization), which stands for its
+	// directory; results then report the directory as source path.
 	Path string
 
+	// Glob selects several kustomization roots at once, e.g. "apps/*/overlays/prod", as an
+	// alternative to Path. New expands it, using the source's filesystem, into one source
+	// per matched directory containing a kustomization file (matched kustomization files
+	// select their directory), in lexical order and without duplicates; other matches are
+	// ignored. Each source renders separately, with its own source path, and shares the
+	// remaining fields. Matching no kustomization fails with ErrGlobNoMatch.
+	//
+	// Glob is only supported by New; the pattern syntax is that of filepath.Match. With a
+	// filesystem built on the Afero adapter (e.g. fs.NewFsOnDisk or WithAferoFs), a "**"
+	// element also matches any number of directories, e.g. "apps/**/kustomization.yaml".
+	Glob string
+
 	// Values provides dynamic key-value data written as a ConfigMap.
 	// Function is called during rendering to obtain dynamic values.
-	// The values are written to a ConfigMap file at Path/values.yaml.
+	// The values are written to a ConfigMap file at Path/values.yaml
+	// (configurable via WithValuesConfigMap).
 	//
 	// IMPORTANT: Values are NOT applied automatically to resources.
 	// The kustomization must explicitly use this ConfigMap via:
@@ -34,33 +59,73 @@ type Source struct {
 	// - configMapGenerator: if integrating with generated configs
 	// - patches: to modify resources based on values
 	//
-	// If Path/values.yaml already exists, rendering will fail with an error
+	// If Path/values.yaml already exists, rendering will fail with ErrValuesFileExists
 	// to prevent accidental overwrites.
 	Values func(context.Context) (map[string]string, error)
 
+	// StructuredValues provides dynamic, typed data written to the same values ConfigMap.
+	// Unlike Values, entries may hold nested maps, lists, numbers or booleans: scalars are
+	// written in their canonical YAML form and maps/lists are serialized as YAML documents,
+	// so a kustomization can consume them verbatim (e.g. as a file-like ConfigMap key).
+	//
+	// When both are set, Values entries take precedence over StructuredValues entries
+	// with the same key.
+	StructuredValues func(context.Context) (map[string]any, error)
+
 	// LoadRestrictions specifies restrictions on what can be referenced.
 	// If LoadRestrictionsUnknown (zero value), uses the renderer-wide default.
 	// Set to LoadRestrictionsRootOnly or LoadRestrictionsNone to override.
 	LoadRestrictions kustomizetypes.LoadRestrictions
+
+	// CacheTTL overrides the renderer-wide cache TTL (see WithCache) for this source.
+	// Zero uses the renderer-wide TTL, a positive value sets a dedicated TTL (use a very
+	// large value for immutable sources such as release artifacts), and a negative value
+	// disables caching for this source. Has no effect unless caching is enabled.
+	CacheTTL time.Duration
+
+	// OnlyFromPath keeps only the objects originating from the given path, relative to the
+	// kustomization root, e.g. "components/monitoring" or "../base/deployment.yaml": objects
+	// declared in a file at or below the path, and objects generated by a kustomization at
+	// or below it. Origins are those of the source.file annotation, so the filter requires
+	// source tracking (WithSourceAnnotations or WithSourceInfoAsLabels) and, if
+	// WithManagedBuildMetadata is disabled, originAnnotations in the buildMetadata of the
+	// kustomization; rendering fails with ErrOriginTrackingRequired otherwise.
+	OnlyFromPath string
+
+	// FileSystem overrides the renderer-wide filesystem (see WithFileSystem) for this
+	// source, so that a single renderer can mix sources from different backends, e.g. an
+	// embedded filesystem, a git checkout and the local disk. If nil, the renderer-wide
+	// filesystem is used.
+	FileSystem filesys.FileSystem
 }
 
 // Renderer is a renderer that uses kustomize to render resources.
 type Renderer struct {
 	inputs []*sourceHolder
-	fs     filesys.FileSystem
 	engine *Engine
 	opts   *RendererOptions
-	cache  cache.Interface[[]unstructured.Unstructured]
+	cache  *renderCache
+	extra  []unstructured.Unstructured
+
+	// fileSystems holds the distinct filesystems of the sources overriding the renderer-wide
+	// one, in source order; their position identifies them in cache keys. Filesystems of
+	// non-comparable types are held once per source.
+	fileSystems []filesys.FileSystem
 }
 
 // New creates a new kustomize renderer.
 func New(inputs []Source, opts ...RendererOption) (*Renderer, error) {
 	// Initialize renderer options
 	rendererOpts := RendererOptions{
-		Filters:          make([]types.Filter, 0),
-		Transformers:     make([]types.Transformer, 0),
-		Plugins:          make([]resmap.Transformer, 0),
-		LoadRestrictions: kustomizetypes.LoadRestrictionsRootOnly,
+		Filters:             make([]types.Filter, 0),
+		Transformers:        make([]types.Transformer, 0),
+		Plugins:             make([]resmap.Transformer, 0),
+		LoadRestrictions:    kustomizetypes.LoadRestrictionsRootOnly,
+		ValuesConfigMapName: defaultValuesConfigMapName,
+		ValuesFileName:      defaultValuesFileName,
+		Reorder:             krusty.ReorderOptionNone,
+		OutputOrder:         OrderAsIs,
+		SourceType:          rendererType,
 	}
 
 	// Apply all options to RendererOptions
@@ -68,6 +133,42 @@ func New(inputs []Source, opts ...RendererOption) (*Renderer, error) {
 		opt.ApplyTo(&rendererOpts)
 	}
 
+	if err := rendererOpts.OutputOrder.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := validateReorder(rendererOpts.Reorder); err != nil {
+		return nil, err
+	}
+
+	var compat *kustomizeVersion
+	if rendererOpts.CompatibilityMode != "" {
+		v, err := parseCompatibilityMode(rendererOpts.CompatibilityMode)
+		if err != nil {
+			return nil, err
+		}
+
+		compat = &v
+	}
+
+	// Use custom filesystem if provided, otherwise default to OS filesystem
+	fsys := rendererOpts.FileSystem
+	if rendererOpts.Hermetic {
+		confined, err := hermeticFileSystem(fsys, "")
+		if err != nil {
+			return nil, err
+		}
+
+		fsys = confined
+	} else if fsys == nil {
+		fsys = fs.NewFsOnDisk()
+	}
+
+	inputs, err := expandSources(fsys, inputs)
+	if err != nil {
+		return nil, err
+	}
+
 	// Wrap sources in holders and validate
 	holders := make([]*sourceHolder, len(inputs))
 	for i := range inputs {
@@ -77,64 +178,358 @@ func New(inputs []Source, opts ...RendererOption) (*Renderer, error) {
 		if err := holders[i].Validate(); err != nil {
 			return nil, err
 		}
+
+		// the engine confines the filesystems of the sources on each render
+		if rendererOpts.Hermetic && inputs[i].FileSystem != nil {
+			if _, err := hermeticFileSystem(inputs[i].FileSystem, ""); err != nil {
+				return nil, fmt.Errorf("source %q: %w", inputs[i].Path, err)
+			}
+		}
 	}
 
-	// Use custom filesystem if provided, otherwise default to OS filesystem
-	fsys := rendererOpts.FileSystem
-	if fsys == nil {
-		fsys = fs.NewFsOnDisk()
+	pluginConfig := clonePluginConfig(rendererOpts.PluginConfig)
+	if rendererOpts.HelmGenerator != nil {
+		helmConfig, err := newHelmConfig(rendererOpts.HelmGenerator.Command, rendererOpts.HelmGenerator.Options)
+		if err != nil {
+			return nil, err
+		}
+
+		pluginConfig.HelmConfig = *helmConfig
+	}
+
+	if rendererOpts.Deterministic {
+		fixFunctionClock(pluginConfig)
+	}
+
+	if rendererOpts.Hermetic && pluginConfig.PluginRestrictions == kustomizetypes.PluginRestrictionsNone {
+		return nil, fmt.Errorf("%w: exec plugins and KRM functions cannot be enabled", ErrHermeticViolation)
+	}
+
+	pluginHome, err := resolvePluginHome(rendererOpts.PluginHome, pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	rendererOpts.PluginHome = pluginHome
+
+	patches := make([]*patchTransformer, len(rendererOpts.Patches))
+	for i, patch := range rendererOpts.Patches {
+		if patches[i], err = newPatchTransformer(patch); err != nil {
+			return nil, fmt.Errorf("patch %d: %w", i, err)
+		}
+	}
+
+	extra, err := parseExtraResources(rendererOpts.ExtraResources)
+	if err != nil {
+		return nil, err
 	}
 
 	r := &Renderer{
 		inputs: holders,
-		fs:     fsys,
-		engine: newKustomizeEngine(fsys, &rendererOpts),
+		engine: newKustomizeEngine(fsys, &rendererOpts, pluginConfig),
 		opts:   &rendererOpts,
-		cache:  newCache(rendererOpts.CacheOptions),
+		cache:  newCache(rendererOpts.CacheOptions, rendererOpts.CacheKeyFunc),
+		extra:  extra,
+	}
+	r.engine.patches = patches
+	r.engine.compat = compat
+
+	for _, holder := range holders {
+		if err := r.engine.checkOriginFilter(holder.Source); err != nil {
+			return nil, err
+		}
+
+		if holder.FileSystem == nil {
+			continue
+		}
+
+		holder.fsIndex = r.fileSystemIndex(holder.FileSystem)
+		if holder.fsIndex == 0 {
+			r.fileSystems = append(r.fileSystems, holder.FileSystem)
+			holder.fsIndex = len(r.fileSystems)
+		}
 	}
 
 	return r, nil
 }
 
+// observeCache reports a cache event to the configured observer, if any.
+func (r *Renderer) observeCache(path string, key string, eventType CacheEventType) {
+	if r.opts.CacheObserver == nil {
+		return
+	}
+
+	r.opts.CacheObserver(CacheEvent{
+		Path: path,
+		Key:  key,
+		Type: eventType,
+	})
+}
+
 // Name returns the renderer type identifier.
 func (r *Renderer) Name() string {
 	return rendererType
 }
 
 // Process implements types.Renderer by rendering the kustomize resources and applying filters and transformers.
+// Sources are rendered sequentially unless WithConcurrency is set, in which case they are rendered by a
+// bounded pool of workers. Either way, output follows source order.
+//
+// Process is RenderDetailed flattened into a single slice and passed through the finalizers;
+// the configured output order applies to the combined objects. With WithContinueOnError, the
+// objects of the successful sources are returned together with the errors of the failed ones.
 func (r *Renderer) Process(ctx context.Context, renderTimeValues map[string]any) ([]unstructured.Unstructured, error) {
-	allObjects := make([]unstructured.Unstructured, 0)
+	result, renderErr := r.RenderDetailed(ctx, renderTimeValues)
+	if result == nil {
+		return nil, renderErr
+	}
+
+	allObjects := result.Objects()
+
+	var err error
+
+	for i, finalize := range r.opts.Finalizers {
+		allObjects, err = finalize(ctx, allObjects)
+		if err != nil {
+			return nil, fmt.Errorf("finalizer %d failed: %w", i, err)
+		}
+	}
+
+	sortObjects(allObjects, r.opts.OutputOrder, r.opts.SortFunc)
+
+	return allObjects, renderErr
+}
+
+// RenderDetailed renders every source like Process, but returns the objects grouped by source
+// together with per-source diagnostics: the warnings detected, whether the result was served
+// from the cache, and how long the source took to render. Objects of each source keep
+// kustomize's order. The extra resources set with WithExtraResources are returned apart.
+//
+// With WithContinueOnError, a failing source does not abort the render: the result is
+// returned together with the joined errors of the failed sources, whose SourceResult.Err is
+// set. Warnings, schema validation and conflict checks then cover the successful sources.
+func (r *Renderer) RenderDetailed(ctx context.Context, renderTimeValues map[string]any) (*Result, error) {
+	start := time.Now()
+
+	var results []SourceResult
+	var renderErr error
+
+	if r.opts.Concurrency > 1 && len(r.inputs) > 1 {
+		results, renderErr = r.processParallel(ctx, renderTimeValues)
+	} else {
+		results, renderErr = r.processSequential(ctx, renderTimeValues)
+	}
+
+	if renderErr != nil && !r.opts.ContinueOnError {
+		return nil, renderErr
+	}
+
+	result := newResult(results, copyObjects(r.extra))
+
+	if err := r.engine.handleWarnings(result.Warnings()); err != nil {
+		return nil, err
+	}
+
+	if len(r.opts.SchemaSources) > 0 {
+		if err := validateSchemas(result.Objects(), r.opts.SchemaSources); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.opts.CheckConflicts {
+		rendered := make([]renderedObject, 0)
+		for _, source := range results {
+			for _, obj := range source.Objects {
+				rendered = append(rendered, renderedObject{obj: obj, sourcePath: source.Path})
+			}
+		}
+
+		for _, obj := range result.Extra {
+			rendered = append(rendered, renderedObject{obj: obj})
+		}
 
-	for _, holder := range r.inputs {
-		objects, err := r.renderSingle(ctx, holder, renderTimeValues)
+		if err := checkConflicts(rendered, r.opts.SourceAnnotationConfig.keys()); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Duration = time.Since(start)
+	result.stats.Duration = result.Duration
+
+	return result, renderErr
+}
+
+// processSequential renders sources one after another, stopping at the first error unless
+// ContinueOnError is set, in which case failed sources are recorded in their result and
+// their errors aggregated in source order.
+func (r *Renderer) processSequential(
+	ctx context.Context,
+	renderTimeValues map[string]any,
+) ([]SourceResult, error) {
+	results := make([]SourceResult, len(r.inputs))
+	errs := make([]error, 0)
+
+	for i, holder := range r.inputs {
+		result, err := r.processSource(ctx, holder, renderTimeValues)
 		if err != nil {
-			return nil, fmt.Errorf("error rendering kustomize path %s: %w", holder.Path, err)
+			if !r.opts.ContinueOnError {
+				return nil, err
+			}
+
+			result = SourceResult{Path: holder.Path, Err: err}
+			errs = append(errs, err)
+		}
+
+		results[i] = result
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// processParallel renders sources using at most opts.Concurrency workers.
+// Results are indexed by source so output order is deterministic, and errors
+// from all failing sources are aggregated in source order.
+func (r *Renderer) processParallel(
+	ctx context.Context,
+	renderTimeValues map[string]any,
+) ([]SourceResult, error) {
+	results := make([]SourceResult, len(r.inputs))
+	errs := make([]error, len(r.inputs))
+
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(r.opts.Concurrency, len(r.inputs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indices {
+				results[i], errs[i] = r.processSource(ctx, r.inputs[i], renderTimeValues)
+			}
+		}()
+	}
+
+	for i := range r.inputs {
+		indices <- i
+	}
+
+	close(indices)
+	wg.Wait()
+
+	err := errors.Join(errs...)
+	if err != nil && !r.opts.ContinueOnError {
+		return nil, err
+	}
+
+	for i, sourceErr := range errs {
+		if sourceErr != nil {
+			results[i] = SourceResult{Path: r.inputs[i].Path, Err: sourceErr}
+		}
+	}
+
+	return results, err
+}
+
+// processSource renders a single source and applies renderer-level filters and transformers.
+func (r *Renderer) processSource(
+	ctx context.Context,
+	holder *sourceHolder,
+	renderTimeValues map[string]any,
+) (SourceResult, error) {
+	start := time.Now()
+	result := SourceResult{Path: holder.Path}
+
+	objects, err := r.renderSingle(ctx, holder, renderTimeValues, &result)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("error rendering kustomize path %s: %w", holder.Path, err)
+	}
+
+	// Take the internal marks first, so that user filters and transformers never see them
+	origins := takeOrigins(objects)
+	valuesNames := takeValuesNames(objects)
+
+	// Apply renderer-level filters and transformers per-source for better error context
+	transformed, kept, err := applyPipeline(ctx, objects, r.opts.Filters, r.opts.Transformers)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf(
+			"error applying filters/transformers to path %s: %w",
+			holder.Path,
+			err,
+		)
+	}
+
+	result.Origins = make([]*Origin, len(kept))
+	result.kinds = make(map[string]int)
+
+	for i, index := range kept {
+		result.Origins[i] = origins[index]
+		result.kinds[transformed[i].GetKind()]++
+
+		if name := valuesNames[index]; name != "" {
+			if result.ValuesNames == nil {
+				result.ValuesNames = make(map[string]string)
+			}
+
+			result.ValuesNames[name] = transformed[i].GetName()
 		}
+	}
+
+	result.Objects = transformed
+	result.Duration = time.Since(start)
+
+	r.engine.logger().DebugContext(ctx, "rendered kustomize source",
+		slog.String("source", holder.Path),
+		slog.Int("objects", len(transformed)),
+		slog.Bool("cacheHit", result.CacheHit),
+		slog.Duration("duration", result.Duration),
+	)
+
+	return result, nil
+}
+
+// applyPipeline applies filters then transformers to objects like pipeline.Apply, and also
+// returns the index in objects of every object kept by the filters, so that data taken from
+// the objects beforehand can be matched with the output.
+func applyPipeline(
+	ctx context.Context,
+	objects []unstructured.Unstructured,
+	filters []types.Filter,
+	transformers []types.Transformer,
+) ([]unstructured.Unstructured, []int, error) {
+	filtered := make([]unstructured.Unstructured, 0, len(objects))
+	kept := make([]int, 0, len(objects))
 
-		// Apply renderer-level filters and transformers per-source for better error context
-		transformed, err := pipeline.Apply(ctx, objects, r.opts.Filters, r.opts.Transformers)
+	for i := range objects {
+		matched, err := pipeline.ApplyFilters(ctx, objects[i:i+1], filters)
 		if err != nil {
-			return nil, fmt.Errorf(
-				"error applying filters/transformers to path %s: %w",
-				holder.Path,
-				err,
-			)
+			return nil, nil, fmt.Errorf("filter error: %w", err)
 		}
 
-		allObjects = append(allObjects, transformed...)
+		if len(matched) > 0 {
+			filtered = append(filtered, matched[0])
+			kept = append(kept, i)
+		}
+	}
+
+	transformed, err := pipeline.ApplyTransformers(ctx, filtered, transformers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("transformer error: %w", err)
 	}
 
-	return allObjects, nil
+	return transformed, kept, nil
 }
 
-// renderSingle performs the rendering for a single kustomize path.
+// renderSingle performs the rendering for a single kustomize path, recording the warnings
+// and cache status in result.
 func (r *Renderer) renderSingle(
 	ctx context.Context,
 	holder *sourceHolder,
 	renderTimeValues map[string]any,
+	result *SourceResult,
 ) ([]unstructured.Unstructured, error) {
 	// Get values dynamically (includes render-time values)
-	values, err := computeValues(ctx, holder.Source, renderTimeValues)
+	values, err := computeValues(ctx, holder.Source, r.opts.EnvValuesPrefix, r.opts.ValuesLayers, renderTimeValues)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"failed to get values for path %q: %w",
@@ -143,31 +538,87 @@ func (r *Renderer) renderSingle(
 		)
 	}
 
-	spec := KustomizationSpec{
-		Path:   holder.Path,
-		Values: values,
-	}
-
-	// Check cache (if enabled)
+	var renderCache cache.Interface[cacheEntry]
 	if r.cache != nil {
-		// ensure objects are evicted
-		r.cache.Sync()
+		renderCache = r.cache.forTTL(holder.CacheTTL)
+	}
 
-		if cached, found := r.cache.Get(spec); found {
-			return cached, nil
+	// No filesystem writes needed - values passed to engine
+	if renderCache == nil {
+		entry, err := r.render(ctx, holder, values, result)
+		if err != nil {
+			return nil, err
 		}
+
+		return entry.objects, nil
 	}
 
-	// No filesystem writes needed - values passed to engine
-	result, err := r.engine.Run(holder.Source, values)
+	// Compute the key once so that lookup and store agree even if the key function
+	// inspects mutable state such as source files.
+	flatValues, structuredValues := splitValues(values)
+
+	key := r.cacheKey(r.cache.keyFunc, KustomizationSpec{
+		Path:             holder.Path,
+		Values:           flatValues,
+		StructuredValues: structuredValues,
+		OnlyFromPath:     holder.OnlyFromPath,
+		FileSystem:       r.engine.fileSystem(holder.Source),
+		Deterministic:    r.opts.Deterministic,
+	}, holder.fsIndex)
+
+	// ensure objects are evicted
+	renderCache.Sync()
+
+	result.cacheLookup = true
+
+	if cached, found := renderCache.Get(key); found {
+		r.observeCache(holder.Path, key, CacheEventHit)
+
+		cached = cached.clone()
+		result.CacheHit = true
+		result.Warnings = cached.warnings
+		result.AppliedComponents = cached.components
+
+		r.engine.recordWarnings(holder.Path, cached.warnings)
+
+		return cached.objects, nil
+	}
+
+	r.observeCache(holder.Path, key, CacheEventMiss)
+
+	entry, err := r.render(ctx, holder, values, result)
 	if err != nil {
-		return nil, fmt.Errorf("failed to run kustomize for path %q: %w", holder.Path, err)
+		r.observeCache(holder.Path, key, CacheEventRenderFailed)
+
+		return nil, err
 	}
 
-	// Cache result (if enabled)
-	if r.cache != nil {
-		r.cache.Set(spec, result)
+	renderCache.Set(key, entry.clone())
+	r.observeCache(holder.Path, key, CacheEventStore)
+
+	return entry.objects, nil
+}
+
+// render builds a source with the engine and lists the components it applied, recording the
+// warnings and components in result.
+func (r *Renderer) render(
+	ctx context.Context,
+	holder *sourceHolder,
+	values map[string]any,
+	result *SourceResult,
+) (cacheEntry, error) {
+	objects, warnings, err := r.engine.runDetailed(ctx, holder.Source, values)
+	if err != nil {
+		return cacheEntry{}, fmt.Errorf("failed to run kustomize for path %q: %w", holder.Path, err)
 	}
 
-	return result, nil
+	components, err := appliedComponents(r.engine.fileSystem(holder.Source), holder.Path)
+	if err != nil {
+		return cacheEntry{}, fmt.Errorf("failed to list components of path %q: %w", holder.Path, err)
+	}
+
+	result.Warnings = warnings
+	result.AppliedComponents = components
+
+	return cacheEntry{objects: objects, warnings: warnings, components: components}, nil
 }
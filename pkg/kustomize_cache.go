@@ -1,29 +1,491 @@
 package kustomize
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
 	"github.com/k8s-manifest-kit/pkg/util/cache"
+	utilk8s "github.com/k8s-manifest-kit/pkg/util/k8s"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/dump"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
 )
 
 // KustomizationSpec contains the data used to generate cache keys for rendered kustomizations.
 type KustomizationSpec struct {
 	Path   string
 	Values map[string]string
+
+	// StructuredValues holds the values of the render that are not strings (numbers, booleans,
+	// nested maps and lists), see Source.StructuredValues.
+	StructuredValues map[string]any
+
+	// OnlyFromPath is the origin filter of the source, see Source.OnlyFromPath.
+	OnlyFromPath string
+
+	// FileSystem is the filesystem the kustomization is read from. Key functions such as
+	// ContentCacheKey use it to inspect the source files; it is never hashed itself, and is
+	// left out of the spec handed to the KeyFunc of the cache options.
+	FileSystem filesys.FileSystem
+
+	// Deterministic is set for renderers created with WithDeterministic: ContentCacheKey
+	// then ignores the modification times of the source files.
+	Deterministic bool
+}
+
+// CacheKeyFunc computes the cache key for a kustomization render.
+type CacheKeyFunc func(spec KustomizationSpec) string
+
+// CacheEventType identifies the outcome of a render cache operation.
+type CacheEventType string
+
+const (
+	// CacheEventHit is emitted when a render is served from the cache.
+	CacheEventHit CacheEventType = "hit"
+
+	// CacheEventMiss is emitted when no usable entry exists and the source is rendered.
+	CacheEventMiss CacheEventType = "miss"
+
+	// CacheEventStore is emitted when a successful render is stored in the cache.
+	CacheEventStore CacheEventType = "store"
+
+	// CacheEventRenderFailed is emitted when the render following a miss fails,
+	// so that nothing is stored.
+	CacheEventRenderFailed CacheEventType = "render-failed"
+)
+
+// CacheEvent describes a single render cache operation.
+type CacheEvent struct {
+	// Path is the source path being rendered.
+	Path string
+
+	// Key is the cache key computed for the render.
+	Key string
+
+	// Type is the outcome of the operation.
+	Type CacheEventType
+}
+
+// CacheObserver receives render cache events.
+type CacheObserver func(event CacheEvent)
+
+// DefaultCacheKey returns the cache key for a KustomizationSpec.
+// Values are hashed (SHA-256) together with the path, so keys never carry
+// raw value material such as secrets.
+func DefaultCacheKey(spec KustomizationSpec) string {
+	sum := sha256.Sum256([]byte(specHashInput(spec)))
+
+	return spec.Path + "@" + hex.EncodeToString(sum[:])
+}
+
+// ContentCacheKey returns a CacheKeyFunc that, in addition to the path and values, hashes the
+// contents and modification times (unless spec.Deterministic is set) of the local files the
+// kustomization depends on: every file below the source directory, plus every file or
+// directory it references outside of it (e.g. "../../base"), followed recursively through
+// nested kustomizations. The key changes whenever any of those inputs change, which makes the
+// cache safe for "edit and re-render" loops.
+//
+// Remote references (git URLs, HTTP) cannot be walked cheaply; only the reference string is
+// hashed, so changes behind an unchanged (unpinned) URL are not detected. Pin remote bases to
+// a tag or commit when caching. If the source tree cannot be read at all, or the spec carries
+// no filesystem, the key falls back to DefaultCacheKey.
+func ContentCacheKey() CacheKeyFunc {
+	return func(spec KustomizationSpec) string {
+		if spec.FileSystem == nil {
+			return DefaultCacheKey(spec)
+		}
+
+		h := sha256.New()
+		h.Write([]byte(specHashInput(spec)))
+
+		hasher := &treeHasher{
+			fs:          spec.FileSystem,
+			h:           h,
+			walked:      make([]string, 0),
+			parsed:      make(map[string]bool),
+			visited:     make(map[string]bool),
+			skipModTime: spec.Deterministic,
+		}
+
+		if err := hasher.hashKustomization(spec.Path); err != nil {
+			return DefaultCacheKey(spec)
+		}
+
+		return spec.Path + "@" + hex.EncodeToString(h.Sum(nil))
+	}
+}
+
+// specHashInput returns the hashed representation of the path, values and origin filter of
+// a spec.
+func specHashInput(spec KustomizationSpec) string {
+	return dump.ForHash(struct {
+		Path             string
+		Values           map[string]string
+		StructuredValues map[string]any
+		OnlyFromPath     string
+	}{
+		Path:             spec.Path,
+		Values:           spec.Values,
+		StructuredValues: spec.StructuredValues,
+		OnlyFromPath:     spec.OnlyFromPath,
+	})
+}
+
+// splitValues splits the values of a render into the string values and the structured
+// values of a KustomizationSpec.
+func splitValues(values map[string]any) (map[string]string, map[string]any) {
+	var flat map[string]string
+	var structured map[string]any
+
+	for k, v := range values {
+		if s, ok := v.(string); ok {
+			if flat == nil {
+				flat = make(map[string]string)
+			}
+
+			flat[k] = s
+
+			continue
+		}
+
+		if structured == nil {
+			structured = make(map[string]any)
+		}
+
+		structured[k] = v
+	}
+
+	return flat, structured
+}
+
+// treeHasher feeds all local inputs of a kustomization tree into a hash.
+type treeHasher struct {
+	fs filesys.FileSystem
+	h  hash.Hash
+
+	// walked holds the directories whose files have all been hashed.
+	walked []string
+
+	// parsed holds the kustomization directories whose references have been followed.
+	parsed map[string]bool
+
+	// visited holds the individual files that have been hashed.
+	visited map[string]bool
+
+	// skipModTime leaves modification times out of the hash.
+	skipModTime bool
+}
+
+func (t *treeHasher) hashKustomization(dir string) error {
+	if t.parsed[dir] {
+		return nil
+	}
+
+	t.parsed[dir] = true
+
+	kust, _, err := readKustomization(t.fs, dir)
+	if err != nil {
+		return err
+	}
+
+	if err := t.hashDir(dir); err != nil {
+		return err
+	}
+
+	for _, ref := range collectReferences(kust) {
+		if isRemoteReference(ref.Value) {
+			t.write("remote", ref.Value)
+
+			continue
+		}
+
+		target := resolveReference(dir, ref.Value)
+
+		switch {
+		case !t.fs.Exists(target):
+			t.write("missing", target)
+		case !t.fs.IsDir(target):
+			if err := t.hashFile(target); err != nil {
+				return err
+			}
+		case ref.Kind == referenceFileOrDir && t.hasKustomization(target):
+			if err := t.hashKustomization(target); err != nil {
+				return err
+			}
+		default:
+			if err := t.hashDir(target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *treeHasher) hasKustomization(dir string) bool {
+	_, found := findKustomizationFile(t.fs, dir)
+
+	return found
+}
+
+// hashDir hashes all files below dir, unless dir has already been covered by a previous walk.
+func (t *treeHasher) hashDir(dir string) error {
+	for _, w := range t.walked {
+		if isWithinDir(w, dir) {
+			return nil
+		}
+	}
+
+	t.walked = append(t.walked, dir)
+
+	err := t.fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		return t.hashFile(path)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to walk %q: %w", dir, err)
+	}
+
+	return nil
+}
+
+func (t *treeHasher) hashFile(path string) error {
+	if t.visited[path] {
+		return nil
+	}
+
+	t.visited[path] = true
+
+	info, err := fs.Stat(t.fs, path)
+	if err != nil {
+		return fmt.Errorf("unable to stat %q: %w", path, err)
+	}
+
+	content, err := t.fs.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %w", path, err)
+	}
+
+	sum := sha256.Sum256(content)
+
+	modTime := info.ModTime().UnixNano()
+	if t.skipModTime {
+		modTime = 0
+	}
+
+	t.write("file", path, strconv.FormatInt(info.Size(), 10), strconv.FormatInt(modTime, 10))
+	t.h.Write(sum[:])
+
+	return nil
 }
 
-// newCache creates a cache instance with Kustomize-specific default KeyFunc.
-func newCache(opts *cache.Options) cache.Interface[[]unstructured.Unstructured] {
+// write feeds length-prefixed fields into the hash so that adjacent values can't collide.
+func (t *treeHasher) write(fields ...string) {
+	for _, f := range fields {
+		t.h.Write([]byte(strconv.Itoa(len(f)) + ":" + f))
+	}
+}
+
+// cacheEntry is a cached render: the objects of a source together with the warnings of its
+// build and the components it applied, so that cache hits need no access to the source.
+type cacheEntry struct {
+	objects    []unstructured.Unstructured
+	warnings   []Warning
+	components []string
+}
+
+// clone deep copies the entry, so that neither the cache nor its callers see each other's
+// changes.
+func (e cacheEntry) clone() cacheEntry {
+	return cacheEntry{
+		objects:    utilk8s.DeepCloneUnstructuredSlice(e.objects),
+		warnings:   slices.Clone(e.warnings),
+		components: slices.Clone(e.components),
+	}
+}
+
+// renderCache holds the renderer-wide cache, plus lazily created caches for sources
+// overriding the TTL, since TTLs are fixed per cache instance.
+type renderCache struct {
+	opts    cache.Options
+	keyFunc CacheKeyFunc
+	global  cache.Interface[cacheEntry]
+
+	mu    sync.Mutex
+	byTTL map[time.Duration]cache.Interface[cacheEntry]
+}
+
+// newCache creates the render cache.
+//
+// Keys are always computed by the renderer and stored as-is. The key function is, in order
+// of precedence: the configured CacheKeyFunc, the KeyFunc of the cache options (applied to
+// the KustomizationSpec), or DefaultCacheKey.
+func newCache(opts *cache.Options, keyFunc CacheKeyFunc) *renderCache {
 	if opts == nil {
 		return nil
 	}
 
 	co := *opts
+	co.KeyFunc = cache.DefaultKeyFunc
+
+	return &renderCache{
+		opts:    co,
+		keyFunc: resolveCacheKeyFunc(opts, keyFunc),
+		global:  cache.New[cacheEntry](co),
+		byTTL:   make(map[time.Duration]cache.Interface[cacheEntry]),
+	}
+}
+
+// resolveCacheKeyFunc returns the key function of a renderer: keyFunc if set, the KeyFunc
+// of the cache options applied to the KustomizationSpec, or DefaultCacheKey.
+//
+// Generic key functions such as cache.DefaultKeyFunc dump the whole key, so the spec they
+// get carries no filesystem: its files would end up in the key and, for stateful
+// filesystems, change it between renders.
+func resolveCacheKeyFunc(opts *cache.Options, keyFunc CacheKeyFunc) CacheKeyFunc {
+	switch {
+	case keyFunc != nil:
+		return keyFunc
+	case opts != nil && opts.KeyFunc != nil:
+		custom := opts.KeyFunc
 
-	// Inject default KeyFunc for Kustomize
-	if co.KeyFunc == nil {
-		co.KeyFunc = cache.DefaultKeyFunc
+		return func(spec KustomizationSpec) string {
+			spec.FileSystem = nil
+
+			return custom(spec)
+		}
+	default:
+		return DefaultCacheKey
 	}
+}
+
+// ComputeCacheKey returns the key the renderer's cache would store the render of spec under,
+// using the configured key function (see WithCacheKeyFunc), so that cache pre-populators and
+// debugging tools can predict keys without rendering. Values and StructuredValues must be
+// those of the render, the source values merged with the render-time values: string values
+// in Values, all others in StructuredValues. If spec.FileSystem is nil, the filesystem of the
+// source at spec.Path is used: its own (Source.FileSystem) if set, the renderer-wide one
+// otherwise. Filesystems of non-comparable types are identified by the source at spec.Path
+// holding a filesystem of the same type. The key is computed even when caching is disabled.
+func (r *Renderer) ComputeCacheKey(spec KustomizationSpec) string {
+	sourceFs := spec.FileSystem
+	fsIndex := 0
+
+	if sourceFs != nil {
+		fsIndex = r.fileSystemIndex(sourceFs)
+	}
+
+	for _, holder := range r.inputs {
+		if holder.Path != spec.Path || holder.FileSystem == nil {
+			continue
+		}
+
+		if sourceFs == nil {
+			sourceFs = holder.FileSystem
+			fsIndex = holder.fsIndex
+
+			break
+		}
+
+		if fsIndex == 0 && !reflect.TypeOf(sourceFs).Comparable() &&
+			reflect.TypeOf(sourceFs) == reflect.TypeOf(holder.FileSystem) {
+			fsIndex = holder.fsIndex
+
+			break
+		}
+	}
+
+	spec.FileSystem = r.engine.fileSystem(Source{FileSystem: sourceFs})
+	spec.Deterministic = spec.Deterministic || r.opts.Deterministic
+
+	keyFunc := resolveCacheKeyFunc(r.opts.CacheOptions, r.opts.CacheKeyFunc)
+	if r.cache != nil {
+		keyFunc = r.cache.keyFunc
+	}
+
+	return r.cacheKey(keyFunc, spec, fsIndex)
+}
+
+// cacheKey computes the key of spec with keyFunc. Key functions need not hash the
+// filesystem, so keys of sources read from their own filesystem (a non-zero fsIndex, see
+// sourceHolder) are suffixed with its identity: sources at the same path on different
+// filesystems never share cache entries.
+func (r *Renderer) cacheKey(keyFunc CacheKeyFunc, spec KustomizationSpec, fsIndex int) string {
+	key := keyFunc(spec)
+
+	if fsIndex == 0 {
+		return key
+	}
+
+	return key + "#fs" + strconv.Itoa(fsIndex)
+}
+
+// fileSystemIndex returns the 1-based position of fsys in the filesystems of the sources, or
+// 0 if it is not one of them.
+func (r *Renderer) fileSystemIndex(fsys filesys.FileSystem) int {
+	for i, known := range r.fileSystems {
+		if sameFileSystem(known, fsys) {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// sameFileSystem reports whether a and b are the same filesystem. Filesystems of
+// non-comparable types are never considered the same.
+func sameFileSystem(a filesys.FileSystem, b filesys.FileSystem) bool {
+	t := reflect.TypeOf(a)
+
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
+// forTTL returns the cache to use for a source with the given TTL override:
+// the renderer-wide cache for zero, a dedicated cache for positive values,
+// and nil (no caching) for negative values.
+func (c *renderCache) forTTL(ttl time.Duration) cache.Interface[cacheEntry] {
+	switch {
+	case ttl == 0:
+		return c.global
+	case ttl < 0:
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if rc, ok := c.byTTL[ttl]; ok {
+		return rc
+	}
+
+	co := c.opts
+	co.TTL = ttl
+
+	rc := cache.New[cacheEntry](co)
+	c.byTTL[ttl] = rc
 
-	return cache.NewRenderCache(co)
+	return rc
 }
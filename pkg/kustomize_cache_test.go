@@ -0,0 +1,343 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDefaultCacheKey(t *testing.T) {
+
+	t.Run("should not expose raw values", func(t *testing.T) {
+		g := NewWithT(t)
+
+		key := kustomize.DefaultCacheKey(kustomize.KustomizationSpec{
+			Path:   "/app",
+			Values: map[string]string{"password": "s3cr3t"},
+		})
+
+		g.Expect(key).To(HavePrefix("/app@"))
+		g.Expect(key).ToNot(ContainSubstring("s3cr3t"))
+	})
+
+	t.Run("should change when values change", func(t *testing.T) {
+		g := NewWithT(t)
+
+		k1 := kustomize.DefaultCacheKey(kustomize.KustomizationSpec{
+			Path:   "/app",
+			Values: map[string]string{"key": "a"},
+		})
+		k2 := kustomize.DefaultCacheKey(kustomize.KustomizationSpec{
+			Path:   "/app",
+			Values: map[string]string{"key": "b"},
+		})
+
+		g.Expect(k1).ToNot(Equal(k2))
+	})
+
+	t.Run("should be stable for identical specs", func(t *testing.T) {
+		g := NewWithT(t)
+
+		spec := kustomize.KustomizationSpec{
+			Path:   "/app",
+			Values: map[string]string{"a": "1", "b": "2", "c": "3"},
+		}
+
+		g.Expect(kustomize.DefaultCacheKey(spec)).To(Equal(kustomize.DefaultCacheKey(spec)))
+	})
+}
+
+func TestContentCacheKey(t *testing.T) {
+	newTree := func(g *WithT) filesys.FileSystem {
+		memFs := fs.NewMemoryFs()
+
+		for path, content := range map[string]string{
+			"/base/kustomization.yaml":           "resources:\n- deployment.yaml\n",
+			"/base/deployment.yaml":              "kind: Deployment\n",
+			"/overlay/kustomization.yaml":        "resources:\n- ../base\npatches:\n- path: ../patches/replicas.yaml\n",
+			"/overlay/notes.txt":                 "unreferenced but inside the source directory\n",
+			"/patches/replicas.yaml":             "kind: Deployment\n",
+			"/unrelated/kustomization.yaml":      "resources: []\n",
+			"/overlay/remote/kustomization.yaml": "resources:\n- https://github.com/org/repo//base?ref=v1\n",
+		} {
+			g.Expect(memFs.WriteFile(path, []byte(content))).To(Succeed())
+		}
+
+		return memFs
+	}
+
+	keyFn := kustomize.ContentCacheKey()
+
+	t.Run("should be stable for unchanged sources", func(t *testing.T) {
+		g := NewWithT(t)
+
+		memFs := newTree(g)
+		spec := kustomize.KustomizationSpec{Path: "/overlay", FileSystem: memFs}
+
+		g.Expect(keyFn(spec)).To(HavePrefix("/overlay@"))
+		g.Expect(keyFn(spec)).To(Equal(keyFn(spec)))
+	})
+
+	t.Run("should change when any input file changes", func(t *testing.T) {
+		for _, path := range []string{
+			"/overlay/kustomization.yaml",
+			"/overlay/notes.txt",
+			"/base/deployment.yaml",
+			"/patches/replicas.yaml",
+		} {
+			t.Run(path, func(t *testing.T) {
+				g := NewWithT(t)
+
+				memFs := newTree(g)
+				spec := kustomize.KustomizationSpec{Path: "/overlay", FileSystem: memFs}
+				before := keyFn(spec)
+
+				content, err := memFs.ReadFile(path)
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(memFs.WriteFile(path, append(content, []byte("# edited\n")...))).To(Succeed())
+
+				g.Expect(keyFn(spec)).ToNot(Equal(before))
+			})
+		}
+	})
+
+	t.Run("should ignore files outside the kustomization tree", func(t *testing.T) {
+		g := NewWithT(t)
+
+		memFs := newTree(g)
+		spec := kustomize.KustomizationSpec{Path: "/overlay", FileSystem: memFs}
+		before := keyFn(spec)
+
+		g.Expect(memFs.WriteFile("/unrelated/kustomization.yaml", []byte("resources:\n- x.yaml\n"))).To(Succeed())
+
+		g.Expect(keyFn(spec)).To(Equal(before))
+	})
+
+	t.Run("should change when values change", func(t *testing.T) {
+		g := NewWithT(t)
+
+		memFs := newTree(g)
+
+		k1 := keyFn(kustomize.KustomizationSpec{Path: "/overlay", FileSystem: memFs, Values: map[string]string{"a": "1"}})
+		k2 := keyFn(kustomize.KustomizationSpec{Path: "/overlay", FileSystem: memFs, Values: map[string]string{"a": "2"}})
+
+		g.Expect(k1).ToNot(Equal(k2))
+	})
+
+	t.Run("should fall back to DefaultCacheKey when the tree cannot be read", func(t *testing.T) {
+		g := NewWithT(t)
+
+		spec := kustomize.KustomizationSpec{Path: "/missing", FileSystem: fs.NewMemoryFs()}
+		g.Expect(keyFn(spec)).To(Equal(kustomize.DefaultCacheKey(spec)))
+
+		spec = kustomize.KustomizationSpec{Path: "/overlay"}
+		g.Expect(keyFn(spec)).To(Equal(kustomize.DefaultCacheKey(spec)))
+	})
+}
+
+func TestKustomizationParsing(t *testing.T) {
+
+	t.Run("should pick up kustomization changes between renders", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetName()).To(Equal("test-configmap"))
+
+		writeFile(t, dir, "kustomization.yaml", "namePrefix: changed-\nresources:\n- configmap.yaml\n")
+
+		objects, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("changed-configmap"))
+	})
+
+	t.Run("should not share kustomization modifications between renders", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		prefixed, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithNamePrefix("prod-"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := prefixed.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetName()).To(Equal("prod-test-configmap"))
+
+		plain, err := kustomize.New([]kustomize.Source{{Path: dir}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err = plain.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetName()).To(Equal("test-configmap"))
+	})
+}
+
+func TestComputeCacheKey(t *testing.T) {
+
+	t.Run("should predict the key of a render", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
+
+		var keys []string
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{
+				Path:   dir,
+				Values: kustomize.Values(map[string]string{"key": "value"}),
+			}},
+			kustomize.WithCache(),
+			kustomize.WithCacheKeyFunc(kustomize.ContentCacheKey()),
+			kustomize.WithCacheObserver(func(e kustomize.CacheEvent) {
+				keys = append(keys, e.Key)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		key := renderer.ComputeCacheKey(kustomize.KustomizationSpec{
+			Path:   dir,
+			Values: map[string]string{"key": "value", "request": "r1"},
+		})
+
+		_, err = renderer.Process(t.Context(), map[string]any{"request": "r1"})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(keys).ToNot(BeEmpty())
+		g.Expect(keys[0]).To(Equal(key))
+	})
+
+	t.Run("should pass string and structured values apart to key functions", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
+
+		var specs []kustomize.KustomizationSpec
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{
+				Path:             dir,
+				Values:           kustomize.Values(map[string]string{"key": "value"}),
+				StructuredValues: kustomize.StructuredValues(map[string]any{"replicas": 3}),
+			}},
+			kustomize.WithCache(),
+			kustomize.WithCacheKeyFunc(func(spec kustomize.KustomizationSpec) string {
+				specs = append(specs, spec)
+
+				return kustomize.DefaultCacheKey(spec)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(specs).To(HaveLen(1))
+		g.Expect(specs[0].Values).To(Equal(map[string]string{"key": "value"}))
+		g.Expect(specs[0].StructuredValues).To(Equal(map[string]any{"replicas": 3}))
+	})
+
+	t.Run("should apply the configured key function without cache", func(t *testing.T) {
+		g := NewWithT(t)
+		spec := kustomize.KustomizationSpec{Path: "/app", Values: map[string]string{"key": "value"}}
+
+		renderer, err := kustomize.New(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(renderer.ComputeCacheKey(spec)).To(Equal(kustomize.DefaultCacheKey(spec)))
+
+		renderer, err = kustomize.New(nil, kustomize.WithCacheKeyFunc(func(spec kustomize.KustomizationSpec) string {
+			return "custom:" + spec.Path
+		}))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(renderer.ComputeCacheKey(spec)).To(Equal("custom:/app"))
+	})
+
+	t.Run("should not share entries between sources on different filesystems", func(t *testing.T) {
+		g := NewWithT(t)
+
+		newFs := func(data string) filesys.FileSystem {
+			memFs := fs.NewMemoryFs()
+			g.Expect(memFs.WriteFile("/app/kustomization.yaml", []byte("resources:\n- configmap.yaml\n"))).To(Succeed())
+			g.Expect(memFs.WriteFile("/app/configmap.yaml", []byte(
+				"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  key: "+data+"\n",
+			))).To(Succeed())
+
+			return memFs
+		}
+
+		fsA := newFs("a")
+		fsB := newFs("b")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{Path: "/app", FileSystem: fsA},
+				{Path: "/app", FileSystem: fsB},
+			},
+			kustomize.WithCache(),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		for range 2 {
+			objects, err := renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(objects).To(HaveLen(2))
+			g.Expect(objects[0].Object).To(HaveKeyWithValue("data", map[string]any{"key": "a"}))
+			g.Expect(objects[1].Object).To(HaveKeyWithValue("data", map[string]any{"key": "b"}))
+		}
+
+		keyA := renderer.ComputeCacheKey(kustomize.KustomizationSpec{Path: "/app", FileSystem: fsA})
+		keyB := renderer.ComputeCacheKey(kustomize.KustomizationSpec{Path: "/app", FileSystem: fsB})
+		g.Expect(keyA).ToNot(Equal(keyB))
+		g.Expect(renderer.ComputeCacheKey(kustomize.KustomizationSpec{Path: "/app"})).To(Equal(keyA))
+	})
+
+	t.Run("should not share entries with a filesystem of a non-comparable type", func(t *testing.T) {
+		g := NewWithT(t)
+
+		newFs := func(data string) filesys.FileSystem {
+			memFs := fs.NewMemoryFs()
+			g.Expect(memFs.WriteFile("/app/kustomization.yaml", []byte("resources:\n- configmap.yaml\n"))).To(Succeed())
+			g.Expect(memFs.WriteFile("/app/configmap.yaml", []byte(
+				"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  key: "+data+"\n",
+			))).To(Succeed())
+
+			return memFs
+		}
+
+		sourceFs := uncomparableFs{FileSystem: newFs("b")}
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{Path: "/app"},
+				{Path: "/app", FileSystem: sourceFs},
+			},
+			kustomize.WithFileSystem(newFs("a")),
+			kustomize.WithCache(),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		for range 2 {
+			objects, err := renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(objects).To(HaveLen(2))
+			g.Expect(objects[0].Object).To(HaveKeyWithValue("data", map[string]any{"key": "a"}))
+			g.Expect(objects[1].Object).To(HaveKeyWithValue("data", map[string]any{"key": "b"}))
+		}
+
+		key := renderer.ComputeCacheKey(kustomize.KustomizationSpec{Path: "/app", FileSystem: sourceFs})
+		g.Expect(key).To(HaveSuffix("#fs1"))
+	})
+}
+
+// uncomparableFs is a filesystem of a non-comparable type.
+type uncomparableFs struct {
+	filesys.FileSystem
+
+	_ []string
+}
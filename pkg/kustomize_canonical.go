@@ -0,0 +1,163 @@
+package kustomize
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// defaultCanonicalListKeys maps the fields holding lists whose order is irrelevant to the
+// key their elements are sorted by.
+//
+//nolint:gochecknoglobals
+var defaultCanonicalListKeys = map[string]string{
+	"volumes":          "name",
+	"volumeMounts":     "mountPath",
+	"imagePullSecrets": "name",
+}
+
+// CanonicalOption configures Canonicalize.
+type CanonicalOption func(cfg *canonicalConfig)
+
+type canonicalConfig struct {
+	keepEmpty     bool
+	keepListOrder bool
+	listKeys      map[string]string
+}
+
+// CanonicalKeepEmpty keeps null fields and empty maps, for golden files where their
+// presence is significant.
+func CanonicalKeepEmpty() CanonicalOption {
+	return func(cfg *canonicalConfig) {
+		cfg.keepEmpty = true
+	}
+}
+
+// CanonicalKeepListOrder keeps every list in its rendered order, including those added
+// with CanonicalSortList.
+func CanonicalKeepListOrder() CanonicalOption {
+	return func(cfg *canonicalConfig) {
+		cfg.keepListOrder = true
+	}
+}
+
+// CanonicalSortList sorts the lists held by field, wherever it appears in an object, by the
+// string value of key in their elements, e.g. CanonicalSortList("env", "name") to sort
+// environment variables by name. Env vars are not sorted by default, since $(VAR)
+// references depend on their order. Lists with an element lacking key are left as is.
+func CanonicalSortList(field string, key string) CanonicalOption {
+	return func(cfg *canonicalConfig) {
+		cfg.listKeys[field] = key
+	}
+}
+
+// Canonicalize returns a canonical form of objects for golden-file comparisons that stay
+// stable across kustomize versions. It is a pure function: objects are deep copied and left
+// untouched. By default:
+//   - null fields are dropped, as are empty maps at the top level ("status: {}") and in
+//     metadata ("labels: {}", "annotations: {}"); other empty maps are kept, since some are
+//     significant ("emptyDir: {}", "namespaceSelector: {}"). Disable with CanonicalKeepEmpty.
+//   - volumes and imagePullSecrets are sorted by name and volumeMounts by mountPath, whose
+//     order has no meaning. Add lists with CanonicalSortList, disable with
+//     CanonicalKeepListOrder.
+//
+// Keys need no normalization: unstructured objects hold maps, which the JSON and YAML
+// encoders always write in sorted key order.
+func Canonicalize(objects []unstructured.Unstructured, opts ...CanonicalOption) []unstructured.Unstructured {
+	cfg := &canonicalConfig{
+		listKeys: maps.Clone(defaultCanonicalListKeys),
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	result := make([]unstructured.Unstructured, len(objects))
+
+	for i := range objects {
+		obj := objects[i].DeepCopy()
+		cfg.normalizeMap(obj.Object)
+
+		if !cfg.keepEmpty {
+			pruneEmptyMaps(obj.Object)
+
+			if metadata, ok := obj.Object["metadata"].(map[string]any); ok {
+				pruneEmptyMaps(metadata)
+			}
+		}
+
+		result[i] = *obj
+	}
+
+	return result
+}
+
+// normalizeMap drops the null fields of m, unless they are kept, and normalizes the values
+// of the others in place.
+func (cfg *canonicalConfig) normalizeMap(m map[string]any) {
+	for key, value := range m {
+		if value == nil && !cfg.keepEmpty {
+			delete(m, key)
+
+			continue
+		}
+
+		m[key] = cfg.normalize(key, value)
+	}
+}
+
+// normalize returns the canonical form of the value held by field.
+func (cfg *canonicalConfig) normalize(field string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		cfg.normalizeMap(v)
+	case []any:
+		for i, elem := range v {
+			v[i] = cfg.normalize("", elem)
+		}
+
+		if key, found := cfg.listKeys[field]; found && !cfg.keepListOrder {
+			sortByKey(v, key)
+		}
+	}
+
+	return value
+}
+
+// sortByKey sorts list by the string value of key in its elements, if they all have one.
+func sortByKey(list []any, key string) {
+	keyOf := func(elem any) (string, bool) {
+		m, ok := elem.(map[string]any)
+		if !ok {
+			return "", false
+		}
+
+		value, ok := m[key].(string)
+
+		return value, ok
+	}
+
+	for _, elem := range list {
+		if _, ok := keyOf(elem); !ok {
+			return
+		}
+	}
+
+	slices.SortStableFunc(list, func(a any, b any) int {
+		keyA, _ := keyOf(a)
+		keyB, _ := keyOf(b)
+
+		return cmp.Compare(keyA, keyB)
+	})
+}
+
+// pruneEmptyMaps drops the fields of m holding empty maps.
+func pruneEmptyMaps(m map[string]any) {
+	for key, value := range m {
+		if v, ok := value.(map[string]any); ok && len(v) == 0 {
+			delete(m, key)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+const canonicalDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  creationTimestamp: null
+  annotations: {}
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        env:
+        - name: B
+          value: b
+        - name: A
+          value: a
+        volumeMounts:
+        - name: data
+          mountPath: /data
+        - name: cache
+          mountPath: /cache
+      volumes:
+      - name: data
+        emptyDir: {}
+      - name: cache
+        emptyDir: {}
+status: {}
+`
+
+func decodeObject(t *testing.T, content string) unstructured.Unstructured {
+	t.Helper()
+
+	obj := unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(content), &obj.Object); err != nil {
+		t.Fatal(err)
+	}
+
+	return obj
+}
+
+func TestCanonicalize(t *testing.T) {
+	t.Run("should drop null fields and empty metadata maps", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := decodeObject(t, canonicalDeployment)
+
+		result := kustomize.Canonicalize([]unstructured.Unstructured{obj})
+		g.Expect(result).To(HaveLen(1))
+		g.Expect(result[0].Object).ToNot(HaveKey("status"))
+		g.Expect(result[0].Object["metadata"]).To(Equal(map[string]any{"name": "app"}))
+
+		// significant empty maps are kept
+		volumes, _, _ := unstructured.NestedSlice(result[0].Object, "spec", "template", "spec", "volumes")
+		g.Expect(volumes).To(ContainElement(HaveKeyWithValue("emptyDir", map[string]any{})))
+	})
+
+	t.Run("should sort order-insensitive lists", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := decodeObject(t, canonicalDeployment)
+
+		result := kustomize.Canonicalize([]unstructured.Unstructured{obj})
+
+		volumes, _, _ := unstructured.NestedSlice(result[0].Object, "spec", "template", "spec", "volumes")
+		g.Expect(volumes).To(HaveExactElements(
+			HaveKeyWithValue("name", "cache"),
+			HaveKeyWithValue("name", "data"),
+		))
+
+		containers, _, _ := unstructured.NestedSlice(result[0].Object, "spec", "template", "spec", "containers")
+		container, _ := containers[0].(map[string]any)
+		g.Expect(container["volumeMounts"]).To(HaveExactElements(
+			HaveKeyWithValue("mountPath", "/cache"),
+			HaveKeyWithValue("mountPath", "/data"),
+		))
+
+		// env order matters for $(VAR) references and is kept by default
+		g.Expect(container["env"]).To(HaveExactElements(
+			HaveKeyWithValue("name", "B"),
+			HaveKeyWithValue("name", "A"),
+		))
+	})
+
+	t.Run("should sort additional lists on request", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := decodeObject(t, canonicalDeployment)
+
+		result := kustomize.Canonicalize([]unstructured.Unstructured{obj}, kustomize.CanonicalSortList("env", "name"))
+
+		containers, _, _ := unstructured.NestedSlice(result[0].Object, "spec", "template", "spec", "containers")
+		container, _ := containers[0].(map[string]any)
+		g.Expect(container["env"]).To(HaveExactElements(
+			HaveKeyWithValue("name", "A"),
+			HaveKeyWithValue("name", "B"),
+		))
+	})
+
+	t.Run("should let normalizations be disabled", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := decodeObject(t, canonicalDeployment)
+
+		result := kustomize.Canonicalize(
+			[]unstructured.Unstructured{obj},
+			kustomize.CanonicalKeepEmpty(),
+			kustomize.CanonicalKeepListOrder(),
+		)
+		g.Expect(result[0].Object).To(Equal(obj.Object))
+	})
+
+	t.Run("should make equivalent renders compare equal", func(t *testing.T) {
+		g := NewWithT(t)
+		a := decodeObject(t, canonicalDeployment)
+		b := decodeObject(t, canonicalDeployment)
+
+		// b as another kustomize version might print it: without the noise, volumes reordered
+		unstructured.RemoveNestedField(b.Object, "status")
+		unstructured.RemoveNestedField(b.Object, "metadata", "creationTimestamp")
+		volumes, _, _ := unstructured.NestedSlice(b.Object, "spec", "template", "spec", "volumes")
+		g.Expect(unstructured.SetNestedSlice(b.Object, []any{volumes[1], volumes[0]}, "spec", "template", "spec", "volumes")).
+			To(Succeed())
+
+		g.Expect(a.Object).ToNot(Equal(b.Object))
+
+		canonicalA, err := yaml.Marshal(kustomize.Canonicalize([]unstructured.Unstructured{a})[0].Object)
+		g.Expect(err).ToNot(HaveOccurred())
+		canonicalB, err := yaml.Marshal(kustomize.Canonicalize([]unstructured.Unstructured{b})[0].Object)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(canonicalA)).To(Equal(string(canonicalB)))
+	})
+
+	t.Run("should leave the input untouched", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := decodeObject(t, canonicalDeployment)
+		original := obj.DeepCopy()
+
+		kustomize.Canonicalize([]unstructured.Unstructured{obj}, kustomize.CanonicalSortList("env", "name"))
+		g.Expect(obj.Object).To(Equal(original.Object))
+	})
+}
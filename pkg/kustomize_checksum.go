@@ -0,0 +1,65 @@
+package kustomize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/dump"
+)
+
+// checksumWorkloadKinds are the kinds of the apps group whose pod template receives the
+// values checksum annotation.
+//
+//nolint:gochecknoglobals
+var checksumWorkloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+}
+
+// valuesChecksum returns a stable hash of values: equal maps hash equally whatever their
+// iteration order.
+func valuesChecksum(values map[string]any) string {
+	sum := sha256.Sum256([]byte(dump.ForHash(values)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// addValuesChecksum sets the configured checksum annotation to the hash of values on the pod
+// template of every Deployment, StatefulSet and DaemonSet of objects, so that workloads roll
+// out when the values change. Nothing is done without annotation key or values.
+func (e *Engine) addValuesChecksum(objects []unstructured.Unstructured, values map[string]any) error {
+	key := e.opts.ValuesChecksumAnnotation
+	if key == "" || len(values) == 0 {
+		return nil
+	}
+
+	checksum := valuesChecksum(values)
+
+	for i := range objects {
+		gvk := objects[i].GroupVersionKind()
+		if gvk.Group != "apps" || !checksumWorkloadKinds[gvk.Kind] {
+			continue
+		}
+
+		annotations, _, err := unstructured.NestedStringMap(objects[i].Object, "spec", "template", "metadata", "annotations")
+		if err != nil {
+			return fmt.Errorf("invalid pod template annotations in %s %q: %w", gvk.Kind, objects[i].GetName(), err)
+		}
+
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		annotations[key] = checksum
+
+		err = unstructured.SetNestedStringMap(objects[i].Object, annotations, "spec", "template", "metadata", "annotations")
+		if err != nil {
+			return fmt.Errorf("unable to annotate pod template of %s %q: %w", gvk.Kind, objects[i].GetName(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package kustomize
+
+import (
+	"context"
+
+	"github.com/k8s-manifest-kit/engine/pkg/types"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// CleanupOptions selects the fields removed by CleanupTransformer. The zero value removes
+// status, null creation timestamps and empty labels and annotations, and keeps managedFields.
+type CleanupOptions struct {
+	// KeepStatus keeps the status field of objects.
+	KeepStatus bool
+
+	// KeepCreationTimestamp keeps null metadata.creationTimestamp fields.
+	KeepCreationTimestamp bool
+
+	// KeepEmptyMetadata keeps empty metadata.labels and metadata.annotations maps.
+	KeepEmptyMetadata bool
+
+	// RemoveManagedFields removes metadata.managedFields, found in objects exported from
+	// a cluster.
+	RemoveManagedFields bool
+}
+
+// CleanupTransformer returns a transformer that removes the fields polluting GitOps diffs of
+// rendered manifests: the status of CRDs and other exported objects, the
+// "creationTimestamp: null" written by generators, empty labels and annotations and,
+// optionally, managedFields. Creation timestamps are also removed from the pod (or job)
+// templates of workloads, where generated Deployments commonly carry them. Set timestamps
+// are always kept.
+//
+// Example:
+//
+//	kustomize.New(sources, kustomize.WithTransformer(
+//	    kustomize.CleanupTransformer(kustomize.CleanupOptions{RemoveManagedFields: true}),
+//	))
+func CleanupTransformer(opts CleanupOptions) types.Transformer {
+	return func(_ context.Context, obj unstructured.Unstructured) (unstructured.Unstructured, error) {
+		if !opts.KeepStatus {
+			delete(obj.Object, "status")
+		}
+
+		if !opts.KeepCreationTimestamp {
+			removeNullCreationTimestamp(obj.Object, "metadata")
+
+			for _, path := range templateMetadataPaths[obj.GroupVersionKind().GroupKind()] {
+				removeNullCreationTimestamp(obj.Object, path...)
+			}
+		}
+
+		metadata, ok := obj.Object["metadata"].(map[string]any)
+		if !ok {
+			return obj, nil
+		}
+
+		if !opts.KeepEmptyMetadata {
+			for _, field := range []string{"labels", "annotations"} {
+				if value, found := metadata[field]; found && isEmptyMetadataField(value) {
+					delete(metadata, field)
+				}
+			}
+		}
+
+		if opts.RemoveManagedFields {
+			delete(metadata, "managedFields")
+		}
+
+		return obj, nil
+	}
+}
+
+// removeNullCreationTimestamp removes the creationTimestamp field of the metadata map at
+// path if it is null.
+func removeNullCreationTimestamp(obj map[string]any, path ...string) {
+	metadata, found, err := unstructured.NestedMap(obj, path...)
+	if err != nil || !found {
+		return
+	}
+
+	if value, found := metadata["creationTimestamp"]; found && value == nil {
+		unstructured.RemoveNestedField(obj, append(path, "creationTimestamp")...)
+	}
+}
+
+// isEmptyMetadataField reports whether a labels or annotations value holds no entry.
+func isEmptyMetadataField(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
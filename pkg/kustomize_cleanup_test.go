@@ -0,0 +1,103 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func noisyObject() unstructured.Unstructured {
+	obj := makeObject("apps/v1", "Deployment", "app")
+	obj.Object["status"] = map[string]any{"replicas": int64(1)}
+	obj.Object["metadata"].(map[string]any)["creationTimestamp"] = nil
+	obj.Object["metadata"].(map[string]any)["labels"] = map[string]any{}
+	obj.Object["metadata"].(map[string]any)["annotations"] = nil
+	obj.Object["metadata"].(map[string]any)["managedFields"] = []any{map[string]any{"manager": "kubectl"}}
+	_ = unstructured.SetNestedField(obj.Object, nil, "spec", "template", "metadata", "creationTimestamp")
+
+	return obj
+}
+
+func TestCleanupTransformer(t *testing.T) {
+
+	t.Run("should remove noise by default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		result, err := kustomize.CleanupTransformer(kustomize.CleanupOptions{})(t.Context(), noisyObject())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(result.Object).ToNot(HaveKey("status"))
+		g.Expect(result.Object["metadata"]).To(Equal(map[string]any{
+			"name":          "app",
+			"managedFields": []any{map[string]any{"manager": "kubectl"}},
+		}))
+
+		template, _, err := unstructured.NestedMap(result.Object, "spec", "template", "metadata")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(template).To(BeEmpty())
+	})
+
+	t.Run("should keep the selected fields", func(t *testing.T) {
+		g := NewWithT(t)
+
+		result, err := kustomize.CleanupTransformer(kustomize.CleanupOptions{
+			KeepStatus:            true,
+			KeepCreationTimestamp: true,
+			KeepEmptyMetadata:     true,
+			RemoveManagedFields:   true,
+		})(t.Context(), noisyObject())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(result.Object).To(HaveKey("status"))
+		g.Expect(result.Object["metadata"]).To(HaveKey("creationTimestamp"))
+		g.Expect(result.Object["metadata"]).To(HaveKey("labels"))
+		g.Expect(result.Object["metadata"]).To(HaveKey("annotations"))
+		g.Expect(result.Object["metadata"]).ToNot(HaveKey("managedFields"))
+	})
+
+	t.Run("should keep set timestamps and non-empty metadata", func(t *testing.T) {
+		g := NewWithT(t)
+
+		obj := makeObject("v1", "ConfigMap", "cm")
+		obj.SetLabels(map[string]string{"app": "cm"})
+		obj.Object["metadata"].(map[string]any)["creationTimestamp"] = "2024-01-01T00:00:00Z"
+
+		result, err := kustomize.CleanupTransformer(kustomize.CleanupOptions{})(t.Context(), obj)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.GetLabels()).To(Equal(map[string]string{"app": "cm"}))
+		g.Expect(result.Object["metadata"]).To(HaveKeyWithValue("creationTimestamp", "2024-01-01T00:00:00Z"))
+	})
+
+	t.Run("should clean rendered objects", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- crd.yaml\n")
+		writeFile(t, dir, "crd.yaml", `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+  creationTimestamp: null
+  labels: {}
+spec:
+  size: 1
+status:
+  ready: true
+`)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithTransformer(kustomize.CleanupTransformer(kustomize.CleanupOptions{})),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object).ToNot(HaveKey("status"))
+		g.Expect(objects[0].Object["metadata"]).To(Equal(map[string]any{"name": "widget"}))
+	})
+}
@@ -0,0 +1,349 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	goyaml "gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/krusty"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/union"
+)
+
+var (
+	// ErrInvalidCompatibilityMode is returned by New when the kustomize version set with
+	// WithCompatibilityMode cannot be parsed or is not supported.
+	ErrInvalidCompatibilityMode = errors.New("invalid compatibility mode")
+
+	// ErrIncompatibleKustomization is returned when a kustomization uses a field unknown to
+	// the kustomize release set with WithCompatibilityMode, which that release rejects.
+	ErrIncompatibleKustomization = errors.New("kustomization not supported by the compatibility mode")
+)
+
+const (
+	// minCompatibilityMajor and maxCompatibilityMajor bound the kustomize major versions
+	// WithCompatibilityMode accepts.
+	minCompatibilityMajor = 3
+	maxCompatibilityMajor = 5
+)
+
+// compatibilityVersionPattern matches kustomize versions such as "v4", "4.5" or "v5.0.1".
+//
+//nolint:gochecknoglobals
+var compatibilityVersionPattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?$`)
+
+// compatibilityReorder is the resource ordering of kustomize build in every supported
+// release: its --reorder flag defaults to legacy, unlike krusty.
+const compatibilityReorder = krusty.ReorderOptionLegacy
+
+// Kustomize releases changing how labels propagate, as found in the kustomize/api module
+// they were built with.
+//
+//nolint:gochecknoglobals
+var (
+	// topologySpreadRelease added the topologySpreadConstraints selectors of Deployments and
+	// StatefulSets to the commonLabels field specs (kustomize/api v0.7.1).
+	topologySpreadRelease = kustomizeVersion{major: 3, minor: 9, patch: 1}
+
+	// labelsRelease introduced the labels field (kustomize/api v0.8.6).
+	labelsRelease = kustomizeVersion{major: 4, minor: 1}
+
+	// includeTemplatesRelease introduced labels[].includeTemplates, which then only labeled
+	// existing spec/template/metadata/labels fields (kustomize/api v0.11.5).
+	includeTemplatesRelease = kustomizeVersion{major: 4, minor: 5, patch: 5}
+
+	// templateLabelsRelease made includeTemplates use the templateLabels field specs, which
+	// create the labels and also cover job templates and volume claim templates
+	// (kustomize/api v0.13.1).
+	templateLabelsRelease = kustomizeVersion{major: 5}
+)
+
+// legacyCommonLabelFieldSpecs are the commonLabels field specs of kustomize v3.5.4 to
+// v3.9.0, without metadata/labels, which the labels transformer always includes.
+const legacyCommonLabelFieldSpecs = `
+- path: spec/selector
+  create: true
+  version: v1
+  kind: Service
+- path: spec/selector
+  create: true
+  version: v1
+  kind: ReplicationController
+- path: spec/template/metadata/labels
+  create: true
+  version: v1
+  kind: ReplicationController
+- path: spec/selector/matchLabels
+  create: true
+  kind: Deployment
+- path: spec/template/metadata/labels
+  create: true
+  kind: Deployment
+- path: spec/template/spec/affinity/podAffinity/preferredDuringSchedulingIgnoredDuringExecution/podAffinityTerm/labelSelector/matchLabels
+  group: apps
+  kind: Deployment
+- path: spec/template/spec/affinity/podAffinity/requiredDuringSchedulingIgnoredDuringExecution/labelSelector/matchLabels
+  group: apps
+  kind: Deployment
+- path: spec/template/spec/affinity/podAntiAffinity/preferredDuringSchedulingIgnoredDuringExecution/podAffinityTerm/labelSelector/matchLabels
+  group: apps
+  kind: Deployment
+- path: spec/template/spec/affinity/podAntiAffinity/requiredDuringSchedulingIgnoredDuringExecution/labelSelector/matchLabels
+  group: apps
+  kind: Deployment
+- path: spec/selector/matchLabels
+  create: true
+  kind: ReplicaSet
+- path: spec/template/metadata/labels
+  create: true
+  kind: ReplicaSet
+- path: spec/selector/matchLabels
+  create: true
+  kind: DaemonSet
+- path: spec/template/metadata/labels
+  create: true
+  kind: DaemonSet
+- path: spec/selector/matchLabels
+  create: true
+  group: apps
+  kind: StatefulSet
+- path: spec/template/metadata/labels
+  create: true
+  group: apps
+  kind: StatefulSet
+- path: spec/template/spec/affinity/podAffinity/preferredDuringSchedulingIgnoredDuringExecution/podAffinityTerm/labelSelector/matchLabels
+  group: apps
+  kind: StatefulSet
+- path: spec/template/spec/affinity/podAffinity/requiredDuringSchedulingIgnoredDuringExecution/labelSelector/matchLabels
+  group: apps
+  kind: StatefulSet
+- path: spec/template/spec/affinity/podAntiAffinity/preferredDuringSchedulingIgnoredDuringExecution/podAffinityTerm/labelSelector/matchLabels
+  group: apps
+  kind: StatefulSet
+- path: spec/template/spec/affinity/podAntiAffinity/requiredDuringSchedulingIgnoredDuringExecution/labelSelector/matchLabels
+  group: apps
+  kind: StatefulSet
+- path: spec/volumeClaimTemplates[]/metadata/labels
+  create: true
+  group: apps
+  kind: StatefulSet
+- path: spec/selector/matchLabels
+  group: batch
+  kind: Job
+- path: spec/template/metadata/labels
+  create: true
+  group: batch
+  kind: Job
+- path: spec/jobTemplate/spec/selector/matchLabels
+  group: batch
+  kind: CronJob
+- path: spec/jobTemplate/metadata/labels
+  create: true
+  group: batch
+  kind: CronJob
+- path: spec/jobTemplate/spec/template/metadata/labels
+  create: true
+  group: batch
+  kind: CronJob
+- path: spec/selector/matchLabels
+  group: policy
+  kind: PodDisruptionBudget
+- path: spec/podSelector/matchLabels
+  group: networking.k8s.io
+  kind: NetworkPolicy
+- path: spec/ingress/from/podSelector/matchLabels
+  group: networking.k8s.io
+  kind: NetworkPolicy
+- path: spec/egress/to/podSelector/matchLabels
+  group: networking.k8s.io
+  kind: NetworkPolicy
+`
+
+// kustomizeVersion is a kustomize release set with WithCompatibilityMode. Omitted minor and
+// patch numbers are zero.
+type kustomizeVersion struct {
+	major int
+	minor int
+	patch int
+}
+
+// before reports whether v is an older release than other.
+func (v kustomizeVersion) before(other kustomizeVersion) bool {
+	switch {
+	case v.major != other.major:
+		return v.major < other.major
+	case v.minor != other.minor:
+		return v.minor < other.minor
+	default:
+		return v.patch < other.patch
+	}
+}
+
+// String returns the version as "vMAJOR.MINOR.PATCH".
+func (v kustomizeVersion) String() string {
+	return fmt.Sprintf("v%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+// parseCompatibilityMode parses a version set with WithCompatibilityMode.
+func parseCompatibilityMode(version string) (kustomizeVersion, error) {
+	m := compatibilityVersionPattern.FindStringSubmatch(version)
+	if m == nil {
+		return kustomizeVersion{}, fmt.Errorf("%w: %q is not a kustomize version", ErrInvalidCompatibilityMode, version)
+	}
+
+	numbers := make([]int, 0, len(m)-1)
+
+	for _, s := range m[1:] {
+		if s == "" {
+			s = "0"
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return kustomizeVersion{}, fmt.Errorf(
+				"%w: %q is not a kustomize version",
+				ErrInvalidCompatibilityMode,
+				version,
+			)
+		}
+
+		numbers = append(numbers, n)
+	}
+
+	v := kustomizeVersion{major: numbers[0], minor: numbers[1], patch: numbers[2]}
+	if v.major < minCompatibilityMajor || v.major > maxCompatibilityMajor {
+		return kustomizeVersion{}, fmt.Errorf(
+			"%w: kustomize %q is not supported, only v%d to v%d are",
+			ErrInvalidCompatibilityMode,
+			version,
+			minCompatibilityMajor,
+			maxCompatibilityMajor,
+		)
+	}
+
+	return v, nil
+}
+
+// adaptLabels rewrites the label fields of kust so that the vendored kustomize propagates
+// them like release v, reporting whether kust was modified. Fields unknown to v fail with
+// ErrIncompatibleKustomization, since that release rejects them.
+func (v kustomizeVersion) adaptLabels(kust *kustomizetypes.Kustomization) (bool, error) {
+	if len(kust.Labels) > 0 && v.before(labelsRelease) {
+		return false, fmt.Errorf(
+			"%w: the labels field requires kustomize %s, not %s",
+			ErrIncompatibleKustomization,
+			labelsRelease,
+			v,
+		)
+	}
+
+	modified := false
+
+	for i, label := range kust.Labels {
+		if !label.IncludeTemplates {
+			continue
+		}
+
+		if v.before(includeTemplatesRelease) {
+			return false, fmt.Errorf(
+				"%w: labels[].includeTemplates requires kustomize %s, not %s",
+				ErrIncompatibleKustomization,
+				includeTemplatesRelease,
+				v,
+			)
+		}
+
+		if label.IncludeSelectors || !v.before(templateLabelsRelease) {
+			continue
+		}
+
+		kust.Labels[i].IncludeTemplates = false
+		kust.Labels[i].FieldSpecs = append(kust.Labels[i].FieldSpecs, kustomizetypes.FieldSpec{
+			Path: "spec/template/metadata/labels",
+		})
+		modified = true
+	}
+
+	if len(kust.CommonLabels) > 0 && v.before(topologySpreadRelease) {
+		var fieldSpecs []kustomizetypes.FieldSpec
+		if err := goyaml.Unmarshal([]byte(legacyCommonLabelFieldSpecs), &fieldSpecs); err != nil {
+			return false, fmt.Errorf("failed to parse legacy commonLabels field specs: %w", err)
+		}
+
+		// the labels transformers run before the commonLabels one, so appending keeps the order
+		kust.Labels = append(kust.Labels, kustomizetypes.Label{
+			Pairs:      kust.CommonLabels,
+			FieldSpecs: fieldSpecs,
+		})
+		kust.CommonLabels = nil
+		modified = true
+	}
+
+	return modified, nil
+}
+
+// compatibilityOverrides returns the union overrides adapting the labels of the local
+// kustomizations below root to the compatibility mode. The kustomization in root itself is
+// adapted by prepareFilesystem, together with the other changes made to it.
+func (e *Engine) compatibilityOverrides(fs filesys.FileSystem, root string) ([]union.Option, error) {
+	if e.compat == nil {
+		return nil, nil
+	}
+
+	opts := make([]union.Option, 0)
+	visited := map[string]bool{root: true}
+
+	var visit func(dir string) error
+	visit = func(dir string) error {
+		kust, name, err := readKustomization(fs, dir)
+		if err != nil {
+			return err
+		}
+
+		if dir != root {
+			modified, err := e.compat.adaptLabels(kust)
+			if err != nil {
+				return fmt.Errorf("path %q: %w", dir, err)
+			}
+
+			if modified {
+				data, err := goyaml.Marshal(kust)
+				if err != nil {
+					return fmt.Errorf("failed to marshal kustomization: %w", err)
+				}
+
+				opts = append(opts, union.WithOverride(filepath.Join(dir, name), data))
+			}
+		}
+
+		for _, ref := range collectReferences(kust) {
+			if !isKustomizationField(ref.Field) || isRemoteReference(ref.Value) {
+				continue
+			}
+
+			target := resolveReference(dir, ref.Value)
+			if visited[target] || !fs.IsDir(target) {
+				continue
+			}
+
+			visited[target] = true
+
+			if err := visit(target); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := visit(root); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
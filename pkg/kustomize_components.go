@@ -0,0 +1,89 @@
+package kustomize
+
+import (
+	"path/filepath"
+	"slices"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// appliedComponents returns the components applied when building the kustomization in
+// root: the components it declares and, recursively, those declared by its local bases and
+// components. Local components are reported by directory, relative to root; remote ones as
+// written. Components are listed in declaration order, depth first, without duplicates.
+func appliedComponents(fs filesys.FileSystem, root string) ([]string, error) {
+	c := &componentCollector{
+		fs:         fs,
+		root:       root,
+		visited:    make(map[string]bool),
+		components: make([]string, 0),
+	}
+
+	if err := c.collect(root); err != nil {
+		return nil, err
+	}
+
+	return c.components, nil
+}
+
+// componentCollector accumulates the components of a kustomization tree.
+type componentCollector struct {
+	fs   filesys.FileSystem
+	root string
+
+	// visited holds the kustomization directories already walked.
+	visited    map[string]bool
+	components []string
+}
+
+func (c *componentCollector) collect(dir string) error {
+	c.visited[dir] = true
+
+	kust, _, err := readKustomization(c.fs, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range collectReferences(kust) {
+		if !isKustomizationField(ref.Field) {
+			continue
+		}
+
+		if isRemoteReference(ref.Value) {
+			if ref.Field == "components" {
+				c.add(ref.Value)
+			}
+
+			continue
+		}
+
+		target := resolveReference(dir, ref.Value)
+		if !c.fs.IsDir(target) {
+			continue
+		}
+
+		if ref.Field == "components" {
+			if rel, err := filepath.Rel(c.root, target); err == nil {
+				c.add(rel)
+			} else {
+				c.add(target)
+			}
+		}
+
+		if c.visited[target] {
+			continue
+		}
+
+		if err := c.collect(target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *componentCollector) add(component string) {
+	if !slices.Contains(c.components, component) {
+		c.components = append(c.components, component)
+	}
+}
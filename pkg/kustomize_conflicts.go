@@ -0,0 +1,188 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ErrResourceConflict is matched (via errors.Is) by the ConflictError returned by Process
+// when conflict checking is enabled and conflicting objects are rendered.
+var ErrResourceConflict = errors.New("conflicting resources rendered")
+
+// ResourceID identifies a rendered object.
+type ResourceID struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+}
+
+// String returns the identity in "group/version, Kind=Kind namespace/name" form.
+func (id ResourceID) String() string {
+	if id.Namespace == "" {
+		return id.GroupVersionKind.String() + " " + id.Name
+	}
+
+	return id.GroupVersionKind.String() + " " + id.Namespace + "/" + id.Name
+}
+
+// ObjectOrigin describes where a rendered copy of an object comes from.
+type ObjectOrigin struct {
+	// SourcePath is the path of the Source that rendered the object. Empty for extra
+	// resources added with WithExtraResources.
+	SourcePath string
+
+	// SourceFile is the file the object was read from, when source annotations are
+	// enabled and kustomize reported it. Empty otherwise.
+	SourceFile string
+}
+
+// String returns the source path, followed by the source file when known.
+func (o ObjectOrigin) String() string {
+	if o.SourcePath == "" && o.SourceFile == "" {
+		return "extra resources"
+	}
+
+	if o.SourceFile == "" {
+		return o.SourcePath
+	}
+
+	return o.SourcePath + " (" + o.SourceFile + ")"
+}
+
+// Conflict is a pair of rendered objects sharing the same identity but differing in content.
+type Conflict struct {
+	ID ResourceID
+
+	// First and Second are the origins of the conflicting copies, in output order.
+	First  ObjectOrigin
+	Second ObjectOrigin
+}
+
+// ConflictError lists every conflicting pair of rendered objects.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+// Error lists every conflict with the origins of both copies.
+func (e *ConflictError) Error() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s: %d conflict(s)", ErrResourceConflict.Error(), len(e.Conflicts))
+
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&sb, "\n  %s: %s <> %s", c.ID, c.First, c.Second)
+	}
+
+	return sb.String()
+}
+
+// Is reports whether target is ErrResourceConflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrResourceConflict
+}
+
+// renderedObject is a rendered object together with the source path that produced it.
+type renderedObject struct {
+	obj        unstructured.Unstructured
+	sourcePath string
+}
+
+// checkConflicts returns a ConflictError enumerating every pair of objects that share the
+// same GVK, namespace and name but differ in content. Identical copies are not conflicts.
+// Source tracking annotations are ignored when comparing, since they differ by source.
+func checkConflicts(objects []renderedObject, keys sourceAnnotationKeys) error {
+	order := make([]ResourceID, 0, len(objects))
+	byID := make(map[ResourceID][]renderedObject, len(objects))
+
+	for _, o := range objects {
+		id := ResourceID{
+			GroupVersionKind: o.obj.GroupVersionKind(),
+			Namespace:        o.obj.GetNamespace(),
+			Name:             o.obj.GetName(),
+		}
+
+		if _, ok := byID[id]; !ok {
+			order = append(order, id)
+		}
+
+		byID[id] = append(byID[id], o)
+	}
+
+	conflicts := make([]Conflict, 0)
+
+	for _, id := range order {
+		copies := byID[id]
+
+		for i := range copies {
+			for j := i + 1; j < len(copies); j++ {
+				if sameContent(copies[i].obj, copies[j].obj, keys) {
+					continue
+				}
+
+				conflicts = append(conflicts, Conflict{
+					ID:     id,
+					First:  originOf(copies[i], keys),
+					Second: originOf(copies[j], keys),
+				})
+			}
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	return &ConflictError{Conflicts: conflicts}
+}
+
+// sameContent compares two objects, ignoring source tracking annotations and labels.
+func sameContent(a unstructured.Unstructured, b unstructured.Unstructured, keys sourceAnnotationKeys) bool {
+	return equality.Semantic.DeepEqual(
+		withoutSourceInfo(a, keys).Object,
+		withoutSourceInfo(b, keys).Object,
+	)
+}
+
+// withoutSourceInfo returns a copy of obj without source tracking annotations and labels.
+func withoutSourceInfo(obj unstructured.Unstructured, keys sourceAnnotationKeys) *unstructured.Unstructured {
+	out := obj.DeepCopy()
+
+	out.SetAnnotations(withoutKeys(out.GetAnnotations(), keys.Type, keys.Path, keys.File))
+	out.SetLabels(withoutKeys(out.GetLabels(), keys.Type, keys.Path, keys.File))
+
+	return out
+}
+
+// withoutKeys deletes keys from m, returning nil if nothing is left.
+func withoutKeys(m map[string]string, keys ...string) map[string]string {
+	for _, k := range keys {
+		delete(m, k)
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+// originOf returns the origin of a rendered object, preferring its source annotations.
+func originOf(o renderedObject, keys sourceAnnotationKeys) ObjectOrigin {
+	annotations := o.obj.GetAnnotations()
+
+	origin := ObjectOrigin{
+		SourcePath: o.sourcePath,
+		SourceFile: annotations[keys.File],
+	}
+
+	if path := annotations[keys.Path]; path != "" {
+		origin.SourcePath = path
+	}
+
+	return origin
+}
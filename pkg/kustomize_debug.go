@@ -0,0 +1,43 @@
+package kustomize
+
+import (
+	"fmt"
+
+	goyaml "gopkg.in/yaml.v3"
+)
+
+// DebugKustomization returns, as YAML, the kustomization of source as kustomize sees it
+// during a build: with the changes of the renderer options (buildMetadata and generator
+// options, namespace and name overrides, the values patch and the label adaptations of the
+// compatibility mode), and with the defaults and deprecated field rewrites kustomize applies
+// when loading it. Diffing it against the
+// kustomization file shows what the renderer changed.
+//
+// No build is run and nothing is written; generated files such as the values ConfigMap are
+// not part of the kustomization and therefore not shown.
+func (r *Renderer) DebugKustomization(source Source) ([]byte, error) {
+	source = kustomizationDir(r.engine.fileSystem(source), source)
+
+	holder := &sourceHolder{Source: source}
+	if err := holder.Validate(); err != nil {
+		return nil, err
+	}
+
+	kust, _, err := readKustomization(r.engine.fileSystem(source), source.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read kustomization from path %q: %w", source.Path, err)
+	}
+
+	if _, _, err := r.engine.modifyKustomization(kust); err != nil {
+		return nil, fmt.Errorf("path %q: %w", source.Path, err)
+	}
+
+	kust.FixKustomization()
+
+	data, err := goyaml.Marshal(kust)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal kustomization from path %q: %w", source.Path, err)
+	}
+
+	return data, nil
+}
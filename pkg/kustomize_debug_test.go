@@ -0,0 +1,118 @@
+package kustomize_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDebugKustomization(t *testing.T) {
+	debug := func(g *WithT, dir string, opts ...kustomize.RendererOption) *kustomizetypes.Kustomization {
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, opts...)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := renderer.DebugKustomization(kustomize.Source{Path: dir})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		kust := &kustomizetypes.Kustomization{}
+		g.Expect(kust.Unmarshal(data)).To(Succeed())
+
+		return kust
+	}
+
+	t.Run("should return the kustomization as written", func(t *testing.T) {
+		g := NewWithT(t)
+
+		kust := debug(g, setupBasicKustomization(t))
+		g.Expect(kust.NamePrefix).To(Equal("test-"))
+		g.Expect(kust.Resources).To(Equal([]string{"configmap.yaml", "pod.yaml"}))
+		g.Expect(kust.BuildMetadata).To(BeEmpty())
+	})
+
+	t.Run("should include the build metadata added by the renderer", func(t *testing.T) {
+		g := NewWithT(t)
+
+		kust := debug(g, setupBasicKustomization(t),
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithTransformerAnnotations(true),
+			kustomize.WithManagedByLabel(true),
+		)
+		g.Expect(kust.BuildMetadata).To(ConsistOf(
+			kustomizetypes.OriginAnnotations,
+			kustomizetypes.TransformerAnnotations,
+			kustomizetypes.ManagedByLabelOption,
+		))
+	})
+
+	t.Run("should include the overrides and values patch of the renderer", func(t *testing.T) {
+		g := NewWithT(t)
+
+		kust := debug(g, setupBasicKustomization(t),
+			kustomize.WithNamespaceOverride("prod"),
+			kustomize.WithNamePrefix("p-"),
+			kustomize.WithDisableNameSuffixHash(true),
+			kustomize.WithValuesPatch(map[string]string{"key": "patched"}),
+		)
+		g.Expect(kust.Namespace).To(Equal("prod"))
+		g.Expect(kust.NamePrefix).To(Equal("p-test-"))
+		g.Expect(kust.GeneratorOptions).ToNot(BeNil())
+		g.Expect(kust.GeneratorOptions.DisableNameSuffixHash).To(BeTrue())
+		g.Expect(kust.Patches).To(HaveLen(1))
+		g.Expect(kust.Patches[0].Patch).To(ContainSubstring("patched"))
+	})
+
+	t.Run("should fail on conflicting overrides", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "namespace: dev\n")
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithNamespaceOverride("prod"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.DebugKustomization(kustomize.Source{Path: dir})
+		g.Expect(err).To(MatchError(kustomize.ErrOverrideConflict))
+	})
+
+	t.Run("should apply defaults and deprecated field rewrites", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "bases:\n- ../base\nimageTags:\n- name: app\n  newTag: v2\n")
+
+		kust := debug(g, dir)
+		g.Expect(kust.APIVersion).To(Equal(kustomizetypes.KustomizationVersion))
+		g.Expect(kust.Kind).To(Equal(kustomizetypes.KustomizationKind))
+		g.Expect(kust.Bases).To(BeEmpty()) //nolint:staticcheck
+		g.Expect(kust.Resources).To(Equal([]string{"../base"}))
+		g.Expect(kust.ImageTags).To(BeEmpty()) //nolint:staticcheck
+		g.Expect(kust.Images).To(ConsistOf(kustomizetypes.Image{Name: "app", NewTag: "v2"}))
+	})
+
+	t.Run("should not modify the kustomization file", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := setupBasicKustomization(t)
+		_ = debug(g, dir, kustomize.WithSourceAnnotations(true))
+
+		data, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal(basicKustomization))
+	})
+
+	t.Run("should fail without a kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: t.TempDir()}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.DebugKustomization(kustomize.Source{Path: t.TempDir()})
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+	})
+}
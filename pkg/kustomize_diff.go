@@ -0,0 +1,545 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// diffContextLines is the number of unchanged lines shown around changes in unified diffs.
+const diffContextLines = 3
+
+//nolint:gochecknoglobals
+var plainFieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// DiffType is the kind of change of an object or field.
+type DiffType string
+
+const (
+	// DiffAdded marks objects or fields only present in the second render.
+	DiffAdded DiffType = "added"
+
+	// DiffRemoved marks objects or fields only present in the first render.
+	DiffRemoved DiffType = "removed"
+
+	// DiffChanged marks objects or fields present in both renders with different content.
+	DiffChanged DiffType = "changed"
+)
+
+// Diff lists the objects that differ between two renders.
+type Diff struct {
+	// Objects are the differing objects: removed and changed objects in the order of the
+	// first render, followed by added objects in the order of the second render.
+	Objects []ObjectDiff
+}
+
+// ObjectDiff describes how a single object differs between two renders.
+type ObjectDiff struct {
+	ID   ResourceID
+	Type DiffType
+
+	// Before is the object in the first render, nil if it was added.
+	Before *unstructured.Unstructured
+
+	// After is the object in the second render, nil if it was removed.
+	After *unstructured.Unstructured
+
+	// Fields are the differing fields of changed objects, in depth-first order.
+	Fields []FieldDiff
+}
+
+// FieldDiff describes a single differing field.
+type FieldDiff struct {
+	// Path locates the field, e.g. spec.template.spec.containers[0].image or
+	// metadata.labels["app.kubernetes.io/name"].
+	Path string
+	Type DiffType
+
+	// Before is the value in the first render, nil if the field was added.
+	Before any
+
+	// After is the value in the second render, nil if the field was removed.
+	After any
+}
+
+// Empty reports whether both renders produced the same objects.
+func (d *Diff) Empty() bool {
+	return len(d.Objects) == 0
+}
+
+// Unified renders the diff of every object as a unified text diff of its YAML form.
+func (d *Diff) Unified() string {
+	var sb strings.Builder
+
+	for _, o := range d.Objects {
+		before, err := objectLines(o.Before)
+		if err != nil {
+			fmt.Fprintf(&sb, "# %s: %v\n", o.ID, err)
+
+			continue
+		}
+
+		after, err := objectLines(o.After)
+		if err != nil {
+			fmt.Fprintf(&sb, "# %s: %v\n", o.ID, err)
+
+			continue
+		}
+
+		from, to := "a/"+o.ID.String(), "b/"+o.ID.String()
+
+		switch o.Type {
+		case DiffAdded:
+			from = "/dev/null"
+		case DiffRemoved:
+			to = "/dev/null"
+		case DiffChanged:
+		}
+
+		fmt.Fprintf(&sb, "--- %s\n+++ %s\n", from, to)
+		writeHunks(&sb, diffLines(before, after))
+	}
+
+	return sb.String()
+}
+
+// Diff renders two sources and compares their output, matching objects by GVK, namespace and
+// name. Both sources go through the renderer's filters and transformers. Source tracking
+// annotations and labels are not compared, since they always differ between sources.
+func (r *Renderer) Diff(ctx context.Context, a Source, b Source) (*Diff, error) {
+	before, err := r.renderForDiff(ctx, a, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := r.renderForDiff(ctx, b, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.diffObjects(before, after), nil
+}
+
+// DiffValues renders a source with two sets of render-time values and compares the output like Diff.
+func (r *Renderer) DiffValues(ctx context.Context, source Source, a map[string]any, b map[string]any) (*Diff, error) {
+	before, err := r.renderForDiff(ctx, source, a)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := r.renderForDiff(ctx, source, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.diffObjects(before, after), nil
+}
+
+func (r *Renderer) renderForDiff(
+	ctx context.Context,
+	source Source,
+	renderTimeValues map[string]any,
+) ([]unstructured.Unstructured, error) {
+	source = kustomizationDir(r.engine.fileSystem(source), source)
+
+	holder := &sourceHolder{Source: source}
+	if err := holder.Validate(); err != nil {
+		return nil, err
+	}
+
+	result, err := r.processSource(ctx, holder, renderTimeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.engine.handleWarnings(result.Warnings); err != nil {
+		return nil, err
+	}
+
+	return result.Objects, nil
+}
+
+// diffObjects compares two renders, ignoring source tracking annotations and labels.
+func (r *Renderer) diffObjects(before []unstructured.Unstructured, after []unstructured.Unstructured) *Diff {
+	keys := r.opts.SourceAnnotationConfig.keys()
+
+	afterByID := make(map[ResourceID]*unstructured.Unstructured, len(after))
+	for i := range after {
+		afterByID[objectID(after[i])] = withoutSourceInfo(after[i], keys)
+	}
+
+	diff := &Diff{Objects: make([]ObjectDiff, 0)}
+	seen := make(map[ResourceID]bool, len(before))
+
+	for i := range before {
+		id := objectID(before[i])
+		seen[id] = true
+		b := withoutSourceInfo(before[i], keys)
+
+		a, ok := afterByID[id]
+		switch {
+		case !ok:
+			diff.Objects = append(diff.Objects, ObjectDiff{ID: id, Type: DiffRemoved, Before: b})
+		case !equality.Semantic.DeepEqual(b.Object, a.Object):
+			diff.Objects = append(diff.Objects, ObjectDiff{
+				ID:     id,
+				Type:   DiffChanged,
+				Before: b,
+				After:  a,
+				Fields: diffFields("", b.Object, a.Object, nil),
+			})
+		}
+	}
+
+	for i := range after {
+		id := objectID(after[i])
+		if !seen[id] {
+			seen[id] = true
+			diff.Objects = append(diff.Objects, ObjectDiff{ID: id, Type: DiffAdded, After: afterByID[id]})
+		}
+	}
+
+	return diff
+}
+
+func objectID(obj unstructured.Unstructured) ResourceID {
+	return ResourceID{
+		GroupVersionKind: obj.GroupVersionKind(),
+		Namespace:        obj.GetNamespace(),
+		Name:             obj.GetName(),
+	}
+}
+
+// diffFields appends the differences between two values to fields. Maps are compared by key
+// and lists by index; any other difference is reported at path.
+func diffFields(path string, before any, after any, fields []FieldDiff) []FieldDiff {
+	switch b := before.(type) {
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok {
+			break
+		}
+
+		for _, k := range sortedKeys(b, a) {
+			bv, inBefore := b[k]
+			av, inAfter := a[k]
+
+			switch {
+			case !inAfter:
+				fields = append(fields, FieldDiff{Path: fieldPath(path, k), Type: DiffRemoved, Before: bv})
+			case !inBefore:
+				fields = append(fields, FieldDiff{Path: fieldPath(path, k), Type: DiffAdded, After: av})
+			default:
+				fields = diffFields(fieldPath(path, k), bv, av, fields)
+			}
+		}
+
+		return fields
+
+	case []any:
+		a, ok := after.([]any)
+		if !ok {
+			break
+		}
+
+		for i := range max(len(b), len(a)) {
+			p := path + "[" + strconv.Itoa(i) + "]"
+
+			switch {
+			case i >= len(a):
+				fields = append(fields, FieldDiff{Path: p, Type: DiffRemoved, Before: b[i]})
+			case i >= len(b):
+				fields = append(fields, FieldDiff{Path: p, Type: DiffAdded, After: a[i]})
+			default:
+				fields = diffFields(p, b[i], a[i], fields)
+			}
+		}
+
+		return fields
+	}
+
+	if equality.Semantic.DeepEqual(before, after) {
+		return fields
+	}
+
+	return append(fields, FieldDiff{Path: path, Type: DiffChanged, Before: before, After: after})
+}
+
+// sortedKeys returns the union of the keys of both maps, sorted.
+func sortedKeys(a map[string]any, b map[string]any) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// fieldPath appends key to path, quoting keys that are not plain identifiers.
+func fieldPath(path string, key string) string {
+	if !plainFieldName.MatchString(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// objectLines returns the YAML form of obj split into lines, or nothing for a nil object.
+func objectLines(obj *unstructured.Unstructured) ([]string, error) {
+	if obj == nil {
+		return nil, nil
+	}
+
+	data, err := sigsyaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal object: %w", err)
+	}
+
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// lineOp is a line of an edit script: ' ' kept, '-' deleted or '+' inserted.
+type lineOp struct {
+	kind byte
+	text string
+}
+
+// diffLines computes a minimal edit script turning a into b with the linear space variant
+// of Myers' O(ND) algorithm, so that large objects with scattered changes need memory
+// proportional to their length only.
+func diffLines(a []string, b []string) []lineOp {
+	return appendDiff(make([]lineOp, 0, len(a)+len(b)), a, b)
+}
+
+// appendDiff appends the edit script turning a into b to ops. The common prefix and suffix
+// are matched first; the rest is split at a point of a shortest edit path and each half is
+// diffed on its own.
+func appendDiff(ops []lineOp, a []string, b []string) []lineOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		ops = append(ops, lineOp{kind: ' ', text: line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	x, y, found := 0, 0, false
+	if len(midA) > 0 && len(midB) > 0 {
+		x, y, found = bisectLines(midA, midB)
+	}
+
+	if found {
+		ops = appendDiff(ops, midA[:x], midB[:y])
+		ops = appendDiff(ops, midA[x:], midB[y:])
+	} else {
+		for _, line := range midA {
+			ops = append(ops, lineOp{kind: '-', text: line})
+		}
+
+		for _, line := range midB {
+			ops = append(ops, lineOp{kind: '+', text: line})
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, lineOp{kind: ' ', text: line})
+	}
+
+	return ops
+}
+
+// bisectLines finds the point (x, y) where the forward and reverse searches for a shortest
+// edit path from a to b meet. It reports false if a and b have no line in common, in which
+// case the shortest path deletes all of a and inserts all of b.
+func bisectLines(a []string, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+
+	// forward[offset+k] and reverse[offset+k] hold the furthest x reached on diagonal k from
+	// the start and from the end respectively, -1 if the diagonal was not reached yet.
+	forward := make([]int, 2*maxD+2)
+	reverse := make([]int, 2*maxD+2)
+
+	for i := range forward {
+		forward[i], reverse[i] = -1, -1
+	}
+
+	forward[offset+1], reverse[offset+1] = 0, 0
+
+	delta := n - m
+	odd := delta%2 != 0
+
+	// diagonals leaving the grid are trimmed from the next rounds
+	fStart, fEnd, rStart, rEnd := 0, 0, 0, 0
+
+	for d := range maxD {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				rk := offset + delta - k
+				if rk >= 0 && rk < len(reverse) && reverse[rk] != -1 && x >= n-reverse[rk] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && reverse[offset+k-1] < reverse[offset+k+1]) {
+				x = reverse[offset+k+1]
+			} else {
+				x = reverse[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+
+			reverse[offset+k] = x
+
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd:
+				fk := offset + delta - k
+				if fk >= 0 && fk < len(forward) && forward[fk] != -1 && forward[fk] >= n-x {
+					return forward[fk], forward[fk] - (delta - k), true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// writeHunks writes the changes of an edit script as unified diff hunks, merging changes
+// separated by few enough unchanged lines.
+func writeHunks(sb *strings.Builder, ops []lineOp) {
+	// aLine[k] and bLine[k] count the lines of each side preceding ops[k]
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+
+	for k, op := range ops {
+		aLine[k+1], bLine[k+1] = aLine[k], bLine[k]
+		if op.kind != '+' {
+			aLine[k+1]++
+		}
+
+		if op.kind != '-' {
+			bLine[k+1]++
+		}
+	}
+
+	next := 0
+
+	for next < len(ops) {
+		start := next
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+
+		if start == len(ops) {
+			return
+		}
+
+		end := start
+
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+
+			unchanged := end
+			for unchanged < len(ops) && ops[unchanged].kind == ' ' {
+				unchanged++
+			}
+
+			if unchanged == len(ops) || unchanged-end > 2*diffContextLines {
+				break
+			}
+
+			end = unchanged
+		}
+
+		from := max(start-diffContextLines, next)
+		to := min(end+diffContextLines, len(ops))
+
+		fmt.Fprintf(sb, "@@ -%s +%s @@\n",
+			hunkRange(aLine[from], aLine[to]-aLine[from]),
+			hunkRange(bLine[from], bLine[to]-bLine[from]),
+		)
+
+		for _, op := range ops[from:to] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+
+		next = to
+	}
+}
+
+// hunkRange formats the range of a hunk side; empty ranges refer to the preceding line.
+func hunkRange(before int, count int) string {
+	if count == 0 {
+		return strconv.Itoa(before) + ",0"
+	}
+
+	if count == 1 {
+		return strconv.Itoa(before + 1)
+	}
+
+	return strconv.Itoa(before+1) + "," + strconv.Itoa(count)
+}
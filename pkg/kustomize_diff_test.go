@@ -0,0 +1,305 @@
+package kustomize_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+const diffPod = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod
+spec:
+  containers:
+  - name: app
+    image: app:v1
+`
+
+const diffSecret = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+`
+
+func TestDiff(t *testing.T) {
+	setupPair := func(t *testing.T) (string, string) {
+		t.Helper()
+
+		before := t.TempDir()
+		writeFile(t, before, "kustomization.yaml", "resources:\n- configmap.yaml\n- pod.yaml\n")
+		writeFile(t, before, "configmap.yaml", basicConfigMap)
+		writeFile(t, before, "pod.yaml", diffPod)
+
+		after := t.TempDir()
+		writeFile(t, after, "kustomization.yaml", "resources:\n- configmap.yaml\n- secret.yaml\n")
+		writeFile(t, after, "configmap.yaml", `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: configmap
+  labels:
+    app.kubernetes.io/name: app
+data:
+  key: changed
+`)
+		writeFile(t, after, "secret.yaml", diffSecret)
+
+		return before, after
+	}
+
+	configMapID := kustomize.ResourceID{
+		GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Name:             "configmap",
+	}
+
+	t.Run("should report added, removed and changed objects", func(t *testing.T) {
+		g := NewWithT(t)
+		before, after := setupPair(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: before}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.Diff(t.Context(), kustomize.Source{Path: before}, kustomize.Source{Path: after})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(diff.Empty()).To(BeFalse())
+		g.Expect(diff.Objects).To(HaveLen(3))
+
+		g.Expect(diff.Objects[0].ID).To(Equal(configMapID))
+		g.Expect(diff.Objects[0].Type).To(Equal(kustomize.DiffChanged))
+		g.Expect(diff.Objects[0].Fields).To(Equal([]kustomize.FieldDiff{
+			{Path: "data.key", Type: kustomize.DiffChanged, Before: "value", After: "changed"},
+			{Path: `metadata.labels`, Type: kustomize.DiffAdded, After: map[string]any{"app.kubernetes.io/name": "app"}},
+		}))
+
+		g.Expect(diff.Objects[1].ID.Name).To(Equal("pod"))
+		g.Expect(diff.Objects[1].Type).To(Equal(kustomize.DiffRemoved))
+		g.Expect(diff.Objects[1].After).To(BeNil())
+
+		g.Expect(diff.Objects[2].ID.Name).To(Equal("secret"))
+		g.Expect(diff.Objects[2].Type).To(Equal(kustomize.DiffAdded))
+		g.Expect(diff.Objects[2].Before).To(BeNil())
+	})
+
+	t.Run("should report nested fields by path", func(t *testing.T) {
+		g := NewWithT(t)
+
+		before := t.TempDir()
+		writeFile(t, before, "kustomization.yaml", "resources:\n- pod.yaml\ncommonAnnotations:\n  example.com/team: a\n")
+		writeFile(t, before, "pod.yaml", diffPod)
+
+		after := t.TempDir()
+		writeFile(t, after, "kustomization.yaml", "resources:\n- pod.yaml\ncommonAnnotations:\n  example.com/team: b\nimages:\n- name: app\n  newTag: v2\n")
+		writeFile(t, after, "pod.yaml", diffPod)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: before}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.Diff(t.Context(), kustomize.Source{Path: before}, kustomize.Source{Path: after})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(diff.Objects).To(HaveLen(1))
+		g.Expect(diff.Objects[0].Fields).To(Equal([]kustomize.FieldDiff{
+			{Path: `metadata.annotations["example.com/team"]`, Type: kustomize.DiffChanged, Before: "a", After: "b"},
+			{Path: "spec.containers[0].image", Type: kustomize.DiffChanged, Before: "app:v1", After: "app:v2"},
+		}))
+	})
+
+	t.Run("should render a unified diff", func(t *testing.T) {
+		g := NewWithT(t)
+		before, after := setupPair(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: before}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.Diff(t.Context(), kustomize.Source{Path: before}, kustomize.Source{Path: after})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(diff.Unified()).To(Equal(`--- a//v1, Kind=ConfigMap configmap
++++ b//v1, Kind=ConfigMap configmap
+@@ -1,6 +1,8 @@
+ apiVersion: v1
+ data:
+-  key: value
++  key: changed
+ kind: ConfigMap
+ metadata:
++  labels:
++    app.kubernetes.io/name: app
+   name: configmap
+--- a//v1, Kind=Pod pod
++++ /dev/null
+@@ -1,8 +0,0 @@
+-apiVersion: v1
+-kind: Pod
+-metadata:
+-  name: pod
+-spec:
+-  containers:
+-  - image: app:v1
+-    name: app
+--- /dev/null
++++ b//v1, Kind=Secret secret
+@@ -0,0 +1,4 @@
++apiVersion: v1
++kind: Secret
++metadata:
++  name: secret
+`))
+	})
+
+	t.Run("should split distant changes into hunks", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data := func(first string, last string) string {
+			return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: configmap\ndata:\n" +
+				"  a: " + first + "\n  b: x\n  c: x\n  d: x\n  e: x\n  f: x\n  g: x\n  h: x\n  i: " + last + "\n"
+		}
+
+		before := t.TempDir()
+		writeFile(t, before, "kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, before, "configmap.yaml", data("old", "old"))
+
+		after := t.TempDir()
+		writeFile(t, after, "kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, after, "configmap.yaml", data("new", "new"))
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: before}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.Diff(t.Context(), kustomize.Source{Path: before}, kustomize.Source{Path: after})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(diff.Unified()).To(Equal(`--- a//v1, Kind=ConfigMap configmap
++++ b//v1, Kind=ConfigMap configmap
+@@ -1,6 +1,6 @@
+ apiVersion: v1
+ data:
+-  a: old
++  a: new
+   b: x
+   c: x
+   d: x
+@@ -8,7 +8,7 @@
+   f: x
+   g: x
+   h: x
+-  i: old
++  i: new
+ kind: ConfigMap
+ metadata:
+   name: configmap
+`))
+	})
+
+	t.Run("should diff large objects changed at both ends", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data := func(first string, last string) string {
+			var sb strings.Builder
+
+			sb.WriteString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: configmap\ndata:\n")
+			sb.WriteString("  a0000: " + first + "\n")
+
+			for i := 1; i < 2000; i++ {
+				fmt.Fprintf(&sb, "  a%04d: x\n", i)
+			}
+
+			sb.WriteString("  a2000: " + last + "\n")
+
+			return sb.String()
+		}
+
+		before := t.TempDir()
+		writeFile(t, before, "kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, before, "configmap.yaml", data("old", "old"))
+
+		after := t.TempDir()
+		writeFile(t, after, "kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, after, "configmap.yaml", data("new", "new"))
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: before}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.Diff(t.Context(), kustomize.Source{Path: before}, kustomize.Source{Path: after})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		unified := diff.Unified()
+		g.Expect(strings.Count(unified, "\n@@ ")).To(Equal(2))
+		g.Expect(unified).To(ContainSubstring("-  a0000: old\n+  a0000: new\n"))
+		g.Expect(unified).To(ContainSubstring("-  a2000: old\n+  a2000: new\n"))
+	})
+
+	t.Run("should be empty for identical sources", func(t *testing.T) {
+		g := NewWithT(t)
+		before, _ := setupPair(t)
+		copied, _ := setupPair(t)
+
+		// source tracking differs between sources and is not compared
+		renderer, err := kustomize.New([]kustomize.Source{{Path: before}}, kustomize.WithSourceAnnotations(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.Diff(t.Context(), kustomize.Source{Path: before}, kustomize.Source{Path: copied})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(diff.Empty()).To(BeTrue())
+		g.Expect(diff.Unified()).To(BeEmpty())
+	})
+
+	t.Run("should fail when a source fails to render", func(t *testing.T) {
+		g := NewWithT(t)
+		before, _ := setupPair(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: before}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Diff(t.Context(), kustomize.Source{Path: before}, kustomize.Source{Path: t.TempDir()})
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+	})
+}
+
+func TestDiffValues(t *testing.T) {
+	t.Run("should compare renders with different values", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
+
+		source := kustomize.Source{Path: dir, Values: kustomize.Values(map[string]string{"static": "x"})}
+
+		renderer, err := kustomize.New([]kustomize.Source{source})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.DiffValues(t.Context(), source,
+			map[string]any{"replicas": 1},
+			map[string]any{"replicas": 3, "debug": true},
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(diff.Objects).To(HaveLen(1))
+		g.Expect(diff.Objects[0].Type).To(Equal(kustomize.DiffChanged))
+		g.Expect(diff.Objects[0].Fields).To(Equal([]kustomize.FieldDiff{
+			{Path: "data.debug", Type: kustomize.DiffAdded, After: "true"},
+			{Path: "data.replicas", Type: kustomize.DiffChanged, Before: "1", After: "3"},
+		}))
+	})
+
+	t.Run("should be empty for equal values", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
+
+		source := kustomize.Source{Path: dir}
+
+		renderer, err := kustomize.New([]kustomize.Source{source})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.DiffValues(t.Context(), source, map[string]any{"a": "b"}, map[string]any{"a": "b"})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(diff.Empty()).To(BeTrue())
+	})
+}
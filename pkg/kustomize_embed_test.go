@@ -0,0 +1,119 @@
+package kustomize_test
+
+import (
+	"context"
+	"embed"
+	"testing"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/union"
+
+	. "github.com/onsi/gomega"
+)
+
+//go:embed testdata/embedded
+var embeddedFS embed.FS
+
+func TestEmbeddedFileSystem(t *testing.T) {
+
+	t.Run("should render with a file injected over the embedded base", func(t *testing.T) {
+		g := NewWithT(t)
+
+		base, err := fs.NewFromIOFS(embeddedFS, "testdata/embedded")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		fsys, err := union.NewFs(base, union.WithOverride("/app/values.yaml", []byte("replicas: 3\n")))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: "/app"}},
+			kustomize.WithFileSystem(fsys),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+
+		names := []string{objects[0].GetName(), objects[1].GetName()}
+		g.Expect(names).To(ConsistOf("static", "settings"))
+
+		for _, obj := range objects {
+			if obj.GetName() == "settings" {
+				g.Expect(obj.Object).To(HaveKeyWithValue("data", HaveKeyWithValue("values.yaml", "replicas: 3\n")))
+			}
+		}
+	})
+
+	t.Run("should inject source values into the embedded base", func(t *testing.T) {
+		g := NewWithT(t)
+
+		base, err := fs.NewFromIOFS(embeddedFS, "testdata/embedded")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{
+				Path: "/app",
+				Values: func(_ context.Context) (map[string]string, error) {
+					return map[string]string{"replicas": "3"}, nil
+				},
+			}},
+			kustomize.WithFileSystem(base),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).ToNot(BeEmpty())
+
+		// The embedded base itself is never written to
+		g.Expect(base.Exists("/app/values.yaml")).To(BeFalse())
+	})
+
+	t.Run("should render each source from its own filesystem", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		base, err := fs.NewFromIOFS(embeddedFS, "testdata/embedded")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := kustomize.New([]kustomize.Source{
+			{
+				Path:       "/app",
+				FileSystem: base,
+				Values: func(_ context.Context) (map[string]string, error) {
+					return map[string]string{"replicas": "3"}, nil
+				},
+			},
+			{Path: dir},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources).To(HaveLen(2))
+
+		names := make([]string, 0)
+		for _, obj := range result.Objects() {
+			names = append(names, obj.GetName())
+		}
+
+		g.Expect(names).To(ConsistOf("static", "settings", "test-configmap", "test-pod"))
+	})
+
+	t.Run("should resolve paths relative to the embedded root", func(t *testing.T) {
+		g := NewWithT(t)
+
+		base, err := fs.NewFromIOFS(embeddedFS, "/testdata/embedded/")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(base.Exists("/app/kustomization.yaml")).To(BeTrue())
+		g.Expect(base.Exists("app/kustomization.yaml")).To(BeTrue())
+		g.Expect(base.IsDir("/")).To(BeTrue())
+
+		entries, err := base.ReadDir("/app")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(entries).To(ConsistOf("configmap.yaml", "kustomization.yaml"))
+	})
+}
@@ -1,19 +1,25 @@
 package kustomize
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"maps"
 	"path/filepath"
 	"slices"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/k8s-manifest-kit/engine/pkg/types"
+	"go.opentelemetry.io/otel/attribute"
 	goyaml "gopkg.in/yaml.v3"
 	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/api/resmap"
 	kresource "sigs.k8s.io/kustomize/api/resource"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -30,114 +36,514 @@ type (
 var (
 	// ErrPathMustBeDirectory is returned when a file path is provided instead of a directory.
 	ErrPathMustBeDirectory = errors.New("path must be a directory containing a kustomization file, got a file instead")
+
+	// ErrKustomizePanic is returned when the kustomize build panics.
+	ErrKustomizePanic = errors.New("kustomize build panicked")
+
+	// ErrRenderTimeout is returned when rendering a source exceeds the configured timeout.
+	ErrRenderTimeout = errors.New("kustomize render timed out")
+
+	// ErrValuesFileExists is returned when the values ConfigMap would shadow an existing file.
+	ErrValuesFileExists = errors.New("values file already exists in kustomization directory")
+
+	// ErrResourceLimitExceeded is returned when a source renders more resources, or more
+	// output, than allowed by WithMaxResources or WithMaxOutputBytes.
+	ErrResourceLimitExceeded = errors.New("resource limit exceeded")
+
+	// ErrOverrideConflict is returned when WithNamespaceOverride conflicts with the namespace
+	// set by the kustomization and WithReplaceOverrides is not enabled.
+	ErrOverrideConflict = errors.New("override conflicts with kustomization")
+
+	// ErrOriginTrackingRequired is returned when Source.OnlyFromPath is set but source
+	// tracking is disabled, or the kustomization does not record origins while
+	// WithManagedBuildMetadata is disabled.
+	ErrOriginTrackingRequired = errors.New("origin tracking required")
 )
 
-// Engine wraps a Kustomize kustomizer for rendering kustomization directories.
+// openAPISchema serializes the builds selecting an OpenAPI schema, kept by kustomize in
+// process-wide state, with all other builds.
+//
+//nolint:gochecknoglobals
+var openAPISchema sync.RWMutex
+
+// Engine renders kustomization directories. It is safe for concurrent use: configuration is
+// read-only after creation, and every run builds its own kustomizer and filesystem overlay.
 type Engine struct {
-	kustomizer *krusty.Kustomizer
-	fs         filesys.FileSystem
-	opts       *RendererOptions
+	fs           filesys.FileSystem
+	opts         *RendererOptions
+	pluginConfig *kustomizetypes.PluginConfig
+	patches      []*patchTransformer
+
+	// compat is the kustomize release set with WithCompatibilityMode, nil if none.
+	compat *kustomizeVersion
 }
 
 // newKustomizeEngine creates a new kustomize rendering engine.
-func newKustomizeEngine(fs filesys.FileSystem, opts *RendererOptions) *Engine {
+func newKustomizeEngine(
+	fs filesys.FileSystem,
+	opts *RendererOptions,
+	pluginConfig *kustomizetypes.PluginConfig,
+) *Engine {
 	return &Engine{
-		kustomizer: krusty.MakeKustomizer(&krusty.Options{
-			LoadRestrictions: opts.LoadRestrictions,
-			PluginConfig:     &kustomizetypes.PluginConfig{},
-		}),
-		fs:   fs,
-		opts: opts,
+		fs:           fs,
+		opts:         opts,
+		pluginConfig: pluginConfig,
 	}
 }
 
 // Run executes the kustomize build process for the given source and returns the rendered objects.
-func (e *Engine) Run(input Source, values map[string]string) ([]unstructured.Unstructured, error) {
-	restrictions := e.opts.LoadRestrictions
-	if input.LoadRestrictions != kustomizetypes.LoadRestrictionsUnknown {
-		restrictions = input.LoadRestrictions
+// The context is checked between phases; since the kustomize build itself is blocking,
+// it runs in a separate goroutine so that a cancelled context unblocks the caller immediately.
+// In that case the abandoned build finishes in the background and its result is discarded.
+//
+// If a render timeout is configured, it bounds the whole run, including filesystem
+// preparation and plugin transformers. If retries are configured, runs failing with a
+// transient error are retried, each attempt getting its own timeout.
+//
+// Values are injected as strings; use RunValues for typed values.
+func (e *Engine) Run(ctx context.Context, input Source, values map[string]string) ([]unstructured.Unstructured, error) {
+	typed := make(map[string]any, len(values))
+	for k, v := range values {
+		typed[k] = v
+	}
+
+	return e.RunValues(ctx, input, typed)
+}
+
+// RunValues is Run with typed values: nested maps, lists and numbers keep their types in the
+// values ConfigMap, like the values of Source.StructuredValues.
+func (e *Engine) RunValues(
+	ctx context.Context,
+	input Source,
+	values map[string]any,
+) ([]unstructured.Unstructured, error) {
+	result, warnings, err := e.runDetailed(ctx, input, values)
+	if err != nil {
+		return nil, err
+	}
+
+	takeOrigins(result)
+	takeValuesNames(result)
+
+	if err := e.handleWarnings(warnings); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// runDetailed is Run, returning the warnings detected in the kustomization instead of
+// passing them to the warning handler.
+func (e *Engine) runDetailed(
+	ctx context.Context,
+	input Source,
+	values map[string]any,
+) ([]unstructured.Unstructured, []Warning, error) {
+	run := e.runWithTimeout
+	if e.opts.RetryAttempts > 1 {
+		run = e.runWithRetry
+	}
+
+	// warnings are recorded once the attempts are done, so retries do not duplicate them
+	result, warnings, err := run(ctx, input, values)
+	e.recordWarnings(input.Path, warnings)
+
+	return result, warnings, err
+}
+
+// runWithTimeout is a single render attempt, bounded by the configured timeout.
+func (e *Engine) runWithTimeout(
+	ctx context.Context,
+	input Source,
+	values map[string]any,
+) ([]unstructured.Unstructured, []Warning, error) {
+	if e.opts.Timeout <= 0 {
+		return e.run(ctx, input, values)
+	}
+
+	start := time.Now()
+
+	runCtx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+
+	result, warnings, err := e.run(runCtx, input, values)
+	if err != nil && ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return nil, nil, fmt.Errorf(
+			"%w: path %q exceeded %s (gave up after %s): %w",
+			ErrRenderTimeout,
+			input.Path,
+			e.opts.Timeout,
+			time.Since(start).Round(time.Millisecond),
+			err,
+		)
+	}
+
+	return result, warnings, err
+}
+
+func (e *Engine) run(
+	ctx context.Context,
+	input Source,
+	values map[string]any,
+) ([]unstructured.Unstructured, []Warning, error) {
+	ctx, span := e.startSpan(ctx, SpanRun, input.Path)
+
+	result, warnings, err := e.runPhases(ctx, input, values)
+
+	span.SetAttributes(attribute.Int(AttributeObjectCount, len(result)))
+	endSpan(span, err)
+
+	return result, warnings, err
+}
+
+// runPhases performs the phases of a render attempt, each in its own span.
+func (e *Engine) runPhases(
+	ctx context.Context,
+	input Source,
+	values map[string]any,
+) ([]unstructured.Unstructured, []Warning, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("kustomize run for path %q aborted: %w", input.Path, err)
+	}
+
+	if err := e.checkOriginFilter(input); err != nil {
+		return nil, nil, err
+	}
+
+	restrictions := e.loadRestrictions(input)
+	sourceFs := e.fileSystem(input)
+
+	_, span := e.startSpan(ctx, SpanReadKustomization, input.Path)
+	kust, name, err := readKustomization(sourceFs, input.Path)
+	endSpan(span, err)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read kustomization from path %q: %w", input.Path, err)
+	}
+
+	if err := e.checkOriginRecorded(input, kust); err != nil {
+		return nil, nil, err
+	}
+
+	// kustomize reports reference cycles with a confusing error, if at all
+	if err := checkCycles(sourceFs, input.Path, e.opts.MaxDepth); err != nil {
+		return nil, nil, err
+	}
+
+	if e.opts.Hermetic {
+		root, err := checkHermetic(sourceFs, input.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if sourceFs, err = hermeticFileSystem(sourceFs, root); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// sortOptions in the kustomization take precedence over the reorder option; leaving the
+	// option unspecified then keeps kustomize from logging that both are set.
+	reorder := e.opts.Reorder
+	if kust.SortOptions != nil {
+		reorder = krusty.ReorderOptionUnspecified
 	}
 
 	// Create kustomizer with appropriate restrictions
 	kustomizer := krusty.MakeKustomizer(&krusty.Options{
+		Reorder:          reorder,
 		LoadRestrictions: restrictions,
-		PluginConfig:     &kustomizetypes.PluginConfig{},
+		PluginConfig:     e.pluginConfig,
 	})
 
-	kust, name, err := readKustomization(e.fs, input.Path)
+	if e.pluginConfig.HelmConfig.Enabled {
+		if err := checkHelmChartHome(input.Path, kust, restrictions); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Check for deprecated fields; the caller hands them to the warning handler
+	warnings := e.checkWarnings(input.Path, kust)
+
+	// Prepare filesystem with overlays if needed
+	_, span = e.startSpan(ctx, SpanPrepareFilesystem, input.Path)
+	fs, addedOriginAnnotations, err := e.prepareFilesystem(sourceFs, input.Path, kust, name, values)
+	endSpan(span, err)
+
 	if err != nil {
-		return nil, fmt.Errorf("unable to read kustomization from path %q: %w", input.Path, err)
+		return nil, nil, err
 	}
 
-	// Check for deprecated fields and handle warnings
-	if warnings := kust.CheckDeprecatedFields(); warnings != nil && len(*warnings) > 0 {
-		handler := e.opts.WarningHandler
-		if handler == nil {
-			handler = WarningLog(os.Stderr)
-		}
+	buildCtx, span := e.startSpan(ctx, SpanBuild, input.Path)
+	resMap, err := e.build(buildCtx, kustomizer, fs, input.Path)
 
-		if err := handler(*warnings); err != nil {
-			return nil, err
-		}
+	if err == nil {
+		span.SetAttributes(attribute.Int(AttributeObjectCount, resMap.Size()))
 	}
 
-	// Prepare filesystem with overlays if needed
-	fs, addedOriginAnnotations, err := e.prepareFilesystem(input.Path, kust, name, values)
+	endSpan(span, err)
+
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("failed to run kustomize for path %q: %w", input.Path, err)
 	}
 
-	// Run kustomize with stderr suppressed to avoid duplicate warnings
-	var resMap resmap.ResMap
-	err = utilio.SuppressStderr(func() error {
-		var runErr error
-		resMap, runErr = kustomizer.Run(fs, input.Path)
-		if runErr != nil {
-			return fmt.Errorf("kustomizer run failed: %w", runErr)
-		}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("kustomize run for path %q aborted: %w", input.Path, err)
+	}
+
+	if err := e.checkValuesPatchTarget(resMap, input.Path); err != nil {
+		return nil, nil, err
+	}
+
+	pluginsCtx, span := e.startSpan(ctx, SpanPlugins, input.Path)
+	err = e.applyPlugins(pluginsCtx, resMap, input.Path)
+	span.SetAttributes(
+		attribute.Int(AttributePluginCount, len(e.opts.Plugins)),
+		attribute.Int(AttributeObjectCount, resMap.Size()),
+	)
+	endSpan(span, err)
 
-		return nil
-	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to run kustomize for path %q: %w", input.Path, err)
+		return nil, nil, err
 	}
 
-	for _, t := range e.opts.Plugins {
+	for i, t := range e.patches {
 		if err := t.Transform(resMap); err != nil {
-			return nil, fmt.Errorf("failed to apply kustomize plugin transformer for path %q: %w", input.Path, err)
+			return nil, nil, fmt.Errorf("failed to apply patch %d for path %q: %w", i, input.Path, err)
+		}
+	}
+
+	if input.OnlyFromPath != "" {
+		if err := filterByOrigin(resMap, input.OnlyFromPath); err != nil {
+			return nil, nil, fmt.Errorf("failed to filter resources for path %q: %w", input.Path, err)
+		}
+	}
+
+	for i, filter := range e.opts.ResMapFilters {
+		if err := filter(resMap); err != nil {
+			return nil, nil, fmt.Errorf("failed to apply resmap filter %d for path %q: %w", i, input.Path, err)
 		}
 	}
 
 	// Convert ResMap to unstructured objects
+	_, span = e.startSpan(ctx, SpanConvertResources, input.Path)
 	result, err := e.convertResources(resMap, input.Path)
+	span.SetAttributes(attribute.Int(AttributeObjectCount, len(result)))
+	endSpan(span, err)
+
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if err := e.addValuesChecksum(result, values); err != nil {
+		return nil, nil, fmt.Errorf("failed to add values checksum for path %q: %w", input.Path, err)
 	}
 
 	// Remove config.kubernetes.io/origin if we added OriginAnnotations ourselves
-	if addedOriginAnnotations {
+	if addedOriginAnnotations && !e.opts.KeepOriginAnnotations {
 		for i := range result {
 			removeOriginAnnotation(&result[i])
 		}
 	}
 
-	return result, nil
+	return result, warnings, nil
+}
+
+// fileSystem returns the filesystem of a source: its own if set, the engine's otherwise.
+func (e *Engine) fileSystem(input Source) filesys.FileSystem {
+	switch {
+	case input.FileSystem == nil:
+		return e.fs
+	case e.opts.Hermetic:
+		// New checked that the filesystems of the sources can deny symlinks; others, only
+		// passed to ComputeCacheKey, do not need to
+		if confined, err := hermeticFileSystem(input.FileSystem, ""); err == nil {
+			return confined
+		}
+
+		return input.FileSystem
+	default:
+		return input.FileSystem
+	}
+}
+
+// loadRestrictions returns the load restrictions of a source: its own if set, the
+// renderer-wide default otherwise, and always LoadRestrictionsRootOnly in hermetic mode.
+func (e *Engine) loadRestrictions(input Source) kustomizetypes.LoadRestrictions {
+	switch {
+	case e.opts.Hermetic:
+		return kustomizetypes.LoadRestrictionsRootOnly
+	case input.LoadRestrictions != kustomizetypes.LoadRestrictionsUnknown:
+		return input.LoadRestrictions
+	default:
+		return e.opts.LoadRestrictions
+	}
+}
+
+// build runs the kustomizer in a separate goroutine and waits for either its result
+// or the cancellation of ctx. Panics raised by kustomize are converted into errors
+// since they can no longer propagate to the caller's goroutine.
+func (e *Engine) build(
+	ctx context.Context,
+	kustomizer *krusty.Kustomizer,
+	fs filesys.FileSystem,
+	path string,
+) (resmap.ResMap, error) {
+	type buildResult struct {
+		resMap resmap.ResMap
+		err    error
+	}
+
+	done := make(chan buildResult, 1)
+
+	go func() {
+		var res buildResult
+
+		defer func() {
+			if r := recover(); r != nil {
+				res = buildResult{err: fmt.Errorf("%w: %v", ErrKustomizePanic, r)}
+			}
+
+			done <- res
+		}()
+
+		// kustomize reads the plugin home from the environment, and only when non-builtin
+		// plugins are allowed
+		if e.pluginConfig.PluginRestrictions == kustomizetypes.PluginRestrictionsNone {
+			defer pluginHomeEnv.acquire(e.opts.PluginHome)()
+		}
+
+		res.resMap, res.err = e.runKustomizer(kustomizer, fs, path)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-done:
+		return res.resMap, res.err
+	}
+}
+
+// runKustomizer runs a kustomize build, isolating it from the process-wide state kustomize
+// relies on. Stderr, where kustomize prints deprecation warnings the renderer reports on
+// its own, is only redirected when the tree may print something: the redirect swaps
+// os.Stderr for the whole process. Builds selecting an OpenAPI schema, which kustomize
+// keeps globally, run alone and reset the schema afterwards so that it does not leak into
+// other builds.
+func (e *Engine) runKustomizer(
+	kustomizer *krusty.Kustomizer,
+	fs filesys.FileSystem,
+	path string,
+) (resmap.ResMap, error) {
+	info := inspectTree(fs, path)
+
+	if info.openAPI {
+		openAPISchema.Lock()
+		defer openAPISchema.Unlock()
+		defer openapi.ResetOpenAPI()
+	} else {
+		openAPISchema.RLock()
+		defer openAPISchema.RUnlock()
+	}
+
+	var resMap resmap.ResMap
+
+	run := func() error {
+		var err error
+
+		resMap, err = kustomizer.Run(fs, path)
+		if err != nil {
+			return fmt.Errorf("kustomizer run failed: %w", err)
+		}
+
+		return nil
+	}
+
+	// exec plugins and KRM functions write their own diagnostics to stderr
+	if info.deprecated || info.unknown ||
+		e.pluginConfig.PluginRestrictions == kustomizetypes.PluginRestrictionsNone {
+		return resMap, utilio.SuppressStderr(run)
+	}
+
+	return resMap, run()
+}
+
+// checkWarnings checks the kustomization for deprecated fields. The warnings are recorded by
+// recordWarnings and passed to the handler by handleWarnings, once the whole render is done.
+// Nothing is checked when the warning scan is disabled.
+func (e *Engine) checkWarnings(inputPath string, kust *kustomizetypes.Kustomization) []Warning {
+	if e.opts.DisableWarningScan {
+		return nil
+	}
+
+	messages := kust.CheckDeprecatedFields()
+	if messages == nil || len(*messages) == 0 {
+		return nil
+	}
+
+	return newWarnings(inputPath, *messages)
+}
+
+// recordWarnings stores the messages of the warnings of a render in the configured collector.
+func (e *Engine) recordWarnings(inputPath string, warnings []Warning) {
+	if e.opts.WarningCollector == nil || len(warnings) == 0 {
+		return
+	}
+
+	messages := make([]string, 0, len(warnings))
+	for _, w := range warnings {
+		messages = append(messages, w.Message)
+	}
+
+	e.opts.WarningCollector.Record(inputPath, messages)
+}
+
+// handleWarnings passes the warnings of a render to the configured handler, returning its
+// error. The handler is not called when there are no warnings.
+func (e *Engine) handleWarnings(warnings []Warning) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	handler := e.opts.StructuredWarningHandler
+	if handler == nil {
+		switch {
+		case e.opts.WarningHandler != nil:
+			handler = AdaptWarningHandler(e.opts.WarningHandler)
+		case e.opts.Logger != nil:
+			handler = WarningLogger(e.opts.Logger)
+		default:
+			handler = AdaptWarningHandler(WarningLog(utilio.Stderr()))
+		}
+	}
+
+	return handler(warnings)
 }
 
-// prepareFilesystem creates a union filesystem with overlays if needed for source annotations or values.
+// logger returns the logger set with WithLogger, or one discarding every record.
+func (e *Engine) logger() *slog.Logger {
+	if e.opts.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+
+	return e.opts.Logger
+}
+
+// prepareFilesystem creates a union filesystem over base with overlays if needed for a
+// modified kustomization or values.
 // Returns the filesystem to use, whether origin annotations were added, and any error.
 func (e *Engine) prepareFilesystem(
+	base filesys.FileSystem,
 	inputPath string,
 	kust *kustomizetypes.Kustomization,
 	kustName string,
-	values map[string]string,
+	values map[string]any,
 ) (filesys.FileSystem, bool, error) {
-	// If neither source annotations nor values are needed, use the base filesystem
-	if !e.opts.SourceAnnotations && len(values) == 0 {
-		return e.fs, false, nil
+	// If neither the kustomization nor values are modified, use the base filesystem
+	if !e.modifiesKustomization() && len(values) == 0 {
+		return base, false, nil
 	}
 
-	p, f, err := e.fs.CleanedAbs(inputPath)
+	p, f, err := base.CleanedAbs(inputPath)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to resolve path %q: %w", inputPath, err)
 	}
@@ -146,33 +552,55 @@ func (e *Engine) prepareFilesystem(
 	}
 
 	var opts []union.Option
-	addedOriginAnnotations := false
 
-	// Add modified kustomization if source annotations are enabled
-	if e.opts.SourceAnnotations {
-		if !slices.Contains(kust.BuildMetadata, kustomizetypes.OriginAnnotations) {
-			kust.BuildMetadata = append(kust.BuildMetadata, kustomizetypes.OriginAnnotations)
-			addedOriginAnnotations = true
+	addedOriginAnnotations, modified, err := e.modifyKustomization(kust)
+	if err != nil {
+		return nil, false, fmt.Errorf("path %q: %w", inputPath, err)
+	}
 
-			data, err := goyaml.Marshal(kust)
-			if err != nil {
-				return nil, false, fmt.Errorf("failed to marshal kustomization: %w", err)
-			}
+	if e.compat != nil {
+		nested, err := e.compatibilityOverrides(base, p.String())
+		if err != nil {
+			return nil, false, err
+		}
+
+		opts = append(opts, nested...)
+	}
 
-			opts = append(opts, union.WithOverride(filepath.Join(p.String(), kustName), data))
+	// Add modified kustomization if build metadata or generator options were added
+	if modified {
+		data, err := goyaml.Marshal(kust)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to marshal kustomization: %w", err)
 		}
+
+		opts = append(opts, union.WithOverride(filepath.Join(p.String(), kustName), data))
 	}
 
 	// Add values ConfigMap if provided
 	if len(values) > 0 {
-		valuesContent, err := createValuesConfigMapYAML(values)
+		valuesPath := filepath.Join(p.String(), e.opts.ValuesFileName)
+		if base.Exists(valuesPath) {
+			return nil, false, fmt.Errorf(
+				"%w: %q (use WithValuesConfigMap to choose a different file name)",
+				ErrValuesFileExists,
+				valuesPath,
+			)
+		}
+
+		createValues := createValuesConfigMapYAML
+		if e.opts.ValuesAsSecret {
+			createValues = createValuesSecretYAML
+		}
+
+		valuesContent, err := createValues(e.opts.ValuesConfigMapName, values)
 		if err != nil {
-			return nil, false, fmt.Errorf("failed to create values ConfigMap: %w", err)
+			return nil, false, fmt.Errorf("failed to create values object: %w", err)
 		}
-		opts = append(opts, union.WithOverride(filepath.Join(p.String(), "values.yaml"), valuesContent))
+		opts = append(opts, union.WithOverride(valuesPath, valuesContent))
 	}
 
-	fsys, err := union.NewFs(e.fs, opts...)
+	fsys, err := union.NewFs(base, opts...)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to create union filesystem: %w", err)
 	}
@@ -180,31 +608,266 @@ func (e *Engine) prepareFilesystem(
 	return fsys, addedOriginAnnotations, nil
 }
 
-// addSourceAnnotationsToObject adds source tracking annotations to a single unstructured object.
-// Only modifies the object if source annotations are enabled in engine options.
-// Removes config.kubernetes.io/origin annotation if addedOriginAnnotations is true.
-func (e *Engine) addSourceAnnotationsToObject(
+// modifyKustomization applies every change the renderer options make to a kustomization:
+// build metadata, generator options, overrides, the values patch and the label adaptations
+// of the compatibility mode. It reports whether origin annotations were added and whether
+// the kustomization was modified at all.
+func (e *Engine) modifyKustomization(kust *kustomizetypes.Kustomization) (bool, bool, error) {
+	addedOriginAnnotations, modified := e.applyBuildMetadata(kust)
+	modified = e.applyGeneratorOptions(kust) || modified
+
+	overridden, err := e.applyOverrides(kust)
+	if err != nil {
+		return false, false, err
+	}
+
+	modified = overridden || modified
+
+	patched, err := e.applyValuesPatch(kust)
+	if err != nil {
+		return false, false, err
+	}
+
+	modified = patched || modified
+
+	if e.compat != nil {
+		adapted, err := e.compat.adaptLabels(kust)
+		if err != nil {
+			return false, false, err
+		}
+
+		modified = adapted || modified
+	}
+
+	return addedOriginAnnotations, modified, nil
+}
+
+// applyBuildMetadata adds the buildMetadata options required by the renderer options to the
+// kustomization. It reports whether origin annotations were added and whether the
+// kustomization was modified at all.
+func (e *Engine) applyBuildMetadata(kust *kustomizetypes.Kustomization) (bool, bool) {
+	// Origin annotations are only needed to compute the source file, so the origin
+	// annotation is removed from the output unless the kustomization asked for it itself.
+	// Transformer annotations and the managed-by label are requested as output and kept.
+	addedOriginAnnotations := e.managesOriginAnnotations() &&
+		addBuildMetadata(kust, kustomizetypes.OriginAnnotations)
+	addedTransformerAnnotations := e.opts.TransformerAnnotations &&
+		addBuildMetadata(kust, kustomizetypes.TransformerAnnotations)
+	addedManagedByLabel := e.opts.ManagedByLabel && addBuildMetadata(kust, kustomizetypes.ManagedByLabelOption)
+
+	return addedOriginAnnotations, addedOriginAnnotations || addedTransformerAnnotations || addedManagedByLabel
+}
+
+// applyGeneratorOptions adds the generatorOptions required by the renderer options to the
+// kustomization, reporting whether it was modified.
+func (e *Engine) applyGeneratorOptions(kust *kustomizetypes.Kustomization) bool {
+	if !e.opts.DisableNameSuffixHash {
+		return false
+	}
+
+	if kust.GeneratorOptions == nil {
+		kust.GeneratorOptions = &kustomizetypes.GeneratorOptions{}
+	}
+
+	if kust.GeneratorOptions.DisableNameSuffixHash {
+		return false
+	}
+
+	kust.GeneratorOptions.DisableNameSuffixHash = true
+
+	return true
+}
+
+// applyOverrides applies the namespace and name prefix/suffix overrides to the kustomization,
+// reporting whether it was modified. Unless ReplaceOverrides is set, prefixes and suffixes are
+// combined with those of the kustomization, the override being outermost like in an overlay,
+// and a namespace override must not conflict with the namespace of the kustomization.
+func (e *Engine) applyOverrides(kust *kustomizetypes.Kustomization) (bool, error) {
+	modified := false
+
+	if ns := e.opts.NamespaceOverride; ns != "" && ns != kust.Namespace {
+		if kust.Namespace != "" && !e.opts.ReplaceOverrides {
+			return false, fmt.Errorf(
+				"%w: namespace override %q differs from namespace %q (use WithReplaceOverrides to replace it)",
+				ErrOverrideConflict,
+				ns,
+				kust.Namespace,
+			)
+		}
+
+		kust.Namespace = ns
+		modified = true
+	}
+
+	if prefix := e.opts.NamePrefix; prefix != "" {
+		if !e.opts.ReplaceOverrides {
+			prefix += kust.NamePrefix
+		}
+
+		modified = modified || prefix != kust.NamePrefix
+		kust.NamePrefix = prefix
+	}
+
+	if suffix := e.opts.NameSuffix; suffix != "" {
+		if !e.opts.ReplaceOverrides {
+			suffix = kust.NameSuffix + suffix
+		}
+
+		modified = modified || suffix != kust.NameSuffix
+		kust.NameSuffix = suffix
+	}
+
+	return modified, nil
+}
+
+// modifiesKustomization reports whether any option requires build metadata, generator
+// options or overrides to be added to the kustomization.
+func (e *Engine) modifiesKustomization() bool {
+	return e.managesOriginAnnotations() || e.opts.TransformerAnnotations || e.opts.ManagedByLabel ||
+		e.opts.DisableNameSuffixHash || e.opts.NamespaceOverride != "" || e.opts.NamePrefix != "" ||
+		e.opts.NameSuffix != "" || len(e.opts.ValuesPatch) > 0 || e.compat != nil
+}
+
+// addBuildMetadata adds a buildMetadata option to the kustomization, reporting whether it
+// was added (false if the kustomization already declares it).
+func addBuildMetadata(kust *kustomizetypes.Kustomization, option string) bool {
+	if slices.Contains(kust.BuildMetadata, option) {
+		return false
+	}
+
+	kust.BuildMetadata = append(kust.BuildMetadata, option)
+
+	return true
+}
+
+// addSourceInfoToObject adds source tracking annotations and/or labels to a single
+// unstructured object. Only modifies the object if source annotations or source labels are
+// enabled in engine options. Label values are sanitized with sanitizeLabelValue.
+func (e *Engine) addSourceInfoToObject(
 	obj *unstructured.Unstructured,
 	inputPath string,
 	res resource,
 ) {
-	if !e.opts.SourceAnnotations {
+	if !e.tracksSource() {
 		return
 	}
 
-	annotations := obj.GetAnnotations()
-	if annotations == nil {
-		annotations = make(map[string]string)
+	keys := e.opts.SourceAnnotationConfig.keys()
+	info := map[string]string{
+		keys.Type: e.opts.SourceType,
+		keys.Path: inputPath,
+	}
+
+	if origin, err := res.GetOrigin(); err == nil && origin != nil && !e.isValuesSecretOrigin(origin.Path) {
+		info[keys.File] = origin.Path
+	}
+
+	if e.opts.SourceAnnotations {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		maps.Copy(annotations, e.opts.SourceAnnotationConfig.extra())
+		maps.Copy(annotations, info)
+		obj.SetAnnotations(annotations)
+	}
+
+	if e.opts.SourceInfoAsLabels {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+
+		for k, v := range info {
+			labels[k] = sanitizeLabelValue(v)
+		}
+
+		obj.SetLabels(labels)
+	}
+}
+
+// checkOriginFilter fails if the source filters by origin while source tracking is disabled.
+func (e *Engine) checkOriginFilter(input Source) error {
+	if input.OnlyFromPath == "" || e.tracksSource() {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: path %q filters by origin %q; enable WithSourceAnnotations or WithSourceInfoAsLabels",
+		ErrOriginTrackingRequired,
+		input.Path,
+		input.OnlyFromPath,
+	)
+}
+
+// checkOriginRecorded fails if the source filters by origin while the kustomization does not
+// record origins and WithManagedBuildMetadata is disabled: every object would be filtered out.
+func (e *Engine) checkOriginRecorded(input Source, kust *kustomizetypes.Kustomization) error {
+	if input.OnlyFromPath == "" || e.managesOriginAnnotations() ||
+		slices.Contains(kust.BuildMetadata, kustomizetypes.OriginAnnotations) {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: path %q filters by origin %q; enable WithManagedBuildMetadata or add %s to its buildMetadata",
+		ErrOriginTrackingRequired,
+		input.Path,
+		input.OnlyFromPath,
+		kustomizetypes.OriginAnnotations,
+	)
+}
+
+// filterByOrigin removes the resources of resMap not originating from path: resources are
+// kept when their origin file, or the kustomization generating them, lies at or below path.
+// Resources without origin are removed.
+func filterByOrigin(resMap resMap, path string) error {
+	path = filepath.Clean(path)
+
+	for _, res := range resMap.Resources() {
+		origin, err := res.GetOrigin()
+		if err != nil {
+			return fmt.Errorf("unable to read origin of resource %s: %w", res.CurId(), err)
+		}
+
+		if origin != nil && (originWithin(origin.Path, path) || originWithin(origin.ConfiguredIn, path)) {
+			continue
+		}
+
+		if err := resMap.Remove(res.CurId()); err != nil {
+			return fmt.Errorf("unable to remove resource %s: %w", res.CurId(), err)
+		}
 	}
 
-	annotations[types.AnnotationSourceType] = rendererType
-	annotations[types.AnnotationSourcePath] = inputPath
+	return nil
+}
 
-	if origin, err := res.GetOrigin(); err == nil && origin != nil {
-		annotations[types.AnnotationSourceFile] = origin.Path
+// originWithin reports whether the origin path is path itself or lies below it.
+func originWithin(originPath string, path string) bool {
+	if originPath == "" {
+		return false
 	}
 
-	obj.SetAnnotations(annotations)
+	rel, err := filepath.Rel(path, filepath.Clean(originPath))
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// tracksSource reports whether source information is added to rendered objects.
+func (e *Engine) tracksSource() bool {
+	return e.opts.SourceAnnotations || e.opts.SourceInfoAsLabels
+}
+
+// managesOriginAnnotations reports whether originAnnotations are added to the buildMetadata
+// of kustomizations for source tracking.
+func (e *Engine) managesOriginAnnotations() bool {
+	return e.tracksSource() && !e.opts.DisableManagedBuildMetadata
+}
+
+// isValuesSecretOrigin reports whether the origin path refers to the injected values Secret,
+// whose location must not leak into source annotations.
+func (e *Engine) isValuesSecretOrigin(originPath string) bool {
+	return e.opts.ValuesAsSecret && filepath.Clean(originPath) == filepath.Clean(e.opts.ValuesFileName)
 }
 
 // removeOriginAnnotation removes the config.kubernetes.io/origin annotation from an object.
@@ -218,11 +881,16 @@ func removeOriginAnnotation(obj *unstructured.Unstructured) {
 }
 
 // convertResources converts a Kustomize ResMap to a slice of unstructured objects.
-// Adds source annotations to each object if enabled.
+// Adds source annotations and labels to each object if enabled. The resource limits are
+// enforced before any conversion work.
 func (e *Engine) convertResources(
 	resMap resMap,
 	inputPath string,
 ) ([]unstructured.Unstructured, error) {
+	if err := e.checkLimits(resMap, inputPath); err != nil {
+		return nil, err
+	}
+
 	result := make([]unstructured.Unstructured, resMap.Size())
 
 	for i, res := range resMap.Resources() {
@@ -237,8 +905,47 @@ func (e *Engine) convertResources(
 			return nil, fmt.Errorf("failed to convert map to unstructured for resource %s: %w", res.CurId(), err)
 		}
 
-		e.addSourceAnnotationsToObject(&result[i], inputPath, res)
+		e.addSourceInfoToObject(&result[i], inputPath, res)
+		e.recordOrigin(&result[i], res)
 	}
 
 	return result, nil
 }
+
+// checkLimits fails if resMap exceeds the configured resource count or output size.
+func (e *Engine) checkLimits(resMap resMap, inputPath string) error {
+	if e.opts.MaxResources > 0 && resMap.Size() > e.opts.MaxResources {
+		return fmt.Errorf(
+			"%w: path %q rendered %d resources, exceeding the limit of %d",
+			ErrResourceLimitExceeded,
+			inputPath,
+			resMap.Size(),
+			e.opts.MaxResources,
+		)
+	}
+
+	if e.opts.MaxOutputBytes <= 0 {
+		return nil
+	}
+
+	size := 0
+
+	for _, res := range resMap.Resources() {
+		data, err := res.AsYAML()
+		if err != nil {
+			return fmt.Errorf("failed to convert resource %s to YAML: %w", res.CurId(), err)
+		}
+
+		size += len(data)
+		if size > e.opts.MaxOutputBytes {
+			return fmt.Errorf(
+				"%w: path %q rendered more than %d bytes of output",
+				ErrResourceLimitExceeded,
+				inputPath,
+				e.opts.MaxOutputBytes,
+			)
+		}
+	}
+
+	return nil
+}
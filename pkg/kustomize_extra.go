@@ -0,0 +1,75 @@
+package kustomize
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// ErrInvalidExtraResource is returned by New when a manifest passed to WithExtraResources
+// cannot be parsed or lacks apiVersion, kind or metadata.name.
+var ErrInvalidExtraResource = errors.New("invalid extra resource")
+
+// extraResourceBufferSize is the buffer size used to detect whether a manifest is YAML or JSON.
+const extraResourceBufferSize = 4096
+
+// parseExtraResources parses the documents of the given manifests, in order. Empty
+// documents are skipped. Integers are decoded as int64, like in rendered objects, so that
+// both can be compared.
+func parseExtraResources(manifests [][]byte) ([]unstructured.Unstructured, error) {
+	objects := make([]unstructured.Unstructured, 0, len(manifests))
+
+	for i, manifest := range manifests {
+		decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), extraResourceBufferSize)
+
+		for doc := 0; ; doc++ {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				return nil, fmt.Errorf("%w: manifest %d, document %d: %w", ErrInvalidExtraResource, i, doc, err)
+			}
+
+			var content map[string]any
+			if err := utiljson.Unmarshal(raw, &content); err != nil {
+				return nil, fmt.Errorf("%w: manifest %d, document %d: %w", ErrInvalidExtraResource, i, doc, err)
+			}
+
+			if len(content) == 0 {
+				continue
+			}
+
+			obj := unstructured.Unstructured{Object: content}
+			if obj.GetAPIVersion() == "" || obj.GetKind() == "" || obj.GetName() == "" {
+				return nil, fmt.Errorf(
+					"%w: manifest %d, document %d: apiVersion, kind and metadata.name are required",
+					ErrInvalidExtraResource,
+					i,
+					doc,
+				)
+			}
+
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, nil
+}
+
+// copyObjects returns deep copies of objects, so that callers can mutate the result.
+func copyObjects(objects []unstructured.Unstructured) []unstructured.Unstructured {
+	result := make([]unstructured.Unstructured, len(objects))
+	for i := range objects {
+		result[i] = *objects[i].DeepCopy()
+	}
+
+	return result
+}
@@ -0,0 +1,121 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+const networkPolicy = `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: default-deny
+spec:
+  podSelector: {}
+`
+
+func TestExtraResources(t *testing.T) {
+	t.Run("should append extra resources after the sources", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithExtraResources(
+				[]byte(networkPolicy),
+				[]byte("---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: a\n---\n"+
+					`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "b"}}`),
+			),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(5))
+		g.Expect(objects[2].GetKind()).To(Equal("NetworkPolicy"))
+		g.Expect(objects[3].GetName()).To(Equal("a"))
+		g.Expect(objects[4].GetName()).To(Equal("b"))
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources[0].Objects).To(HaveLen(2))
+		g.Expect(result.Extra).To(HaveLen(3))
+	})
+
+	t.Run("should return independent copies on every render", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithExtraResources([]byte(networkPolicy)))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		result.Extra[0].SetName("changed")
+
+		result, err = renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Extra[0].GetName()).To(Equal("default-deny"))
+	})
+
+	t.Run("should check extra resources for conflicts", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithConflictCheck(true),
+			kustomize.WithExtraResources([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test-configmap\n")),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrResourceConflict))
+		g.Expect(err.Error()).To(ContainSubstring("extra resources"))
+	})
+
+	t.Run("should not report extra resources identical to rendered objects", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		deployment := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 3\n"
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- deployment.yaml\n")
+		writeFile(t, dir, "deployment.yaml", deployment)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithConflictCheck(true),
+			kustomize.WithExtraResources([]byte(deployment)),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Extra).To(HaveLen(1))
+
+		replicas, found, err := unstructured.NestedInt64(result.Extra[0].Object, "spec", "replicas")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(found).To(BeTrue())
+		g.Expect(replicas).To(Equal(int64(3)))
+	})
+
+	t.Run("should reject documents without identity", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		for _, manifest := range []string{
+			"kind: ConfigMap\nmetadata:\n  name: a\n",
+			"apiVersion: v1\nmetadata:\n  name: a\n",
+			"apiVersion: v1\nkind: ConfigMap\n",
+			"apiVersion: v1\nkind: [\n",
+		} {
+			_, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithExtraResources([]byte(manifest)))
+			g.Expect(err).To(MatchError(kustomize.ErrInvalidExtraResource), manifest)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package kustomize
+
+import (
+	"context"
+	"slices"
+
+	"github.com/k8s-manifest-kit/engine/pkg/types"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// FilterByGVK returns a filter keeping only objects matching one of the given GVKs, e.g. to
+// extract the NetworkPolicies of a rendered application. Group and kind must match exactly;
+// an empty version matches every version of the kind. Surviving objects keep their order.
+//
+// Example:
+//
+//	kustomize.WithFilter(kustomize.FilterByGVK(
+//	    schema.GroupVersionKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"},
+//	))
+func FilterByGVK(gvks ...schema.GroupVersionKind) types.Filter {
+	return func(_ context.Context, obj unstructured.Unstructured) (bool, error) {
+		return matchesGVK(obj.GroupVersionKind(), gvks), nil
+	}
+}
+
+// ExcludeByGVK returns a filter dropping objects matching one of the given GVKs, e.g. to
+// remove Secrets before handing the output to a less-trusted stage. GVKs are matched as
+// in FilterByGVK.
+func ExcludeByGVK(gvks ...schema.GroupVersionKind) types.Filter {
+	return func(_ context.Context, obj unstructured.Unstructured) (bool, error) {
+		return !matchesGVK(obj.GroupVersionKind(), gvks), nil
+	}
+}
+
+// FilterByLabelSelector returns a filter keeping only objects whose labels match the selector.
+// A nil selector keeps every object.
+//
+// Example:
+//
+//	sel, _ := labels.Parse("app.kubernetes.io/component=frontend")
+//	kustomize.WithFilter(kustomize.FilterByLabelSelector(sel))
+func FilterByLabelSelector(sel labels.Selector) types.Filter {
+	return func(_ context.Context, obj unstructured.Unstructured) (bool, error) {
+		if sel == nil {
+			return true, nil
+		}
+
+		return sel.Matches(labels.Set(obj.GetLabels())), nil
+	}
+}
+
+func matchesGVK(gvk schema.GroupVersionKind, candidates []schema.GroupVersionKind) bool {
+	return slices.ContainsFunc(candidates, func(c schema.GroupVersionKind) bool {
+		return c.Group == gvk.Group && c.Kind == gvk.Kind && (c.Version == "" || c.Version == gvk.Version)
+	})
+}
@@ -0,0 +1,115 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+const filterResources = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+  labels:
+    tier: backend
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-all
+  labels:
+    tier: backend
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  labels:
+    tier: frontend
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-dns
+`
+
+func renderFiltered(t *testing.T, opts ...kustomize.RendererOption) []string {
+	t.Helper()
+
+	g := NewWithT(t)
+
+	objects, err := kustomize.RenderBytes(t.Context(), map[string][]byte{
+		"kustomization.yaml": []byte("sortOptions:\n  order: fifo\nresources:\n- resources.yaml\n"),
+		"resources.yaml":     []byte(filterResources),
+	}, opts...)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	names := make([]string, len(objects))
+	for i := range objects {
+		names[i] = objects[i].GetName()
+	}
+
+	return names
+}
+
+func TestFilterByGVK(t *testing.T) {
+	t.Run("should keep matching kinds in order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		names := renderFiltered(t, kustomize.WithFilter(kustomize.FilterByGVK(
+			schema.GroupVersionKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"},
+		)))
+		g.Expect(names).To(Equal([]string{"deny-all", "allow-dns"}))
+	})
+
+	t.Run("should match versions exactly when set", func(t *testing.T) {
+		g := NewWithT(t)
+
+		filter := kustomize.FilterByGVK(schema.GroupVersionKind{Version: "v1", Kind: "Secret"})
+
+		keep, err := filter(t.Context(), makeObject("v1", "Secret", "s"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(keep).To(BeTrue())
+
+		keep, err = filter(t.Context(), makeObject("v2", "Secret", "s"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(keep).To(BeFalse())
+	})
+}
+
+func TestExcludeByGVK(t *testing.T) {
+	t.Run("should drop matching kinds", func(t *testing.T) {
+		g := NewWithT(t)
+
+		names := renderFiltered(t, kustomize.WithFilter(kustomize.ExcludeByGVK(
+			schema.GroupVersionKind{Kind: "Secret"},
+		)))
+		g.Expect(names).To(Equal([]string{"deny-all", "config", "allow-dns"}))
+	})
+}
+
+func TestFilterByLabelSelector(t *testing.T) {
+	t.Run("should keep objects matching the selector", func(t *testing.T) {
+		g := NewWithT(t)
+
+		names := renderFiltered(t, kustomize.WithFilter(kustomize.FilterByLabelSelector(
+			labels.SelectorFromSet(labels.Set{"tier": "backend"}),
+		)))
+		g.Expect(names).To(Equal([]string{"credentials", "deny-all"}))
+	})
+
+	t.Run("should keep everything with a nil selector", func(t *testing.T) {
+		g := NewWithT(t)
+
+		keep, err := kustomize.FilterByLabelSelector(nil)(t.Context(), unstructured.Unstructured{})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(keep).To(BeTrue())
+	})
+}
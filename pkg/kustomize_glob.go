@@ -0,0 +1,94 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+var (
+	// ErrInvalidGlob is returned when a Source glob is malformed, combined with a Path, or
+	// passed where a single kustomization is expected.
+	ErrInvalidGlob = errors.New("invalid source glob")
+
+	// ErrGlobNoMatch is returned when a Source glob matches no kustomization directory.
+	ErrGlobNoMatch = errors.New("source glob matched no kustomization")
+)
+
+// expandSources replaces every glob Source by one Source per matched kustomization
+// directory, in lexical order. Globs are matched against the source's own filesystem if
+// set, fsys otherwise. Other sources are kept as-is, except that paths to kustomization
+// files are replaced by their directory.
+func expandSources(fsys filesys.FileSystem, inputs []Source) ([]Source, error) {
+	expanded := make([]Source, 0, len(inputs))
+
+	for _, input := range inputs {
+		sourceFs := fsys
+		if input.FileSystem != nil {
+			sourceFs = input.FileSystem
+		}
+
+		if input.Glob == "" {
+			expanded = append(expanded, kustomizationDir(sourceFs, input))
+
+			continue
+		}
+
+		dirs, err := expandGlob(sourceFs, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dir := range dirs {
+			source := input
+			source.Path = dir
+			source.Glob = ""
+
+			expanded = append(expanded, source)
+		}
+	}
+
+	return expanded, nil
+}
+
+// expandGlob returns the distinct directories containing a kustomization file matched by
+// the glob of input. Matches may be kustomization directories or kustomization files;
+// anything else is ignored.
+func expandGlob(fsys filesys.FileSystem, input Source) ([]string, error) {
+	if input.Path != "" {
+		return nil, fmt.Errorf("%w %q: Path and Glob are mutually exclusive", ErrInvalidGlob, input.Glob)
+	}
+
+	matches, err := fsys.Glob(input.Glob)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidGlob, input.Glob, err)
+	}
+
+	dirs := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		dir := filepath.Clean(match)
+		if !fsys.IsDir(dir) {
+			if !slices.Contains(kustomizationFiles, filepath.Base(dir)) {
+				continue
+			}
+
+			dir = filepath.Dir(dir)
+		}
+
+		if _, found := findKustomizationFile(fsys, dir); found && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrGlobNoMatch, input.Glob)
+	}
+
+	slices.Sort(dirs)
+
+	return dirs, nil
+}
@@ -0,0 +1,151 @@
+package kustomize_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/k8s-manifest-kit/engine/pkg/types"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+
+	. "github.com/onsi/gomega"
+)
+
+// setupMonorepo creates apps/{a,b}/overlays/prod kustomizations, each rendering a
+// ConfigMap named after the app, and an apps/c/overlays/prod directory without
+// kustomization file.
+func setupMonorepo(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	for _, app := range []string{"a", "b"} {
+		dir := filepath.Join(root, "apps", app, "overlays", "prod")
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, dir, "configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+app+"\n")
+	}
+
+	writeFile(t, filepath.Join(root, "apps", "c", "overlays", "prod"), "configmap.yaml", basicConfigMap)
+
+	return root
+}
+
+func TestSourceGlob(t *testing.T) {
+	t.Run("should render every matched kustomization with its own source path", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupMonorepo(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Glob: filepath.Join(root, "apps", "*", "overlays", "prod")}},
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources).To(HaveLen(2))
+
+		for i, app := range []string{"a", "b"} {
+			dir := filepath.Join(root, "apps", app, "overlays", "prod")
+
+			g.Expect(result.Sources[i].Path).To(Equal(dir))
+			g.Expect(result.Sources[i].Objects).To(HaveLen(1))
+			g.Expect(result.Sources[i].Objects[0].GetName()).To(Equal(app))
+			g.Expect(result.Sources[i].Objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourcePath, dir))
+		}
+	})
+
+	t.Run("should select the directory of matched kustomization files", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupMonorepo(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{
+			{Glob: filepath.Join(root, "apps", "*", "overlays", "prod", "*")},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+
+	t.Run("should match doublestar patterns with an adapter filesystem", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupMonorepo(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{Glob: filepath.Join(root, "apps", "**", "kustomization.yaml")},
+			},
+			kustomize.WithFileSystem(fs.NewFsOnDisk()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+		g.Expect(objects[0].GetName()).To(Equal("a"))
+		g.Expect(objects[1].GetName()).To(Equal("b"))
+	})
+
+	t.Run("should match each directory once", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupMonorepo(t)
+
+		// matches both kustomization.yaml and configmap.yaml of each app
+		renderer, err := kustomize.New([]kustomize.Source{
+			{Glob: filepath.Join(root, "apps", "[ab]", "overlays", "prod", "*.yaml")},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources).To(HaveLen(2))
+	})
+
+	t.Run("should share the remaining source fields", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupMonorepo(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{
+			Glob:   filepath.Join(root, "apps", "*", "overlays", "prod"),
+			Values: kustomize.Values(map[string]string{"key": "value"}),
+		}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+
+	t.Run("should fail when no kustomization matches", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupMonorepo(t)
+
+		_, err := kustomize.New([]kustomize.Source{{Glob: filepath.Join(root, "apps", "c", "*", "*")}})
+		g.Expect(err).To(MatchError(kustomize.ErrGlobNoMatch))
+	})
+
+	t.Run("should reject invalid globs", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupMonorepo(t)
+
+		_, err := kustomize.New([]kustomize.Source{{Glob: filepath.Join(root, "apps", "[")}})
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidGlob))
+
+		_, err = kustomize.New([]kustomize.Source{{Path: root, Glob: filepath.Join(root, "apps", "*")}})
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidGlob))
+	})
+
+	t.Run("should reject globs where a single kustomization is expected", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupMonorepo(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: filepath.Join(root, "apps", "a", "overlays", "prod")}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.DebugKustomization(kustomize.Source{Glob: filepath.Join(root, "apps", "*", "overlays", "prod")})
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidGlob))
+	})
+}
@@ -0,0 +1,103 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+)
+
+const defaultHelmCommand = "helm"
+
+// ErrHelmNotFound is returned by New when the helm binary configured with WithHelmGenerator
+// cannot be found or is not executable.
+var ErrHelmNotFound = errors.New("helm binary not found")
+
+// HelmOption configures the Helm chart inflation generator enabled by WithHelmGenerator.
+type HelmOption func(cfg *kustomizetypes.HelmConfig)
+
+// WithHelmKubeVersion sets the Kubernetes version passed to helm template (--kube-version).
+// It overrides the kubeVersion of individual charts.
+func WithHelmKubeVersion(version string) HelmOption {
+	return func(cfg *kustomizetypes.HelmConfig) {
+		cfg.KubeVersion = version
+	}
+}
+
+// WithHelmAPIVersions sets the API versions passed to helm template (--api-versions),
+// used by charts checking .Capabilities.APIVersions. It overrides the apiVersions of
+// individual charts.
+func WithHelmAPIVersions(versions ...string) HelmOption {
+	return func(cfg *kustomizetypes.HelmConfig) {
+		cfg.ApiVersions = append(cfg.ApiVersions, versions...)
+	}
+}
+
+// WithHelmDebug enables helm's --debug output for every chart.
+func WithHelmDebug(enabled bool) HelmOption {
+	return func(cfg *kustomizetypes.HelmConfig) {
+		cfg.Debug = enabled
+	}
+}
+
+// newHelmConfig resolves the helm binary and builds the kustomize Helm configuration.
+func newHelmConfig(helmPath string, opts []HelmOption) (*kustomizetypes.HelmConfig, error) {
+	if helmPath == "" {
+		helmPath = defaultHelmCommand
+	}
+
+	command, err := exec.LookPath(helmPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrHelmNotFound, helmPath, err)
+	}
+
+	cfg := &kustomizetypes.HelmConfig{
+		Enabled: true,
+		Command: command,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	cfg.ApiVersions = slices.Clip(cfg.ApiVersions)
+
+	return cfg, nil
+}
+
+// checkHelmChartHome enforces load restrictions on the chart home of a kustomization.
+// kustomize runs helm against the chart home on disk and does not restrict its location
+// itself, so with LoadRestrictionsRootOnly it must stay below the kustomization root.
+func checkHelmChartHome(
+	dir string,
+	kust *kustomizetypes.Kustomization,
+	restrictions kustomizetypes.LoadRestrictions,
+) error {
+	if restrictions != kustomizetypes.LoadRestrictionsRootOnly {
+		return nil
+	}
+
+	homes := make([]string, 0, 1+len(kust.HelmChartInflationGenerator))
+	if kust.HelmGlobals != nil {
+		homes = append(homes, kust.HelmGlobals.ChartHome)
+	}
+
+	for _, args := range kust.HelmChartInflationGenerator {
+		homes = append(homes, args.ChartHome)
+	}
+
+	for _, home := range homes {
+		if home == "" {
+			continue
+		}
+
+		if !isWithinDir(dir, resolveReference(dir, home)) {
+			return fmt.Errorf("%w: helm chartHome %q is outside of kustomization root %q",
+				ErrLoadRestrictionViolation, home, dir)
+		}
+	}
+
+	return nil
+}
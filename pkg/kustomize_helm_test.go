@@ -0,0 +1,117 @@
+package kustomize_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+const fakeHelm = `#!/bin/sh
+case "$1" in
+version) echo "v3.15.0+gfake" ;;
+template) printf 'apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n' "$2" ;;
+*) exit 1 ;;
+esac
+`
+
+const helmKustomization = `
+helmGlobals:
+  chartHome: %s
+helmCharts:
+- name: demo
+  releaseName: demo-release
+`
+
+// setupFakeHelm writes a helm stand-in answering version and template commands.
+func setupFakeHelm(t *testing.T) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake helm requires a POSIX shell")
+	}
+
+	path := filepath.Join(t.TempDir(), "helm")
+	if err := os.WriteFile(path, []byte(fakeHelm), 0o755); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func setupHelmKustomization(t *testing.T, chartHome string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "kustomization.yaml", fmt.Sprintf(helmKustomization, chartHome))
+	writeFile(t, filepath.Join(dir, "charts", "demo"), "values.yaml", "replicas: 1\n")
+
+	return dir
+}
+
+func TestHelmGenerator(t *testing.T) {
+	t.Run("should fail when helm binary is missing", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.New(nil, kustomize.WithHelmGenerator(filepath.Join(t.TempDir(), "helm")))
+		g.Expect(err).To(MatchError(kustomize.ErrHelmNotFound))
+	})
+
+	t.Run("should render helm charts", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := setupHelmKustomization(t, "charts")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithHelmGenerator(setupFakeHelm(t), kustomize.WithHelmKubeVersion("1.31.0")),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("demo-release"))
+	})
+
+	t.Run("should not render helm charts by default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: setupHelmKustomization(t, "charts")}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("should reject chart home outside root", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := setupHelmKustomization(t, t.TempDir())
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithHelmGenerator(setupFakeHelm(t)),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrLoadRestrictionViolation))
+
+		renderer, err = kustomize.New(
+			[]kustomize.Source{{Path: dir, LoadRestrictions: kustomizetypes.LoadRestrictionsNone}},
+			kustomize.WithHelmGenerator(setupFakeHelm(t)),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(MatchError(kustomize.ErrLoadRestrictionViolation))
+	})
+}
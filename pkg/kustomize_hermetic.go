@@ -0,0 +1,194 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+)
+
+// ErrHermeticViolation is returned when a render in hermetic mode would reach outside of
+// the source tree.
+var ErrHermeticViolation = errors.New("hermetic violation")
+
+// HermeticViolation is a reference that a hermetic render refuses.
+type HermeticViolation struct {
+	// Kustomization is the directory of the kustomization declaring the reference.
+	Kustomization string
+
+	// Field is the kustomization field holding the reference (e.g. "resources").
+	Field string
+
+	// Reference is the reference as written in the kustomization.
+	Reference string
+
+	// Reason explains why the reference is refused.
+	Reason string
+}
+
+// String returns the violation in a human-readable form.
+func (v HermeticViolation) String() string {
+	return fmt.Sprintf("%s: %s %q: %s", v.Kustomization, v.Field, v.Reference, v.Reason)
+}
+
+// HermeticViolationError lists every violation found in a kustomization tree.
+type HermeticViolationError struct {
+	Violations []HermeticViolation
+}
+
+// Error lists every violation.
+func (e *HermeticViolationError) Error() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s: %d violation(s)", ErrHermeticViolation.Error(), len(e.Violations))
+
+	for _, v := range e.Violations {
+		fmt.Fprintf(&sb, "\n  %s", v)
+	}
+
+	return sb.String()
+}
+
+// Is reports whether target is ErrHermeticViolation.
+func (e *HermeticViolationError) Is(target error) bool {
+	return target == ErrHermeticViolation
+}
+
+// hermeticFileSystem returns fsys, the OS filesystem if nil, denying symlinks which escape
+// root, or their directory if root is empty (see adapter.SymlinkDeny). Every other setting
+// of fsys is kept. Filesystems unable to deny symlinks, i.e. not backed by an adapter of
+// this module, fail with ErrHermeticViolation.
+func hermeticFileSystem(fsys filesys.FileSystem, root string) (filesys.FileSystem, error) {
+	if fsys == nil {
+		fsys = adapter.New(afero.NewOsFs())
+	}
+
+	confined, err := adapter.ConfigureSymlinks(fsys, adapter.SymlinkDeny, root)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHermeticViolation, err)
+	}
+
+	return confined, nil
+}
+
+// checkHermetic scans the kustomization tree of root, following local bases and components,
+// and returns a HermeticViolationError listing every remote or absolute reference and every
+// Helm chart pulled from a repository. Otherwise, it returns the deepest directory
+// containing every kustomization of the tree, to which symlinks are confined.
+func checkHermetic(fs filesys.FileSystem, root string) (string, error) {
+	s := &hermeticScanner{
+		fs:      fs,
+		visited: make(map[string]bool),
+	}
+
+	if err := s.scan(root); err != nil {
+		return "", err
+	}
+
+	if len(s.violations) > 0 {
+		return "", &HermeticViolationError{Violations: s.violations}
+	}
+
+	return s.treeRoot()
+}
+
+// hermeticScanner accumulates the hermetic violations of a kustomization tree.
+type hermeticScanner struct {
+	fs         filesys.FileSystem
+	visited    map[string]bool
+	violations []HermeticViolation
+}
+
+func (s *hermeticScanner) scan(dir string) error {
+	s.visited[dir] = true
+
+	kust, _, err := readKustomization(s.fs, dir)
+	if err != nil {
+		return err
+	}
+
+	s.checkHelmCharts(dir, kust)
+
+	for _, ref := range collectReferences(kust) {
+		switch {
+		case isRemoteReference(ref.Value):
+			s.report(dir, ref.Field, ref.Value, "remote references are not allowed")
+
+			continue
+		case filepath.IsAbs(ref.Value):
+			s.report(dir, ref.Field, ref.Value, "absolute paths are not allowed")
+
+			continue
+		}
+
+		target := resolveReference(dir, ref.Value)
+		if !isKustomizationField(ref.Field) || s.visited[target] || !s.fs.IsDir(target) {
+			continue
+		}
+
+		if _, found := findKustomizationFile(s.fs, target); !found {
+			continue
+		}
+
+		if err := s.scan(target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *hermeticScanner) checkHelmCharts(dir string, kust *kustomizetypes.Kustomization) {
+	for _, chart := range kust.HelmCharts {
+		if chart.Repo != "" {
+			s.report(dir, "helmCharts", chart.Repo, "charts cannot be pulled from repositories")
+		}
+	}
+
+	// the deprecated field kustomize still converts to helmCharts
+	for _, chart := range kust.HelmChartInflationGenerator {
+		if chart.ChartRepoURL != "" {
+			s.report(dir, "helmChartInflationGenerator", chart.ChartRepoURL, "charts cannot be pulled from repositories")
+		}
+	}
+}
+
+// treeRoot returns the deepest directory containing every scanned kustomization.
+func (s *hermeticScanner) treeRoot() (string, error) {
+	root := ""
+
+	for dir := range s.visited {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve path %q: %w", dir, err)
+		}
+
+		if root == "" {
+			root = abs
+
+			continue
+		}
+
+		// directories on other volumes share no root, leaving the volume root in place
+		for !isWithinDir(root, abs) && filepath.Dir(root) != root {
+			root = filepath.Dir(root)
+		}
+	}
+
+	return root, nil
+}
+
+func (s *hermeticScanner) report(dir string, field string, ref string, reason string) {
+	s.violations = append(s.violations, HermeticViolation{
+		Kustomization: dir,
+		Field:         field,
+		Reference:     ref,
+		Reason:        reason,
+	})
+}
@@ -0,0 +1,247 @@
+package kustomize_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestHermetic(t *testing.T) {
+	t.Run("should render self-contained kustomizations", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithHermetic(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+
+	t.Run("should list every violation in one pass", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "base/kustomization.yaml", "resources:\n- https://example.com/remote.yaml\n")
+		writeFile(t, root, "app/kustomization.yaml", `resources:
+- ../base
+- /etc/manifests/absolute.yaml
+components:
+- github.com/example/repo//component?ref=v1
+helmCharts:
+- name: chart
+  repo: https://charts.example.com
+`)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: filepath.Join(root, "app")}}, kustomize.WithHermetic(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrHermeticViolation))
+
+		var violationErr *kustomize.HermeticViolationError
+		g.Expect(errors.As(err, &violationErr)).To(BeTrue())
+		g.Expect(violationErr.Violations).To(HaveLen(4))
+		g.Expect(violationErr.Violations[0].Field).To(Equal("helmCharts"))
+		g.Expect(violationErr.Violations[1].Kustomization).To(Equal(filepath.Join(root, "base")))
+		g.Expect(violationErr.Violations[1].Reference).To(Equal("https://example.com/remote.yaml"))
+		g.Expect(violationErr.Violations[2].Reference).To(Equal("/etc/manifests/absolute.yaml"))
+		g.Expect(violationErr.Violations[3].Field).To(Equal("components"))
+	})
+
+	t.Run("should report charts of the helmChartInflationGenerator field", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "kustomization.yaml", `helmChartInflationGenerator:
+- chartName: chart
+  chartRepoUrl: https://charts.example.com
+`)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: root}}, kustomize.WithHermetic(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+
+		var violationErr *kustomize.HermeticViolationError
+		g.Expect(errors.As(err, &violationErr)).To(BeTrue())
+		g.Expect(violationErr.Violations).To(HaveLen(1))
+		g.Expect(violationErr.Violations[0].Field).To(Equal("helmChartInflationGenerator"))
+		g.Expect(violationErr.Violations[0].Reference).To(Equal("https://charts.example.com"))
+	})
+
+	t.Run("should force root-only load restrictions", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "outside.yaml", basicConfigMap)
+		writeFile(t, root, "app/kustomization.yaml", "resources:\n- ../outside.yaml\n")
+
+		source := kustomize.Source{
+			Path:             filepath.Join(root, "app"),
+			LoadRestrictions: kustomizetypes.LoadRestrictionsNone,
+		}
+
+		renderer, err := kustomize.New([]kustomize.Source{source}, kustomize.WithHermetic(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("security; file"))
+	})
+
+	t.Run("should deny symlinks escaping the tree", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges on windows")
+		}
+
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "secret/kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, root, "secret/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "app/kustomization.yaml", "resources:\n- linked\n")
+		g.Expect(os.Symlink(filepath.Join(root, "secret"), filepath.Join(root, "app", "linked"))).To(Succeed())
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: filepath.Join(root, "app")}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err = kustomize.New([]kustomize.Source{{Path: filepath.Join(root, "app")}}, kustomize.WithHermetic(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("symlink escapes its root"))
+	})
+
+	t.Run("should deny symlinks escaping the tree when rendering with values", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges on windows")
+		}
+
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "outside/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "app/kustomization.yaml", "resources:\n- link/configmap.yaml\n")
+		g.Expect(os.Symlink(filepath.Join("..", "outside"), filepath.Join(root, "app", "link"))).To(Succeed())
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{
+				Path:   filepath.Join(root, "app"),
+				Values: kustomize.Values(map[string]string{"key": "value"}),
+			}},
+			kustomize.WithHermetic(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("symlink escapes its root"))
+	})
+
+	t.Run("should follow symlinks to sibling directories of the tree", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges on windows")
+		}
+
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "app/base/kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, root, "app/base/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "app/common/kustomization.yaml", "resources:\n- pod.yaml\n")
+		writeFile(t, root, "app/common/pod.yaml", basicPod)
+		writeFile(t, root, "app/overlays/prod/kustomization.yaml", "resources:\n- ../../base\n- common\n")
+		g.Expect(os.Symlink(
+			filepath.Join("..", "..", "common"),
+			filepath.Join(root, "app", "overlays", "prod", "common"),
+		)).To(Succeed())
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: filepath.Join(root, "app", "overlays", "prod")}},
+			kustomize.WithHermetic(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+
+	t.Run("should deny symlinks escaping the tree through wrapping filesystems", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges on windows")
+		}
+
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "outside/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "app/kustomization.yaml", "resources:\n- link/configmap.yaml\n")
+		g.Expect(os.Symlink(filepath.Join("..", "outside"), filepath.Join(root, "app", "link"))).To(Succeed())
+
+		wrapped := fs.NewReadOnlyFs(fs.NewCachingFs(fs.NewFsOnDisk()))
+
+		for name, opts := range map[string]struct {
+			source   kustomize.Source
+			renderer []kustomize.RendererOption
+		}{
+			"renderer": {
+				source:   kustomize.Source{Path: filepath.Join(root, "app")},
+				renderer: []kustomize.RendererOption{kustomize.WithFileSystem(wrapped)},
+			},
+			"source": {
+				source: kustomize.Source{Path: filepath.Join(root, "app"), FileSystem: wrapped},
+			},
+		} {
+			renderer, err := kustomize.New(
+				[]kustomize.Source{opts.source},
+				append(opts.renderer, kustomize.WithHermetic(true))...,
+			)
+			g.Expect(err).ToNot(HaveOccurred(), name)
+
+			_, err = renderer.Process(t.Context(), nil)
+			g.Expect(err).To(HaveOccurred(), name)
+			g.Expect(err.Error()).To(ContainSubstring("symlink escapes its root"), name)
+		}
+	})
+
+	t.Run("should refuse filesystems unable to deny symlinks", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		_, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithHermetic(true),
+			kustomize.WithFileSystem(filesys.MakeFsOnDisk()),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrHermeticViolation))
+		g.Expect(err).To(MatchError(adapter.ErrSymlinksNotConfigurable))
+
+		_, err = kustomize.New(
+			[]kustomize.Source{{Path: dir, FileSystem: fs.NewCachingFs(filesys.MakeFsOnDisk())}},
+			kustomize.WithHermetic(true),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrHermeticViolation))
+	})
+
+	t.Run("should refuse exec plugins", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.New(
+			[]kustomize.Source{{Path: t.TempDir()}},
+			kustomize.WithHermetic(true),
+			kustomize.WithExecPlugins(true),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrHermeticViolation))
+	})
+}
@@ -0,0 +1,141 @@
+package kustomize
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// ListInputs returns the inputs of a kustomization without building it: its kustomization
+// file and every file it references (resources, components, patches, generator sources,
+// ...), following local bases and components recursively. Files of a directory used as a
+// generator source are listed individually.
+//
+// Local paths are relative to the source path, starting with "../" when outside of it;
+// remote references are reported as written, without being fetched. Inputs are listed in
+// discovery order, without duplicates.
+//
+// Generators and transformers are not executed, so inputs they read on their own (e.g.
+// Helm charts) are not reported. A missing local reference fails with ErrMissingReference,
+// kustomizations referencing each other fail with ErrCyclicReference, and kustomizations
+// nested deeper than WithMaxDepth allows fail with ErrMaxDepthExceeded.
+func (r *Renderer) ListInputs(source Source) ([]string, error) {
+	source = kustomizationDir(r.engine.fileSystem(source), source)
+
+	holder := &sourceHolder{Source: source}
+	if err := holder.Validate(); err != nil {
+		return nil, err
+	}
+
+	sourceFs := r.engine.fileSystem(source)
+
+	if err := checkCycles(sourceFs, source.Path, r.opts.MaxDepth); err != nil {
+		return nil, err
+	}
+
+	l := &inputLister{
+		fs:      sourceFs,
+		root:    source.Path,
+		visited: make(map[string]bool),
+		seen:    make(map[string]bool),
+		inputs:  make([]string, 0),
+	}
+
+	if err := l.list(source.Path); err != nil {
+		return nil, err
+	}
+
+	return l.inputs, nil
+}
+
+// inputLister accumulates the inputs of a kustomization tree.
+type inputLister struct {
+	fs   filesys.FileSystem
+	root string
+
+	// visited holds the kustomization directories whose references have been followed.
+	visited map[string]bool
+
+	// seen holds the inputs already reported.
+	seen   map[string]bool
+	inputs []string
+}
+
+func (l *inputLister) list(dir string) error {
+	l.visited[dir] = true
+
+	kust, name, err := readKustomization(l.fs, dir)
+	if err != nil {
+		return err
+	}
+
+	l.add(filepath.Join(dir, name))
+
+	for _, ref := range collectReferences(kust) {
+		if isRemoteReference(ref.Value) {
+			l.addInput(ref.Value)
+
+			continue
+		}
+
+		target := resolveReference(dir, ref.Value)
+
+		switch {
+		case !l.fs.Exists(target):
+			return fmt.Errorf("%s: %s %q: %w", dir, ref.Field, ref.Value, ErrMissingReference)
+		case !l.fs.IsDir(target):
+			l.add(target)
+		case ref.Kind == referenceFileOrDir:
+			if l.visited[target] {
+				continue
+			}
+
+			if err := l.list(target); err != nil {
+				return fmt.Errorf("%s: %s %q: %w", dir, ref.Field, ref.Value, err)
+			}
+		default:
+			if err := l.addDir(target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addDir adds the files directly contained in dir, as kustomize reads them for a
+// directory generator source.
+func (l *inputLister) addDir(dir string) error {
+	names, err := l.fs.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("unable to read directory %q: %w", dir, err)
+	}
+
+	for _, name := range names {
+		if path := filepath.Join(dir, name); !l.fs.IsDir(path) {
+			l.add(path)
+		}
+	}
+
+	return nil
+}
+
+// add reports a local input relative to the source path.
+func (l *inputLister) add(path string) {
+	if rel, err := filepath.Rel(l.root, path); err == nil {
+		path = rel
+	}
+
+	l.addInput(path)
+}
+
+// addInput reports an input as is.
+func (l *inputLister) addInput(input string) {
+	if l.seen[input] {
+		return
+	}
+
+	l.seen[input] = true
+	l.inputs = append(l.inputs, input)
+}
@@ -0,0 +1,85 @@
+package kustomize_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestListInputs(t *testing.T) {
+	t.Run("should list referenced files recursively", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "base/kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, root, "base/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "app/kustomization.yaml", `resources:
+- ../base
+- pod.yaml
+- https://github.com/example/repo//base?ref=v1
+components:
+- ../component
+patches:
+- path: patch.yaml
+- patch: |-
+    - op: add
+      path: /metadata/labels
+      value: {}
+  target:
+    kind: Pod
+configMapGenerator:
+- name: generated
+  files:
+  - config=files
+`)
+		writeFile(t, root, "app/pod.yaml", diffPod)
+		writeFile(t, root, "app/patch.yaml", "apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod\n")
+		writeFile(t, root, "app/files/a.properties", "a=1\n")
+		writeFile(t, root, "app/files/b.properties", "b=2\n")
+		writeFile(t, root, "app/files/nested/c.properties", "c=3\n")
+		writeFile(t, root, "component/kustomization.yaml",
+			"apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nresources:\n- ../base/configmap.yaml\n")
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: filepath.Join(root, "app")}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		inputs, err := renderer.ListInputs(kustomize.Source{Path: filepath.Join(root, "app")})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(inputs).To(Equal([]string{
+			"kustomization.yaml",
+			"../base/kustomization.yaml",
+			"../base/configmap.yaml",
+			"pod.yaml",
+			"https://github.com/example/repo//base?ref=v1",
+			"../component/kustomization.yaml",
+			"patch.yaml",
+			"files/a.properties",
+			"files/b.properties",
+		}))
+	})
+
+	t.Run("should fail on a missing reference", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- missing.yaml\n")
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.ListInputs(kustomize.Source{Path: dir})
+		g.Expect(err).To(MatchError(kustomize.ErrMissingReference))
+	})
+
+	t.Run("should fail when the source has no kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.ListInputs(kustomize.Source{Path: dir})
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+	})
+}
@@ -0,0 +1,104 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/k8s-manifest-kit/engine/pkg/types"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// templateMetadataPaths lists, per workload kind, the pod (or job) template metadata
+// blocks that receive common labels and annotations.
+//
+//nolint:gochecknoglobals
+var templateMetadataPaths = map[schema.GroupKind][][]string{
+	{Group: "", Kind: "ReplicationController"}: {{"spec", "template", "metadata"}},
+	{Group: "apps", Kind: "Deployment"}:        {{"spec", "template", "metadata"}},
+	{Group: "apps", Kind: "StatefulSet"}:       {{"spec", "template", "metadata"}},
+	{Group: "apps", Kind: "DaemonSet"}:         {{"spec", "template", "metadata"}},
+	{Group: "apps", Kind: "ReplicaSet"}:        {{"spec", "template", "metadata"}},
+	{Group: "batch", Kind: "Job"}:              {{"spec", "template", "metadata"}},
+	{Group: "batch", Kind: "CronJob"}: {
+		{"spec", "jobTemplate", "metadata"},
+		{"spec", "jobTemplate", "spec", "template", "metadata"},
+	},
+}
+
+// CommonLabelsTransformer returns a transformer that adds labels to metadata.labels of every
+// object and to the pod templates of workloads (Deployments, StatefulSets, DaemonSets,
+// ReplicaSets, ReplicationControllers, Jobs and CronJobs). Existing values for the same keys
+// are overwritten.
+//
+// Unlike kustomize's commonLabels, selectors are never modified: spec.selector is immutable
+// on most workloads, so changing it breaks updates of existing objects, and Service selectors
+// must keep matching pods that are already running. Since labels are only added, existing
+// selectors keep matching the labelled pod templates.
+//
+// Being a post-render transformer, it can be applied to the merged output of several sources:
+//
+//	kustomize.New(sources, kustomize.WithTransformer(
+//	    kustomize.CommonLabelsTransformer(map[string]string{"team": "platform"}),
+//	))
+func CommonLabelsTransformer(labels map[string]string) types.Transformer {
+	return metadataTransformer("labels", labels)
+}
+
+// CommonAnnotationsTransformer returns a transformer that adds annotations to
+// metadata.annotations of every object and to the pod templates of workloads, following
+// the same rules as CommonLabelsTransformer.
+func CommonAnnotationsTransformer(annotations map[string]string) types.Transformer {
+	return metadataTransformer("annotations", annotations)
+}
+
+// metadataTransformer merges entries into the given metadata field (labels or annotations)
+// of an object and of its workload templates.
+func metadataTransformer(field string, entries map[string]string) types.Transformer {
+	entries = maps.Clone(entries)
+
+	return func(_ context.Context, obj unstructured.Unstructured) (unstructured.Unstructured, error) {
+		if len(entries) == 0 {
+			return obj, nil
+		}
+
+		if err := mergeStringMap(obj.Object, entries, "metadata", field); err != nil {
+			return obj, err
+		}
+
+		for _, path := range templateMetadataPaths[obj.GroupVersionKind().GroupKind()] {
+			// only decorate templates that are present; never create a template from scratch
+			if _, found, _ := unstructured.NestedMap(obj.Object, path[:len(path)-1]...); !found {
+				continue
+			}
+
+			if err := mergeStringMap(obj.Object, entries, append(path, field)...); err != nil {
+				return obj, err
+			}
+		}
+
+		return obj, nil
+	}
+}
+
+// mergeStringMap merges entries into the string map found at path, creating it if missing.
+func mergeStringMap(obj map[string]any, entries map[string]string, path ...string) error {
+	current, _, err := unstructured.NestedStringMap(obj, path...)
+	if err != nil {
+		return fmt.Errorf("unable to read %v: %w", path, err)
+	}
+
+	if current == nil {
+		current = make(map[string]string, len(entries))
+	}
+
+	maps.Copy(current, entries)
+
+	if err := unstructured.SetNestedStringMap(obj, current, path...); err != nil {
+		return fmt.Errorf("unable to set %v: %w", path, err)
+	}
+
+	return nil
+}
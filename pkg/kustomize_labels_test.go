@@ -0,0 +1,115 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func makeWorkload(apiVersion string, kind string, name string, templatePath ...string) unstructured.Unstructured {
+	obj := makeObject(apiVersion, kind, name)
+
+	selector := map[string]any{"app": name}
+	_ = unstructured.SetNestedField(obj.Object, map[string]any{"matchLabels": selector}, "spec", "selector")
+	_ = unstructured.SetNestedStringMap(obj.Object, map[string]string{"app": name}, append(templatePath, "labels")...)
+
+	return obj
+}
+
+func TestCommonLabelsTransformer(t *testing.T) {
+	labels := map[string]string{"team": "platform"}
+
+	t.Run("should add labels to metadata", func(t *testing.T) {
+		g := NewWithT(t)
+
+		obj := makeObject("v1", "ConfigMap", "cm")
+		obj.SetLabels(map[string]string{"app": "cm", "team": "old"})
+
+		result, err := kustomize.CommonLabelsTransformer(labels)(t.Context(), obj)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.GetLabels()).To(Equal(map[string]string{"app": "cm", "team": "platform"}))
+	})
+
+	t.Run("should label pod templates but not selectors", func(t *testing.T) {
+		g := NewWithT(t)
+
+		obj := makeWorkload("apps/v1", "Deployment", "app", "spec", "template", "metadata")
+
+		result, err := kustomize.CommonLabelsTransformer(labels)(t.Context(), obj)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		templateLabels, _, err := unstructured.NestedStringMap(result.Object, "spec", "template", "metadata", "labels")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(templateLabels).To(Equal(map[string]string{"app": "app", "team": "platform"}))
+
+		selector, _, err := unstructured.NestedStringMap(result.Object, "spec", "selector", "matchLabels")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(selector).To(Equal(map[string]string{"app": "app"}))
+	})
+
+	t.Run("should label cronjob templates", func(t *testing.T) {
+		g := NewWithT(t)
+
+		obj := makeWorkload("batch/v1", "CronJob", "job", "spec", "jobTemplate", "spec", "template", "metadata")
+
+		result, err := kustomize.CommonLabelsTransformer(labels)(t.Context(), obj)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		jobLabels, _, err := unstructured.NestedStringMap(result.Object, "spec", "jobTemplate", "metadata", "labels")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(jobLabels).To(Equal(labels))
+
+		podLabels, _, err := unstructured.NestedStringMap(result.Object,
+			"spec", "jobTemplate", "spec", "template", "metadata", "labels")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(podLabels).To(HaveKeyWithValue("team", "platform"))
+	})
+
+	t.Run("should not touch service selectors", func(t *testing.T) {
+		g := NewWithT(t)
+
+		obj := makeObject("v1", "Service", "svc")
+		_ = unstructured.SetNestedStringMap(obj.Object, map[string]string{"app": "svc"}, "spec", "selector")
+
+		result, err := kustomize.CommonLabelsTransformer(labels)(t.Context(), obj)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.GetLabels()).To(Equal(labels))
+
+		selector, _, err := unstructured.NestedStringMap(result.Object, "spec", "selector")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(selector).To(Equal(map[string]string{"app": "svc"}))
+	})
+
+	t.Run("should not create missing templates", func(t *testing.T) {
+		g := NewWithT(t)
+
+		result, err := kustomize.CommonLabelsTransformer(labels)(t.Context(), makeObject("apps/v1", "Deployment", "app"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, found, err := unstructured.NestedMap(result.Object, "spec")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(found).To(BeFalse())
+	})
+}
+
+func TestCommonAnnotationsTransformer(t *testing.T) {
+	t.Run("should add annotations to metadata and pod templates", func(t *testing.T) {
+		g := NewWithT(t)
+
+		annotations := map[string]string{"owner": "platform"}
+		obj := makeWorkload("apps/v1", "StatefulSet", "db", "spec", "template", "metadata")
+
+		result, err := kustomize.CommonAnnotationsTransformer(annotations)(t.Context(), obj)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.GetAnnotations()).To(Equal(annotations))
+
+		templateAnnotations, _, err := unstructured.NestedStringMap(result.Object,
+			"spec", "template", "metadata", "annotations")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(templateAnnotations).To(Equal(annotations))
+	})
+}
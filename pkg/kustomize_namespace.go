@@ -0,0 +1,164 @@
+package kustomize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/k8s-manifest-kit/engine/pkg/types"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Scope is the scope of a Kubernetes resource kind.
+type Scope string
+
+const (
+	// ScopeUnknown means a resolver has no information about a kind.
+	ScopeUnknown Scope = ""
+
+	// ScopeNamespaced marks kinds whose objects live in a namespace.
+	ScopeNamespaced Scope = "namespaced"
+
+	// ScopeCluster marks cluster-scoped kinds.
+	ScopeCluster Scope = "cluster"
+)
+
+// ErrUnknownScope is returned when a resolver reports an invalid scope value.
+var ErrUnknownScope = errors.New("unknown resource scope")
+
+// ScopeResolver determines the scope of a kind. It returns ScopeUnknown when it has
+// no information, letting the next resolver decide.
+type ScopeResolver func(gvk schema.GroupVersionKind) (Scope, error)
+
+// defaultClusterScopedKinds lists common built-in cluster-scoped kinds.
+//
+//nolint:gochecknoglobals
+var defaultClusterScopedKinds = []schema.GroupKind{
+	{Group: "", Kind: "Namespace"},
+	{Group: "", Kind: "Node"},
+	{Group: "", Kind: "PersistentVolume"},
+	{Group: "", Kind: "ComponentStatus"},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"},
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"},
+	{Group: "apiregistration.k8s.io", Kind: "APIService"},
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"},
+	{Group: "networking.k8s.io", Kind: "IngressClass"},
+	{Group: "node.k8s.io", Kind: "RuntimeClass"},
+	{Group: "policy", Kind: "PodSecurityPolicy"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"},
+	{Group: "storage.k8s.io", Kind: "CSIDriver"},
+	{Group: "storage.k8s.io", Kind: "CSINode"},
+	{Group: "storage.k8s.io", Kind: "StorageClass"},
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"},
+}
+
+// StaticScopeResolver returns a ScopeResolver backed by a fixed list of cluster-scoped
+// kinds: listed kinds are cluster-scoped, all others are unknown.
+func StaticScopeResolver(clusterScoped ...schema.GroupKind) ScopeResolver {
+	kinds := make(map[schema.GroupKind]struct{}, len(clusterScoped))
+	for _, gk := range clusterScoped {
+		kinds[gk] = struct{}{}
+	}
+
+	return func(gvk schema.GroupVersionKind) (Scope, error) {
+		if _, ok := kinds[gvk.GroupKind()]; ok {
+			return ScopeCluster, nil
+		}
+
+		return ScopeUnknown, nil
+	}
+}
+
+// DefaultScopeResolver returns the ScopeResolver for common built-in cluster-scoped
+// kinds (Namespaces, ClusterRoles, CRDs, StorageClasses, webhooks, ...).
+func DefaultScopeResolver() ScopeResolver {
+	return StaticScopeResolver(defaultClusterScopedKinds...)
+}
+
+// RESTMapperScopeResolver returns a ScopeResolver backed by a REST mapper, e.g. one built
+// from cluster discovery, so custom resources are resolved precisely. Kinds unknown to the
+// mapper are reported as ScopeUnknown.
+func RESTMapperScopeResolver(mapper meta.RESTMapper) ScopeResolver {
+	return func(gvk schema.GroupVersionKind) (Scope, error) {
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				return ScopeUnknown, nil
+			}
+
+			return ScopeUnknown, fmt.Errorf("unable to resolve scope of %s: %w", gvk, err)
+		}
+
+		if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+			return ScopeCluster, nil
+		}
+
+		return ScopeNamespaced, nil
+	}
+}
+
+// NamespaceTransformer returns a transformer that sets metadata.namespace to ns on namespaced
+// objects only, leaving cluster-scoped objects (Namespaces, ClusterRoles, CRDs, ...) untouched.
+//
+// The scope of each kind is determined by consulting the given resolvers in order, then the
+// built-in DefaultScopeResolver. Kinds no resolver knows about are treated as namespaced,
+// which matches the common case of namespaced custom resources; pass a RESTMapperScopeResolver
+// or a StaticScopeResolver to handle cluster-scoped custom resources.
+//
+// Example:
+//
+//	kustomize.New(sources, kustomize.WithTransformer(
+//	    kustomize.NamespaceTransformer("team-a",
+//	        kustomize.StaticScopeResolver(schema.GroupKind{Group: "example.com", Kind: "ClusterWidget"}),
+//	    ),
+//	))
+func NamespaceTransformer(ns string, resolvers ...ScopeResolver) types.Transformer {
+	chain := make([]ScopeResolver, 0, len(resolvers)+1)
+	chain = append(chain, resolvers...)
+	chain = append(chain, DefaultScopeResolver())
+
+	return func(_ context.Context, obj unstructured.Unstructured) (unstructured.Unstructured, error) {
+		gvk := obj.GroupVersionKind()
+
+		scope, err := resolveScope(chain, gvk)
+		if err != nil {
+			return obj, err
+		}
+
+		if scope != ScopeCluster {
+			obj.SetNamespace(ns)
+		}
+
+		return obj, nil
+	}
+}
+
+func resolveScope(resolvers []ScopeResolver, gvk schema.GroupVersionKind) (Scope, error) {
+	for _, resolve := range resolvers {
+		scope, err := resolve(gvk)
+		if err != nil {
+			return ScopeUnknown, err
+		}
+
+		switch scope {
+		case ScopeNamespaced, ScopeCluster:
+			return scope, nil
+		case ScopeUnknown:
+			continue
+		default:
+			return ScopeUnknown, fmt.Errorf("%w %q for %s", ErrUnknownScope, scope, gvk)
+		}
+	}
+
+	return ScopeUnknown, nil
+}
@@ -0,0 +1,119 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func makeObject(apiVersion string, kind string, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+
+	return obj
+}
+
+func TestNamespaceTransformer(t *testing.T) {
+
+	t.Run("should set namespace on namespaced objects only", func(t *testing.T) {
+		g := NewWithT(t)
+
+		transformer := kustomize.NamespaceTransformer("team-a")
+
+		for _, tc := range []struct {
+			obj      unstructured.Unstructured
+			expected string
+		}{
+			{makeObject("v1", "ConfigMap", "cm"), "team-a"},
+			{makeObject("apps/v1", "Deployment", "app"), "team-a"},
+			{makeObject("example.com/v1", "Widget", "w"), "team-a"},
+			{makeObject("v1", "Namespace", "team-a"), ""},
+			{makeObject("rbac.authorization.k8s.io/v1", "ClusterRole", "cr"), ""},
+			{makeObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "widgets.example.com"), ""},
+		} {
+			obj, err := transformer(t.Context(), tc.obj)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(obj.GetNamespace()).To(Equal(tc.expected), tc.obj.GetKind())
+		}
+	})
+
+	t.Run("should use static scope table", func(t *testing.T) {
+		g := NewWithT(t)
+
+		transformer := kustomize.NamespaceTransformer("team-a",
+			kustomize.StaticScopeResolver(schema.GroupKind{Group: "example.com", Kind: "ClusterWidget"}),
+		)
+
+		obj, err := transformer(t.Context(), makeObject("example.com/v1", "ClusterWidget", "w"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(obj.GetNamespace()).To(BeEmpty())
+
+		obj, err = transformer(t.Context(), makeObject("v1", "Namespace", "ns"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(obj.GetNamespace()).To(BeEmpty())
+	})
+
+	t.Run("should use REST mapper", func(t *testing.T) {
+		g := NewWithT(t)
+
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "ClusterWidget"}, meta.RESTScopeRoot)
+		// the mapper takes precedence over the built-in table
+		mapper.Add(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Node"}, meta.RESTScopeNamespace)
+
+		transformer := kustomize.NamespaceTransformer("team-a", kustomize.RESTMapperScopeResolver(mapper))
+
+		obj, err := transformer(t.Context(), makeObject("example.com/v1", "ClusterWidget", "w"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(obj.GetNamespace()).To(BeEmpty())
+
+		obj, err = transformer(t.Context(), makeObject("v1", "Node", "n"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(obj.GetNamespace()).To(Equal("team-a"))
+
+		// unknown to the mapper, falls back to the built-in table
+		obj, err = transformer(t.Context(), makeObject("v1", "Namespace", "ns"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(obj.GetNamespace()).To(BeEmpty())
+	})
+
+	t.Run("should work as renderer transformer", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(),
+			map[string][]byte{
+				"kustomization.yaml": []byte("resources:\n- resources.yaml\n"),
+				"resources.yaml": []byte(`
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`),
+			},
+			kustomize.WithTransformer(kustomize.NamespaceTransformer("team-a")),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+
+		for _, obj := range objects {
+			if obj.GetKind() == "Namespace" {
+				g.Expect(obj.GetNamespace()).To(BeEmpty())
+			} else {
+				g.Expect(obj.GetNamespace()).To(Equal("team-a"))
+			}
+		}
+	})
+}
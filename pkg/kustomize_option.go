@@ -1,12 +1,24 @@
 package kustomize
 
 import (
+	"context"
+	"log/slog"
+	"maps"
+	"time"
+
 	"github.com/k8s-manifest-kit/engine/pkg/types"
 	"github.com/k8s-manifest-kit/pkg/util"
 	"github.com/k8s-manifest-kit/pkg/util/cache"
+	"github.com/spf13/afero"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/api/resmap"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
 )
 
 // RendererOption is a generic option for RendererOptions.
@@ -23,25 +35,229 @@ type RendererOptions struct {
 	// Plugins are kustomize-native transformer plugins applied during kustomize build.
 	Plugins []resmap.Transformer
 
+	// PluginObserver is notified of the effect of every plugin transformer on each source.
+	PluginObserver PluginObserver
+
+	// Patches are applied to the output of every kustomize build, after Plugins.
+	Patches []Patch
+
+	// ResMapFilters are applied to the kustomize ResMap of every source, last, right before
+	// its conversion to unstructured objects.
+	ResMapFilters []ResMapFilter
+
 	// CacheOptions holds cache configuration. nil = caching disabled.
 	CacheOptions *cache.Options
 
+	// CacheKeyFunc computes the cache key of each render. If nil, DefaultCacheKey is used
+	// (unless CacheOptions carries a custom KeyFunc).
+	CacheKeyFunc CacheKeyFunc
+
+	// CacheObserver is notified of every render cache hit, miss, store and failed render.
+	// If nil, no events are emitted.
+	CacheObserver CacheObserver
+
 	// SourceAnnotations enables automatic addition of source tracking annotations.
 	SourceAnnotations bool
 
+	// SourceAnnotationConfig customizes the keys of the source tracking annotations and adds
+	// static annotations. nil = default keys, no extra annotations.
+	SourceAnnotationConfig *SourceAnnotationConfig
+
+	// SourceType is the value of the source.type annotation and label.
+	// Default: "kustomize".
+	SourceType string
+
+	// SourceInfoAsLabels mirrors the source tracking information into labels, with values
+	// sanitized to be valid label values.
+	SourceInfoAsLabels bool
+
+	// KeepOriginAnnotations keeps the config.kubernetes.io/origin annotation that source
+	// tracking makes kustomize add, instead of removing it from the output.
+	KeepOriginAnnotations bool
+
+	// DisableManagedBuildMetadata keeps source tracking from adding originAnnotations to the
+	// buildMetadata of kustomizations: see WithManagedBuildMetadata.
+	DisableManagedBuildMetadata bool
+
+	// TransformerAnnotations enables kustomize's transformerAnnotations build metadata,
+	// recording the transformers that modified each object.
+	TransformerAnnotations bool
+
+	// ManagedByLabel enables kustomize's managedByLabel build metadata, adding the
+	// app.kubernetes.io/managed-by label to every object.
+	ManagedByLabel bool
+
+	// DisableNameSuffixHash sets generatorOptions.disableNameSuffixHash in the kustomization
+	// of every source, so that the ConfigMaps and Secrets it generates keep stable names.
+	DisableNameSuffixHash bool
+
+	// NamespaceOverride sets the namespace field of the kustomization of every source.
+	NamespaceOverride string
+
+	// NamePrefix and NameSuffix are combined with the namePrefix and nameSuffix fields of
+	// the kustomization of every source.
+	NamePrefix string
+	NameSuffix string
+
+	// ReplaceOverrides makes NamespaceOverride, NamePrefix and NameSuffix replace the
+	// corresponding kustomization fields instead of being combined with them.
+	ReplaceOverrides bool
+
 	// LoadRestrictions sets renderer-wide default for load restrictions.
 	// Individual Sources can override this via Source.LoadRestrictions.
 	// Default: LoadRestrictionsRootOnly (security best practice).
 	LoadRestrictions kustomizetypes.LoadRestrictions
 
+	// Hermetic confines renders to the source tree: see WithHermetic.
+	Hermetic bool
+
+	// Deterministic fixes the time-based inputs of renders: see WithDeterministic.
+	Deterministic bool
+
 	// WarningHandler is called when kustomize deprecation warnings are detected.
-	// If nil, warnings are logged to os.Stderr by default.
+	// If nil, warnings are logged to Logger if set, and to os.Stderr otherwise.
 	WarningHandler WarningHandler
 
+	// StructuredWarningHandler is called with typed warnings when kustomize deprecation
+	// warnings are detected. If set, it takes precedence over WarningHandler.
+	StructuredWarningHandler StructuredWarningHandler
+
+	// Logger receives warnings, unless a handler is set, and diagnostics such as retries.
+	// If nil, diagnostics are discarded.
+	Logger *slog.Logger
+
+	// Tracer creates spans around the phases of every render. If nil, no span is created.
+	Tracer trace.Tracer
+
+	// WarningCollector records deprecation warnings per source, in addition to WarningHandler.
+	// If nil, warnings are not collected.
+	WarningCollector *WarningCollector
+
+	// DisableWarningScan skips the check for deprecated kustomization fields, so that no
+	// warnings are reported, handled or collected.
+	DisableWarningScan bool
+
 	// FileSystem specifies a custom filesystem to use for kustomize operations.
 	// If nil, uses the OS filesystem (filesys.MakeFsOnDisk()).
 	// This allows using embedded filesystems, in-memory filesystems, or custom implementations.
+	// Source.FileSystem overrides it for a single source.
 	FileSystem filesys.FileSystem
+
+	// ValuesConfigMapName is the metadata.name of the generated values ConfigMap.
+	// Default: "values".
+	ValuesConfigMapName string
+
+	// ValuesPatch holds the keys merged into the data of an existing values ConfigMap: see
+	// WithValuesPatch.
+	ValuesPatch map[string]string
+
+	// ValuesFileName is the path, relative to the kustomization directory, at which the
+	// values ConfigMap is injected into the overlay filesystem.
+	// Default: "values.yaml".
+	ValuesFileName string
+
+	// Concurrency is the maximum number of sources rendered in parallel by Process().
+	// Values <= 1 render sources sequentially.
+	Concurrency int
+
+	// ContinueOnError renders every source even when some fail, returning the objects of
+	// the successful sources together with the aggregated errors.
+	ContinueOnError bool
+
+	// Timeout bounds the rendering of each individual source. Zero disables the timeout.
+	Timeout time.Duration
+
+	// RetryAttempts is the maximum number of attempts to render a source failing with a
+	// transient error. Values <= 1 disable retries.
+	RetryAttempts int
+
+	// RetryBackoff is the delay before the first retry, doubled after each attempt.
+	RetryBackoff time.Duration
+
+	// RetryClassifier reports whether an error is transient. If nil, IsTransientError is used.
+	RetryClassifier RetryClassifier
+
+	// MaxResources bounds the number of resources a single source may render. Zero disables
+	// the limit.
+	MaxResources int
+
+	// MaxOutputBytes bounds the size, as YAML, of the resources a single source may render.
+	// Zero disables the limit.
+	MaxOutputBytes int
+
+	// MaxDepth bounds how deeply the kustomizations of a source may reference each other.
+	// Zero disables the limit.
+	MaxDepth int
+
+	// ValuesAsSecret emits the injected values as an Opaque v1/Secret instead of a ConfigMap.
+	ValuesAsSecret bool
+
+	// ValuesChecksumAnnotation is the pod template annotation receiving a hash of the
+	// injected values on Deployments, StatefulSets and DaemonSets. Empty = no checksum.
+	ValuesChecksumAnnotation string
+
+	// EnvValuesPrefix selects the environment variables merged into the injected values,
+	// keyed by their name without the prefix. Empty = no environment variables.
+	EnvValuesPrefix string
+
+	// ValuesLayers are merged into the injected values in order, later layers overriding
+	// earlier ones.
+	ValuesLayers []map[string]string
+
+	// Reorder selects kustomize's own resource ordering within each build (the --reorder flag
+	// of kustomize build). Default: krusty.ReorderOptionNone.
+	Reorder krusty.ReorderOption
+
+	// OutputOrder selects the ordering of the objects returned by Process.
+	// Default: OrderAsIs.
+	OutputOrder OutputOrder
+
+	// CompatibilityMode is the kustomize release whose output is approximated, set with
+	// WithCompatibilityMode. Empty = none.
+	CompatibilityMode string
+
+	// SortFunc, if set, sorts the objects returned by Process after OutputOrder.
+	SortFunc SortFunc
+
+	// CheckConflicts makes Process fail with a ConflictError when objects sharing the same
+	// GVK, namespace and name but differing in content are rendered.
+	CheckConflicts bool
+
+	// HelmGenerator enables kustomize's Helm chart inflation generator (helmCharts).
+	// nil = disabled.
+	HelmGenerator *HelmGenerator
+
+	// PluginConfig controls which non-builtin kustomize plugins (exec plugins, KRM functions)
+	// may run. nil = builtin plugins only.
+	PluginConfig *kustomizetypes.PluginConfig
+
+	// PluginHome is the directory legacy exec plugins are loaded from, used only when exec
+	// plugins are enabled. Empty = kustomize's default locations.
+	PluginHome string
+
+	// SchemaSources enables validation of the rendered objects against their OpenAPI schema,
+	// looked up in order. nil = no validation.
+	SchemaSources []SchemaSource
+
+	// Finalizers run once, in order, on the merged output of all sources in Process.
+	Finalizers []Finalizer
+
+	// ExtraResources are raw YAML manifests appended to the rendered output.
+	ExtraResources [][]byte
+}
+
+// Finalizer inspects or mutates the merged output of all sources, returning the objects to
+// emit. A returned error aborts the render.
+type Finalizer func(ctx context.Context, objects []unstructured.Unstructured) ([]unstructured.Unstructured, error)
+
+// HelmGenerator configures kustomize's Helm chart inflation generator.
+type HelmGenerator struct {
+	// Command is the helm binary, either a path or a name looked up in PATH.
+	// Default: "helm".
+	Command string
+
+	// Options tune the helm invocation.
+	Options []HelmOption
 }
 
 // ApplyTo applies the renderer options to the target configuration.
@@ -49,7 +265,16 @@ func (opts RendererOptions) ApplyTo(target *RendererOptions) {
 	target.Filters = opts.Filters
 	target.Transformers = opts.Transformers
 	target.Plugins = opts.Plugins
+
+	if opts.PluginObserver != nil {
+		target.PluginObserver = opts.PluginObserver
+	}
+
+	target.Patches = opts.Patches
+	target.ResMapFilters = opts.ResMapFilters
 	target.LoadRestrictions = opts.LoadRestrictions
+	target.Hermetic = opts.Hermetic
+	target.Deterministic = opts.Deterministic
 
 	if opts.CacheOptions != nil {
 		if target.CacheOptions == nil {
@@ -58,12 +283,165 @@ func (opts RendererOptions) ApplyTo(target *RendererOptions) {
 		opts.CacheOptions.ApplyTo(target.CacheOptions)
 	}
 
+	if opts.CacheKeyFunc != nil {
+		target.CacheKeyFunc = opts.CacheKeyFunc
+	}
+
+	if opts.CacheObserver != nil {
+		target.CacheObserver = opts.CacheObserver
+	}
+
 	target.SourceAnnotations = opts.SourceAnnotations
+	target.SourceInfoAsLabels = opts.SourceInfoAsLabels
+
+	if opts.SourceType != "" {
+		target.SourceType = opts.SourceType
+	}
+
+	target.KeepOriginAnnotations = opts.KeepOriginAnnotations
+
+	if opts.SourceAnnotationConfig != nil {
+		target.SourceAnnotationConfig = opts.SourceAnnotationConfig.clone()
+	}
+
+	target.DisableManagedBuildMetadata = opts.DisableManagedBuildMetadata
+	target.TransformerAnnotations = opts.TransformerAnnotations
+	target.ManagedByLabel = opts.ManagedByLabel
+	target.DisableNameSuffixHash = opts.DisableNameSuffixHash
+
+	if opts.NamespaceOverride != "" {
+		target.NamespaceOverride = opts.NamespaceOverride
+	}
+
+	if opts.NamePrefix != "" {
+		target.NamePrefix = opts.NamePrefix
+	}
+
+	if opts.NameSuffix != "" {
+		target.NameSuffix = opts.NameSuffix
+	}
+
+	target.ReplaceOverrides = opts.ReplaceOverrides
 	target.WarningHandler = opts.WarningHandler
+	target.StructuredWarningHandler = opts.StructuredWarningHandler
+
+	if opts.Logger != nil {
+		target.Logger = opts.Logger
+	}
+
+	if opts.Tracer != nil {
+		target.Tracer = opts.Tracer
+	}
+
+	if opts.WarningCollector != nil {
+		target.WarningCollector = opts.WarningCollector
+	}
+
+	target.DisableWarningScan = opts.DisableWarningScan
 
 	if opts.FileSystem != nil {
 		target.FileSystem = opts.FileSystem
 	}
+
+	if opts.ValuesConfigMapName != "" {
+		target.ValuesConfigMapName = opts.ValuesConfigMapName
+	}
+
+	if opts.ValuesPatch != nil {
+		target.ValuesPatch = opts.ValuesPatch
+	}
+
+	if opts.ValuesFileName != "" {
+		target.ValuesFileName = opts.ValuesFileName
+	}
+
+	target.ValuesAsSecret = opts.ValuesAsSecret
+
+	if opts.ValuesChecksumAnnotation != "" {
+		target.ValuesChecksumAnnotation = opts.ValuesChecksumAnnotation
+	}
+
+	if opts.EnvValuesPrefix != "" {
+		target.EnvValuesPrefix = opts.EnvValuesPrefix
+	}
+
+	target.ValuesLayers = opts.ValuesLayers
+
+	if opts.Concurrency > 0 {
+		target.Concurrency = opts.Concurrency
+	}
+
+	target.ContinueOnError = opts.ContinueOnError
+
+	if opts.Timeout > 0 {
+		target.Timeout = opts.Timeout
+	}
+
+	if opts.RetryAttempts > 0 {
+		target.RetryAttempts = opts.RetryAttempts
+	}
+
+	if opts.RetryBackoff > 0 {
+		target.RetryBackoff = opts.RetryBackoff
+	}
+
+	if opts.RetryClassifier != nil {
+		target.RetryClassifier = opts.RetryClassifier
+	}
+
+	if opts.MaxResources > 0 {
+		target.MaxResources = opts.MaxResources
+	}
+
+	if opts.MaxOutputBytes > 0 {
+		target.MaxOutputBytes = opts.MaxOutputBytes
+	}
+
+	if opts.MaxDepth > 0 {
+		target.MaxDepth = opts.MaxDepth
+	}
+
+	if opts.Reorder != "" {
+		target.Reorder = opts.Reorder
+	}
+
+	if opts.OutputOrder != "" {
+		target.OutputOrder = opts.OutputOrder
+	}
+
+	if opts.CompatibilityMode != "" {
+		target.CompatibilityMode = opts.CompatibilityMode
+	}
+
+	if opts.SortFunc != nil {
+		target.SortFunc = opts.SortFunc
+	}
+
+	target.CheckConflicts = opts.CheckConflicts
+
+	if opts.HelmGenerator != nil {
+		target.HelmGenerator = opts.HelmGenerator
+	}
+
+	if opts.PluginConfig != nil {
+		target.PluginConfig = clonePluginConfig(opts.PluginConfig)
+	}
+
+	if opts.PluginHome != "" {
+		target.PluginHome = opts.PluginHome
+	}
+
+	if opts.SchemaSources != nil {
+		target.SchemaSources = opts.SchemaSources
+	}
+
+	if opts.Finalizers != nil {
+		target.Finalizers = opts.Finalizers
+	}
+
+	if opts.ExtraResources != nil {
+		target.ExtraResources = opts.ExtraResources
+	}
 }
 
 // WithFilter adds a renderer-specific filter to this Kustomize renderer's processing chain.
@@ -85,12 +463,77 @@ func WithTransformer(t types.Transformer) RendererOption {
 }
 
 // WithPlugin registers a plugin transformer (resmap.Transformer) for kustomize.
+// Plugins are applied in registration order, except that consecutive ReadOnlyTransformer
+// plugins are applied concurrently; errors name the failing plugin by its NamedTransformer
+// name, or by its Go type.
 func WithPlugin(plugin resmap.Transformer) RendererOption {
 	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
 		opts.Plugins = append(opts.Plugins, plugin)
 	})
 }
 
+// WithNamedPlugin registers a plugin transformer under the given name, as a NamedTransformer.
+func WithNamedPlugin(name string, plugin resmap.Transformer) RendererOption {
+	return WithPlugin(NamedTransformer{Name: name, Transformer: plugin})
+}
+
+// WithPluginObserver registers a callback receiving, for every source, the number of
+// resources each plugin transformer added, removed and modified. Counting snapshots the
+// resources around every plugin, so it is only done when an observer is set. Read-only
+// plugins are not snapshotted and always report no changes.
+//
+// Like WithCacheObserver, the observer is called synchronously and, when WithConcurrency
+// is used, concurrently.
+func WithPluginObserver(observer PluginObserver) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.PluginObserver = observer
+	})
+}
+
+// WithResMapFilter registers a filter of the kustomize ResMap of every source, applied after
+// plugins, patches and Source.OnlyFromPath, right before the resources are converted to
+// unstructured objects. Unlike WithFilter, it works on kustomize's native resources, so it
+// can use their metadata (e.g. origin and reference IDs) and skips the conversion of the
+// resources it removes. Filters are applied in registration order; an error fails the render.
+//
+// Example:
+//
+//	kustomize.WithResMapFilter(func(m resmap.ResMap) error {
+//		for _, res := range m.Resources() {
+//			if res.GetKind() == "Secret" {
+//				if err := m.Remove(res.CurId()); err != nil {
+//					return err
+//				}
+//			}
+//		}
+//		return nil
+//	})
+func WithResMapFilter(filter ResMapFilter) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ResMapFilters = append(opts.ResMapFilters, filter)
+	})
+}
+
+// WithPatch applies a strategic merge or JSON 6902 patch to the output of every source, as
+// if it were listed in the patches field of each kustomization, e.g. to inject
+// environment-specific tweaks into immutable bases. Patches run after the plugins registered
+// with WithPlugin, in the order they are added. New fails with ErrInvalidPatch if the patch
+// cannot be parsed, and a render fails if a strategic merge patch without target matches
+// no resource.
+//
+// Example:
+//
+//	kustomize.WithPatch(kustomize.Patch{
+//	    Type:   kustomize.PatchJSON6902,
+//	    Patch:  []byte(`[{"op": "replace", "path": "/spec/replicas", "value": 3}]`),
+//	    Target: &kustomizetypes.Selector{ResId: resid.NewResId(resid.Gvk{Kind: "Deployment"}, "app")},
+//	})
+func WithPatch(patch Patch) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Patches = append(opts.Patches, patch)
+	})
+}
+
 // WithCache enables render result caching with the specified options.
 // If no options are provided, uses default TTL of 5 minutes.
 // By default, caching is NOT enabled.
@@ -106,6 +549,46 @@ func WithCache(opts ...cache.Option) RendererOption {
 	})
 }
 
+// WithCacheKeyFunc selects the function computing cache keys from the KustomizationSpec
+// of each render, e.g. ContentCacheKey() to invalidate entries when source files change.
+// The key is computed once per render and used for both lookup and store. It takes
+// precedence over a KeyFunc passed through WithCache. Has no effect unless caching is enabled.
+// Default: DefaultCacheKey.
+//
+// Example:
+//
+//	kustomize.New(sources,
+//	    kustomize.WithCache(),
+//	    kustomize.WithCacheKeyFunc(kustomize.ContentCacheKey()),
+//	)
+func WithCacheKeyFunc(fn CacheKeyFunc) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.CacheKeyFunc = fn
+	})
+}
+
+// WithCacheObserver registers a callback notified of render cache activity, e.g. to feed
+// Prometheus counters. For every cached render it receives either a hit, or a miss followed
+// by a store (render succeeded) or a render-failed event (nothing was stored).
+// Has no effect unless caching is enabled.
+//
+// The observer is called synchronously on the rendering goroutine, and concurrently when
+// WithConcurrency is used, so it must be cheap and safe for concurrent use.
+//
+// Example:
+//
+//	kustomize.New(sources,
+//	    kustomize.WithCache(),
+//	    kustomize.WithCacheObserver(func(e kustomize.CacheEvent) {
+//	        cacheEvents.WithLabelValues(string(e.Type)).Inc()
+//	    }),
+//	)
+func WithCacheObserver(observer CacheObserver) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.CacheObserver = observer
+	})
+}
+
 // WithSourceAnnotations enables or disables automatic addition of source tracking annotations.
 // When enabled, the renderer adds metadata annotations to track the source type and path.
 // Annotations added: manifests.k8s-manifests-lib/source.type, source.path.
@@ -116,6 +599,147 @@ func WithSourceAnnotations(enabled bool) RendererOption {
 	})
 }
 
+// WithSourceAnnotationConfig enables source tracking annotations with custom keys and
+// extra static annotations. cfg.Prefix replaces the manifests.k8s-manifests-lib prefix of
+// the source.type, source.path and source.file keys; cfg.Extra is added to every object.
+//
+// Example:
+//
+//	kustomize.WithSourceAnnotationConfig(kustomize.SourceAnnotationConfig{
+//	    Prefix: "example.com",
+//	    Extra:  map[string]string{"example.com/pipeline": "release"},
+//	})
+func WithSourceAnnotationConfig(cfg SourceAnnotationConfig) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.SourceAnnotations = true
+		opts.SourceAnnotationConfig = cfg.clone()
+	})
+}
+
+// WithSourceType sets the value of the source.type annotation (and label, see
+// WithSourceInfoAsLabels), e.g. to present a product name instead of "kustomize". It does
+// not enable source tracking by itself, and Name still reports "kustomize".
+// Default: "kustomize".
+func WithSourceType(value string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.SourceType = value
+	})
+}
+
+// WithSourceInfoAsLabels enables or disables mirroring the source tracking information into
+// labels, so it can be used with label selectors. Labels use the same keys as the source
+// annotations (see WithSourceAnnotationConfig) and work with or without the annotations.
+//
+// Label values are limited to 63 characters from [A-Za-z0-9-_.], so values are transformed:
+// invalid characters become '_' (source.path "/srv/app/overlays/prod" is labeled
+// "srv_app_overlays_prod") and longer values are shortened to a hash prefix followed by
+// their tail. Use the annotations to recover the exact paths.
+// Default: false (disabled).
+func WithSourceInfoAsLabels(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.SourceInfoAsLabels = enabled
+	})
+}
+
+// WithKeepOriginAnnotations keeps kustomize's config.kubernetes.io/origin annotation, with
+// the path, repo and ref each object was loaded from, on the output. Source tracking asks
+// kustomize for origin annotations to compute the source.file annotation; they are removed
+// afterwards unless this option is enabled. Kustomizations requesting originAnnotations in
+// their buildMetadata always keep them.
+// Default: false (removed).
+func WithKeepOriginAnnotations(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.KeepOriginAnnotations = enabled
+	})
+}
+
+// WithManagedBuildMetadata enables or disables the originAnnotations build metadata that
+// source tracking adds to the kustomization of every source. Disabling it leaves
+// kustomizations managing their own buildMetadata exactly as written: objects are then
+// tracked by source.type and source.path only, and get no source.file annotation unless the
+// kustomization declares originAnnotations itself. Sources filtering by origin
+// (Source.OnlyFromPath) fail with ErrOriginTrackingRequired in that case.
+//
+// Build metadata explicitly requested with WithTransformerAnnotations or WithManagedByLabel
+// is still added.
+// Default: true.
+func WithManagedBuildMetadata(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.DisableManagedBuildMetadata = !enabled
+	})
+}
+
+// WithTransformerAnnotations enables or disables kustomize's transformerAnnotations build
+// metadata: every object modified by a transformer (namePrefix, patches, labels, ...) gets an
+// alpha.config.kubernetes.io/transformations annotation listing those transformers and
+// where they were configured. Kustomizations already declaring it are left untouched.
+// Default: false (disabled).
+func WithTransformerAnnotations(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.TransformerAnnotations = enabled
+	})
+}
+
+// WithManagedByLabel enables or disables kustomize's managedByLabel build metadata, which
+// labels every object with app.kubernetes.io/managed-by set to the kustomize version.
+// Kustomizations already declaring it are left untouched.
+// Default: false (disabled).
+func WithManagedByLabel(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ManagedByLabel = enabled
+	})
+}
+
+// WithDisableNameSuffixHash enables or disables the content hash suffix kustomize appends to
+// the names of generated ConfigMaps and Secrets (e.g. app-config-5g2m7f8d2t). When disabled,
+// generatorOptions.disableNameSuffixHash is set in the kustomization of every source, so
+// that the generated objects of that kustomization keep their declared names. Bases and
+// components have their own generatorOptions and are left untouched. The values ConfigMap
+// is a plain resource and never carries a suffix.
+// Default: false (suffix hash enabled).
+func WithDisableNameSuffixHash(disabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.DisableNameSuffixHash = disabled
+	})
+}
+
+// WithNamespaceOverride sets the namespace of the kustomization of every source at render
+// time, as its namespace field would, so that one base can be rendered into several
+// environments without editing files. A kustomization already setting another namespace
+// fails the render with ErrOverrideConflict, unless WithReplaceOverrides is enabled.
+func WithNamespaceOverride(namespace string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.NamespaceOverride = namespace
+	})
+}
+
+// WithNamePrefix adds a name prefix to the kustomization of every source at render time. It
+// is prepended to the namePrefix of the kustomization, as an overlay's prefix would be
+// (e.g. "prod-" and "app-" yield prod-app-config), unless WithReplaceOverrides is enabled.
+func WithNamePrefix(prefix string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.NamePrefix = prefix
+	})
+}
+
+// WithNameSuffix adds a name suffix to the kustomization of every source at render time. It
+// is appended to the nameSuffix of the kustomization, as an overlay's suffix would be,
+// unless WithReplaceOverrides is enabled.
+func WithNameSuffix(suffix string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.NameSuffix = suffix
+	})
+}
+
+// WithReplaceOverrides makes WithNamespaceOverride, WithNamePrefix and WithNameSuffix replace
+// the namespace, namePrefix and nameSuffix of the kustomization instead of merging with them.
+// Default: false.
+func WithReplaceOverrides(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ReplaceOverrides = enabled
+	})
+}
+
 // WithLoadRestrictions sets the renderer-wide default LoadRestrictions.
 // Valid values: LoadRestrictionsRootOnly (default), LoadRestrictionsNone, LoadRestrictionsUnknown.
 // Individual Sources can override this via Source.LoadRestrictions field.
@@ -128,12 +752,51 @@ func WithLoadRestrictions(restrictions kustomizetypes.LoadRestrictions) Renderer
 	})
 }
 
+// WithHermetic guarantees that renders touch nothing outside of the source tree, for
+// security-sensitive CI. When enabled:
+//   - LoadRestrictionsRootOnly applies to every source, whatever WithLoadRestrictions or
+//     Source.LoadRestrictions say
+//   - before each build, the kustomization tree is scanned and the render fails with a
+//     HermeticViolationError (matching ErrHermeticViolation) listing every remote or absolute
+//     reference, and every Helm chart pulled from a repository
+//   - symlinks escaping the source tree, the deepest directory containing every scanned
+//     kustomization, are denied (see adapter.SymlinkDeny), through the wrappers of the fs
+//     and union packages too; symlinks to sibling directories of the tree are followed
+//   - New fails with ErrHermeticViolation if exec plugins or KRM functions are enabled, or
+//     if a filesystem passed to WithFileSystem or Source.FileSystem cannot deny symlinks,
+//     i.e. is not backed by an adapter (see adapter.ConfigureSymlinks)
+//
+// Default: false.
+func WithHermetic(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Hermetic = enabled
+	})
+}
+
+// WithDeterministic makes repeated renders of identical inputs produce byte-identical output
+// and cache keys, for reproducible builds. When enabled:
+//   - exec and containerized KRM functions run with SOURCE_DATE_EPOCH=0, the reproducible
+//     builds convention for the current time, unless WithFunctionEnv sets it
+//   - ContentCacheKey hashes the contents of files but not their modification times, so
+//     keys do not change when a tree is checked out again
+//
+// The annotations and objects added by the renderer itself (source tracking, values objects
+// and checksums) depend on the inputs only, with or without this option. Helm charts are
+// rendered by the helm binary, whose "now" and random functions cannot be fixed.
+//
+// Default: false.
+func WithDeterministic(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Deterministic = enabled
+	})
+}
+
 // WithWarningHandler sets a custom handler for kustomize deprecation warnings.
 // The handler receives a list of warning messages and can choose to log them, fail, or ignore them.
 // Use pre-built handlers like WarningLog(w), WarningFail(), or WarningIgnore(),
 // or provide a custom function.
 //
-// Default: WarningLog(os.Stderr) if not set.
+// Default: WarningLogger(logger) if WithLogger is set, WarningLog(os.Stderr) otherwise.
 //
 // Example:
 //
@@ -144,6 +807,72 @@ func WithWarningHandler(handler WarningHandler) RendererOption {
 	})
 }
 
+// WithStructuredWarningHandler sets a handler receiving typed kustomize warnings.
+// It takes precedence over WithWarningHandler. Combine it with WarningFilter to act
+// only on specific warnings, and use AdaptWarningHandler to reuse string-based handlers.
+//
+// Example:
+//
+//	kustomize.New(sources, kustomize.WithStructuredWarningHandler(
+//	    kustomize.WarningFilter(
+//	        func(w kustomize.Warning) bool { return w.Field == "bases" },
+//	        kustomize.AdaptWarningHandler(kustomize.WarningFail()),
+//	    ),
+//	))
+func WithStructuredWarningHandler(handler StructuredWarningHandler) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.StructuredWarningHandler = handler
+	})
+}
+
+// WithLogger sets a structured logger for the renderer. Warnings are logged to it at warn
+// level with their source path, unless a warning handler is set, and diagnostics are logged
+// at lower levels: retried renders at info, rendered sources with their object count, cache
+// status and duration at debug. Wrap a logr.Logger with slog.New(logr.ToSlogHandler(l)).
+// Default: warnings are written to os.Stderr and diagnostics discarded.
+func WithLogger(logger *slog.Logger) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Logger = logger
+	})
+}
+
+// WithTracer traces renders with OpenTelemetry: every render attempt of a source gets a
+// SpanRun span, child of the span of the render context, with one child span per phase
+// (reading the kustomization, preparing the filesystem, the kustomize build, plugin
+// transformers and conversion), to pinpoint which phase dominates the latency of slow
+// kustomizations. Spans carry the source path and the number of objects produced; failed
+// phases record their error. Default: no tracing, at no cost.
+//
+// Example:
+//
+//	kustomize.New(sources, kustomize.WithTracer(otel.Tracer("renderer")))
+func WithTracer(tracer trace.Tracer) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Tracer = tracer
+	})
+}
+
+// WithWarningCollector records kustomize deprecation warnings into the given collector,
+// attributed to the source path that produced them. Collection happens before the
+// WarningHandler is invoked, so both can be used together.
+func WithWarningCollector(collector *WarningCollector) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.WarningCollector = collector
+	})
+}
+
+// WithWarningScan enables or disables the check of every kustomization for deprecated
+// fields. Disabling it saves work when rendering trusted kustomizations at high rates: no
+// warnings are reported by RenderDetailed, passed to the warning handler or recorded by the
+// warning collector.
+//
+// Default: true.
+func WithWarningScan(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.DisableWarningScan = !enabled
+	})
+}
+
 // WithFileSystem sets a custom filesystem for kustomize operations.
 // This allows using embedded filesystems (via embed.FS), in-memory filesystems for testing,
 // or any custom filesystem implementation.
@@ -166,3 +895,464 @@ func WithFileSystem(fs filesys.FileSystem) RendererOption {
 		opts.FileSystem = fs
 	})
 }
+
+// WithAferoFs sets an afero.Fs as filesystem for kustomize operations, e.g. one created with
+// afero.NewBasePathFs or afero.NewHttpFs. It is equivalent to
+// WithFileSystem(adapter.New(afs)).
+//
+// Example:
+//
+//	renderer := kustomize.New(sources, kustomize.WithAferoFs(afero.NewBasePathFs(afero.NewOsFs(), "/srv")))
+func WithAferoFs(afs afero.Fs) RendererOption {
+	return WithFileSystem(adapter.New(afs))
+}
+
+// WithValuesConfigMap sets the name of the generated values ConfigMap and the file it is
+// injected as, relative to the kustomization directory. Empty arguments keep the defaults
+// ("values" and "values.yaml").
+//
+// Rendering fails if a file already exists at the chosen path in the base filesystem,
+// so user content is never silently shadowed. Use this option when a kustomization
+// legitimately ships its own values.yaml.
+//
+// Example:
+//
+//	kustomize.New(sources, kustomize.WithValuesConfigMap("app-values", "generated/app-values.yaml"))
+func WithValuesConfigMap(name string, fileName string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		if name != "" {
+			opts.ValuesConfigMapName = name
+		}
+		if fileName != "" {
+			opts.ValuesFileName = fileName
+		}
+	})
+}
+
+// WithValuesPatch overrides keys of a values ConfigMap the kustomizations already declare or
+// generate, instead of injecting a new one: values are merged into the data of the
+// ConfigMap named by WithValuesConfigMap (default "values"), matched by its name before
+// prefixes, suffixes and hashes are added. The patch is applied by kustomize itself, so the
+// name hash of a generated ConfigMap and the references to it are updated. Rendering a
+// source without such ConfigMap fails with ErrValuesPatchTarget.
+//
+// Example:
+//
+//	kustomize.New(sources,
+//	    kustomize.WithValuesConfigMap("app-config", ""),
+//	    kustomize.WithValuesPatch(map[string]string{"LOG_LEVEL": "debug"}),
+//	)
+func WithValuesPatch(values map[string]string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ValuesPatch = maps.Clone(values)
+	})
+}
+
+// WithValuesAsSecret makes the renderer inject values as an Opaque v1/Secret instead of a
+// ConfigMap. The values are written to stringData, which the API server base64-encodes into
+// data on apply. The object name and file are still controlled by WithValuesConfigMap.
+//
+// The Secret is injected as the values file the kustomizations list in their resources, like
+// the ConfigMap, rather than through a secretGenerator: a generator would require the
+// kustomizations to declare it instead of the file, and would add a name hash that
+// references to the values would have to follow.
+//
+// When enabled, source annotations never point source.file at the injected values file,
+// and cache keys only ever contain a hash of the values.
+// Default: false (values are injected as a ConfigMap).
+func WithValuesAsSecret(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ValuesAsSecret = enabled
+	})
+}
+
+// WithValuesChecksumAnnotation stamps a hash of the values injected into each source on the
+// pod template (spec.template.metadata.annotations) of its Deployments, StatefulSets and
+// DaemonSets, under the given annotation key, e.g. "checksum/values". Pods then roll out
+// whenever the values change, like the checksum annotations of Helm charts. The hash covers
+// the merged values (environment, source and render-time values) and does not depend on
+// their order; sources rendered without values are left untouched.
+// Default: disabled.
+func WithValuesChecksumAnnotation(key string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ValuesChecksumAnnotation = key
+	})
+}
+
+// WithEnvValues merges the environment variables whose name starts with prefix into the
+// injected values, keyed by their name without the prefix: with prefix "APP_", APP_REPLICAS=3
+// becomes the value "REPLICAS". The environment is read at every render. Values layers, source
+// values and render-time values take precedence over environment values with the same key.
+//
+// Values are quoted as needed in the generated ConfigMap, so they may contain any
+// character. An empty prefix disables the option rather than injecting the whole environment.
+// Default: no environment values.
+func WithEnvValues(prefix string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.EnvValuesPrefix = prefix
+	})
+}
+
+// WithValuesLayer adds a layer of values injected into every source, e.g. defaults, then
+// team values, then environment values. Layers are merged in registration order, the last
+// layer winning on conflicting keys, so that callers no longer merge maps themselves.
+//
+// Layers have a lower precedence than the values of the source (Source.Values and
+// Source.StructuredValues) and render-time values, and a higher one than the environment
+// values selected with WithEnvValues. The map is copied; later changes do not affect the
+// renderer.
+//
+// Example:
+//
+//	kustomize.New(sources,
+//		kustomize.WithValuesLayer(defaults),
+//		kustomize.WithValuesLayer(teamValues),
+//		kustomize.WithValuesLayer(envValues),
+//	)
+func WithValuesLayer(m map[string]string) RendererOption {
+	layer := maps.Clone(m)
+
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ValuesLayers = append(opts.ValuesLayers, layer)
+	})
+}
+
+// WithConcurrency renders up to n sources in parallel using a worker pool.
+// Output keeps source order regardless of completion order. When sources fail,
+// every error (each wrapped with its source path) is aggregated via errors.Join.
+//
+// Source Values functions may be invoked concurrently when n > 1.
+// Default: 1 (sequential rendering).
+func WithConcurrency(n int) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Concurrency = n
+	})
+}
+
+// WithContinueOnError selects how a render reacts to failing sources. By default it fails
+// closed: the first failure aborts the render and no objects are returned. When enabled, it
+// fails open: every source is rendered, and Process returns the objects of the successful
+// sources together with the errors of the failed ones, each wrapped with its source path and
+// aggregated via errors.Join. Callers must check both results, e.g. to report all broken
+// overlays of a CI job at once; RenderDetailed reports the error of every failed source in
+// SourceResult.Err. Schema validation, conflict and finalizer errors still abort the render.
+// Default: false.
+func WithContinueOnError(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ContinueOnError = enabled
+	})
+}
+
+// WithTimeout bounds the rendering of each source, even when Process is called with a
+// context without deadline. The timeout covers the whole engine run for that source:
+// reading the kustomization, preparing the filesystem, the kustomize build and plugin
+// transformers. On expiry the error wraps ErrRenderTimeout and context.DeadlineExceeded
+// and names the source path and elapsed time.
+// Default: no timeout.
+func WithTimeout(d time.Duration) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Timeout = d
+	})
+}
+
+// WithRetry retries the rendering of a source failing with a transient error, up to attempts
+// runs in total. The first retry waits backoff, and the delay doubles after each attempt;
+// waiting stops when the context is done. Errors are classified by IsTransientError unless
+// WithRetryClassifier is set: invalid kustomizations, load restriction violations and
+// other permanent errors are returned immediately. Each attempt gets its own WithTimeout.
+// Default: no retry.
+func WithRetry(attempts int, backoff time.Duration) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.RetryAttempts = attempts
+		opts.RetryBackoff = backoff
+	})
+}
+
+// WithRetryClassifier sets the function deciding which errors WithRetry retries.
+// Default: IsTransientError.
+func WithRetryClassifier(classifier RetryClassifier) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.RetryClassifier = classifier
+	})
+}
+
+// WithMaxResources limits the number of resources each source may render, protecting
+// rendering services from pathological kustomizations such as generator loops. The limit is
+// checked right after the kustomize build; when exceeded the render fails with an error
+// wrapping ErrResourceLimitExceeded that names the source path and the limit.
+// Default: no limit.
+func WithMaxResources(n int) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.MaxResources = n
+	})
+}
+
+// WithMaxOutputBytes limits the size of the resources each source may render, measured as
+// their YAML serialization (as printed by kustomize build). The limit is checked right after
+// the kustomize build, stopping at the first resource exceeding it; the error wraps
+// ErrResourceLimitExceeded and names the source path and the limit.
+// Default: no limit.
+func WithMaxOutputBytes(n int) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.MaxOutputBytes = n
+	})
+}
+
+// WithMaxDepth limits how many levels of local kustomizations, referenced through resources,
+// bases and components, a source may nest: with n = 1, the kustomization of the source may
+// reference other kustomizations, but these may not. Like the cycle check, the limit is
+// enforced by scanning the kustomization files before the kustomize build, so deep or
+// accidentally recursive chains fail fast; the render (or ListInputs) fails with an error
+// wrapping ErrMaxDepthExceeded that shows the offending chain. Remote bases are not counted.
+// Default: no limit.
+func WithMaxDepth(n int) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.MaxDepth = n
+	})
+}
+
+// WithOutputOrdering selects how the objects returned by Process are ordered:
+//   - OrderAsIs keeps kustomize's output order (default)
+//   - OrderApply sorts into a safe apply order (Namespaces and CRDs first, custom resources last)
+//   - OrderAlphabetical sorts by kind, namespace and name
+//
+// Ordering applies to the combined output of all sources, after filters and transformers.
+// The sort is stable: objects of equal priority keep their rendered order.
+func WithOutputOrdering(order OutputOrder) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.OutputOrder = order
+	})
+}
+
+// WithSortFunc imposes a custom order on the objects returned by Process, e.g. for golden
+// files ordered by namespace, name and kind. The function compares two objects like
+// cmp.Compare. It runs as the last step of Process, after WithOutputOrdering; the sort is
+// stable, so objects comparing equal keep the order selected by WithOutputOrdering.
+//
+// Example:
+//
+//	kustomize.WithSortFunc(func(a, b unstructured.Unstructured) int {
+//	    return cmp.Or(
+//	        cmp.Compare(a.GetNamespace(), b.GetNamespace()),
+//	        cmp.Compare(a.GetName(), b.GetName()),
+//	        cmp.Compare(a.GetKind(), b.GetKind()),
+//	    )
+//	})
+func WithSortFunc(fn SortFunc) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.SortFunc = fn
+	})
+}
+
+// WithReorder selects how kustomize orders the resources of each build, like the --reorder
+// flag of kustomize build:
+//   - krusty.ReorderOptionNone keeps the depth-first input order of the kustomization (default)
+//   - krusty.ReorderOptionLegacy sorts Namespaces, CRDs and other cluster resources first,
+//     then the rest by kind and name, reproducing the output of older kustomize releases
+//   - krusty.ReorderOptionUnspecified lets kustomize pick its default (currently legacy)
+//
+// A sortOptions field in the kustomization always takes precedence. Reordering happens inside
+// each source's build; WithOutputOrdering orders the combined output of all sources afterwards.
+func WithReorder(reorder krusty.ReorderOption) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Reorder = reorder
+	})
+}
+
+// WithCompatibilityMode approximates the output of kustomize build of an older kustomize
+// release, e.g. to compare against golden files generated with it. The version is a
+// kustomize version from v3 to v5, such as "v4", "v4.5.7" or "5.0", omitted numbers being
+// zero; New fails with ErrInvalidCompatibilityMode for any other value.
+//
+// Reproduced:
+//   - the resource order of kustomize build, whose --reorder flag defaults to legacy in every
+//     release while this renderer defaults to krusty.ReorderOptionNone. The option sets the
+//     ordering like WithReorder(krusty.ReorderOptionLegacy); a later WithReorder wins.
+//   - label propagation. Before v3.9.1, commonLabels did not reach the topologySpreadConstraints
+//     selectors of Deployments and StatefulSets: commonLabels are rewritten into a labels entry
+//     with the field specs of those releases. Before v5, labels[].includeTemplates only labeled
+//     existing spec/template/metadata/labels fields: such entries are rewritten to that field
+//     spec. Releases without the labels field (before v4.1) or without includeTemplates
+//     (before v4.5.5) rejected them, so the render fails with ErrIncompatibleKustomization.
+//     The rewrites apply to the local kustomizations of the source tree; remote bases are
+//     built as they are.
+//
+// Not reproduced: the other transformers follow the kustomize version built into the
+// renderer, as kustomize has no switch to restore the behavior of an older release. This
+// includes name reference resolution, and commonLabels field specs added through the
+// configurations field of releases before v3.9.1, which the labels rewrite does not carry
+// over. Output differing in those respects requires regenerating the golden files.
+func WithCompatibilityMode(version string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.CompatibilityMode = version
+		opts.Reorder = compatibilityReorder
+	})
+}
+
+// WithConflictCheck enables or disables detection of conflicting objects in the combined
+// output of all sources. When enabled, Process fails if objects share the same GVK,
+// namespace and name but differ in content (e.g. two overlays patching the same base
+// differently), instead of returning both copies and leaving the last one to win on apply.
+// The returned *ConflictError matches ErrResourceConflict and lists every conflicting pair
+// with the source path (and, with source annotations enabled, the file) of each copy.
+// Identical copies are not reported.
+// Default: false (disabled).
+func WithConflictCheck(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.CheckConflicts = enabled
+	})
+}
+
+// WithHelmGenerator enables kustomize's Helm chart inflation generator, so kustomizations
+// using helmCharts render like `kustomize build --enable-helm`. helmPath is the helm binary,
+// either a path or a name looked up in PATH; empty uses "helm". New fails with
+// ErrHelmNotFound if the binary cannot be found.
+//
+// helm runs as a subprocess against the real filesystem: local charts must live on disk
+// under the chart home, and remote charts are pulled into it. With LoadRestrictionsRootOnly
+// the chart home (helmGlobals.chartHome) must be located below the kustomization root,
+// otherwise rendering fails with ErrLoadRestrictionViolation.
+// Default: disabled.
+//
+// Example:
+//
+//	kustomize.New(sources, kustomize.WithHelmGenerator("/usr/local/bin/helm",
+//	    kustomize.WithHelmKubeVersion("1.31.0"),
+//	))
+func WithHelmGenerator(helmPath string, opts ...HelmOption) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(rendererOpts *RendererOptions) {
+		rendererOpts.HelmGenerator = &HelmGenerator{
+			Command: helmPath,
+			Options: opts,
+		}
+	})
+}
+
+// WithPluginConfig sets the kustomize plugin configuration used for every build, for full
+// control over plugin restrictions and function runtime settings. The configuration is
+// copied. WithHelmGenerator, if also set, overrides its HelmConfig.
+//
+// SECURITY: configurations lifting PluginRestrictionsBuiltinsOnly let kustomizations run
+// arbitrary programs on the rendering host. Only use them with trusted kustomizations.
+// Default: builtin plugins only.
+func WithPluginConfig(cfg *kustomizetypes.PluginConfig) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.PluginConfig = clonePluginConfig(cfg)
+	})
+}
+
+// WithExecPlugins allows kustomizations to run exec plugins and exec KRM functions
+// (config.kubernetes.io/function with an exec path) declared in generators, transformers
+// and validators. kustomize cannot allow exec without lifting the builtins-only restriction,
+// so this also allows containerized KRM functions (see WithKRMFunctions) and plugins from
+// the kustomize plugin home. When exec is not enabled, exec functions are silently skipped
+// by kustomize rather than reported.
+//
+// SECURITY: exec plugins run arbitrary binaries with the privileges of the rendering
+// process, chosen by whoever controls the kustomization. Never enable this for
+// kustomizations from untrusted sources, such as user-supplied or unpinned remote bases.
+// Default: false (disabled).
+func WithExecPlugins(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		if opts.PluginConfig == nil {
+			opts.PluginConfig = lockedDownPluginConfig()
+		}
+
+		opts.PluginConfig.FnpLoadingOptions.EnableExec = enabled
+
+		if enabled {
+			enableNonBuiltinPlugins(opts.PluginConfig)
+		}
+	})
+}
+
+// WithPluginHome sets the directory legacy exec plugins are loaded from, instead of
+// kustomize's default locations ($KUSTOMIZE_PLUGIN_HOME, then $XDG_CONFIG_HOME/kustomize/plugin).
+// A plugin of a given apiVersion and kind is the executable
+// <dir>/<group>/<version>/<lowercase kind>/<kind>.
+//
+// The directory is only used when WithExecPlugins is enabled; New then fails with
+// ErrPluginHomeNotFound if it does not exist. kustomize only reads the plugin home from the
+// environment, so builds using different plugin homes are serialized. With a custom
+// filesystem (WithFileSystem), the directory must also exist in that filesystem.
+//
+// SECURITY: every executable in the directory may be run by kustomizations referencing it.
+// Default: unset, and ignored unless exec plugins are enabled.
+func WithPluginHome(dir string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.PluginHome = dir
+	})
+}
+
+// WithKRMFunctions allows kustomizations to run containerized KRM functions
+// (config.kubernetes.io/function with a container image). Functions run through the
+// local container runtime (docker), without network access unless WithFunctionNetwork
+// is given. Exec functions stay disabled unless WithExecPlugins is also set.
+//
+// SECURITY: kustomizations choose the images that are pulled and run, and mounts and
+// network access widen what those containers can reach. Only enable this for trusted
+// kustomizations.
+// Default: disabled.
+//
+// Example:
+//
+//	kustomize.New(sources, kustomize.WithKRMFunctions(
+//	    kustomize.WithFunctionEnv("REGISTRY"),
+//	))
+func WithKRMFunctions(fnOpts ...FunctionOption) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		if opts.PluginConfig == nil {
+			opts.PluginConfig = lockedDownPluginConfig()
+		}
+
+		enableNonBuiltinPlugins(opts.PluginConfig)
+
+		for _, fnOpt := range fnOpts {
+			fnOpt(&opts.PluginConfig.FnpLoadingOptions)
+		}
+	})
+}
+
+// WithSchemaValidation validates every rendered object against its OpenAPI schema, failing
+// the render with a SchemaValidationError listing all violations by object. Schemas are looked
+// up in the given sources in order, so CRD schemas can be listed before the built-in ones;
+// objects whose kind no source knows are not validated.
+// Default: disabled, so no schema is loaded.
+//
+// Example:
+//
+//	k8s, err := kustomize.KubernetesSchema()
+//	crds, err := kustomize.NewCRDSchema(crdObjects...)
+//	renderer, err := kustomize.New(sources, kustomize.WithSchemaValidation(crds, k8s))
+func WithSchemaValidation(sources ...SchemaSource) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.SchemaSources = append(opts.SchemaSources, sources...)
+	})
+}
+
+// WithFinalizer adds a hook invoked once by Process with the merged output of all sources,
+// after conflict checking and before output ordering. Unlike transformers, which run per
+// source, a finalizer sees the whole object set: it can enforce invariants across sources or
+// derive data from all of them. Returning an error aborts the render. Finalizers run in the
+// order they were added; RenderDetailed, which keeps objects grouped by source, does not run them.
+func WithFinalizer(f Finalizer) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Finalizers = append(opts.Finalizers, f)
+	})
+}
+
+// WithExtraResources appends raw YAML manifests, each holding one or more documents, to the
+// rendered output without modifying any kustomization, e.g. a standard NetworkPolicy. The
+// objects are added once per render, after the objects of all sources: they are not
+// processed by kustomize nor by the renderer filters and transformers, but are subject to
+// schema validation, conflict checking and finalizers. Every document must declare
+// apiVersion, kind and metadata.name; New fails with ErrInvalidExtraResource otherwise.
+//
+// Example:
+//
+//	kustomize.New(sources, kustomize.WithExtraResources(networkPolicy))
+func WithExtraResources(manifests ...[]byte) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.ExtraResources = append(opts.ExtraResources, manifests...)
+	})
+}
@@ -0,0 +1,149 @@
+package kustomize
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// OutputOrder selects how rendered objects are ordered in the output of Process.
+type OutputOrder string
+
+const (
+	// OrderAsIs keeps the order produced by kustomize, source by source.
+	OrderAsIs OutputOrder = "as-is"
+
+	// OrderApply sorts objects into a safe apply order: Namespaces and CRDs first, then
+	// cluster and namespace configuration, RBAC, services and workloads, with custom
+	// resources and other unknown kinds last. Modeled on Helm's install order.
+	OrderApply OutputOrder = "apply"
+
+	// OrderAlphabetical sorts objects by kind, namespace and name.
+	OrderAlphabetical OutputOrder = "alphabetical"
+)
+
+var (
+	// ErrInvalidOutputOrder is returned by New when an unknown OutputOrder is configured.
+	ErrInvalidOutputOrder = errors.New("invalid output order")
+
+	// ErrInvalidReorder is returned by New when an unknown kustomize reorder option is configured.
+	ErrInvalidReorder = errors.New("invalid reorder option")
+)
+
+// SortFunc compares two objects, returning a negative number when a sorts before b, a
+// positive number when a sorts after b, and zero when their order does not matter.
+type SortFunc func(a unstructured.Unstructured, b unstructured.Unstructured) int
+
+// applyOrder lists kinds in the order they should be applied to a cluster.
+//
+//nolint:gochecknoglobals
+var applyOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"PriorityClass",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// applyPriority maps kinds to their position in applyOrder.
+//
+//nolint:gochecknoglobals
+var applyPriority = func() map[string]int {
+	m := make(map[string]int, len(applyOrder))
+	for i, kind := range applyOrder {
+		m[kind] = i
+	}
+
+	return m
+}()
+
+func (o OutputOrder) validate() error {
+	switch o {
+	case OrderAsIs, OrderApply, OrderAlphabetical:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidOutputOrder, o)
+	}
+}
+
+// validateReorder checks that reorder is a reorder option known to kustomize.
+func validateReorder(reorder krusty.ReorderOption) error {
+	switch reorder {
+	case krusty.ReorderOptionNone, krusty.ReorderOptionLegacy, krusty.ReorderOptionUnspecified:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidReorder, reorder)
+	}
+}
+
+// sortObjects orders objects in place by order, then by sortFunc if set. Sorting is stable,
+// so objects that compare equal keep their previous order and diffs of the output remain
+// minimal.
+func sortObjects(objects []unstructured.Unstructured, order OutputOrder, sortFunc SortFunc) {
+	switch order {
+	case OrderApply:
+		slices.SortStableFunc(objects, func(a unstructured.Unstructured, b unstructured.Unstructured) int {
+			return cmp.Compare(kindPriority(a.GetKind()), kindPriority(b.GetKind()))
+		})
+	case OrderAlphabetical:
+		slices.SortStableFunc(objects, func(a unstructured.Unstructured, b unstructured.Unstructured) int {
+			return cmp.Or(
+				cmp.Compare(a.GetKind(), b.GetKind()),
+				cmp.Compare(a.GetNamespace(), b.GetNamespace()),
+				cmp.Compare(a.GetName(), b.GetName()),
+			)
+		})
+	case OrderAsIs:
+	}
+
+	if sortFunc != nil {
+		slices.SortStableFunc(objects, sortFunc)
+	}
+}
+
+// kindPriority returns the apply priority of a kind; unknown kinds sort last.
+func kindPriority(kind string) int {
+	if p, ok := applyPriority[kind]; ok {
+		return p
+	}
+
+	return len(applyOrder)
+}
@@ -0,0 +1,114 @@
+package kustomize
+
+import (
+	kresource "sigs.k8s.io/kustomize/api/resource"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// originInfoAnnotation carries the kustomize origin of an object from the engine to the
+// renderer, which turns it into SourceResult.Origins. It never reaches the output.
+const originInfoAnnotation = "internal.renderer-kustomize.k8s-manifest-kit.io/origin"
+
+// Origin is where kustomize loaded or generated a rendered object from, as recorded in the
+// config.kubernetes.io/origin annotation.
+type Origin struct {
+	// Path is the file the object was declared in, relative to the source path for local
+	// files and to the repository root for remote ones.
+	Path string
+
+	// Repo is the repository of a remote base, empty for local files.
+	Repo string
+
+	// Ref is the ref of Repo the object was loaded at.
+	Ref string
+
+	// ConfiguredIn is the file holding the generator that created the object, for objects
+	// generated by fields other than resources.
+	ConfiguredIn string
+
+	// ConfiguredBy identifies the generator that created the object, nil for objects
+	// declared in resources.
+	ConfiguredBy *OriginReference
+}
+
+// OriginReference identifies the generator configuration of a generated object.
+type OriginReference struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+}
+
+// newOrigin converts a kustomize origin.
+func newOrigin(origin *kresource.Origin) *Origin {
+	result := &Origin{
+		Path:         origin.Path,
+		Repo:         origin.Repo,
+		Ref:          origin.Ref,
+		ConfiguredIn: origin.ConfiguredIn,
+	}
+
+	if by := origin.ConfiguredBy; by != (kyaml.ResourceIdentifier{}) {
+		result.ConfiguredBy = &OriginReference{
+			APIVersion: by.APIVersion,
+			Kind:       by.Kind,
+			Name:       by.Name,
+			Namespace:  by.Namespace,
+		}
+	}
+
+	return result
+}
+
+// recordOrigin stores the kustomize origin of res, if known, in obj for takeOrigins.
+func (e *Engine) recordOrigin(obj *unstructured.Unstructured, res resource) {
+	origin, err := res.GetOrigin()
+	if err != nil || origin == nil || e.isValuesSecretOrigin(origin.Path) {
+		return
+	}
+
+	data, err := origin.String()
+	if err != nil {
+		return
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[originInfoAnnotation] = data
+	obj.SetAnnotations(annotations)
+}
+
+// takeOrigins removes the origins recorded by recordOrigin from objects and returns them,
+// by object index. Objects without recorded origin get nil.
+func takeOrigins(objects []unstructured.Unstructured) []*Origin {
+	origins := make([]*Origin, len(objects))
+
+	for i := range objects {
+		annotations := objects[i].GetAnnotations()
+
+		data, found := annotations[originInfoAnnotation]
+		if !found {
+			continue
+		}
+
+		delete(annotations, originInfoAnnotation)
+
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+
+		objects[i].SetAnnotations(annotations)
+
+		origin := &kresource.Origin{}
+		if err := kyaml.Unmarshal([]byte(data), origin); err == nil {
+			origins[i] = newOrigin(origin)
+		}
+	}
+
+	return origins
+}
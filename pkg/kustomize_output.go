@@ -0,0 +1,153 @@
+package kustomize
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrInvalidOutputPath is returned by WriteSplit when a naming scheme produces a path
+// outside of the output directory, or the same path for several objects.
+var ErrInvalidOutputPath = errors.New("invalid output path")
+
+// NamingScheme returns the path of the file holding obj, relative to the output directory
+// of WriteSplit.
+type NamingScheme func(obj unstructured.Unstructured) string
+
+// NamingKindName names files <kind>_<name>.yaml, with the kind lowercased, e.g.
+// "deployment_app.yaml".
+func NamingKindName(obj unstructured.Unstructured) string {
+	return fmt.Sprintf("%s_%s.yaml", strings.ToLower(obj.GetKind()), obj.GetName())
+}
+
+// NamingNamespaceKindName names files <namespace>/<kind>.<name>.yaml, with the kind
+// lowercased, e.g. "prod/deployment.app.yaml". Objects without namespace, such as
+// cluster-scoped ones, are written at the top of the output directory.
+func NamingNamespaceKindName(obj unstructured.Unstructured) string {
+	return filepath.Join(obj.GetNamespace(), fmt.Sprintf("%s.%s.yaml", strings.ToLower(obj.GetKind()), obj.GetName()))
+}
+
+// ToYAML marshals objects into a multi-document YAML stream, formatted like the output
+// of `kustomize build`: map keys sorted, two-space indentation, compact sequences and
+// documents separated by "---". An empty slice produces no output.
+func ToYAML(objs []unstructured.Unstructured) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := WriteYAML(&buf, objs...); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteYAML streams objects to w as a multi-document YAML stream, formatted like ToYAML.
+// Nothing is written for an empty list of objects.
+func WriteYAML(w io.Writer, objs ...unstructured.Unstructured) error {
+	if len(objs) == 0 {
+		return nil
+	}
+
+	encoder := kyaml.NewEncoder(w)
+
+	for i := range objs {
+		if err := encoder.Encode(objs[i].Object); err != nil {
+			return fmt.Errorf("unable to marshal %s %q to YAML: %w", objs[i].GetKind(), objs[i].GetName(), err)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("unable to write YAML: %w", err)
+	}
+
+	return nil
+}
+
+// ToJSONList wraps objects into a v1 List (apiVersion: v1, kind: List, items: [...]) and
+// marshals it to JSON, as accepted by `kubectl apply -f -`. Items keep the order of objs and
+// their fields exactly as rendered; map keys are sorted, so the output is deterministic.
+// An empty slice produces a List with no items.
+func ToJSONList(objs []unstructured.Unstructured) ([]byte, error) {
+	items := make([]any, len(objs))
+	for i := range objs {
+		items[i] = objs[i].Object
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal objects to a JSON list: %w", err)
+	}
+
+	return data, nil
+}
+
+// WriteSplit writes each object to its own file under dir, like `kubectl ... -o dir`,
+// formatted like ToYAML. File paths are given by naming, NamingNamespaceKindName if nil;
+// missing directories are created and existing files overwritten, while other files in dir
+// are left untouched. Paths are checked before anything is written: a path outside of dir,
+// or shared by several objects, fails with ErrInvalidOutputPath.
+func WriteSplit(fsys filesys.FileSystem, dir string, objs []unstructured.Unstructured, naming NamingScheme) error {
+	if naming == nil {
+		naming = NamingNamespaceKindName
+	}
+
+	paths := make([]string, len(objs))
+	owners := make(map[string]int, len(objs))
+
+	for i := range objs {
+		rel := filepath.Clean(naming(objs[i]))
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf(
+				"%w: %q for %s %q is not within the output directory",
+				ErrInvalidOutputPath,
+				rel,
+				objs[i].GetKind(),
+				objs[i].GetName(),
+			)
+		}
+
+		if j, found := owners[rel]; found {
+			return fmt.Errorf(
+				"%w: %q is shared by %s %q and %s %q",
+				ErrInvalidOutputPath,
+				rel,
+				objs[j].GetKind(),
+				objs[j].GetName(),
+				objs[i].GetKind(),
+				objs[i].GetName(),
+			)
+		}
+
+		owners[rel] = i
+		paths[i] = filepath.Join(dir, rel)
+	}
+
+	for i, path := range paths {
+		data, err := ToYAML(objs[i : i+1])
+		if err != nil {
+			return err
+		}
+
+		if err := fsys.MkdirAll(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("unable to create directory of %q: %w", path, err)
+		}
+
+		if err := fsys.WriteFile(path, data); err != nil {
+			return fmt.Errorf("unable to write %q: %w", path, err)
+		}
+	}
+
+	return nil
+}
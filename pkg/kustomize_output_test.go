@@ -0,0 +1,193 @@
+package kustomize_test
+
+import (
+	"bytes"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestToYAML(t *testing.T) {
+	t.Run("should produce no output for empty input", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data, err := kustomize.ToYAML(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(BeEmpty())
+	})
+
+	t.Run("should match kustomize build output", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), map[string][]byte{
+			"kustomization.yaml": []byte("resources:\n- resources.yaml\n"),
+			"resources.yaml": []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  replicas: "3"
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod
+spec:
+  containers:
+  - name: nginx
+    image: nginx:latest
+    ports:
+    - containerPort: 80
+`),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := kustomize.ToYAML(objects)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal(`apiVersion: v1
+data:
+  replicas: "3"
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod
+spec:
+  containers:
+  - image: nginx:latest
+    name: nginx
+    ports:
+    - containerPort: 80
+`))
+	})
+
+	t.Run("should stream the same output", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects := []unstructured.Unstructured{
+			makeObject("v1", "ConfigMap", "a"),
+			makeObject("v1", "ConfigMap", "b"),
+		}
+
+		data, err := kustomize.ToYAML(objects)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		var buf bytes.Buffer
+		g.Expect(kustomize.WriteYAML(&buf, objects...)).To(Succeed())
+		g.Expect(buf.String()).To(Equal(string(data)))
+		g.Expect(buf.String()).ToNot(HavePrefix("---"))
+		g.Expect(buf.String()).ToNot(HaveSuffix("---\n"))
+	})
+}
+
+func TestToJSONList(t *testing.T) {
+	t.Run("should wrap objects in a v1 List", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cm := makeObject("v1", "ConfigMap", "config")
+		cm.Object["data"] = map[string]any{"replicas": "3"}
+
+		data, err := kustomize.ToJSONList([]unstructured.Unstructured{cm, makeObject("apps/v1", "Deployment", "app")})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal(`{"apiVersion":"v1","items":[` +
+			`{"apiVersion":"v1","data":{"replicas":"3"},"kind":"ConfigMap","metadata":{"name":"config"}},` +
+			`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"app"}}` +
+			`],"kind":"List"}`))
+
+		list := unstructured.UnstructuredList{}
+		g.Expect(list.UnmarshalJSON(data)).To(Succeed())
+		g.Expect(list.Items).To(HaveLen(2))
+		g.Expect(list.Items[0].Object).To(Equal(cm.Object))
+	})
+
+	t.Run("should produce an empty list for empty input", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data, err := kustomize.ToJSONList(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal(`{"apiVersion":"v1","items":[],"kind":"List"}`))
+	})
+}
+
+func TestWriteSplit(t *testing.T) {
+	deployment := makeObject("apps/v1", "Deployment", "app")
+	deployment.SetNamespace("prod")
+
+	namespace := makeObject("v1", "Namespace", "prod")
+
+	t.Run("should write each object to its own file", func(t *testing.T) {
+		g := NewWithT(t)
+		fsys := filesys.MakeFsInMemory()
+
+		err := kustomize.WriteSplit(fsys, "/out", []unstructured.Unstructured{deployment, namespace}, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := fsys.ReadFile("/out/prod/deployment.app.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		expected, err := kustomize.ToYAML([]unstructured.Unstructured{deployment})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal(string(expected)))
+
+		g.Expect(fsys.Exists("/out/namespace.prod.yaml")).To(BeTrue())
+	})
+
+	t.Run("should use the given naming scheme", func(t *testing.T) {
+		g := NewWithT(t)
+		fsys := filesys.MakeFsInMemory()
+
+		err := kustomize.WriteSplit(
+			fsys,
+			"/out",
+			[]unstructured.Unstructured{deployment, namespace},
+			kustomize.NamingKindName,
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		entries, err := fsys.ReadDir("/out")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(entries).To(ConsistOf("deployment_app.yaml", "namespace_prod.yaml"))
+	})
+
+	t.Run("should reject paths shared by several objects", func(t *testing.T) {
+		g := NewWithT(t)
+		fsys := filesys.MakeFsInMemory()
+
+		staging := deployment.DeepCopy()
+		staging.SetNamespace("staging")
+
+		err := kustomize.WriteSplit(
+			fsys,
+			"/out",
+			[]unstructured.Unstructured{deployment, *staging},
+			kustomize.NamingKindName,
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidOutputPath))
+		g.Expect(fsys.Exists("/out")).To(BeFalse())
+	})
+
+	t.Run("should reject paths outside of the output directory", func(t *testing.T) {
+		g := NewWithT(t)
+		fsys := filesys.MakeFsInMemory()
+
+		err := kustomize.WriteSplit(
+			fsys,
+			"/out",
+			[]unstructured.Unstructured{deployment},
+			func(obj unstructured.Unstructured) string {
+				return "../" + obj.GetName() + ".yaml"
+			},
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidOutputPath))
+	})
+}
@@ -0,0 +1,84 @@
+package kustomize
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+)
+
+// maxParsedKustomizations bounds the number of kustomization files kept by parsedKustomizations.
+const maxParsedKustomizations = 1024
+
+// parsedKustomizations memoizes the parsing of kustomization files for every renderer.
+// Renders, cache keys, validation and the reference walkers read the same kustomization
+// files over and over; parsing the YAML only once per content keeps this cheap even without
+// result caching.
+//
+//nolint:gochecknoglobals
+var parsedKustomizations = newKustomizationCache(maxParsedKustomizations)
+
+// kustomizationCache holds parsed kustomizations by file path, together with the hash of
+// the content they were parsed from, so that an entry is replaced as soon as the file
+// changes. Entries are stored as JSON, which decodes much faster than YAML, and every
+// lookup decodes a fresh Kustomization that callers are free to modify.
+type kustomizationCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]parsedKustomization
+}
+
+type parsedKustomization struct {
+	sum  [sha256.Size]byte
+	data []byte
+}
+
+func newKustomizationCache(maxEntries int) *kustomizationCache {
+	return &kustomizationCache{
+		max:     maxEntries,
+		entries: make(map[string]parsedKustomization),
+	}
+}
+
+// parse returns the kustomization held by content, read from path, parsing it only if
+// content changed since the last call for path.
+func (c *kustomizationCache) parse(path string, content []byte) (*kustomizetypes.Kustomization, error) {
+	sum := sha256.Sum256(content)
+
+	c.mu.Lock()
+	entry, found := c.entries[path]
+	c.mu.Unlock()
+
+	kust := &kustomizetypes.Kustomization{}
+
+	if found && entry.sum == sum {
+		if err := json.Unmarshal(entry.data, kust); err != nil {
+			return nil, fmt.Errorf("failed to decode cached kustomization: %w", err)
+		}
+
+		return kust, nil
+	}
+
+	if err := kust.Unmarshal(content); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	data, err := json.Marshal(kust)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode kustomization: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the files of a process rarely outnumber the limit; start over rather than tracking use
+	if _, exists := c.entries[path]; !exists && len(c.entries) >= c.max {
+		clear(c.entries)
+	}
+
+	c.entries[path] = parsedKustomization{sum: sum, data: data}
+
+	return kust, nil
+}
@@ -0,0 +1,163 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"sigs.k8s.io/kustomize/api/filters/patchjson6902"
+	"sigs.k8s.io/kustomize/api/provider"
+	kresource "sigs.k8s.io/kustomize/api/resource"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// ErrInvalidPatch is returned by New when a patch passed to WithPatch cannot be parsed.
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// PatchType selects how a Patch is applied.
+type PatchType string
+
+const (
+	// PatchStrategicMerge applies the patch as a strategic merge patch. This is the default.
+	PatchStrategicMerge PatchType = "StrategicMerge"
+
+	// PatchJSON6902 applies the patch as a JSON patch (RFC 6902), in JSON or YAML form.
+	PatchJSON6902 PatchType = "JSON6902"
+)
+
+// Patch is a patch applied to the rendered output of every source, like an entry of the
+// patches field of a kustomization.
+type Patch struct {
+	// Type selects the patch format. Default: PatchStrategicMerge.
+	Type PatchType
+
+	// Patch is the content of the patch.
+	Patch []byte
+
+	// Target selects the resources to patch. It is required for JSON patches. Strategic
+	// merge patches without target apply to the resource matching their own apiVersion,
+	// kind, name and namespace; with a target, the patch must hold a single document.
+	Target *kustomizetypes.Selector
+}
+
+// patchTransformer applies a Patch to a ResMap, mirroring kustomize's builtin
+// PatchTransformer with an explicit patch type.
+type patchTransformer struct {
+	target    *kustomizetypes.Selector
+	smPatches []*kresource.Resource
+	jsonPatch string
+}
+
+// newPatchTransformer parses patch, failing with ErrInvalidPatch if it does not match its type.
+func newPatchTransformer(patch Patch) (*patchTransformer, error) {
+	text := strings.TrimSpace(string(patch.Patch))
+	if text == "" {
+		return nil, fmt.Errorf("%w: empty patch", ErrInvalidPatch)
+	}
+
+	t := &patchTransformer{target: patch.Target}
+
+	switch patch.Type {
+	case PatchStrategicMerge, "":
+		rf := provider.NewDefaultDepProvider().GetResourceFactory()
+
+		resources, err := rf.SliceFromBytes([]byte(text))
+		if err != nil {
+			return nil, fmt.Errorf("%w: strategic merge patch: %w", ErrInvalidPatch, err)
+		}
+
+		if len(resources) == 0 {
+			return nil, fmt.Errorf("%w: strategic merge patch holds no document", ErrInvalidPatch)
+		}
+
+		if patch.Target != nil && len(resources) > 1 {
+			return nil, fmt.Errorf("%w: strategic merge patch with a target must hold a single document", ErrInvalidPatch)
+		}
+
+		t.smPatches = resources
+	case PatchJSON6902:
+		if patch.Target == nil {
+			return nil, fmt.Errorf("%w: JSON patch requires a target", ErrInvalidPatch)
+		}
+
+		ops, err := sigsyaml.YAMLToJSON([]byte(text))
+		if err != nil {
+			return nil, fmt.Errorf("%w: JSON patch: %w", ErrInvalidPatch, err)
+		}
+
+		if _, err := jsonpatch.DecodePatch(ops); err != nil {
+			return nil, fmt.Errorf("%w: JSON patch: %w", ErrInvalidPatch, err)
+		}
+
+		t.jsonPatch = string(ops)
+	default:
+		return nil, fmt.Errorf("%w: unknown patch type %q", ErrInvalidPatch, patch.Type)
+	}
+
+	return t, nil
+}
+
+// Transform applies the patch to the matching resources of m.
+func (t *patchTransformer) Transform(m resMap) error {
+	if t.smPatches == nil {
+		return t.transformJSON6902(m)
+	}
+
+	if t.target != nil {
+		selected, err := m.Select(*t.target)
+		if err != nil {
+			return fmt.Errorf("unable to select patch target %v: %w", t.target, err)
+		}
+
+		if err := m.ApplySmPatch(kresource.MakeIdSet(selected), t.smPatches[0].DeepCopy()); err != nil {
+			return fmt.Errorf("failed to apply strategic merge patch: %w", err)
+		}
+
+		return nil
+	}
+
+	for _, patch := range t.smPatches {
+		target, err := m.GetById(patch.OrgId())
+		if err != nil {
+			return fmt.Errorf("no resource matches strategic merge patch %q: %w", patch.OrgId(), err)
+		}
+
+		if err := target.ApplySmPatch(patch.DeepCopy()); err != nil {
+			return fmt.Errorf("failed to apply strategic merge patch to %s: %w", patch.OrgId(), err)
+		}
+	}
+
+	return nil
+}
+
+func (t *patchTransformer) transformJSON6902(m resMap) error {
+	selected, err := m.Select(*t.target)
+	if err != nil {
+		return fmt.Errorf("unable to select patch target %v: %w", t.target, err)
+	}
+
+	for _, res := range selected {
+		res.StorePreviousId()
+
+		// the filter drops kustomize's internal annotations, which track the origin of the
+		// resource, so restore them afterwards
+		internal := kioutil.GetInternalAnnotations(&res.RNode)
+
+		if err := res.ApplyFilter(patchjson6902.Filter{Patch: t.jsonPatch}); err != nil {
+			return fmt.Errorf("failed to apply JSON patch to %s: %w", res.CurId(), err)
+		}
+
+		annotations := res.GetAnnotations()
+		maps.Copy(annotations, internal)
+
+		if err := res.SetAnnotations(annotations); err != nil {
+			return fmt.Errorf("failed to restore annotations of %s: %w", res.CurId(), err)
+		}
+	}
+
+	return nil
+}
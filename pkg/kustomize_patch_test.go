@@ -0,0 +1,113 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"github.com/k8s-manifest-kit/engine/pkg/types"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPatch(t *testing.T) {
+	render := func(t *testing.T, opts ...kustomize.RendererOption) ([]unstructured.Unstructured, error) {
+		t.Helper()
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: setupBasicKustomization(t)}}, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return renderer.Process(t.Context(), nil)
+	}
+
+	configMapSelector := &kustomizetypes.Selector{ResId: resid.NewResId(resid.Gvk{Version: "v1", Kind: "ConfigMap"}, "")}
+
+	t.Run("should apply strategic merge patches matching the patch id", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := render(t, kustomize.WithPatch(kustomize.Patch{
+			Patch: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test-configmap\ndata:\n  key: patched\n"),
+		}))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+
+		value, _, _ := unstructured.NestedString(objects[0].Object, "data", "key")
+		g.Expect(value).To(Equal("patched"))
+	})
+
+	t.Run("should apply strategic merge patches to the selected targets", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := render(t, kustomize.WithPatch(kustomize.Patch{
+			Type:   kustomize.PatchStrategicMerge,
+			Patch:  []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: any\n  labels:\n    env: prod\n"),
+			Target: configMapSelector,
+		}))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(objects[0].GetName()).To(Equal("test-configmap"))
+		g.Expect(objects[0].GetLabels()).To(HaveKeyWithValue("env", "prod"))
+		g.Expect(objects[1].GetLabels()).ToNot(HaveKey("env"))
+	})
+
+	t.Run("should apply JSON patches to the selected targets", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := render(t,
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithPatch(kustomize.Patch{
+				Type:   kustomize.PatchJSON6902,
+				Patch:  []byte("- op: add\n  path: /data/extra\n  value: added\n"),
+				Target: configMapSelector,
+			}),
+			kustomize.WithPatch(kustomize.Patch{
+				Type:   kustomize.PatchJSON6902,
+				Patch:  []byte(`[{"op": "replace", "path": "/data/extra", "value": "replaced"}]`),
+				Target: configMapSelector,
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		value, _, _ := unstructured.NestedString(objects[0].Object, "data", "extra")
+		g.Expect(value).To(Equal("replaced"))
+
+		// the origin of the patched resource is still tracked
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourceFile, "configmap.yaml"))
+	})
+
+	t.Run("should fail when a strategic merge patch matches no resource", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := render(t, kustomize.WithPatch(kustomize.Patch{
+			Patch: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: missing\n"),
+		}))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("missing"))
+	})
+
+	t.Run("should reject invalid patches", func(t *testing.T) {
+		for name, patch := range map[string]kustomize.Patch{
+			"empty":                     {},
+			"unknown type":              {Type: "Merge", Patch: []byte("a: b")},
+			"JSON patch without target": {Type: kustomize.PatchJSON6902, Patch: []byte("[]")},
+			"malformed JSON patch":      {Type: kustomize.PatchJSON6902, Patch: []byte("a: b"), Target: configMapSelector},
+			"multiple documents with target": {
+				Patch:  []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"),
+				Target: configMapSelector,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				g := NewWithT(t)
+
+				_, err := kustomize.New([]kustomize.Source{{Path: t.TempDir()}}, kustomize.WithPatch(patch))
+				g.Expect(err).To(MatchError(kustomize.ErrInvalidPatch))
+			})
+		}
+	})
+}
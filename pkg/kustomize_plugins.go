@@ -0,0 +1,429 @@
+package kustomize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/resmap"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+)
+
+var (
+	// ErrPluginHomeNotFound is returned by New when the directory set with WithPluginHome
+	// does not exist while exec plugins are enabled.
+	ErrPluginHomeNotFound = errors.New("plugin home not found")
+
+	// ErrPluginPanic is returned when a ReadOnlyTransformer run concurrently panics.
+	ErrPluginPanic = errors.New("plugin transformer panicked")
+)
+
+// sourceDateEpochEnv is the environment variable fixing the current time of reproducible
+// builds, see https://reproducible-builds.org/specs/source-date-epoch/.
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// pluginHomeEnv scopes $KUSTOMIZE_PLUGIN_HOME, the only way to choose kustomize's plugin home.
+//
+//nolint:gochecknoglobals
+var pluginHomeEnv = newPluginHomeScope()
+
+// FunctionOption configures how containerized KRM functions are run.
+type FunctionOption func(opts *kustomizetypes.FnPluginLoadingOptions)
+
+// WithFunctionNetwork gives function containers access to the given network.
+// An empty name uses the container runtime's default network.
+func WithFunctionNetwork(name string) FunctionOption {
+	return func(opts *kustomizetypes.FnPluginLoadingOptions) {
+		opts.Network = true
+		opts.NetworkName = name
+	}
+}
+
+// WithFunctionMounts adds storage mounts (in docker --mount syntax) to function containers.
+func WithFunctionMounts(mounts ...string) FunctionOption {
+	return func(opts *kustomizetypes.FnPluginLoadingOptions) {
+		opts.Mounts = append(opts.Mounts, mounts...)
+	}
+}
+
+// WithFunctionEnv passes environment variables ("KEY=value", or "KEY" to forward the
+// current value) to function containers.
+func WithFunctionEnv(env ...string) FunctionOption {
+	return func(opts *kustomizetypes.FnPluginLoadingOptions) {
+		opts.Env = append(opts.Env, env...)
+	}
+}
+
+// WithFunctionAsCurrentUser runs function containers with the uid and gid of the current process.
+func WithFunctionAsCurrentUser(enabled bool) FunctionOption {
+	return func(opts *kustomizetypes.FnPluginLoadingOptions) {
+		opts.AsCurrentUser = enabled
+	}
+}
+
+// lockedDownPluginConfig returns the default plugin configuration: only builtin plugins,
+// no exec or container functions and no helm.
+func lockedDownPluginConfig() *kustomizetypes.PluginConfig {
+	return &kustomizetypes.PluginConfig{}
+}
+
+// enableNonBuiltinPlugins lifts the builtins-only restriction while keeping builtin
+// plugins statically linked.
+func enableNonBuiltinPlugins(cfg *kustomizetypes.PluginConfig) {
+	cfg.PluginRestrictions = kustomizetypes.PluginRestrictionsNone
+
+	if cfg.BpLoadingOptions == kustomizetypes.BploUndefined {
+		cfg.BpLoadingOptions = kustomizetypes.BploUseStaticallyLinked
+	}
+}
+
+// fixFunctionClock sets SOURCE_DATE_EPOCH in the environment of KRM functions, unless
+// already set, so that functions honoring it do not depend on the current time.
+func fixFunctionClock(cfg *kustomizetypes.PluginConfig) {
+	for _, env := range cfg.FnpLoadingOptions.Env {
+		if key, _, _ := strings.Cut(env, "="); key == sourceDateEpochEnv {
+			return
+		}
+	}
+
+	cfg.FnpLoadingOptions.Env = append(cfg.FnpLoadingOptions.Env, sourceDateEpochEnv+"=0")
+}
+
+// clonePluginConfig returns a copy of cfg that does not share slices with it.
+func clonePluginConfig(cfg *kustomizetypes.PluginConfig) *kustomizetypes.PluginConfig {
+	if cfg == nil {
+		return lockedDownPluginConfig()
+	}
+
+	out := *cfg
+	out.FnpLoadingOptions.Mounts = slices.Clone(cfg.FnpLoadingOptions.Mounts)
+	out.FnpLoadingOptions.Env = slices.Clone(cfg.FnpLoadingOptions.Env)
+	out.HelmConfig.ApiVersions = slices.Clone(cfg.HelmConfig.ApiVersions)
+
+	return &out
+}
+
+// resolvePluginHome returns the absolute plugin home to use for builds with the given plugin
+// configuration, or an empty string if kustomize's default locations apply.
+func resolvePluginHome(dir string, cfg *kustomizetypes.PluginConfig) (string, error) {
+	if dir == "" || !cfg.FnpLoadingOptions.EnableExec {
+		return "", nil
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q: %w", ErrPluginHomeNotFound, dir, err)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q: %w", ErrPluginHomeNotFound, abs, err)
+	}
+
+	if !info.IsDir() {
+		return "", fmt.Errorf("%w: %q is not a directory", ErrPluginHomeNotFound, abs)
+	}
+
+	return abs, nil
+}
+
+// pluginHomeScope reference-counts overrides of $KUSTOMIZE_PLUGIN_HOME so that overlapping
+// builds agreeing on the plugin home share the environment, while builds needing another one
+// wait until the last of them has finished. An empty home keeps the environment unchanged.
+type pluginHomeScope struct {
+	mu       sync.Mutex
+	released *sync.Cond
+	refs     int
+	home     string
+	original string
+	wasSet   bool
+}
+
+func newPluginHomeScope() *pluginHomeScope {
+	s := &pluginHomeScope{}
+	s.released = sync.NewCond(&s.mu)
+
+	return s
+}
+
+// acquire makes home the plugin home until the returned function is called.
+func (s *pluginHomeScope) acquire(home string) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.refs > 0 && s.home != home {
+		s.released.Wait()
+	}
+
+	if s.refs == 0 && home != "" {
+		s.original, s.wasSet = os.LookupEnv(konfig.KustomizePluginHomeEnv)
+		_ = os.Setenv(konfig.KustomizePluginHomeEnv, home)
+	}
+
+	s.home = home
+	s.refs++
+
+	return s.release
+}
+
+func (s *pluginHomeScope) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+
+	if s.home != "" {
+		if s.wasSet {
+			_ = os.Setenv(konfig.KustomizePluginHomeEnv, s.original)
+		} else {
+			_ = os.Unsetenv(konfig.KustomizePluginHomeEnv)
+		}
+	}
+
+	s.home = ""
+	s.released.Broadcast()
+}
+
+// NamedTransformer is a plugin transformer with a name, used to identify the plugin in
+// errors and PluginEvents. It can be registered with WithPlugin or WithNamedPlugin.
+type NamedTransformer struct {
+	// Name identifies the plugin.
+	Name string
+
+	// Transformer is the wrapped plugin.
+	Transformer resmap.Transformer
+}
+
+// Transform applies the wrapped plugin to m.
+func (t NamedTransformer) Transform(m resmap.ResMap) error {
+	return t.Transformer.Transform(m) //nolint:wrapcheck
+}
+
+// ReadOnly reports whether the wrapped plugin is a read-only ReadOnlyTransformer.
+func (t NamedTransformer) ReadOnly() bool {
+	return isReadOnly(t.Transformer)
+}
+
+// ReadOnlyTransformer is implemented by plugin transformers that only inspect the resources
+// they are given, e.g. to validate or index them. Consecutive plugins whose ReadOnly method
+// returns true are applied concurrently to the same ResMap; every other plugin is applied
+// alone, in registration order, so a mutating plugin always sees the effect of the plugins
+// registered before it and none of those registered after it.
+//
+// The contract is strict. Transform of a read-only plugin must not modify the ResMap
+// (Append, Remove, Replace, Clear, ...) nor any of its resources (SetLabels, SetAnnotations,
+// field setters, ...), and must be safe to call concurrently with other read-only plugins.
+// A mutating plugin labeled read-only races with the plugins it runs with, and corrupts or
+// crashes renders. When in doubt, do not implement ReadOnlyTransformer: plugins are then
+// applied sequentially, as before.
+type ReadOnlyTransformer interface {
+	resmap.Transformer
+
+	// ReadOnly reports whether Transform leaves the ResMap and its resources unchanged.
+	ReadOnly() bool
+}
+
+// isReadOnly reports whether plugin is a read-only ReadOnlyTransformer.
+func isReadOnly(plugin resmap.Transformer) bool {
+	readOnly, ok := plugin.(ReadOnlyTransformer)
+
+	return ok && readOnly.ReadOnly()
+}
+
+// PluginEvent describes the effect of a plugin transformer on the resources of a source.
+type PluginEvent struct {
+	// Path is the source path being rendered.
+	Path string
+
+	// Plugin is the NamedTransformer name of the plugin, or its Go type.
+	Plugin string
+
+	// Index is the position of the plugin among the registered plugins.
+	Index int
+
+	// Added, Removed and Modified count the resources the plugin added, removed and
+	// changed, by resource ID.
+	Added    int
+	Removed  int
+	Modified int
+}
+
+// PluginObserver receives plugin events.
+type PluginObserver func(event PluginEvent)
+
+// ResMapFilter filters or edits the kustomize ResMap of a source in place, see
+// WithResMapFilter.
+type ResMapFilter func(m resmap.ResMap) error
+
+// pluginName returns the name of a NamedTransformer, or the Go type of other plugins.
+func pluginName(plugin resmap.Transformer) string {
+	switch t := plugin.(type) {
+	case NamedTransformer:
+		return t.Name
+	case *NamedTransformer:
+		return t.Name
+	default:
+		return fmt.Sprintf("%T", plugin)
+	}
+}
+
+// applyPlugins applies the plugins to m in order, naming the failing plugin in errors and
+// reporting the effect of each plugin to the observer, if any. Consecutive read-only plugins
+// are applied concurrently.
+func (e *Engine) applyPlugins(ctx context.Context, m resmap.ResMap, path string) error {
+	plugins := e.opts.Plugins
+
+	for start := 0; start < len(plugins); {
+		end := start + 1
+
+		var err error
+		if isReadOnly(plugins[start]) {
+			for end < len(plugins) && isReadOnly(plugins[end]) {
+				end++
+			}
+
+			err = e.applyReadOnlyPlugins(m, path, start, end)
+		} else {
+			err = e.applyPlugin(m, path, start)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("kustomize run for path %q aborted: %w", path, err)
+		}
+
+		start = end
+	}
+
+	return nil
+}
+
+// applyPlugin applies the plugin at index i to m.
+func (e *Engine) applyPlugin(m resmap.ResMap, path string, i int) error {
+	plugin := e.opts.Plugins[i]
+
+	var before map[string]string
+	if e.opts.PluginObserver != nil {
+		before = resourceSnapshot(m)
+	}
+
+	if err := plugin.Transform(m); err != nil {
+		return pluginError(plugin, i, path, err)
+	}
+
+	if e.opts.PluginObserver != nil {
+		event := PluginEvent{Path: path, Plugin: pluginName(plugin), Index: i}
+		event.Added, event.Removed, event.Modified = diffSnapshots(before, resourceSnapshot(m))
+
+		e.opts.PluginObserver(event)
+	}
+
+	return nil
+}
+
+// applyReadOnlyPlugins applies the read-only plugins from index start to end (excluded) to m
+// concurrently, returning the error of the first failing plugin by index. Their events,
+// reporting no changes, are sent in index order once all of them have completed.
+func (e *Engine) applyReadOnlyPlugins(m resmap.ResMap, path string, start int, end int) error {
+	errs := make([]error, end-start)
+
+	if end-start == 1 {
+		errs[0] = e.opts.Plugins[start].Transform(m)
+	} else {
+		var wg sync.WaitGroup
+
+		for i := start; i < end; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				// a panic can no longer propagate to the caller's goroutine
+				defer func() {
+					if r := recover(); r != nil {
+						errs[i-start] = fmt.Errorf("%w: %v", ErrPluginPanic, r)
+					}
+				}()
+
+				errs[i-start] = e.opts.Plugins[i].Transform(m)
+			}()
+		}
+
+		wg.Wait()
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			return pluginError(e.opts.Plugins[start+i], start+i, path, err)
+		}
+	}
+
+	if e.opts.PluginObserver != nil {
+		for i := start; i < end; i++ {
+			e.opts.PluginObserver(PluginEvent{Path: path, Plugin: pluginName(e.opts.Plugins[i]), Index: i})
+		}
+	}
+
+	return nil
+}
+
+// pluginError wraps the error of the plugin at index i.
+func pluginError(plugin resmap.Transformer, i int, path string, err error) error {
+	return fmt.Errorf(
+		"failed to apply kustomize plugin transformer %q (#%d) for path %q: %w",
+		pluginName(plugin),
+		i,
+		path,
+		err,
+	)
+}
+
+// resourceSnapshot returns the YAML of every resource of m by resource ID.
+func resourceSnapshot(m resmap.ResMap) map[string]string {
+	snapshot := make(map[string]string, m.Size())
+
+	for _, res := range m.Resources() {
+		// an unserializable resource cannot be compared and is recorded empty, which
+		// diffSnapshots counts as modified
+		data, _ := res.AsYAML()
+		snapshot[res.CurId().String()] = string(data)
+	}
+
+	return snapshot
+}
+
+// diffSnapshots counts the resources added, removed and modified from before to after.
+func diffSnapshots(before map[string]string, after map[string]string) (int, int, int) {
+	added, removed, modified := 0, 0, 0
+
+	for id, data := range after {
+		previous, found := before[id]
+
+		switch {
+		case !found:
+			added++
+		case previous != data || data == "":
+			modified++
+		}
+	}
+
+	for id := range before {
+		if _, found := after[id]; !found {
+			removed++
+		}
+	}
+
+	return added, removed, modified
+}
@@ -0,0 +1,587 @@
+package kustomize_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kustomize/api/resmap"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+// renameFunction is an exec KRM function renaming the "original" ConfigMap.
+const renameFunction = `#!/bin/sh
+sed 's/name: original/name: renamed/'
+`
+
+const execFunctionKustomization = `
+resources:
+- configmap.yaml
+transformers:
+- transformer.yaml
+`
+
+const execFunctionTransformer = `
+apiVersion: example.com/v1
+kind: Rename
+metadata:
+  name: rename
+  annotations:
+    config.kubernetes.io/function: |
+      exec:
+        path: ./rename.sh
+`
+
+func setupExecFunctionKustomization(t *testing.T) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("exec function requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "kustomization.yaml", execFunctionKustomization)
+	writeFile(t, dir, "transformer.yaml", execFunctionTransformer)
+	writeFile(t, dir, "configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: original\n")
+
+	if err := os.WriteFile(filepath.Join(dir, "rename.sh"), []byte(renameFunction), 0o755); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestPlugins(t *testing.T) {
+	t.Run("should refuse exec functions by default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: setupExecFunctionKustomization(t)}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("should run exec functions when enabled", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupExecFunctionKustomization(t)}},
+			kustomize.WithExecPlugins(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("renamed"))
+	})
+
+	t.Run("should skip exec functions when only containers are enabled", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupExecFunctionKustomization(t)}},
+			kustomize.WithKRMFunctions(),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetName()).To(Equal("original"))
+	})
+
+	t.Run("should use explicit plugin config", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cfg := kustomizetypes.MakePluginConfig(
+			kustomizetypes.PluginRestrictionsNone,
+			kustomizetypes.BploUseStaticallyLinked,
+		)
+		cfg.FnpLoadingOptions.EnableExec = true
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupExecFunctionKustomization(t)}},
+			kustomize.WithPluginConfig(cfg),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// later changes to the caller's config must not affect the renderer
+		cfg.FnpLoadingOptions.EnableExec = false
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetName()).To(Equal("renamed"))
+	})
+}
+
+// transformerFunc adapts a function to a kustomize plugin transformer.
+type transformerFunc func(m resmap.ResMap) error
+
+func (f transformerFunc) Transform(m resmap.ResMap) error {
+	return f(m)
+}
+
+func TestNamedPlugins(t *testing.T) {
+	errBroken := errors.New("broken")
+	broken := transformerFunc(func(_ resmap.ResMap) error { return errBroken })
+
+	t.Run("should name the failing plugin", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithPlugin(transformerFunc(func(_ resmap.ResMap) error { return nil })),
+			kustomize.WithNamedPlugin("label-injector", broken),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(errBroken))
+		g.Expect(err.Error()).To(ContainSubstring(`"label-injector" (#1)`))
+	})
+
+	t.Run("should name unnamed plugins by type", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithPlugin(broken),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(errBroken))
+		g.Expect(err.Error()).To(ContainSubstring(`"kustomize_test.transformerFunc" (#0)`))
+	})
+
+	t.Run("should report the effect of each plugin", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		label := transformerFunc(func(m resmap.ResMap) error {
+			for _, res := range m.Resources() {
+				if err := res.SetLabels(map[string]string{"team": "platform"}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		prune := transformerFunc(func(m resmap.ResMap) error {
+			for _, res := range m.Resources() {
+				if res.GetKind() == "Pod" {
+					return m.Remove(res.CurId())
+				}
+			}
+
+			return nil
+		})
+
+		var mu sync.Mutex
+		events := make([]kustomize.PluginEvent, 0)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithNamedPlugin("label", label),
+			kustomize.WithNamedPlugin("prune", prune),
+			kustomize.WithPluginObserver(func(event kustomize.PluginEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				events = append(events, event)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+
+		g.Expect(events).To(Equal([]kustomize.PluginEvent{
+			{Path: dir, Plugin: "label", Index: 0, Modified: 2},
+			{Path: dir, Plugin: "prune", Index: 1, Removed: 1},
+		}))
+	})
+}
+
+// readOnlyFunc adapts a function to a read-only kustomize plugin transformer.
+type readOnlyFunc func(m resmap.ResMap) error
+
+func (f readOnlyFunc) Transform(m resmap.ResMap) error {
+	return f(m)
+}
+
+func (f readOnlyFunc) ReadOnly() bool {
+	return true
+}
+
+func TestReadOnlyPlugins(t *testing.T) {
+	t.Run("should apply consecutive read-only plugins concurrently", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// each plugin waits for the other one, which only succeeds if both run at once
+		var arrived sync.WaitGroup
+		arrived.Add(2)
+
+		meet := readOnlyFunc(func(_ resmap.ResMap) error {
+			arrived.Done()
+
+			met := make(chan struct{})
+			go func() {
+				arrived.Wait()
+				close(met)
+			}()
+
+			select {
+			case <-met:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("plugins not applied concurrently")
+			}
+		})
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithPlugin(meet),
+			kustomize.WithNamedPlugin("meet", meet),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+
+	t.Run("should keep mutating plugins ordered between read-only ones", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var mu sync.Mutex
+		seen := make(map[string]bool)
+
+		observe := func(name string) readOnlyFunc {
+			return func(m resmap.ResMap) error {
+				labeled := true
+				for _, res := range m.Resources() {
+					labeled = labeled && res.GetLabels()["team"] == "platform"
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				seen[name] = labeled
+
+				return nil
+			}
+		}
+
+		label := transformerFunc(func(m resmap.ResMap) error {
+			for _, res := range m.Resources() {
+				if err := res.SetLabels(map[string]string{"team": "platform"}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		events := make([]kustomize.PluginEvent, 0)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithNamedPlugin("before-1", observe("before-1")),
+			kustomize.WithNamedPlugin("before-2", observe("before-2")),
+			kustomize.WithNamedPlugin("label", label),
+			kustomize.WithNamedPlugin("after", observe("after")),
+			kustomize.WithPluginObserver(func(event kustomize.PluginEvent) {
+				events = append(events, event)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(seen).To(Equal(map[string]bool{"before-1": false, "before-2": false, "after": true}))
+
+		g.Expect(events).To(HaveLen(4))
+		for i, name := range []string{"before-1", "before-2", "label", "after"} {
+			g.Expect(events[i].Plugin).To(Equal(name))
+			g.Expect(events[i].Index).To(Equal(i))
+		}
+
+		g.Expect(events[0].Modified).To(BeZero())
+		g.Expect(events[2].Modified).To(Equal(2))
+	})
+
+	t.Run("should report the first failing read-only plugin", func(t *testing.T) {
+		g := NewWithT(t)
+		errFirst := errors.New("first")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithNamedPlugin("ok", readOnlyFunc(func(_ resmap.ResMap) error { return nil })),
+			kustomize.WithNamedPlugin("first", readOnlyFunc(func(_ resmap.ResMap) error { return errFirst })),
+			kustomize.WithNamedPlugin("second", readOnlyFunc(func(_ resmap.ResMap) error {
+				return errors.New("second")
+			})),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(errFirst))
+		g.Expect(err.Error()).To(ContainSubstring(`"first" (#1)`))
+	})
+
+	t.Run("should convert panics of concurrent plugins into errors", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithPlugin(readOnlyFunc(func(_ resmap.ResMap) error { return nil })),
+			kustomize.WithNamedPlugin("panicking", readOnlyFunc(func(_ resmap.ResMap) error {
+				panic("boom")
+			})),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrPluginPanic))
+		g.Expect(err.Error()).To(ContainSubstring(`"panicking" (#1)`))
+	})
+
+	t.Run("should treat NamedTransformer as read-only only if its plugin is", func(t *testing.T) {
+		g := NewWithT(t)
+
+		named := kustomize.NamedTransformer{Name: "label", Transformer: transformerFunc(nil)}
+		g.Expect(named.ReadOnly()).To(BeFalse())
+
+		named.Transformer = readOnlyFunc(nil)
+		g.Expect(named.ReadOnly()).To(BeTrue())
+	})
+}
+
+// legacyGenerator is a legacy exec generator plugin emitting a single ConfigMap, named by
+// the format argument.
+const legacyGenerator = `#!/bin/sh
+cat <<EOF
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+EOF
+`
+
+// setupLegacyPlugin creates a kustomization using a legacy exec generator and the plugin
+// home containing it. The generator emits a ConfigMap with the given name.
+func setupLegacyPlugin(t *testing.T, name string) (string, string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("exec plugin requires a POSIX shell")
+	}
+
+	home := t.TempDir()
+	pluginDir := filepath.Join(home, "example.com", "v1", "legacygenerator")
+	if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(pluginDir, "LegacyGenerator"), []byte(fmt.Sprintf(legacyGenerator, name)), 0o755); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "kustomization.yaml", "generators:\n- generator.yaml\n")
+	writeFile(t, dir, "generator.yaml", "apiVersion: example.com/v1\nkind: LegacyGenerator\nmetadata:\n  name: gen\n")
+
+	return dir, home
+}
+
+func TestResMapFilters(t *testing.T) {
+	t.Run("should filter the resmap before conversion", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithNamedPlugin("prefix", transformerFunc(func(m resmap.ResMap) error {
+				for _, res := range m.Resources() {
+					res.SetName("plugin-" + res.GetName())
+				}
+
+				return nil
+			})),
+			kustomize.WithResMapFilter(func(m resmap.ResMap) error {
+				for _, res := range m.Resources() {
+					if res.GetKind() == "Pod" {
+						if err := m.Remove(res.CurId()); err != nil {
+							return err
+						}
+					}
+				}
+
+				return nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetKind()).To(Equal("ConfigMap"))
+		g.Expect(objects[0].GetName()).To(Equal("plugin-test-configmap"))
+	})
+
+	t.Run("should apply filters in registration order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var order []int
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithResMapFilter(func(_ resmap.ResMap) error {
+				order = append(order, 1)
+
+				return nil
+			}),
+			kustomize.WithResMapFilter(func(_ resmap.ResMap) error {
+				order = append(order, 2)
+
+				return nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(order).To(Equal([]int{1, 2}))
+	})
+
+	t.Run("should fail the render on filter errors", func(t *testing.T) {
+		g := NewWithT(t)
+
+		errFilter := errors.New("filter failed")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithResMapFilter(func(_ resmap.ResMap) error {
+				return errFilter
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(errFilter))
+		g.Expect(err.Error()).To(ContainSubstring("resmap filter 0"))
+	})
+}
+
+func TestPluginHome(t *testing.T) {
+	t.Run("should load legacy exec plugins from the plugin home", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, home := setupLegacyPlugin(t, "from-plugin-home")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithExecPlugins(true),
+			kustomize.WithPluginHome(home),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("from-plugin-home"))
+	})
+
+	t.Run("should restore the environment after the build", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, home := setupLegacyPlugin(t, "from-plugin-home")
+		t.Setenv("KUSTOMIZE_PLUGIN_HOME", "/previous")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithExecPlugins(true),
+			kustomize.WithPluginHome(home),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(os.Getenv("KUSTOMIZE_PLUGIN_HOME")).To(Equal("/previous"))
+	})
+
+	t.Run("should ignore the plugin home unless exec plugins are enabled", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, _ := setupLegacyPlugin(t, "from-plugin-home")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithPluginHome(filepath.Join(t.TempDir(), "missing")),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("should fail when the plugin home does not exist", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.New(
+			[]kustomize.Source{{Path: t.TempDir()}},
+			kustomize.WithExecPlugins(true),
+			kustomize.WithPluginHome(filepath.Join(t.TempDir(), "missing")),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrPluginHomeNotFound))
+	})
+
+	t.Run("should render sources with different plugin homes concurrently", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, home := setupLegacyPlugin(t, "first")
+		otherDir, otherHome := setupLegacyPlugin(t, "second")
+
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		names := make([]string, len(errs))
+
+		for i := range errs {
+			source, pluginHome := dir, home
+			if i%2 == 1 {
+				source, pluginHome = otherDir, otherHome
+			}
+
+			renderer, err := kustomize.New(
+				[]kustomize.Source{{Path: source}},
+				kustomize.WithExecPlugins(true),
+				kustomize.WithPluginHome(pluginHome),
+			)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				objects, err := renderer.Process(t.Context(), nil)
+				if errs[i] = err; err == nil && len(objects) == 1 {
+					names[i] = objects[0].GetName()
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		for i, err := range errs {
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(names[i]).To(Equal([]string{"first", "second"}[i%2]))
+		}
+	})
+}
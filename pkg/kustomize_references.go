@@ -0,0 +1,345 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+var (
+	// ErrCyclicReference is returned when kustomizations reference each other in a cycle.
+	ErrCyclicReference = errors.New("cyclic kustomization reference")
+
+	// ErrMaxDepthExceeded is returned when kustomizations are nested deeper than the limit
+	// set with WithMaxDepth.
+	ErrMaxDepthExceeded = errors.New("kustomization nesting too deep")
+)
+
+// referenceKind describes what a kustomization reference is allowed to point at.
+type referenceKind int
+
+const (
+	// referenceFile must resolve to a file.
+	referenceFile referenceKind = iota
+
+	// referenceFileOrDir may resolve to a file or to a directory containing a kustomization.
+	referenceFileOrDir
+
+	// referenceFileOrAnyDir may resolve to a file or to any directory (e.g. generator file sources).
+	referenceFileOrAnyDir
+)
+
+// reference is a single path referenced from a kustomization file.
+type reference struct {
+	// Field is the kustomization field holding the reference.
+	Field string
+
+	// Value is the reference as written in the kustomization.
+	Value string
+
+	// Kind describes what the reference may resolve to.
+	Kind referenceKind
+}
+
+// collectReferences returns all local-or-remote paths referenced by a kustomization,
+// in declaration order. Inline content (inline patches, inline generator configs) is skipped.
+func collectReferences(kust *kustomizetypes.Kustomization) []reference {
+	refs := make([]reference, 0)
+
+	add := func(field string, kind referenceKind, values ...string) {
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if v == "" || isInlineReference(v) {
+				continue
+			}
+
+			refs = append(refs, reference{Field: field, Value: v, Kind: kind})
+		}
+	}
+
+	add("resources", referenceFileOrDir, kust.Resources...)
+	add("bases", referenceFileOrDir, kust.Bases...)
+	add("components", referenceFileOrDir, kust.Components...)
+	add("crds", referenceFile, kust.Crds...)
+	add("configurations", referenceFile, kust.Configurations...)
+	add("generators", referenceFileOrDir, kust.Generators...)
+	add("transformers", referenceFileOrDir, kust.Transformers...)
+	add("validators", referenceFileOrDir, kust.Validators...)
+
+	for _, p := range kust.PatchesStrategicMerge {
+		add("patchesStrategicMerge", referenceFile, string(p))
+	}
+
+	for _, p := range kust.PatchesJson6902 {
+		add("patchesJson6902", referenceFile, p.Path)
+	}
+
+	for _, p := range kust.Patches {
+		add("patches", referenceFile, p.Path)
+	}
+
+	for _, r := range kust.Replacements {
+		add("replacements", referenceFile, r.Path)
+	}
+
+	for _, g := range kust.ConfigMapGenerator {
+		add("configMapGenerator", referenceFileOrAnyDir, generatorFileSources(g.GeneratorArgs)...)
+		add("configMapGenerator", referenceFile, generatorEnvSources(g.GeneratorArgs)...)
+	}
+
+	for _, g := range kust.SecretGenerator {
+		add("secretGenerator", referenceFileOrAnyDir, generatorFileSources(g.GeneratorArgs)...)
+		add("secretGenerator", referenceFile, generatorEnvSources(g.GeneratorArgs)...)
+	}
+
+	if p, ok := kust.OpenAPI["path"]; ok {
+		add("openapi", referenceFile, p)
+	}
+
+	return refs
+}
+
+// generatorFileSources returns the paths of a generator's file sources ("[key=]path").
+func generatorFileSources(args kustomizetypes.GeneratorArgs) []string {
+	result := make([]string, 0, len(args.FileSources))
+	for _, src := range args.FileSources {
+		if _, path, found := strings.Cut(src, "="); found {
+			src = path
+		}
+
+		result = append(result, src)
+	}
+
+	return result
+}
+
+// generatorEnvSources returns the paths of a generator's env sources.
+func generatorEnvSources(args kustomizetypes.GeneratorArgs) []string {
+	result := make([]string, 0, len(args.EnvSources)+1)
+	result = append(result, args.EnvSources...)
+
+	if args.EnvSource != "" {
+		result = append(result, args.EnvSource)
+	}
+
+	return result
+}
+
+// isInlineReference reports whether a reference holds inline YAML content rather than a path.
+func isInlineReference(ref string) bool {
+	return strings.Contains(ref, "\n")
+}
+
+// isRemoteReference reports whether a reference points at a remote location (git, http)
+// rather than the local filesystem.
+func isRemoteReference(ref string) bool {
+	if strings.Contains(ref, "://") {
+		return true
+	}
+
+	for _, prefix := range []string{"git@", "git::", "github.com/", "gitlab.com/", "bitbucket.org/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveReference returns the location of a local reference relative to the kustomization dir.
+func resolveReference(dir string, ref string) string {
+	if filepath.IsAbs(ref) {
+		return filepath.Clean(ref)
+	}
+
+	return filepath.Join(dir, ref)
+}
+
+// isWithinDir reports whether path is dir itself or located below it.
+func isWithinDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkCycles walks the kustomizations referenced from root through resources, bases and
+// components, failing with ErrCyclicReference when a kustomization references itself,
+// directly or through others, and with ErrMaxDepthExceeded when a chain of references is
+// longer than maxDepth (zero disables the limit). Remote references are not followed, and
+// unreadable kustomizations are skipped, leaving their errors to the build.
+func checkCycles(fs filesys.FileSystem, root string, maxDepth int) error {
+	c := &cycleChecker{
+		fs:       fs,
+		maxDepth: maxDepth,
+		state:    make(map[string]visitState),
+		chains:   make(map[string][]string),
+		stack:    make([]string, 0),
+	}
+
+	return c.visit(filepath.Clean(root))
+}
+
+// visitState tracks the progress of the cycle check on a kustomization directory.
+type visitState int
+
+const (
+	unvisited visitState = iota
+	visiting
+	visited
+)
+
+// cycleChecker performs a depth-first walk of a kustomization tree.
+type cycleChecker struct {
+	fs       filesys.FileSystem
+	maxDepth int
+	state    map[string]visitState
+
+	// chains holds the longest chain of references starting at every visited directory,
+	// so that a directory reached again through a deeper path is checked without being
+	// walked again.
+	chains map[string][]string
+
+	// stack holds the kustomization directories of the current walk path.
+	stack []string
+}
+
+func (c *cycleChecker) visit(dir string) error {
+	c.state[dir] = visiting
+	c.stack = append(c.stack, dir)
+
+	var deepest []string
+
+	kust, _, err := readKustomization(c.fs, dir)
+	if err == nil {
+		for _, ref := range collectReferences(kust) {
+			if !isKustomizationField(ref.Field) || isRemoteReference(ref.Value) {
+				continue
+			}
+
+			target := resolveReference(dir, ref.Value)
+			if !c.fs.IsDir(target) {
+				continue
+			}
+
+			switch c.state[target] {
+			case visiting:
+				cycle := append(slices.Clone(c.stack[slices.Index(c.stack, target):]), target)
+
+				return fmt.Errorf("%w: %s", ErrCyclicReference, strings.Join(cycle, " -> "))
+			case visited:
+			case unvisited:
+				// fail before descending, so that the walk itself stays bounded
+				if err := c.checkDepth([]string{target}); err != nil {
+					return err
+				}
+
+				if err := c.visit(target); err != nil {
+					return err
+				}
+			}
+
+			if err := c.checkDepth(c.chains[target]); err != nil {
+				return err
+			}
+
+			if len(c.chains[target]) > len(deepest) {
+				deepest = c.chains[target]
+			}
+		}
+	}
+
+	c.stack = c.stack[:len(c.stack)-1]
+	c.state[dir] = visited
+	c.chains[dir] = append([]string{dir}, deepest...)
+
+	return nil
+}
+
+// checkDepth fails with ErrMaxDepthExceeded if the current walk path followed by chain,
+// starting with a directory referenced by the top of the stack, exceeds the depth limit.
+func (c *cycleChecker) checkDepth(chain []string) error {
+	if c.maxDepth <= 0 || len(c.stack)+len(chain)-1 <= c.maxDepth {
+		return nil
+	}
+
+	full := append(slices.Clone(c.stack), chain...)
+
+	return fmt.Errorf("%w: more than %d levels: %s", ErrMaxDepthExceeded, c.maxDepth, strings.Join(full, " -> "))
+}
+
+// isKustomizationField reports whether a kustomization field may reference other
+// kustomizations.
+func isKustomizationField(field string) bool {
+	return field == "resources" || field == "bases" || field == "components"
+}
+
+// treeInfo describes what the build of a kustomization tree touches besides its own files.
+type treeInfo struct {
+	// deprecated is set when a kustomization has deprecated fields, which kustomize
+	// reports on stderr.
+	deprecated bool
+
+	// openAPI is set when a kustomization selects an OpenAPI schema, which kustomize
+	// keeps in process-wide state.
+	openAPI bool
+
+	// unknown is set when part of the tree could not be inspected: remote bases and
+	// unreadable kustomizations.
+	unknown bool
+}
+
+// inspectTree walks the local kustomizations referenced from root through resources, bases
+// and components. Remote references are not followed.
+func inspectTree(fs filesys.FileSystem, root string) treeInfo {
+	var info treeInfo
+
+	seen := make(map[string]bool)
+
+	var visit func(dir string)
+	visit = func(dir string) {
+		seen[dir] = true
+
+		kust, _, err := readKustomization(fs, dir)
+		if err != nil {
+			info.unknown = true
+
+			return
+		}
+
+		if messages := kust.CheckDeprecatedFields(); messages != nil && len(*messages) > 0 {
+			info.deprecated = true
+		}
+
+		if len(kust.OpenAPI) > 0 {
+			info.openAPI = true
+		}
+
+		for _, ref := range collectReferences(kust) {
+			if !isKustomizationField(ref.Field) {
+				continue
+			}
+
+			if isRemoteReference(ref.Value) {
+				info.unknown = true
+
+				continue
+			}
+
+			if target := resolveReference(dir, ref.Value); !seen[target] && fs.IsDir(target) {
+				visit(target)
+			}
+		}
+	}
+
+	visit(filepath.Clean(root))
+
+	return info
+}
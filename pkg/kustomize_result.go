@@ -0,0 +1,188 @@
+package kustomize
+
+import (
+	"maps"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Result is the detailed outcome of RenderDetailed.
+type Result struct {
+	// Sources holds the result of every source, in source order.
+	Sources []SourceResult
+
+	// WarningsBySource maps the path of every source with warnings to its warnings. Like
+	// SourceResult.Warnings, it is populated whatever the warning handler does with the
+	// warnings (print them with WarningLog, drop them with WarningIgnore, ...), so they can
+	// be inspected programmatically while console output is suppressed.
+	WarningsBySource map[string][]Warning
+
+	// Extra holds the objects added with WithExtraResources, which belong to no source.
+	Extra []unstructured.Unstructured
+
+	// Duration is the wall-clock time taken by the whole render. With WithConcurrency, it is
+	// less than the sum of the source durations.
+	Duration time.Duration
+
+	stats Stats
+}
+
+// Stats summarizes a render, e.g. for CLI output.
+type Stats struct {
+	// Objects is the total number of objects, extra resources included.
+	Objects int
+
+	// ObjectsByKind counts the objects of every kind.
+	ObjectsByKind map[string]int
+
+	// Sources is the number of rendered sources.
+	Sources int
+
+	// Duration is the wall-clock time taken by the render.
+	Duration time.Duration
+
+	// CacheHits is the number of sources served from the render cache.
+	CacheHits int
+
+	// CacheMisses is the number of sources rendered after looking up the render cache in
+	// vain. Failed sources and sources rendered without cache count as neither hits nor
+	// misses.
+	CacheMisses int
+
+	// CacheHitRatio is CacheHits divided by CacheHits plus CacheMisses, or 0 without cache
+	// lookups.
+	CacheHitRatio float64
+}
+
+// newResult creates the Result of the given source results and extra resources, summing the
+// object counts taken by processSource into its Stats.
+func newResult(sources []SourceResult, extra []unstructured.Unstructured) *Result {
+	bySource := make(map[string][]Warning)
+	stats := Stats{
+		ObjectsByKind: make(map[string]int),
+		Sources:       len(sources),
+	}
+
+	for _, source := range sources {
+		if len(source.Warnings) > 0 {
+			bySource[source.Path] = append(bySource[source.Path], source.Warnings...)
+		}
+
+		for kind, count := range source.kinds {
+			stats.ObjectsByKind[kind] += count
+		}
+
+		stats.Objects += len(source.Objects)
+
+		switch {
+		case source.CacheHit:
+			stats.CacheHits++
+		case source.cacheLookup:
+			stats.CacheMisses++
+		}
+	}
+
+	for _, obj := range extra {
+		stats.ObjectsByKind[obj.GetKind()]++
+	}
+
+	stats.Objects += len(extra)
+
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		stats.CacheHitRatio = float64(stats.CacheHits) / float64(lookups)
+	}
+
+	return &Result{
+		Sources:          sources,
+		WarningsBySource: bySource,
+		Extra:            extra,
+		stats:            stats,
+	}
+}
+
+// SourceResult is the outcome of rendering a single source.
+type SourceResult struct {
+	// Path is the path of the rendered Source.
+	Path string
+
+	// Objects are the objects rendered from the source, after renderer-level filters and
+	// transformers.
+	Objects []unstructured.Unstructured
+
+	// Origins holds the kustomize origin of every object, by index in Objects: the file,
+	// repository and ref it was loaded from, or the generator that created it. Origins are
+	// recorded by kustomize only with source tracking (WithSourceAnnotations or
+	// WithSourceInfoAsLabels) or when the kustomization requests originAnnotations; objects
+	// without known origin, such as those added by transformers, have a nil entry.
+	Origins []*Origin
+
+	// ValuesNames maps the name of the values ConfigMap (or Secret) injected into the source
+	// to its name in Objects, after kustomize and the renderer transformers applied name
+	// prefixes, suffixes or hash suffixes, e.g. to reference it from a patched Deployment.
+	// It is nil when no values were injected, or when the values object is not part of the
+	// output.
+	ValuesNames map[string]string
+
+	// Warnings are the kustomize warnings detected while rendering the source. Cache hits
+	// report the warnings stored with the cached objects.
+	Warnings []Warning
+
+	// AppliedComponents lists the kustomize components applied by the source, including
+	// those declared by its local bases and components, in declaration order. Local
+	// components are given by directory relative to the source path, remote ones by URL.
+	// Cache hits report the components stored with the cached objects.
+	AppliedComponents []string
+
+	// CacheHit reports whether the objects were served from the render cache.
+	CacheHit bool
+
+	// Duration is the time taken to render the source, including values, filters and
+	// transformers.
+	Duration time.Duration
+
+	// Err is the error of a failed source, only reported with WithContinueOnError. Failed
+	// sources have no objects.
+	Err error
+
+	// kinds counts the objects of every kind, for Result.Stats.
+	kinds map[string]int
+
+	// cacheLookup reports whether the render cache was looked up, for Result.Stats.
+	cacheLookup bool
+}
+
+// Objects returns the objects of all sources, flattened in source order, followed by the
+// extra resources.
+func (r *Result) Objects() []unstructured.Unstructured {
+	objects := make([]unstructured.Unstructured, 0)
+	for _, source := range r.Sources {
+		objects = append(objects, source.Objects...)
+	}
+
+	return append(objects, r.Extra...)
+}
+
+// Warnings returns the warnings of all sources, in source order.
+func (r *Result) Warnings() []Warning {
+	warnings := make([]Warning, 0)
+	for _, source := range r.Sources {
+		warnings = append(warnings, source.Warnings...)
+	}
+
+	return warnings
+}
+
+// Stats returns the statistics of the render. Objects are counted as returned, after filters
+// and transformers, whether served from the cache or freshly built. The counts are taken while
+// the render assembles its output, so the objects are not walked again.
+func (r *Result) Stats() Stats {
+	stats := r.stats
+	stats.ObjectsByKind = maps.Clone(r.stats.ObjectsByKind)
+
+	if stats.ObjectsByKind == nil {
+		stats.ObjectsByKind = make(map[string]int)
+	}
+
+	return stats
+}
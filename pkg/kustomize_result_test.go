@@ -0,0 +1,525 @@
+package kustomize_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k8s-manifest-kit/pkg/util/cache"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRenderDetailed(t *testing.T) {
+	t.Run("should group objects and warnings by source", func(t *testing.T) {
+		g := NewWithT(t)
+		deprecatedDir := setupDeprecatedKustomization(t)
+		cleanDir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: deprecatedDir}, {Path: cleanDir}},
+			kustomize.WithWarningHandler(kustomize.WarningIgnore()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources).To(HaveLen(2))
+
+		deprecated := result.Sources[0]
+		g.Expect(deprecated.Path).To(Equal(deprecatedDir))
+		g.Expect(deprecated.Objects).ToNot(BeEmpty())
+		g.Expect(deprecated.Warnings).To(ContainElement(HaveField("Field", "commonLabels")))
+		g.Expect(deprecated.CacheHit).To(BeFalse())
+		g.Expect(deprecated.Duration).To(BeNumerically(">", 0))
+
+		clean := result.Sources[1]
+		g.Expect(clean.Path).To(Equal(cleanDir))
+		g.Expect(clean.Objects).To(HaveLen(2))
+		g.Expect(clean.Warnings).To(BeEmpty())
+
+		g.Expect(result.Warnings()).To(Equal(deprecated.Warnings))
+		g.Expect(result.Objects()).To(HaveLen(len(deprecated.Objects) + len(clean.Objects)))
+	})
+
+	t.Run("should index warnings by source whatever the handler", func(t *testing.T) {
+		for name, handler := range map[string]kustomize.WarningHandler{
+			"ignore": kustomize.WarningIgnore(),
+			"log":    kustomize.WarningLog(io.Discard),
+		} {
+			t.Run(name, func(t *testing.T) {
+				g := NewWithT(t)
+				deprecatedDir := setupDeprecatedKustomization(t)
+				cleanDir := setupBasicKustomization(t)
+
+				renderer, err := kustomize.New(
+					[]kustomize.Source{{Path: deprecatedDir}, {Path: cleanDir}},
+					kustomize.WithWarningHandler(handler),
+				)
+				g.Expect(err).ToNot(HaveOccurred())
+
+				result, err := renderer.RenderDetailed(t.Context(), nil)
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(result.WarningsBySource).To(HaveLen(1))
+				g.Expect(result.WarningsBySource).To(HaveKeyWithValue(deprecatedDir, result.Sources[0].Warnings))
+				g.Expect(result.WarningsBySource[deprecatedDir]).To(ContainElement(HaveField("Field", "commonLabels")))
+			})
+		}
+	})
+
+	t.Run("should match the flattened output of Process", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}, {Path: setupBasicKustomization(t)}},
+			kustomize.WithConcurrency(2),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Objects()).To(Equal(objects))
+	})
+
+	t.Run("should report cache hits", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupDeprecatedKustomization(t)}},
+			kustomize.WithCache(cache.WithTTL(time.Minute)),
+			kustomize.WithWarningHandler(kustomize.WarningIgnore()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		first, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(first.Sources[0].CacheHit).To(BeFalse())
+		g.Expect(first.Sources[0].Warnings).ToNot(BeEmpty())
+
+		second, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(second.Sources[0].CacheHit).To(BeTrue())
+		g.Expect(second.Sources[0].Warnings).To(Equal(first.Sources[0].Warnings))
+		g.Expect(second.WarningsBySource).To(Equal(first.WarningsBySource))
+		g.Expect(second.Sources[0].Objects).To(Equal(first.Sources[0].Objects))
+	})
+
+	t.Run("should pass cached warnings to the collector and the handler", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupDeprecatedKustomization(t)
+
+		handled := 0
+		collector := kustomize.NewWarningCollector()
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithCache(cache.WithTTL(time.Minute)),
+			kustomize.WithWarningCollector(collector),
+			kustomize.WithStructuredWarningHandler(func(warnings []kustomize.Warning) error {
+				handled += len(warnings)
+
+				return nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		second, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(second.Sources[0].CacheHit).To(BeTrue())
+		g.Expect(collector.WarningsFor(dir)).To(HaveLen(2))
+		g.Expect(handled).To(Equal(2))
+	})
+
+	t.Run("should fail on render errors", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: t.TempDir()}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+		g.Expect(result).To(BeNil())
+	})
+}
+
+func TestResultStats(t *testing.T) {
+	t.Run("should summarize the render", func(t *testing.T) {
+		g := NewWithT(t)
+		cachedDir := setupBasicKustomization(t)
+		uncachedDir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: cachedDir}, {Path: uncachedDir, CacheTTL: -1}},
+			kustomize.WithCache(cache.WithTTL(time.Minute)),
+			kustomize.WithExtraResources([]byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: extra\n")),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		first, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		stats := first.Stats()
+		g.Expect(stats.Objects).To(Equal(5))
+		g.Expect(stats.ObjectsByKind).To(Equal(map[string]int{"ConfigMap": 2, "Pod": 2, "Namespace": 1}))
+		g.Expect(stats.Sources).To(Equal(2))
+		g.Expect(stats.Duration).To(BeNumerically(">", 0))
+		g.Expect(stats.CacheHits).To(Equal(0))
+		g.Expect(stats.CacheMisses).To(Equal(1))
+		g.Expect(stats.CacheHitRatio).To(BeZero())
+
+		// the uncached source has no cache lookup to count
+		second, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(second.Stats().CacheHits).To(Equal(1))
+		g.Expect(second.Stats().CacheMisses).To(Equal(0))
+		g.Expect(second.Stats().CacheHitRatio).To(Equal(1.0))
+	})
+
+	t.Run("should leave failed sources out of the cache hit ratio", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}, {Path: filepath.Join(t.TempDir(), "missing")}},
+			kustomize.WithCache(cache.WithTTL(time.Minute)),
+			kustomize.WithContinueOnError(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Sources[1].Err).To(HaveOccurred())
+
+		stats := result.Stats()
+		g.Expect(stats.Sources).To(Equal(2))
+		g.Expect(stats.CacheHits).To(Equal(1))
+		g.Expect(stats.CacheMisses).To(Equal(0))
+		g.Expect(stats.CacheHitRatio).To(Equal(1.0))
+	})
+
+	t.Run("should return the counts taken during the render", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		stats := result.Stats()
+		stats.ObjectsByKind["Pod"] = 10
+		result.Sources[0].Objects = nil
+
+		g.Expect(result.Stats().Objects).To(Equal(2))
+		g.Expect(result.Stats().ObjectsByKind).To(Equal(map[string]int{"ConfigMap": 1, "Pod": 1}))
+	})
+
+	t.Run("should be empty without sources", func(t *testing.T) {
+		g := NewWithT(t)
+
+		stats := (&kustomize.Result{}).Stats()
+		g.Expect(stats.Objects).To(BeZero())
+		g.Expect(stats.ObjectsByKind).To(BeEmpty())
+		g.Expect(stats.CacheHitRatio).To(BeZero())
+	})
+}
+
+func TestAppliedComponents(t *testing.T) {
+	t.Run("should report the components applied by a source", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+
+		component := func(dir string, extra string) {
+			writeFile(t, root, dir+"/kustomization.yaml",
+				"apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\n"+
+					"commonAnnotations:\n  example.com/"+filepath.Base(dir)+": enabled\n"+extra)
+		}
+
+		writeFile(t, root, "base/kustomization.yaml", "resources:\n- configmap.yaml\ncomponents:\n- ../components/base-only\n")
+		writeFile(t, root, "base/configmap.yaml", basicConfigMap)
+		component("components/base-only", "")
+		component("components/monitoring", "components:\n- ../tracing\n")
+		component("components/tracing", "")
+		component("components/unused", "")
+		writeFile(t, root, "overlay/kustomization.yaml",
+			"resources:\n- ../base\ncomponents:\n- ../components/monitoring\n- ../components/tracing\n")
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: filepath.Join(root, "overlay")}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources[0].AppliedComponents).To(Equal([]string{
+			"../components/base-only",
+			"../components/monitoring",
+			"../components/tracing",
+		}))
+	})
+
+	t.Run("should report no components for plain kustomizations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: setupBasicKustomization(t)}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources[0].AppliedComponents).To(BeEmpty())
+	})
+
+	t.Run("should report the cached components on cache hits", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+
+		writeFile(t, root, "components/monitoring/kustomization.yaml",
+			"apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\n"+
+				"commonAnnotations:\n  example.com/monitoring: enabled\n")
+		writeFile(t, root, "app/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "app/kustomization.yaml",
+			"resources:\n- configmap.yaml\ncomponents:\n- ../components/monitoring\n")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: filepath.Join(root, "app")}},
+			kustomize.WithCache(cache.WithTTL(time.Minute)),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		first, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(first.Sources[0].AppliedComponents).To(Equal([]string{"../components/monitoring"}))
+
+		// the cache key ignores the files, so the stale render is served along with its components
+		writeFile(t, root, "app/kustomization.yaml", "resources:\n- configmap.yaml\n")
+
+		second, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(second.Sources[0].CacheHit).To(BeTrue())
+		g.Expect(second.Sources[0].Objects).To(Equal(first.Sources[0].Objects))
+		g.Expect(second.Sources[0].AppliedComponents).To(Equal([]string{"../components/monitoring"}))
+	})
+}
+
+func TestOrigins(t *testing.T) {
+	t.Run("should report the origin of every object", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", `
+resources:
+- configmap.yaml
+configMapGenerator:
+- name: generated
+  literals:
+  - key=value
+`)
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithCache(),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// the second render is served from the cache
+		for range 2 {
+			result, err := renderer.RenderDetailed(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			source := result.Sources[0]
+			g.Expect(source.Objects).To(HaveLen(2))
+			g.Expect(source.Origins).To(HaveLen(2))
+
+			g.Expect(source.Origins[0]).To(Equal(&kustomize.Origin{Path: "configmap.yaml"}))
+
+			g.Expect(source.Origins[1].ConfiguredIn).To(Equal("kustomization.yaml"))
+			g.Expect(source.Origins[1].ConfiguredBy).To(Equal(&kustomize.OriginReference{
+				APIVersion: "builtin",
+				Kind:       "ConfigMapGenerator",
+			}))
+
+			for _, obj := range source.Objects {
+				g.Expect(obj.GetAnnotations()).To(HaveLen(3))
+			}
+		}
+	})
+
+	t.Run("should hide the origin marker from filters and transformers", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", `
+resources:
+- configmap.yaml
+configMapGenerator:
+- name: generated
+  literals:
+  - key=value
+`)
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+		var seen []map[string]string
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithFilter(func(_ context.Context, obj unstructured.Unstructured) (bool, error) {
+				seen = append(seen, obj.GetAnnotations())
+
+				return obj.GetName() != "configmap", nil
+			}),
+			kustomize.WithTransformer(func(_ context.Context, obj unstructured.Unstructured) (unstructured.Unstructured, error) {
+				seen = append(seen, obj.GetAnnotations())
+
+				return obj, nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(seen).To(HaveLen(3))
+		for _, annotations := range seen {
+			g.Expect(annotations).ToNot(HaveKey(HavePrefix("internal.")))
+		}
+
+		source := result.Sources[0]
+		g.Expect(source.Objects).To(HaveLen(1))
+		g.Expect(source.Origins).To(HaveLen(1))
+		g.Expect(source.Origins[0].ConfiguredIn).To(Equal("kustomization.yaml"))
+	})
+
+	t.Run("should report no origin without source tracking", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: setupBasicKustomization(t)}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources[0].Origins).To(Equal([]*kustomize.Origin{nil, nil}))
+
+		for _, obj := range result.Sources[0].Objects {
+			g.Expect(obj.GetAnnotations()).To(BeEmpty())
+		}
+	})
+}
+
+func TestValuesNames(t *testing.T) {
+	setupPrefixedValues := func(t *testing.T) string {
+		t.Helper()
+
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "namePrefix: app-\nresources:\n- values.yaml\n")
+
+		return dir
+	}
+
+	t.Run("should map the values ConfigMap to its rendered name", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{
+			Path:   setupPrefixedValues(t),
+			Values: kustomize.Values(map[string]string{"key": "value"}),
+		}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources[0].ValuesNames).To(Equal(map[string]string{"values": "app-values"}))
+		g.Expect(result.Sources[0].Objects).To(HaveLen(1))
+		g.Expect(result.Sources[0].Objects[0].GetName()).To(Equal("app-values"))
+		g.Expect(result.Sources[0].Objects[0].GetAnnotations()).To(BeEmpty())
+	})
+
+	t.Run("should map the values on cache hits", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{
+				Path:   setupPrefixedValues(t),
+				Values: kustomize.Values(map[string]string{"key": "value"}),
+			}},
+			kustomize.WithCache(cache.WithTTL(time.Minute)),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources[0].CacheHit).To(BeTrue())
+		g.Expect(result.Sources[0].ValuesNames).To(Equal(map[string]string{"values": "app-values"}))
+	})
+
+	t.Run("should keep the mark out of Process output", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{
+			Path:   setupPrefixedValues(t),
+			Values: kustomize.Values(map[string]string{"key": "value"}),
+		}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetAnnotations()).To(BeEmpty())
+	})
+
+	t.Run("should hide the mark from filters and transformers", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var seen []map[string]string
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{
+				Path:   setupPrefixedValues(t),
+				Values: kustomize.Values(map[string]string{"key": "value"}),
+			}},
+			kustomize.WithFilter(func(_ context.Context, obj unstructured.Unstructured) (bool, error) {
+				seen = append(seen, obj.GetAnnotations())
+
+				return true, nil
+			}),
+			kustomize.WithTransformer(func(_ context.Context, obj unstructured.Unstructured) (unstructured.Unstructured, error) {
+				seen = append(seen, obj.GetAnnotations())
+				obj.SetName(obj.GetName() + "-renamed")
+
+				return obj, nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(seen).To(Equal([]map[string]string{nil, nil}))
+		g.Expect(result.Sources[0].ValuesNames).To(Equal(map[string]string{"values": "app-values-renamed"}))
+	})
+
+	t.Run("should be nil without values", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: setupBasicKustomization(t)}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources[0].ValuesNames).To(BeNil())
+	})
+}
@@ -0,0 +1,108 @@
+package kustomize
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// RetryClassifier reports whether a render error is transient and the render worth retrying.
+type RetryClassifier func(err error) bool
+
+// transientMessages are fragments of the messages of transient network errors. Kustomize
+// reports some failures, e.g. of git clones, as plain text, losing the original error.
+//
+//nolint:gochecknoglobals
+var transientMessages = []string{
+	"connection reset by peer",
+	"connection refused",
+	"i/o timeout",
+	"tls handshake timeout",
+	"temporary failure in name resolution",
+	"unexpected eof",
+}
+
+// IsTransientError is the default RetryClassifier. It reports network timeouts, reset or
+// refused connections, unexpected EOFs and temporary DNS failures as transient. Context
+// cancellation, render timeouts and every other error (invalid YAML, load restrictions,
+// missing files, ...) are not.
+func IsTransientError(err error) bool {
+	if err == nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrRenderTimeout) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ETIMEDOUT) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range transientMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runWithRetry runs runWithTimeout up to RetryAttempts times while it fails with an error
+// classified as transient, doubling the delay between attempts. Waiting stops as soon as
+// ctx is done, returning the last render error joined with the context error.
+func (e *Engine) runWithRetry(
+	ctx context.Context,
+	input Source,
+	values map[string]any,
+) ([]unstructured.Unstructured, []Warning, error) {
+	classify := e.opts.RetryClassifier
+	if classify == nil {
+		classify = IsTransientError
+	}
+
+	delay := e.opts.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		result, warnings, err := e.runWithTimeout(ctx, input, values)
+		if err == nil || attempt >= e.opts.RetryAttempts || ctx.Err() != nil || !classify(err) {
+			return result, warnings, err
+		}
+
+		e.logger().InfoContext(ctx, "retrying kustomize render",
+			slog.String("source", input.Path),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("delay", delay),
+			slog.Any("error", err),
+		)
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+
+				return nil, nil, errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+
+			delay *= 2
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	openapiv2 "github.com/google/gnostic-models/openapiv2"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/kustomize/kyaml/openapi/kubernetesapi"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+const (
+	definitionRefPrefix = "#/definitions/"
+	gvkExtensionKey     = "x-kubernetes-group-version-kind"
+	intOrStringExtKey   = "x-kubernetes-int-or-string"
+	intOrStringFormat   = "int-or-string"
+	intOrStringRefName  = "io.k8s.apimachinery.pkg.util.intstr.IntOrString"
+	quantityRefName     = "io.k8s.apimachinery.pkg.api.resource.Quantity"
+)
+
+var (
+	// ErrSchemaValidation is matched (via errors.Is) by the SchemaValidationError returned
+	// when rendered objects do not match their schema.
+	ErrSchemaValidation = errors.New("schema validation failed")
+
+	// ErrInvalidSchema is returned when an OpenAPI document or CRD cannot be used as schema source.
+	ErrInvalidSchema = errors.New("invalid schema")
+)
+
+// SchemaSource resolves the OpenAPI schema of rendered objects.
+type SchemaSource interface {
+	// SchemaFor returns the schema of the given kind, or false if the source does not know it.
+	// Returned schemas must not contain references.
+	SchemaFor(gvk schema.GroupVersionKind) (*spec.Schema, bool)
+}
+
+// OpenAPISchema is a SchemaSource backed by OpenAPI v2 definitions. Definitions carrying the
+// x-kubernetes-group-version-kind extension are resolved by kind, with references inlined.
+// It is safe for concurrent use.
+type OpenAPISchema struct {
+	definitions spec.Definitions
+	byGVK       map[schema.GroupVersionKind]string
+
+	mu       sync.Mutex
+	resolved map[string]*spec.Schema
+}
+
+// NewOpenAPISchema parses an OpenAPI v2 (Swagger) document in JSON or YAML form, such as the
+// output of `kubectl get --raw /openapi/v2`.
+func NewOpenAPISchema(data []byte) (*OpenAPISchema, error) {
+	jsonData, err := sigsyaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+
+	var swagger spec.Swagger
+	if err := swagger.UnmarshalJSON(jsonData); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+
+	return newOpenAPISchema(swagger.Definitions), nil
+}
+
+// KubernetesSchema returns the schemas of the built-in Kubernetes kinds bundled with
+// kustomize (Kubernetes v1.21). The schemas are parsed on first use only.
+// For newer kinds or versions, use NewOpenAPISchema with the document of the target cluster.
+func KubernetesSchema() (*OpenAPISchema, error) {
+	return kubernetesSchema()
+}
+
+//nolint:gochecknoglobals
+var kubernetesSchema = sync.OnceValues(func() (*OpenAPISchema, error) {
+	version := kubernetesapi.DefaultOpenAPI
+	asset := "kubernetesapi/" + strings.ReplaceAll(version, ".", "_") + "/swagger.pb"
+
+	doc := &openapiv2.Document{}
+	if err := proto.Unmarshal(kubernetesapi.OpenAPIMustAsset[version](asset), doc); err != nil {
+		return nil, fmt.Errorf("%w: built-in Kubernetes schema: %w", ErrInvalidSchema, err)
+	}
+
+	var swagger spec.Swagger
+	if _, err := swagger.FromGnostic(doc); err != nil {
+		return nil, fmt.Errorf("%w: built-in Kubernetes schema: %w", ErrInvalidSchema, err)
+	}
+
+	return newOpenAPISchema(swagger.Definitions), nil
+})
+
+// NewCRDSchema builds a SchemaSource from CustomResourceDefinitions (apiextensions.k8s.io/v1),
+// using the openAPIV3Schema of every served version.
+func NewCRDSchema(crds ...unstructured.Unstructured) (*OpenAPISchema, error) {
+	definitions := make(spec.Definitions)
+
+	for _, crd := range crds {
+		if crd.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}) {
+			return nil, fmt.Errorf("%w: %s %q is not a CustomResourceDefinition", ErrInvalidSchema, crd.GetKind(), crd.GetName())
+		}
+
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+
+		versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		if err != nil {
+			return nil, fmt.Errorf("%w: CRD %q: %w", ErrInvalidSchema, crd.GetName(), err)
+		}
+
+		for _, v := range versions {
+			version, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			name, _, _ := unstructured.NestedString(version, "name")
+
+			raw, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+			if !found {
+				continue
+			}
+
+			data, err := sigsyaml.Marshal(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: CRD %q version %q: %w", ErrInvalidSchema, crd.GetName(), name, err)
+			}
+
+			var s spec.Schema
+			if err := sigsyaml.Unmarshal(data, &s); err != nil {
+				return nil, fmt.Errorf("%w: CRD %q version %q: %w", ErrInvalidSchema, crd.GetName(), name, err)
+			}
+
+			s.AddExtension(gvkExtensionKey, []any{
+				map[string]any{"group": group, "version": name, "kind": kind},
+			})
+
+			definitions[group+"/"+name+"/"+kind] = s
+		}
+	}
+
+	return newOpenAPISchema(definitions), nil
+}
+
+func newOpenAPISchema(definitions spec.Definitions) *OpenAPISchema {
+	byGVK := make(map[schema.GroupVersionKind]string)
+
+	for name, def := range definitions {
+		gvks, ok := def.Extensions[gvkExtensionKey].([]any)
+		if !ok {
+			continue
+		}
+
+		for _, g := range gvks {
+			m, ok := g.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			group, _ := m["group"].(string)
+			version, _ := m["version"].(string)
+			kind, _ := m["kind"].(string)
+
+			byGVK[schema.GroupVersionKind{Group: group, Version: version, Kind: kind}] = name
+		}
+	}
+
+	return &OpenAPISchema{
+		definitions: definitions,
+		byGVK:       byGVK,
+		resolved:    make(map[string]*spec.Schema),
+	}
+}
+
+// SchemaFor returns the schema of the given kind with all references inlined.
+func (s *OpenAPISchema) SchemaFor(gvk schema.GroupVersionKind) (*spec.Schema, bool) {
+	name, ok := s.byGVK[gvk]
+	if !ok {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resolved, ok := s.resolved[name]; ok {
+		return resolved, true
+	}
+
+	resolved := s.inline(s.definitions[name], map[string]bool{name: true})
+	s.resolved[name] = &resolved
+
+	return &resolved, true
+}
+
+// inline returns a copy of in with references replaced by the definitions they point to.
+// Recursive references and unknown definitions accept any value. Every schema is made nullable,
+// since the API server treats null fields as unset, and int-or-string fields accept both.
+func (s *OpenAPISchema) inline(in spec.Schema, visiting map[string]bool) spec.Schema {
+	if ref := in.Ref.String(); ref != "" {
+		name := strings.TrimPrefix(ref, definitionRefPrefix)
+
+		def, ok := s.definitions[name]
+		if !ok || visiting[name] || name == intOrStringRefName || name == quantityRefName {
+			return spec.Schema{SchemaProps: spec.SchemaProps{Description: in.Description, Nullable: true}}
+		}
+
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		return s.inline(def, visiting)
+	}
+
+	out := in
+	out.Nullable = true
+
+	if isIntOrString, _ := in.Extensions.GetBool(intOrStringExtKey); isIntOrString || in.Format == intOrStringFormat {
+		out.Type = nil
+		out.Format = ""
+	}
+
+	out.Properties = s.inlineMap(in.Properties, visiting)
+	out.PatternProperties = s.inlineMap(in.PatternProperties, visiting)
+	out.AllOf = s.inlineSlice(in.AllOf, visiting)
+	out.AnyOf = s.inlineSlice(in.AnyOf, visiting)
+	out.OneOf = s.inlineSlice(in.OneOf, visiting)
+
+	if in.Not != nil {
+		not := s.inline(*in.Not, visiting)
+		out.Not = &not
+	}
+
+	if in.Items != nil {
+		items := &spec.SchemaOrArray{Schemas: s.inlineSlice(in.Items.Schemas, visiting)}
+		if in.Items.Schema != nil {
+			item := s.inline(*in.Items.Schema, visiting)
+			items.Schema = &item
+		}
+
+		out.Items = items
+	}
+
+	if in.AdditionalProperties != nil && in.AdditionalProperties.Schema != nil {
+		additional := s.inline(*in.AdditionalProperties.Schema, visiting)
+		out.AdditionalProperties = &spec.SchemaOrBool{Allows: true, Schema: &additional}
+	}
+
+	return out
+}
+
+func (s *OpenAPISchema) inlineMap(in map[string]spec.Schema, visiting map[string]bool) map[string]spec.Schema {
+	if in == nil {
+		return nil
+	}
+
+	out := make(map[string]spec.Schema, len(in))
+	for k, v := range in {
+		out[k] = s.inline(v, visiting)
+	}
+
+	return out
+}
+
+func (s *OpenAPISchema) inlineSlice(in []spec.Schema, visiting map[string]bool) []spec.Schema {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]spec.Schema, len(in))
+	for i, v := range in {
+		out[i] = s.inline(v, visiting)
+	}
+
+	return out
+}
+
+// SchemaValidationError lists the schema violations of every invalid object.
+type SchemaValidationError struct {
+	// Errors maps each invalid object to its violations.
+	Errors map[ResourceID][]error
+}
+
+// Error lists every violation, grouped by object.
+func (e *SchemaValidationError) Error() string {
+	ids := make([]ResourceID, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+
+	slices.SortFunc(ids, func(a ResourceID, b ResourceID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s: %d invalid object(s)", ErrSchemaValidation.Error(), len(ids))
+
+	for _, id := range ids {
+		fmt.Fprintf(&sb, "\n  %s:", id)
+
+		for _, err := range e.Errors[id] {
+			fmt.Fprintf(&sb, "\n    - %s", err)
+		}
+	}
+
+	return sb.String()
+}
+
+// Is reports whether target is ErrSchemaValidation.
+func (e *SchemaValidationError) Is(target error) bool {
+	return target == ErrSchemaValidation
+}
+
+// validateSchemas validates objects against the first source knowing their kind. Objects of
+// kinds unknown to every source are skipped.
+func validateSchemas(objects []unstructured.Unstructured, sources []SchemaSource) error {
+	invalid := make(map[ResourceID][]error)
+
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+
+		for _, source := range sources {
+			s, ok := source.SchemaFor(gvk)
+			if !ok {
+				continue
+			}
+
+			result := validate.NewSchemaValidator(s, nil, "", strfmt.Default).Validate(obj.Object)
+			if result.HasErrors() {
+				id := ResourceID{GroupVersionKind: gvk, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+				invalid[id] = append(invalid[id], result.Errors...)
+			}
+
+			break
+		}
+	}
+
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	return &SchemaValidationError{Errors: invalid}
+}
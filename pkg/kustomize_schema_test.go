@@ -0,0 +1,234 @@
+package kustomize_test
+
+import (
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+const validWorkloads = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      creationTimestamp: null
+      labels:
+        app: app
+    spec:
+      containers:
+      - name: app
+        image: app:latest
+        ports:
+        - containerPort: 8080
+        resources:
+          limits:
+            cpu: 1
+            memory: 128Mi
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  ports:
+  - port: 80
+    targetPort: 8080
+  - port: 81
+    targetPort: http
+`
+
+const invalidDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: broken
+spec:
+  replicas: three
+  selector:
+    matchLabels:
+      app: broken
+  template:
+    spec:
+      containers:
+      - image: app:latest
+`
+
+const widgetCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [size]
+            properties:
+              size:
+                type: integer
+`
+
+func renderWithSchema(t *testing.T, resources string, opts ...kustomize.RendererOption) error {
+	t.Helper()
+
+	_, err := kustomize.RenderBytes(t.Context(), map[string][]byte{
+		"kustomization.yaml": []byte("resources:\n- resources.yaml\n"),
+		"resources.yaml":     []byte(resources),
+	}, opts...)
+
+	return err
+}
+
+func TestSchemaValidation(t *testing.T) {
+	k8s, err := kustomize.KubernetesSchema()
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	t.Run("should accept valid objects", func(t *testing.T) {
+		g := NewWithT(t)
+
+		err := renderWithSchema(t, validWorkloads, kustomize.WithSchemaValidation(k8s))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should not validate by default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		err := renderWithSchema(t, invalidDeployment)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should report violations by object", func(t *testing.T) {
+		g := NewWithT(t)
+
+		err := renderWithSchema(t, invalidDeployment, kustomize.WithSchemaValidation(k8s))
+		g.Expect(err).To(MatchError(kustomize.ErrSchemaValidation))
+
+		var schemaErr *kustomize.SchemaValidationError
+		g.Expect(errors.As(err, &schemaErr)).To(BeTrue())
+
+		id := kustomize.ResourceID{
+			GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			Name:             "broken",
+		}
+		g.Expect(schemaErr.Errors).To(HaveLen(1))
+		g.Expect(schemaErr.Errors).To(HaveKey(id))
+		g.Expect(err.Error()).To(ContainSubstring("spec.replicas"))
+		g.Expect(err.Error()).To(ContainSubstring("name"))
+	})
+
+	t.Run("should validate custom resources against CRD schemas", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var crd unstructured.Unstructured
+		g.Expect(yaml.Unmarshal([]byte(widgetCRD), &crd.Object)).To(Succeed())
+
+		crds, err := kustomize.NewCRDSchema(crd)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		widget := "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec:\n  size: big\n"
+		err = renderWithSchema(t, widget, kustomize.WithSchemaValidation(crds, k8s))
+		g.Expect(err).To(MatchError(kustomize.ErrSchemaValidation))
+		g.Expect(err.Error()).To(ContainSubstring("spec.size"))
+
+		err = renderWithSchema(t, "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec: {}\n",
+			kustomize.WithSchemaValidation(crds))
+		g.Expect(err).To(MatchError(ContainSubstring("spec.size in body is required")))
+
+		err = renderWithSchema(t, "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec:\n  size: 3\n",
+			kustomize.WithSchemaValidation(crds))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should skip kinds unknown to every source", func(t *testing.T) {
+		g := NewWithT(t)
+
+		err := renderWithSchema(t, "apiVersion: example.com/v1\nkind: Gadget\nmetadata:\n  name: g\nspec: 42\n",
+			kustomize.WithSchemaValidation(k8s))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should reject non-CRD schema sources", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.NewCRDSchema(makeObject("v1", "ConfigMap", "config"))
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidSchema))
+	})
+}
+
+func TestNewOpenAPISchema(t *testing.T) {
+	const document = `
+swagger: "2.0"
+info:
+  title: test
+  version: v1
+paths: {}
+definitions:
+  com.example.v1.Gadget:
+    type: object
+    required: [spec]
+    properties:
+      spec:
+        $ref: "#/definitions/com.example.v1.GadgetSpec"
+    x-kubernetes-group-version-kind:
+    - group: example.com
+      version: v1
+      kind: Gadget
+  com.example.v1.GadgetSpec:
+    type: object
+    properties:
+      color:
+        type: string
+`
+
+	t.Run("should resolve kinds and references", func(t *testing.T) {
+		g := NewWithT(t)
+
+		source, err := kustomize.NewOpenAPISchema([]byte(document))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		s, ok := source.SchemaFor(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"})
+		g.Expect(ok).To(BeTrue())
+		g.Expect(s.Properties["spec"].Properties).To(HaveKey("color"))
+
+		_, ok = source.SchemaFor(schema.GroupVersionKind{Group: "example.com", Version: "v2", Kind: "Gadget"})
+		g.Expect(ok).To(BeFalse())
+
+		err = renderWithSchema(t, "apiVersion: example.com/v1\nkind: Gadget\nmetadata:\n  name: g\nspec:\n  color: 1\n",
+			kustomize.WithSchemaValidation(source))
+		g.Expect(err).To(MatchError(ContainSubstring("spec.color")))
+	})
+
+	t.Run("should reject malformed documents", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.NewOpenAPISchema([]byte("definitions: [\n"))
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidSchema))
+	})
+}
@@ -0,0 +1,99 @@
+package kustomize
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+)
+
+// ErrSelfTestFailed is returned by SelfTest when the built-in kustomization does not render
+// as expected.
+var ErrSelfTestFailed = errors.New("kustomize self-test failed")
+
+// selfTestFS holds the kustomization rendered by SelfTest.
+//
+//go:embed selftest
+var selfTestFS embed.FS
+
+// SelfTest renders a tiny built-in kustomization, a single ConfigMap with a name prefix and
+// a deprecated commonLabels field, through the whole renderer: embedded filesystem adapter,
+// kustomize build, warning detection and handling, and conversion to unstructured objects.
+// It returns an error wrapping ErrSelfTestFailed if the output or warnings differ from what
+// the kustomization declares.
+//
+// SelfTest has no external dependencies and takes about a millisecond, so it fits readiness
+// and health probes of rendering services.
+//
+// Example:
+//
+//	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+//	    if err := kustomize.SelfTest(r.Context()); err != nil {
+//	        http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//	    }
+//	})
+func SelfTest(ctx context.Context) error {
+	fsys, err := fs.NewFromIOFS(selfTestFS, "selftest")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSelfTestFailed, err)
+	}
+
+	var warnings []Warning
+
+	renderer, err := New(
+		[]Source{{Path: "/"}},
+		WithFileSystem(fsys),
+		WithStructuredWarningHandler(func(w []Warning) error {
+			warnings = append(warnings, w...)
+
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSelfTestFailed, err)
+	}
+
+	objects, err := renderer.Process(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSelfTestFailed, err)
+	}
+
+	if err := checkSelfTestOutput(objects); err != nil {
+		return fmt.Errorf("%w: %w", ErrSelfTestFailed, err)
+	}
+
+	if len(warnings) != 1 || warnings[0].Field != "commonLabels" {
+		return fmt.Errorf("%w: expected a single commonLabels deprecation warning, got %d warning(s)",
+			ErrSelfTestFailed, len(warnings))
+	}
+
+	return nil
+}
+
+// checkSelfTestOutput verifies the objects rendered by SelfTest.
+func checkSelfTestOutput(objects []unstructured.Unstructured) error {
+	if len(objects) != 1 {
+		return fmt.Errorf("expected 1 object, got %d", len(objects)) //nolint:err113
+	}
+
+	obj := objects[0]
+
+	if obj.GetAPIVersion() != "v1" || obj.GetKind() != "ConfigMap" || obj.GetName() != "selftest-config" {
+		return fmt.Errorf("unexpected object %s %s/%s", obj.GetAPIVersion(), obj.GetKind(), obj.GetName()) //nolint:err113
+	}
+
+	if label := obj.GetLabels()["app.kubernetes.io/name"]; label != "selftest" {
+		return fmt.Errorf("unexpected app.kubernetes.io/name label %q", label) //nolint:err113
+	}
+
+	status, _, _ := unstructured.NestedString(obj.Object, "data", "status")
+	if status != "ok" {
+		return fmt.Errorf("unexpected data.status %q", status) //nolint:err113
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package kustomize_test
+
+import (
+	"context"
+	"testing"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSelfTest(t *testing.T) {
+	t.Run("should pass", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(kustomize.SelfTest(t.Context())).To(Succeed())
+	})
+
+	t.Run("should fail when the context is done", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		err := kustomize.SelfTest(ctx)
+		g.Expect(err).To(MatchError(kustomize.ErrSelfTestFailed))
+		g.Expect(err).To(MatchError(context.Canceled))
+	})
+}
+
+func BenchmarkSelfTest(b *testing.B) {
+	for b.Loop() {
+		if err := kustomize.SelfTest(b.Context()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package kustomize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"strings"
+
+	"github.com/k8s-manifest-kit/engine/pkg/types"
+)
+
+const (
+	sourceTypeKeySuffix = "/source.type"
+	sourcePathKeySuffix = "/source.path"
+	sourceFileKeySuffix = "/source.file"
+
+	// maxLabelValueLength is the maximum length of a label value.
+	maxLabelValueLength = 63
+
+	// labelHashLength is the number of hex digits of the hash prefixing shortened label values.
+	labelHashLength = 10
+)
+
+// SourceAnnotationConfig customizes the source tracking annotations.
+type SourceAnnotationConfig struct {
+	// Prefix replaces the "manifests.k8s-manifests-lib" prefix of the annotation keys,
+	// e.g. "example.com" yields example.com/source.type, example.com/source.path and
+	// example.com/source.file. Empty keeps the default keys.
+	Prefix string
+
+	// Extra are static annotations added to every object alongside the source annotations.
+	// They never override the source annotations themselves.
+	Extra map[string]string
+}
+
+// sourceAnnotationKeys are the annotation keys used for source tracking.
+type sourceAnnotationKeys struct {
+	Type string
+	Path string
+	File string
+}
+
+// defaultSourceAnnotationKeys are the engine's well-known source annotation keys.
+//
+//nolint:gochecknoglobals
+var defaultSourceAnnotationKeys = sourceAnnotationKeys{
+	Type: types.AnnotationSourceType,
+	Path: types.AnnotationSourcePath,
+	File: types.AnnotationSourceFile,
+}
+
+// keys returns the annotation keys selected by the config; a nil config selects the defaults.
+func (c *SourceAnnotationConfig) keys() sourceAnnotationKeys {
+	if c == nil {
+		return defaultSourceAnnotationKeys
+	}
+
+	prefix := strings.TrimSuffix(c.Prefix, "/")
+	if prefix == "" {
+		return defaultSourceAnnotationKeys
+	}
+
+	return sourceAnnotationKeys{
+		Type: prefix + sourceTypeKeySuffix,
+		Path: prefix + sourcePathKeySuffix,
+		File: prefix + sourceFileKeySuffix,
+	}
+}
+
+// extra returns the static annotations of the config, if any.
+func (c *SourceAnnotationConfig) extra() map[string]string {
+	if c == nil {
+		return nil
+	}
+
+	return c.Extra
+}
+
+// clone returns a deep copy of the config.
+func (c *SourceAnnotationConfig) clone() *SourceAnnotationConfig {
+	if c == nil {
+		return nil
+	}
+
+	return &SourceAnnotationConfig{
+		Prefix: c.Prefix,
+		Extra:  maps.Clone(c.Extra),
+	}
+}
+
+// sanitizeLabelValue turns v into a valid label value: characters outside [A-Za-z0-9-_.]
+// become '_' and leading or trailing non-alphanumerics are trimmed. Values longer than 63
+// characters are replaced by a hash of v followed by as much of their tail as fits, since
+// the end of a path is usually its most telling part.
+func sanitizeLabelValue(v string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if isAlphanumeric(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+
+		return '_'
+	}, v)
+
+	sanitized = strings.TrimFunc(sanitized, func(r rune) bool { return !isAlphanumeric(r) })
+	if len(sanitized) <= maxLabelValueLength && (sanitized != "" || v == "") {
+		return sanitized
+	}
+
+	sum := sha256.Sum256([]byte(v))
+	hash := hex.EncodeToString(sum[:])[:labelHashLength]
+
+	tail := sanitized[max(0, len(sanitized)-(maxLabelValueLength-labelHashLength-1)):]
+	tail = strings.TrimLeftFunc(tail, func(r rune) bool { return !isAlphanumeric(r) })
+
+	if tail == "" {
+		return hash
+	}
+
+	return hash + "-" + tail
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
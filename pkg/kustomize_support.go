@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/k8s-manifest-kit/pkg/util"
 	utilerrors "github.com/k8s-manifest-kit/pkg/util/errors"
 	goyaml "gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/konfig"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
@@ -23,12 +27,11 @@ func Values(values map[string]string) func(context.Context) (map[string]string,
 }
 
 var (
+	// kustomizationFiles are the kustomization file names kustomize itself recognizes, in the
+	// order it probes them.
+	//
 	//nolint:gochecknoglobals
-	kustomizationFiles = []string{
-		"kustomization.yaml",
-		"kustomization.yml",
-		"Kustomization",
-	}
+	kustomizationFiles = konfig.RecognizedKustomizationFileNames()
 
 	// ErrNoKustomizationFile is returned when no kustomization file is found in a directory.
 	ErrNoKustomizationFile = errors.New("no kustomization file found")
@@ -37,10 +40,18 @@ var (
 // sourceHolder wraps a Source with internal state for consistency with other renderers.
 type sourceHolder struct {
 	Source
+
+	// fsIndex identifies the filesystem of the source in cache keys: 0 for the renderer-wide
+	// filesystem, the 1-based position of Source.FileSystem in Renderer.fileSystems otherwise.
+	fsIndex int
 }
 
 // Validate checks if the Source configuration is valid.
 func (h *sourceHolder) Validate() error {
+	if h.Glob != "" {
+		return fmt.Errorf("%w %q: globs are only expanded by New", ErrInvalidGlob, h.Glob)
+	}
+
 	if len(strings.TrimSpace(h.Path)) == 0 {
 		return utilerrors.ErrPathEmpty
 	}
@@ -48,8 +59,40 @@ func (h *sourceHolder) Validate() error {
 	return nil
 }
 
-func computeValues(ctx context.Context, input Source, renderTimeValues map[string]any) (map[string]string, error) {
-	sourceValues := map[string]any{}
+// StructuredValues returns a StructuredValues function that always returns the provided static values.
+// This is a convenience helper for the common case of non-dynamic typed values.
+func StructuredValues(values map[string]any) func(context.Context) (map[string]any, error) {
+	return func(_ context.Context) (map[string]any, error) {
+		return values, nil
+	}
+}
+
+// computeValues merges the values of a render. From lowest to highest precedence: the
+// environment variables selected by envPrefix, the values layers in order, the source
+// StructuredValues and Values, and the render-time values.
+func computeValues(
+	ctx context.Context,
+	input Source,
+	envPrefix string,
+	layers []map[string]string,
+	renderTimeValues map[string]any,
+) (map[string]any, error) {
+	sourceValues := envValues(envPrefix)
+
+	for _, layer := range layers {
+		for k, v := range layer {
+			sourceValues[k] = v
+		}
+	}
+
+	if input.StructuredValues != nil {
+		v, err := input.StructuredValues(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get structured values for kustomize path %q: %w", input.Path, err)
+		}
+
+		sourceValues = util.DeepMerge(sourceValues, v)
+	}
 
 	if input.Values != nil {
 		v, err := input.Values(ctx)
@@ -63,56 +106,179 @@ func computeValues(ctx context.Context, input Source, renderTimeValues map[strin
 	}
 
 	// Deep merge with render-time values taking precedence
-	merged := util.DeepMerge(sourceValues, renderTimeValues)
+	return util.DeepMerge(sourceValues, renderTimeValues), nil
+}
 
-	// Convert back to map[string]string
-	result := make(map[string]string, len(merged))
-	for k, v := range merged {
-		result[k] = fmt.Sprintf("%v", v)
+// envValues returns the environment variables whose name starts with prefix, keyed by
+// their name without the prefix. An empty prefix selects nothing.
+func envValues(prefix string) map[string]any {
+	values := map[string]any{}
+	if prefix == "" {
+		return values
 	}
 
-	return result, nil
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if key, ok := strings.CutPrefix(name, prefix); ok && key != "" {
+			values[key] = value
+		}
+	}
+
+	return values
 }
 
 // createValuesConfigMapYAML creates the YAML content for a values ConfigMap.
 // Does NOT write to filesystem - returns bytes for in-memory override.
-func createValuesConfigMapYAML(values map[string]string) ([]byte, error) {
+func createValuesConfigMapYAML(name string, values map[string]any) ([]byte, error) {
+	data, err := encodeValues(values)
+	if err != nil {
+		return nil, err
+	}
+
 	configMap := map[string]any{
 		"apiVersion": "v1",
 		"kind":       "ConfigMap",
-		"metadata": map[string]string{
-			"name": "values",
-		},
-		"data": values,
+		"metadata":   valuesMetadata(name),
+		"data":       data,
 	}
 
-	data, err := goyaml.Marshal(configMap)
+	content, err := goyaml.Marshal(configMap)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal values ConfigMap: %w", err)
 	}
 
+	return content, nil
+}
+
+// createValuesSecretYAML creates the YAML content for a values Secret.
+// Entries are stored in the Secret's stringData field, which the API server encodes into data.
+// Does NOT write to filesystem - returns bytes for in-memory override.
+func createValuesSecretYAML(name string, values map[string]any) ([]byte, error) {
+	stringData, err := encodeValues(values)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "Opaque",
+		"metadata":   valuesMetadata(name),
+		"stringData": stringData,
+	}
+
+	content, err := goyaml.Marshal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal values Secret: %w", err)
+	}
+
+	return content, nil
+}
+
+// valuesMetadata returns the metadata of a values object, marked for takeValuesNames.
+func valuesMetadata(name string) map[string]any {
+	return map[string]any{
+		"name": name,
+		"annotations": map[string]string{
+			valuesNameAnnotation: name,
+		},
+	}
+}
+
+// encodeValues converts all values into the string form stored in the generated object.
+func encodeValues(values map[string]any) (map[string]string, error) {
+	data := make(map[string]string, len(values))
+	for k, v := range values {
+		encoded, err := encodeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value for key %q: %w", k, err)
+		}
+
+		data[k] = encoded
+	}
+
 	return data, nil
 }
 
-func readKustomization(fs filesys.FileSystem, path string) (*kustomizetypes.Kustomization, string, error) {
-	var kustName string
-	var kustFile string
+// encodeValue converts a single value into the string stored in the values ConfigMap.
+// Strings are kept verbatim (multi-line content is emitted as a YAML block by the
+// marshaller, not escaped twice), scalars use their canonical YAML representation and
+// maps/lists are serialized as YAML documents so their structure survives.
+func encodeValue(v any) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case []byte:
+		return string(val), nil
+	}
 
-	for _, filename := range kustomizationFiles {
-		candidate := filepath.Join(path, filename)
-		if fs.Exists(candidate) {
-			kustName = filename
-			kustFile = candidate
+	content, err := goyaml.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal value: %w", err)
+	}
 
-			break
+	switch kind := reflect.ValueOf(v).Kind(); {
+	case kind == reflect.Map, kind == reflect.Slice, kind == reflect.Array, kind == reflect.Struct, kind == reflect.Pointer:
+		return string(content), nil
+	default:
+		// Scalars marshal as a single line followed by a newline; keep them inline.
+		return strings.TrimSuffix(string(content), "\n"), nil
+	}
+}
+
+// findKustomizationFile returns the name of the kustomization file present in dir, if any.
+// The name is one of kustomizationFiles, exactly as kustomize loads it, so overrides written
+// under that name replace the file kustomize reads. Directories bearing one of the names are
+// skipped, as kustomize cannot load them either.
+func findKustomizationFile(fs filesys.FileSystem, dir string) (string, bool) {
+	for _, filename := range kustomizationFiles {
+		if path := filepath.Join(dir, filename); fs.Exists(path) && !fs.IsDir(path) {
+			return filename, true
 		}
 	}
 
-	if kustFile == "" {
+	return "", false
+}
+
+// kustomizationDir returns input with its Path set to the directory of the kustomization
+// file it points to, since kustomize only builds directories. Other sources are returned
+// as-is, so that paths to arbitrary files still fail.
+func kustomizationDir(fs filesys.FileSystem, input Source) Source {
+	if !slices.Contains(kustomizationFiles, filepath.Base(input.Path)) ||
+		!fs.Exists(input.Path) ||
+		fs.IsDir(input.Path) {
+		return input
+	}
+
+	input.Path = filepath.Dir(input.Path)
+
+	return input
+}
+
+// readKustomization reads and parses the kustomization file in path, returning it together
+// with its file name (kustomization.yaml, kustomization.yml or Kustomization). Parsing is
+// memoized by file path and content; the returned kustomization can be freely modified.
+func readKustomization(fs filesys.FileSystem, path string) (*kustomizetypes.Kustomization, string, error) {
+	kustName, found := findKustomizationFile(fs, path)
+	kustFile := filepath.Join(path, kustName)
+
+	if !found {
+		reason := ""
+
+		switch {
+		case !fs.Exists(path):
+			reason = ": directory does not exist"
+		case !fs.IsDir(path):
+			reason = ": not a directory"
+		}
+
 		return nil, "", fmt.Errorf(
-			"%w in %q (expected one of: %v)",
+			"%w in %q%s (expected one of: %v)",
 			ErrNoKustomizationFile,
 			path,
+			reason,
 			kustomizationFiles,
 		)
 	}
@@ -122,8 +288,8 @@ func readKustomization(fs filesys.FileSystem, path string) (*kustomizetypes.Kust
 		return nil, "", fmt.Errorf("failed to read kustomization from %s: %w", kustFile, err)
 	}
 
-	kust := &kustomizetypes.Kustomization{}
-	if err := kust.Unmarshal(content); err != nil {
+	kust, err := parsedKustomizations.parse(kustFile, content)
+	if err != nil {
 		return nil, "", fmt.Errorf(
 			"failed to parse kustomization from %s (check YAML syntax): %w",
 			kustFile,
@@ -1,10 +1,24 @@
 package kustomize_test
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/k8s-manifest-kit/engine/pkg/filter/meta/gvk"
 	"github.com/k8s-manifest-kit/engine/pkg/transformer/meta/labels"
@@ -12,11 +26,18 @@ import (
 	"github.com/k8s-manifest-kit/pkg/util/cache"
 	jqmatcher "github.com/lburgazzoli/gomega-matchers/pkg/matchers/jq"
 	"github.com/rs/xid"
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/api/krusty"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/union"
 
 	. "github.com/onsi/gomega"
 )
@@ -307,6 +328,23 @@ resources:
 - ../configmap.yaml
 `
 
+// Test constants for values ConfigMap tests.
+const valuesKustomization = `
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+
+resources:
+- values.yaml
+`
+
+const customValuesKustomization = `
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+
+resources:
+- generated/app-values.yaml
+`
+
 func TestRenderer(t *testing.T) {
 
 	t.Run("should render basic kustomization", func(t *testing.T) {
@@ -579,606 +617,3241 @@ func TestValuesConfigMap(t *testing.T) {
 		valuesPath := filepath.Join(dir, "values.yaml")
 		g.Expect(valuesPath).ToNot(BeAnExistingFile())
 	})
-}
-
-func TestCacheIntegration(t *testing.T) {
-
-	t.Run("should cache identical renders", func(t *testing.T) {
+	t.Run("should write structured values preserving types", func(t *testing.T) {
 		g := NewWithT(t)
-		dir := setupBasicKustomization(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
 
 		renderer, err := kustomize.New([]kustomize.Source{
 			{
 				Path: dir,
-				Values: kustomize.Values(map[string]string{
-					"key": "value",
+				StructuredValues: kustomize.StructuredValues(map[string]any{
+					"replicas": 3,
+					"enabled":  true,
+					"config": map[string]any{
+						"host":  "localhost",
+						"ports": []any{80, 443},
+					},
 				}),
 			},
-		},
-			kustomize.WithCache(),
-		)
-		g.Expect(err).ToNot(HaveOccurred())
-
-		// First render - cache miss
-		result1, err := renderer.Process(t.Context(), nil)
+		})
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result1).ToNot(BeEmpty())
 
-		// Second render - cache hit (should be identical)
-		result2, err := renderer.Process(t.Context(), nil)
+		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result2).To(HaveLen(len(result1)))
+		g.Expect(objects).To(HaveLen(1))
 
-		// Results should be equal
-		for i := range result1 {
-			g.Expect(result2[i]).To(Equal(result1[i]))
-		}
+		g.Expect(objects[0].Object).To(And(
+			jqmatcher.Match(`.kind == "ConfigMap"`),
+			jqmatcher.Match(`.data.replicas == "3"`),
+			jqmatcher.Match(`.data.enabled == "true"`),
+			jqmatcher.Match(`.data.config == "host: localhost\nports:\n    - 80\n    - 443\n"`),
+		))
 	})
 
-	t.Run("should miss cache on different values", func(t *testing.T) {
+	t.Run("should not double-escape multi-line values", func(t *testing.T) {
 		g := NewWithT(t)
-		dir := setupBasicKustomization(t)
-
-		callCount := 0
-		dynamicValues := func(_ context.Context) (map[string]string, error) {
-			callCount++
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
 
-			return map[string]string{
-				"key": xid.New().String(),
-			}, nil
-		}
+		block := "key: value\nlist:\n- a\n- \"b\"\n"
 
 		renderer, err := kustomize.New([]kustomize.Source{
 			{
-				Path:   dir,
-				Values: dynamicValues,
+				Path:             dir,
+				StructuredValues: kustomize.StructuredValues(map[string]any{"block": block}),
 			},
-		},
-			kustomize.WithCache(),
-		)
+		})
 		g.Expect(err).ToNot(HaveOccurred())
 
-		// First render
-		result1, err := renderer.Process(t.Context(), nil)
+		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result1).ToNot(BeEmpty())
+		g.Expect(objects).To(HaveLen(1))
 
-		// Second render with different values - cache miss
-		result2, err := renderer.Process(t.Context(), nil)
+		data, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(HaveKeyWithValue("block", block))
+	})
+
+	t.Run("should let flat values override structured values", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
+
+		renderer, err := kustomize.New([]kustomize.Source{
+			{
+				Path:             dir,
+				StructuredValues: kustomize.StructuredValues(map[string]any{"key": 1, "other": 2}),
+				Values:           kustomize.Values(map[string]string{"key": "flat"}),
+			},
+		})
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result2).ToNot(BeEmpty())
 
-		// Values function should be called twice (no cache hits)
-		g.Expect(callCount).To(Equal(2))
-	})
+		objects, err := renderer.Process(t.Context(), map[string]any{
+			"nested": map[string]any{"a": "b"},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
 
-	t.Run("should work with cache disabled", func(t *testing.T) {
+		g.Expect(objects[0].Object).To(And(
+			jqmatcher.Match(`.data.key == "flat"`),
+			jqmatcher.Match(`.data.other == "2"`),
+			jqmatcher.Match(`.data.nested == "a: b\n"`),
+		))
+	})
+	t.Run("should use custom values ConfigMap name and file", func(t *testing.T) {
 		g := NewWithT(t)
-		dir := setupBasicKustomization(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", customValuesKustomization)
 
 		renderer, err := kustomize.New(
 			[]kustomize.Source{
 				{
-					Path: dir,
-					Values: kustomize.Values(map[string]string{
-						"key": "value",
-					}),
+					Path:   dir,
+					Values: kustomize.Values(map[string]string{"key": "value"}),
 				},
 			},
+			kustomize.WithValuesConfigMap("app-values", "generated/app-values.yaml"),
 		)
 		g.Expect(err).ToNot(HaveOccurred())
 
-		// First render
-		result1, err := renderer.Process(t.Context(), nil)
+		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result1).ToNot(BeEmpty())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object).To(And(
+			jqmatcher.Match(`.metadata.name == "app-values"`),
+			jqmatcher.Match(`.data.key == "value"`),
+		))
 
-		// Second render - should work even without cache
-		result2, err := renderer.Process(t.Context(), nil)
-		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result2).To(HaveLen(len(result1)))
+		g.Expect(filepath.Join(dir, "generated", "app-values.yaml")).ToNot(BeAnExistingFile())
 	})
 
-	t.Run("should return clones from cache", func(t *testing.T) {
+	t.Run("should fail when values file already exists", func(t *testing.T) {
 		g := NewWithT(t)
-		dir := setupBasicKustomization(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
+		writeFile(t, dir, "values.yaml", basicConfigMap)
 
 		renderer, err := kustomize.New([]kustomize.Source{
 			{
-				Path: dir,
-				Values: kustomize.Values(map[string]string{
-					"key": "value",
-				}),
+				Path:   dir,
+				Values: kustomize.Values(map[string]string{"key": "value"}),
 			},
-		},
-			kustomize.WithCache(),
-		)
+		})
 		g.Expect(err).ToNot(HaveOccurred())
 
-		// First render
-		result1, err := renderer.Process(t.Context(), nil)
-		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result1).ToNot(BeEmpty())
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrValuesFileExists))
+		g.Expect(err.Error()).To(ContainSubstring("values.yaml"))
+	})
+	t.Run("should inject values as a Secret", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
 
-		// Modify first result
-		if len(result1) > 0 {
-			result1[0].SetName("modified-name")
-		}
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{
+					Path:   dir,
+					Values: kustomize.Values(map[string]string{"password": "s3cr3t"}),
+				},
+			},
+			kustomize.WithValuesAsSecret(true),
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
 
-		// Second render - should not be affected by modification
-		result2, err := renderer.Process(t.Context(), nil)
+		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result2).ToNot(BeEmpty())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object).To(And(
+			jqmatcher.Match(`.kind == "Secret"`),
+			jqmatcher.Match(`.type == "Opaque"`),
+			jqmatcher.Match(`.stringData.password == "s3cr3t"`),
+			jqmatcher.Match(`has("data") | not`),
+		))
 
-		if len(result2) > 0 {
-			g.Expect(result2[0].GetName()).ToNot(Equal("modified-name"))
-		}
+		annotations := objects[0].GetAnnotations()
+		g.Expect(annotations).To(HaveKeyWithValue(types.AnnotationSourcePath, dir))
+		g.Expect(annotations).ToNot(HaveKey(types.AnnotationSourceFile))
 	})
 }
 
-func BenchmarkKustomizeRenderWithoutCache(b *testing.B) {
-	dir := b.TempDir()
-
-	writeFileB(b, dir, "kustomization.yaml", basicKustomization)
-	writeFileB(b, dir, "configmap.yaml", basicConfigMap)
-	writeFileB(b, dir, "pod.yaml", basicPod)
-
-	renderer, err := kustomize.New([]kustomize.Source{
-		{
-			Path: dir,
-			Values: kustomize.Values(map[string]string{
-				"key1": "value1",
-				"key2": "value2",
-			}),
-		},
-	})
-	if err != nil {
-		b.Fatalf("failed to create renderer: %v", err)
-	}
+const checksumDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    metadata:
+      annotations:
+        existing: kept
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+`
 
-	b.ResetTimer()
-	b.ReportAllocs()
+func TestValuesChecksumAnnotation(t *testing.T) {
+	render := func(t *testing.T, dir string, values map[string]string) []unstructured.Unstructured {
+		t.Helper()
+		g := NewWithT(t)
 
-	for b.Loop() {
-		_, err := renderer.Process(b.Context(), nil)
-		if err != nil {
-			b.Fatalf("failed to render: %v", err)
+		source := kustomize.Source{Path: dir}
+		if values != nil {
+			source.Values = kustomize.Values(values)
 		}
-	}
-}
 
-func BenchmarkKustomizeRenderWithCache(b *testing.B) {
-	dir := b.TempDir()
+		renderer, err := kustomize.New(
+			[]kustomize.Source{source},
+			kustomize.WithValuesChecksumAnnotation("checksum/values"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
 
-	writeFileB(b, dir, "kustomization.yaml", basicKustomization)
-	writeFileB(b, dir, "configmap.yaml", basicConfigMap)
-	writeFileB(b, dir, "pod.yaml", basicPod)
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
 
-	renderer, err := kustomize.New(
-		[]kustomize.Source{
-			{
-				Path: dir,
-				Values: kustomize.Values(map[string]string{
-					"key1": "value1",
-					"key2": "value2",
-				}),
-			},
-		},
-		kustomize.WithCache(),
-	)
-	if err != nil {
-		b.Fatalf("failed to create renderer: %v", err)
+		return objects
 	}
 
-	b.ResetTimer()
-	b.ReportAllocs()
+	checksumOf := func(objects []unstructured.Unstructured) string {
+		for _, obj := range objects {
+			if obj.GetKind() == "Deployment" {
+				checksum, _, _ := unstructured.NestedString(obj.Object, "spec", "template", "metadata", "annotations", "checksum/values")
 
-	for b.Loop() {
-		_, err := renderer.Process(b.Context(), nil)
-		if err != nil {
-			b.Fatalf("failed to render: %v", err)
+				return checksum
+			}
 		}
-	}
-}
 
-func BenchmarkKustomizeRenderCacheMiss(b *testing.B) {
-	dir := b.TempDir()
+		return ""
+	}
 
-	writeFileB(b, dir, "kustomization.yaml", basicKustomization)
-	writeFileB(b, dir, "configmap.yaml", basicConfigMap)
-	writeFileB(b, dir, "pod.yaml", basicPod)
+	setup := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- deployment.yaml\n- configmap.yaml\n")
+		writeFile(t, dir, "deployment.yaml", checksumDeployment)
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
 
-	renderer, err := kustomize.New(
-		[]kustomize.Source{
-			{
-				Path: dir,
-				Values: func(_ context.Context) (map[string]string, error) {
-					return map[string]string{
-						"key": xid.New().String(),
-					}, nil
-				},
-			},
-		},
-		kustomize.WithCache(),
-	)
-	if err != nil {
-		b.Fatalf("failed to create renderer: %v", err)
+		return dir
 	}
 
-	b.ResetTimer()
-	b.ReportAllocs()
+	t.Run("should stamp the values checksum on pod templates", func(t *testing.T) {
+		g := NewWithT(t)
 
-	for b.Loop() {
-		_, err := renderer.Process(b.Context(), nil)
-		if err != nil {
-			b.Fatalf("failed to render: %v", err)
+		objects := render(t, setup(t), map[string]string{"replicas": "3"})
+		g.Expect(objects).To(HaveLen(2))
+
+		for _, obj := range objects {
+			switch obj.GetKind() {
+			case "Deployment":
+				g.Expect(obj.Object).To(And(
+					jqmatcher.Match(`.spec.template.metadata.annotations["checksum/values"] | length == 64`),
+					jqmatcher.Match(`.spec.template.metadata.annotations.existing == "kept"`),
+				))
+				g.Expect(obj.GetAnnotations()).ToNot(HaveKey("checksum/values"))
+			default:
+				g.Expect(obj.Object).ToNot(HaveKey("spec"))
+			}
 		}
-	}
-}
+	})
 
-// Helper for benchmarks.
-func writeFileB(b *testing.B, dir string, name string, content string) {
-	b.Helper()
-	path := filepath.Join(dir, name)
-	err := os.WriteFile(path, []byte(content), 0600)
-	if err != nil {
-		b.Fatal(err)
-	}
-}
+	t.Run("should change with the values only", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
 
-func TestSourceAnnotations(t *testing.T) {
+		first := checksumOf(render(t, dir, map[string]string{"a": "1", "b": "2"}))
+		same := checksumOf(render(t, dir, map[string]string{"b": "2", "a": "1"}))
+		changed := checksumOf(render(t, dir, map[string]string{"a": "1", "b": "3"}))
 
-	t.Run("should add source annotations when enabled", func(t *testing.T) {
+		g.Expect(first).ToNot(BeEmpty())
+		g.Expect(same).To(Equal(first))
+		g.Expect(changed).ToNot(Equal(first))
+	})
+
+	t.Run("should leave sources without values untouched", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(checksumOf(render(t, setup(t), nil))).To(BeEmpty())
+	})
+}
+
+func TestEnvValues(t *testing.T) {
+	t.Run("should inject prefixed environment variables without the prefix", func(t *testing.T) {
 		g := NewWithT(t)
 		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
 
-		writeFile(t, dir, "kustomization.yaml", basicKustomization)
-		writeFile(t, dir, "configmap.yaml", basicConfigMap)
-		writeFile(t, dir, "pod.yaml", basicPod)
+		t.Setenv("RENDER_TEST_REPLICAS", "3")
+		t.Setenv("RENDER_TEST_", "ignored")
+		t.Setenv("OTHER_REPLICAS", "5")
 
 		renderer, err := kustomize.New(
-			[]kustomize.Source{
-				{Path: dir},
-			},
-			kustomize.WithSourceAnnotations(true),
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithEnvValues("RENDER_TEST_"),
 		)
 		g.Expect(err).ToNot(HaveOccurred())
 
 		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(objects).ToNot(BeEmpty())
+		g.Expect(objects).To(HaveLen(1))
 
-		// Verify all objects have source annotations
-		for _, obj := range objects {
-			annotations := obj.GetAnnotations()
-			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
-			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, dir))
-			// Kustomize renderer should have file annotation with relative path
-			g.Expect(annotations).Should(HaveKey(types.AnnotationSourceFile))
-			g.Expect(annotations[types.AnnotationSourceFile]).ShouldNot(BeEmpty())
-			// File should be one of: configmap.yaml or pod.yaml
-			g.Expect(annotations[types.AnnotationSourceFile]).Should(
-				Or(
-					Equal("configmap.yaml"),
-					Equal("pod.yaml"),
-				),
-			)
-		}
+		data, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(Equal(map[string]string{"REPLICAS": "3"}))
 	})
 
-	t.Run("should not add source annotations when disabled", func(t *testing.T) {
+	t.Run("should let explicit values win over environment values", func(t *testing.T) {
 		g := NewWithT(t)
 		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
 
-		writeFile(t, dir, "kustomization.yaml", basicKustomization)
-		writeFile(t, dir, "configmap.yaml", basicConfigMap)
-		writeFile(t, dir, "pod.yaml", basicPod)
+		t.Setenv("RENDER_TEST_SOURCE", "env")
+		t.Setenv("RENDER_TEST_RENDER", "env")
+		t.Setenv("RENDER_TEST_ENV", "env")
 
-		renderer, err := kustomize.New([]kustomize.Source{
-			{Path: dir},
-		})
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir, Values: kustomize.Values(map[string]string{"SOURCE": "source"})}},
+			kustomize.WithEnvValues("RENDER_TEST_"),
+		)
 		g.Expect(err).ToNot(HaveOccurred())
 
-		objects, err := renderer.Process(t.Context(), nil)
+		objects, err := renderer.Process(t.Context(), map[string]any{"RENDER": "render"})
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(objects).ToNot(BeEmpty())
 
-		// Verify no source annotations are present
-		for _, obj := range objects {
-			annotations := obj.GetAnnotations()
-			g.Expect(annotations).ShouldNot(HaveKey(types.AnnotationSourceType))
-			g.Expect(annotations).ShouldNot(HaveKey(types.AnnotationSourcePath))
-			// g.Expect(annotations).ShouldNot(HaveKey(types.AnnotationSourceFile))
-		}
+		data, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(Equal(map[string]string{"SOURCE": "source", "RENDER": "render", "ENV": "env"}))
 	})
 
-	t.Run("should annotate nested resources with relative paths", func(t *testing.T) {
+	t.Run("should quote values with special YAML characters", func(t *testing.T) {
 		g := NewWithT(t)
 		dir := t.TempDir()
-
-		writeFile(t, dir, "kustomization.yaml", nestedResourcesKustomization)
-		writeFile(t, dir, "resources/configmap.yaml", nestedConfigMap)
-		writeFile(t, dir, "resources/configs/secret.yaml", nestedSecret)
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
+
+		special := map[string]string{
+			"COMMENT": "a # b",
+			"MAPPING": "key: value",
+			"QUOTES":  `it's "quoted"`,
+			"BOOL":    "true",
+			"NUMBER":  "0123",
+			"LINES":   "first\nsecond",
+			"ANCHOR":  "&anchor *alias",
+			"EMPTY":   "",
+		}
+		for key, value := range special {
+			t.Setenv("RENDER_TEST_"+key, value)
+		}
 
 		renderer, err := kustomize.New(
 			[]kustomize.Source{{Path: dir}},
-			kustomize.WithSourceAnnotations(true),
+			kustomize.WithEnvValues("RENDER_TEST_"),
 		)
 		g.Expect(err).ToNot(HaveOccurred())
 
 		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(objects).Should(HaveLen(2))
-
-		// Verify annotations include nested paths
-		foundConfigMap := false
-		foundSecret := false
-
-		for _, obj := range objects {
-			annotations := obj.GetAnnotations()
-			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
-			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, dir))
-			g.Expect(annotations).Should(HaveKey(types.AnnotationSourceFile))
-
-			sourceFile := annotations[types.AnnotationSourceFile]
-			switch sourceFile {
-			case "resources/configmap.yaml":
-				foundConfigMap = true
-				g.Expect(obj.GetKind()).Should(Equal("ConfigMap"))
-			case "resources/configs/secret.yaml":
-				foundSecret = true
-				g.Expect(obj.GetKind()).Should(Equal("Secret"))
-			default:
-				// Other source files are ignored in this test
-			}
-		}
 
-		g.Expect(foundConfigMap).Should(BeTrue(), "should find ConfigMap with nested path")
-		g.Expect(foundSecret).Should(BeTrue(), "should find Secret with deeply nested path")
+		data, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(Equal(special))
 	})
+}
 
-	t.Run("should annotate resources from base kustomization", func(t *testing.T) {
+func TestValuesLayers(t *testing.T) {
+	t.Run("should let later layers win", func(t *testing.T) {
 		g := NewWithT(t)
 		dir := t.TempDir()
-
-		writeFile(t, dir, "base/kustomization.yaml", annotationsBaseKustomization)
-		writeFile(t, dir, "base/configmap.yaml", annotationsBaseConfigMap)
-		writeFile(t, dir, "base/deployment.yaml", annotationsBaseDeployment)
-		writeFile(t, dir, "overlay/kustomization.yaml", annotationsOverlayKustomization)
-
-		overlayDir := filepath.Join(dir, "overlay")
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
 
 		renderer, err := kustomize.New(
-			[]kustomize.Source{{Path: overlayDir}},
-			kustomize.WithSourceAnnotations(true),
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithValuesLayer(map[string]string{"replicas": "1", "team": "none", "region": "eu"}),
+			kustomize.WithValuesLayer(map[string]string{"team": "platform"}),
+			kustomize.WithValuesLayer(map[string]string{"replicas": "3"}),
 		)
 		g.Expect(err).ToNot(HaveOccurred())
 
 		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(objects).Should(HaveLen(2))
-
-		// Verify all objects have source annotations pointing to overlay
-		for _, obj := range objects {
-			annotations := obj.GetAnnotations()
-			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
-			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, overlayDir))
-			g.Expect(annotations).Should(HaveKey(types.AnnotationSourceFile))
-
-			// Source files should reference the base directory
-			sourceFile := annotations[types.AnnotationSourceFile]
-			g.Expect(sourceFile).Should(
-				Or(
-					Equal("../base/configmap.yaml"),
-					Equal("../base/deployment.yaml"),
-				),
-				"source file should reference base directory",
-			)
+		g.Expect(objects).To(HaveLen(1))
 
-			// Verify overlay label was applied
-			g.Expect(obj.GetLabels()).Should(HaveKeyWithValue("overlay", "true"))
-		}
+		data, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(Equal(map[string]string{"replicas": "3", "team": "platform", "region": "eu"}))
 	})
 
-	t.Run("should annotate resources from multiple included kustomizations", func(t *testing.T) {
+	t.Run("should rank layers between environment and source values", func(t *testing.T) {
 		g := NewWithT(t)
 		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
 
-		writeFile(t, dir, "components/frontend/kustomization.yaml", componentsFrontendKustomization)
-		writeFile(t, dir, "components/frontend/deployment.yaml", componentsFrontendDeployment)
-		writeFile(t, dir, "components/backend/kustomization.yaml", componentsBackendKustomization)
-		writeFile(t, dir, "components/backend/deployment.yaml", componentsBackendDeployment)
-		writeFile(t, dir, "components/backend/service.yaml", componentsBackendService)
-		writeFile(t, dir, "kustomization.yaml", componentsMainKustomization)
+		t.Setenv("RENDER_TEST_ENV", "env")
+		t.Setenv("RENDER_TEST_LAYER", "env")
 
 		renderer, err := kustomize.New(
-			[]kustomize.Source{{Path: dir}},
-			kustomize.WithSourceAnnotations(true),
+			[]kustomize.Source{{Path: dir, Values: kustomize.Values(map[string]string{"SOURCE": "source"})}},
+			kustomize.WithEnvValues("RENDER_TEST_"),
+			kustomize.WithValuesLayer(map[string]string{"LAYER": "layer", "SOURCE": "layer", "RENDER": "layer"}),
 		)
 		g.Expect(err).ToNot(HaveOccurred())
 
-		objects, err := renderer.Process(t.Context(), nil)
+		objects, err := renderer.Process(t.Context(), map[string]any{"RENDER": "render"})
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(objects).Should(HaveLen(3))
-
-		// Map to track found resources
-		foundResources := make(map[string]string)
-
-		for _, obj := range objects {
-			annotations := obj.GetAnnotations()
-			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
-			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, dir))
-			g.Expect(annotations).Should(HaveKey(types.AnnotationSourceFile))
-
-			sourceFile := annotations[types.AnnotationSourceFile]
-			key := obj.GetKind() + "/" + obj.GetName()
-			foundResources[key] = sourceFile
-		}
 
-		// Verify we found all expected resources with correct source files
-		g.Expect(foundResources).Should(HaveKeyWithValue("Deployment/frontend", "components/frontend/deployment.yaml"))
-		g.Expect(foundResources).Should(HaveKeyWithValue("Deployment/backend", "components/backend/deployment.yaml"))
-		g.Expect(foundResources).Should(HaveKeyWithValue("Service/backend", "components/backend/service.yaml"))
+		data, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(Equal(map[string]string{
+			"ENV":    "env",
+			"LAYER":  "layer",
+			"SOURCE": "source",
+			"RENDER": "render",
+		}))
 	})
-}
-
-func TestLoadRestrictions(t *testing.T) {
 
-	t.Run("should use default LoadRestrictionsRootOnly", func(t *testing.T) {
+	t.Run("should copy the layer", func(t *testing.T) {
 		g := NewWithT(t)
-		parentDir := t.TempDir()
-		childDir := filepath.Join(parentDir, "child")
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
 
-		// Create configmap in parent dir
-		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+		layer := map[string]string{"key": "before"}
 
-		// Create kustomization in child that tries to reference parent
-		writeFile(t, childDir, "kustomization.yaml", kustomizationWithParent)
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithValuesLayer(layer),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
 
-		renderer, err := kustomize.New([]kustomize.Source{
-			{Path: childDir},
-		})
+		layer["key"] = "after"
+
+		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
 
-		// Should fail because default is LoadRestrictionsRootOnly
-		_, err = renderer.Process(t.Context(), nil)
-		g.Expect(err).Should(HaveOccurred())
-		g.Expect(err.Error()).Should(ContainSubstring("failed to run kustomize"))
+		data, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(Equal(map[string]string{"key": "before"}))
 	})
+}
 
-	t.Run("should allow LoadRestrictionsNone via renderer option", func(t *testing.T) {
-		g := NewWithT(t)
-		parentDir := t.TempDir()
-		childDir := filepath.Join(parentDir, "child")
-
-		// Create configmap in parent dir
-		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+func TestValuesPatch(t *testing.T) {
+	const valuesConfigMap = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: values
+data:
+  a: "1"
+  b: "2"
+`
 
-		// Create kustomization in child that references parent
-		writeFile(t, childDir, "kustomization.yaml", kustomizationWithParent)
+	t.Run("should patch the existing values ConfigMap of a base", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "base/kustomization.yaml", "namePrefix: base-\nresources:\n- values.yaml\n")
+		writeFile(t, root, "base/values.yaml", valuesConfigMap)
+		writeFile(t, root, "overlay/kustomization.yaml", "namePrefix: app-\nresources:\n- ../base\n")
 
 		renderer, err := kustomize.New(
-			[]kustomize.Source{
-				{Path: childDir},
-			},
-			kustomize.WithLoadRestrictions(kustomizetypes.LoadRestrictionsNone),
+			[]kustomize.Source{{Path: filepath.Join(root, "overlay")}},
+			kustomize.WithValuesPatch(map[string]string{"b": "3", "c": "4"}),
 		)
 		g.Expect(err).ToNot(HaveOccurred())
 
-		// Should succeed with LoadRestrictionsNone
 		objects, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(objects).Should(HaveLen(1))
-		g.Expect(objects[0].GetKind()).Should(Equal("ConfigMap"))
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("app-base-values"))
+		g.Expect(objects[0].Object).To(HaveKeyWithValue("data", map[string]any{"a": "1", "b": "3", "c": "4"}))
 	})
 
-	t.Run("should allow per-Source LoadRestrictions override", func(t *testing.T) {
+	t.Run("should update the hash of a generated ConfigMap and its references", func(t *testing.T) {
 		g := NewWithT(t)
-		parentDir := t.TempDir()
-		child1Dir := filepath.Join(parentDir, "child1")
-		child2Dir := filepath.Join(parentDir, "child2")
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", `
+resources:
+- pod.yaml
+configMapGenerator:
+- name: app-config
+  literals:
+  - LOG_LEVEL=info
+`)
+		writeFile(t, dir, "pod.yaml", `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod
+spec:
+  containers:
+  - name: app
+    image: app
+    envFrom:
+    - configMapRef:
+        name: app-config
+`)
+
+		render := func(opts ...kustomize.RendererOption) []unstructured.Unstructured {
+			renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, opts...)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			objects, err := renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(objects).To(HaveLen(2))
+
+			return objects
+		}
 
-		// Create configmap in parent dir
-		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+		configMapOf := func(objects []unstructured.Unstructured) unstructured.Unstructured {
+			for _, obj := range objects {
+				if obj.GetKind() == "ConfigMap" {
+					return obj
+				}
+			}
 
-		// Create kustomization in child1 that references parent (will use None)
-		writeFile(t, child1Dir, "kustomization.yaml", kustomizationWithParent)
+			t.Fatal("no ConfigMap rendered")
 
-		// Create basic kustomization in child2 (will use RootOnly)
-		writeFile(t, child2Dir, "kustomization.yaml", basicKustomization)
-		writeFile(t, child2Dir, "configmap.yaml", basicConfigMap)
-		writeFile(t, child2Dir, "pod.yaml", basicPod)
+			return unstructured.Unstructured{}
+		}
 
-		renderer, err := kustomize.New(
-			[]kustomize.Source{
-				{
-					Path:             child1Dir,
-					LoadRestrictions: kustomizetypes.LoadRestrictionsNone,
-				},
-				{
-					Path:             child2Dir,
-					LoadRestrictions: kustomizetypes.LoadRestrictionsRootOnly,
-				},
-			},
-			kustomize.WithLoadRestrictions(kustomizetypes.LoadRestrictionsRootOnly),
+		plain := configMapOf(render())
+		patched := render(
+			kustomize.WithValuesConfigMap("app-config", ""),
+			kustomize.WithValuesPatch(map[string]string{"LOG_LEVEL": "debug"}),
 		)
-		g.Expect(err).ToNot(HaveOccurred())
+		configMap := configMapOf(patched)
 
-		// Should succeed: child1 uses None, child2 uses RootOnly
-		objects, err := renderer.Process(t.Context(), nil)
-		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(objects).Should(HaveLen(3)) // 1 from child1, 2 from child2
+		g.Expect(configMap.GetName()).To(HavePrefix("app-config-"))
+		g.Expect(configMap.GetName()).ToNot(Equal(plain.GetName()))
+		g.Expect(configMap.GetAnnotations()).To(BeEmpty())
+		g.Expect(configMap.Object).To(jqmatcher.Match(`.data.LOG_LEVEL == "debug"`))
+
+		for _, obj := range patched {
+			if obj.GetKind() == "Pod" {
+				g.Expect(obj.Object).To(jqmatcher.Match(`.spec.containers[0].envFrom[0].configMapRef.name == "%s"`, configMap.GetName()))
+			}
+		}
 	})
 
-	t.Run("should respect Source override over renderer-wide default", func(t *testing.T) {
+	t.Run("should fail when the values ConfigMap is missing", func(t *testing.T) {
 		g := NewWithT(t)
-		parentDir := t.TempDir()
-		childDir := filepath.Join(parentDir, "child")
+		dir := setupBasicKustomization(t)
 
-		// Create configmap in parent dir
-		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithValuesPatch(map[string]string{"key": "value"}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
 
-		// Create kustomization in child that references parent
-		writeFile(t, childDir, "kustomization.yaml", kustomizationWithParent)
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrValuesPatchTarget))
+		g.Expect(err.Error()).To(ContainSubstring(`"values"`))
+	})
+}
+
+func TestCacheIntegration(t *testing.T) {
+
+	t.Run("should cache identical renders", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{
+			{
+				Path: dir,
+				Values: kustomize.Values(map[string]string{
+					"key": "value",
+				}),
+			},
+		},
+			kustomize.WithCache(),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// First render - cache miss
+		result1, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result1).ToNot(BeEmpty())
+
+		// Second render - cache hit (should be identical)
+		result2, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result2).To(HaveLen(len(result1)))
+
+		// Results should be equal
+		for i := range result1 {
+			g.Expect(result2[i]).To(Equal(result1[i]))
+		}
+	})
+
+	t.Run("should miss cache on different values", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		callCount := 0
+		dynamicValues := func(_ context.Context) (map[string]string, error) {
+			callCount++
+
+			return map[string]string{
+				"key": xid.New().String(),
+			}, nil
+		}
+
+		renderer, err := kustomize.New([]kustomize.Source{
+			{
+				Path:   dir,
+				Values: dynamicValues,
+			},
+		},
+			kustomize.WithCache(),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// First render
+		result1, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result1).ToNot(BeEmpty())
+
+		// Second render with different values - cache miss
+		result2, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result2).ToNot(BeEmpty())
+
+		// Values function should be called twice (no cache hits)
+		g.Expect(callCount).To(Equal(2))
+	})
+
+	t.Run("should work with cache disabled", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
 
 		renderer, err := kustomize.New(
 			[]kustomize.Source{
 				{
-					Path:             childDir,
-					LoadRestrictions: kustomizetypes.LoadRestrictionsRootOnly,
+					Path: dir,
+					Values: kustomize.Values(map[string]string{
+						"key": "value",
+					}),
 				},
 			},
-			kustomize.WithLoadRestrictions(kustomizetypes.LoadRestrictionsNone),
 		)
 		g.Expect(err).ToNot(HaveOccurred())
 
-		// Should fail because Source overrides to RootOnly
-		_, err = renderer.Process(t.Context(), nil)
-		g.Expect(err).Should(HaveOccurred())
-		g.Expect(err.Error()).Should(ContainSubstring("failed to run kustomize"))
+		// First render
+		result1, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result1).ToNot(BeEmpty())
+
+		// Second render - should work even without cache
+		result2, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result2).To(HaveLen(len(result1)))
 	})
 
-	t.Run("should use custom cache key function", func(t *testing.T) {
+	t.Run("should return clones from cache", func(t *testing.T) {
 		g := NewWithT(t)
 		dir := setupBasicKustomization(t)
 
-		// Use FastCacheKey which ignores values
 		renderer, err := kustomize.New([]kustomize.Source{
 			{
 				Path: dir,
 				Values: kustomize.Values(map[string]string{
-					"key1": "value1",
+					"key": "value",
 				}),
 			},
 		},
-			kustomize.WithCache(
-				cache.WithKeyFunc(func(key any) string {
-					if spec, ok := key.(kustomize.KustomizationSpec); ok {
-						return spec.Path
-					}
+			kustomize.WithCache(),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
 
-					return cache.DefaultKeyFunc(key)
-				}),
-			),
+		// First render
+		result1, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result1).ToNot(BeEmpty())
+
+		// Modify first result
+		if len(result1) > 0 {
+			result1[0].SetName("modified-name")
+		}
+
+		// Second render - should not be affected by modification
+		result2, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result2).ToNot(BeEmpty())
+
+		if len(result2) > 0 {
+			g.Expect(result2[0].GetName()).ToNot(Equal("modified-name"))
+		}
+	})
+
+	t.Run("should use configured cache key function", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		specs := make([]kustomize.KustomizationSpec, 0)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}},
+			kustomize.WithCache(),
+			kustomize.WithCacheKeyFunc(func(spec kustomize.KustomizationSpec) string {
+				specs = append(specs, spec)
+
+				return spec.Path
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result1, err := renderer.Process(t.Context(), map[string]any{"key": "a"})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// values are ignored by the key function, so this is a cache hit
+		result2, err := renderer.Process(t.Context(), map[string]any{"key": "b"})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result2).To(Equal(result1))
+
+		// one key computation per render
+		g.Expect(specs).To(HaveLen(2))
+		g.Expect(specs[0].Path).To(Equal(dir))
+		g.Expect(specs[0].FileSystem).ToNot(BeNil())
+	})
+
+	t.Run("should notify cache observer", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		events := make([]kustomize.CacheEvent, 0)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}},
+			kustomize.WithCache(),
+			kustomize.WithCacheObserver(func(e kustomize.CacheEvent) {
+				events = append(events, e)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(events).To(HaveLen(3))
+		g.Expect(events[0].Type).To(Equal(kustomize.CacheEventMiss))
+		g.Expect(events[1].Type).To(Equal(kustomize.CacheEventStore))
+		g.Expect(events[2].Type).To(Equal(kustomize.CacheEventHit))
+
+		for _, e := range events {
+			g.Expect(e.Path).To(Equal(dir))
+			g.Expect(e.Key).To(Equal(events[0].Key))
+			g.Expect(e.Key).To(HavePrefix(dir + "@"))
+		}
+	})
+
+	t.Run("should report failed renders to cache observer", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- missing.yaml\n")
+
+		events := make([]kustomize.CacheEventType, 0)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}},
+			kustomize.WithCache(),
+			kustomize.WithCacheObserver(func(e kustomize.CacheEvent) {
+				events = append(events, e.Type)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(events).To(Equal([]kustomize.CacheEventType{
+			kustomize.CacheEventMiss,
+			kustomize.CacheEventRenderFailed,
+		}))
+	})
+
+	t.Run("should honor per-source cache TTL", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		events := make(map[string][]kustomize.CacheEventType)
+		sources := []kustomize.Source{
+			{Path: dir, Values: kustomize.Values(map[string]string{"source": "global"})},
+			{Path: dir, Values: kustomize.Values(map[string]string{"source": "short"}), CacheTTL: 50 * time.Millisecond},
+			{Path: dir, Values: kustomize.Values(map[string]string{"source": "never"}), CacheTTL: -1},
+		}
+
+		renderer, err := kustomize.New(sources,
+			kustomize.WithCache(cache.WithTTL(time.Hour)),
+			kustomize.WithCacheKeyFunc(func(spec kustomize.KustomizationSpec) string {
+				return spec.Values["source"]
+			}),
+			kustomize.WithCacheObserver(func(e kustomize.CacheEvent) {
+				events[e.Key] = append(events[e.Key], e.Type)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		time.Sleep(100 * time.Millisecond)
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(events).To(HaveKeyWithValue("global", []kustomize.CacheEventType{
+			kustomize.CacheEventMiss, kustomize.CacheEventStore, kustomize.CacheEventHit,
+		}))
+		g.Expect(events).To(HaveKeyWithValue("short", []kustomize.CacheEventType{
+			kustomize.CacheEventMiss, kustomize.CacheEventStore, kustomize.CacheEventMiss, kustomize.CacheEventStore,
+		}))
+		g.Expect(events).ToNot(HaveKey("never"))
+	})
+
+	t.Run("should invalidate on source change with ContentCacheKey", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}},
+			kustomize.WithCache(),
+			kustomize.WithCacheKeyFunc(kustomize.ContentCacheKey()),
 		)
 		g.Expect(err).ToNot(HaveOccurred())
 
-		// First render - cache miss
 		result1, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(result1).To(HaveLen(2))
+		g.Expect(result1).ToNot(BeEmpty())
 
-		// Second render with DIFFERENT values but using FastCacheKey
-		// ignores values, so this should be a cache hit
-		result2, err := renderer.Process(t.Context(), map[string]any{
-			"key1": "different-value",
-		})
+		kustomization, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+		g.Expect(err).ToNot(HaveOccurred())
+		writeFile(t, dir, "kustomization.yaml", string(kustomization)+"namePrefix: edited-\n")
+
+		result2, err := renderer.Process(t.Context(), nil)
 		g.Expect(err).ToNot(HaveOccurred())
 		g.Expect(result2).To(HaveLen(len(result1)))
+		g.Expect(result2[0].GetName()).To(HavePrefix("edited-"))
+	})
 
-		// Results should be identical despite different values (cache hit)
-		for i := range result1 {
-			g.Expect(result2[i]).To(Equal(result1[i]))
-		}
+	t.Run("should hit the cache with a generic key function on a caching filesystem", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		var events []kustomize.CacheEventType
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(fs.NewCachingFs(filesys.MakeFsOnDisk())),
+			kustomize.WithCache(cache.WithKeyFunc(cache.DefaultKeyFunc)),
+			kustomize.WithCacheObserver(func(e kustomize.CacheEvent) {
+				g.Expect(e.Key).ToNot(ContainSubstring("ConfigMap"))
+
+				events = append(events, e.Type)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(events).To(Equal([]kustomize.CacheEventType{
+			kustomize.CacheEventMiss, kustomize.CacheEventStore, kustomize.CacheEventHit,
+		}))
+	})
+}
+
+func BenchmarkKustomizeRenderWithoutCache(b *testing.B) {
+	dir := b.TempDir()
+
+	writeFileB(b, dir, "kustomization.yaml", basicKustomization)
+	writeFileB(b, dir, "configmap.yaml", basicConfigMap)
+	writeFileB(b, dir, "pod.yaml", basicPod)
+
+	renderer, err := kustomize.New([]kustomize.Source{
+		{
+			Path: dir,
+			Values: kustomize.Values(map[string]string{
+				"key1": "value1",
+				"key2": "value2",
+			}),
+		},
+	})
+	if err != nil {
+		b.Fatalf("failed to create renderer: %v", err)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, err := renderer.Process(b.Context(), nil)
+		if err != nil {
+			b.Fatalf("failed to render: %v", err)
+		}
+	}
+}
+
+func BenchmarkKustomizeRenderWithCache(b *testing.B) {
+	dir := b.TempDir()
+
+	writeFileB(b, dir, "kustomization.yaml", basicKustomization)
+	writeFileB(b, dir, "configmap.yaml", basicConfigMap)
+	writeFileB(b, dir, "pod.yaml", basicPod)
+
+	renderer, err := kustomize.New(
+		[]kustomize.Source{
+			{
+				Path: dir,
+				Values: kustomize.Values(map[string]string{
+					"key1": "value1",
+					"key2": "value2",
+				}),
+			},
+		},
+		kustomize.WithCache(),
+	)
+	if err != nil {
+		b.Fatalf("failed to create renderer: %v", err)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, err := renderer.Process(b.Context(), nil)
+		if err != nil {
+			b.Fatalf("failed to render: %v", err)
+		}
+	}
+}
+
+func BenchmarkKustomizeRenderCacheMiss(b *testing.B) {
+	dir := b.TempDir()
+
+	writeFileB(b, dir, "kustomization.yaml", basicKustomization)
+	writeFileB(b, dir, "configmap.yaml", basicConfigMap)
+	writeFileB(b, dir, "pod.yaml", basicPod)
+
+	renderer, err := kustomize.New(
+		[]kustomize.Source{
+			{
+				Path: dir,
+				Values: func(_ context.Context) (map[string]string, error) {
+					return map[string]string{
+						"key": xid.New().String(),
+					}, nil
+				},
+			},
+		},
+		kustomize.WithCache(),
+	)
+	if err != nil {
+		b.Fatalf("failed to create renderer: %v", err)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, err := renderer.Process(b.Context(), nil)
+		if err != nil {
+			b.Fatalf("failed to render: %v", err)
+		}
+	}
+}
+
+// Helper for benchmarks.
+func writeFileB(b *testing.B, dir string, name string, content string) {
+	b.Helper()
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+func TestSourceAnnotations(t *testing.T) {
+
+	t.Run("should add source annotations when enabled", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		writeFile(t, dir, "kustomization.yaml", basicKustomization)
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+		writeFile(t, dir, "pod.yaml", basicPod)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{Path: dir},
+			},
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).ToNot(BeEmpty())
+
+		// Verify all objects have source annotations
+		for _, obj := range objects {
+			annotations := obj.GetAnnotations()
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, dir))
+			// Kustomize renderer should have file annotation with relative path
+			g.Expect(annotations).Should(HaveKey(types.AnnotationSourceFile))
+			g.Expect(annotations[types.AnnotationSourceFile]).ShouldNot(BeEmpty())
+			// File should be one of: configmap.yaml or pod.yaml
+			g.Expect(annotations[types.AnnotationSourceFile]).Should(
+				Or(
+					Equal("configmap.yaml"),
+					Equal("pod.yaml"),
+				),
+			)
+		}
+	})
+
+	t.Run("should not add source annotations when disabled", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		writeFile(t, dir, "kustomization.yaml", basicKustomization)
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+		writeFile(t, dir, "pod.yaml", basicPod)
+
+		renderer, err := kustomize.New([]kustomize.Source{
+			{Path: dir},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).ToNot(BeEmpty())
+
+		// Verify no source annotations are present
+		for _, obj := range objects {
+			annotations := obj.GetAnnotations()
+			g.Expect(annotations).ShouldNot(HaveKey(types.AnnotationSourceType))
+			g.Expect(annotations).ShouldNot(HaveKey(types.AnnotationSourcePath))
+			// g.Expect(annotations).ShouldNot(HaveKey(types.AnnotationSourceFile))
+		}
+	})
+
+	t.Run("should annotate nested resources with relative paths", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		writeFile(t, dir, "kustomization.yaml", nestedResourcesKustomization)
+		writeFile(t, dir, "resources/configmap.yaml", nestedConfigMap)
+		writeFile(t, dir, "resources/configs/secret.yaml", nestedSecret)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).Should(HaveLen(2))
+
+		// Verify annotations include nested paths
+		foundConfigMap := false
+		foundSecret := false
+
+		for _, obj := range objects {
+			annotations := obj.GetAnnotations()
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, dir))
+			g.Expect(annotations).Should(HaveKey(types.AnnotationSourceFile))
+
+			sourceFile := annotations[types.AnnotationSourceFile]
+			switch sourceFile {
+			case "resources/configmap.yaml":
+				foundConfigMap = true
+				g.Expect(obj.GetKind()).Should(Equal("ConfigMap"))
+			case "resources/configs/secret.yaml":
+				foundSecret = true
+				g.Expect(obj.GetKind()).Should(Equal("Secret"))
+			default:
+				// Other source files are ignored in this test
+			}
+		}
+
+		g.Expect(foundConfigMap).Should(BeTrue(), "should find ConfigMap with nested path")
+		g.Expect(foundSecret).Should(BeTrue(), "should find Secret with deeply nested path")
+	})
+
+	t.Run("should annotate resources from base kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		writeFile(t, dir, "base/kustomization.yaml", annotationsBaseKustomization)
+		writeFile(t, dir, "base/configmap.yaml", annotationsBaseConfigMap)
+		writeFile(t, dir, "base/deployment.yaml", annotationsBaseDeployment)
+		writeFile(t, dir, "overlay/kustomization.yaml", annotationsOverlayKustomization)
+
+		overlayDir := filepath.Join(dir, "overlay")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: overlayDir}},
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).Should(HaveLen(2))
+
+		// Verify all objects have source annotations pointing to overlay
+		for _, obj := range objects {
+			annotations := obj.GetAnnotations()
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, overlayDir))
+			g.Expect(annotations).Should(HaveKey(types.AnnotationSourceFile))
+
+			// Source files should reference the base directory
+			sourceFile := annotations[types.AnnotationSourceFile]
+			g.Expect(sourceFile).Should(
+				Or(
+					Equal("../base/configmap.yaml"),
+					Equal("../base/deployment.yaml"),
+				),
+				"source file should reference base directory",
+			)
+
+			// Verify overlay label was applied
+			g.Expect(obj.GetLabels()).Should(HaveKeyWithValue("overlay", "true"))
+		}
+	})
+
+	t.Run("should annotate resources from multiple included kustomizations", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		writeFile(t, dir, "components/frontend/kustomization.yaml", componentsFrontendKustomization)
+		writeFile(t, dir, "components/frontend/deployment.yaml", componentsFrontendDeployment)
+		writeFile(t, dir, "components/backend/kustomization.yaml", componentsBackendKustomization)
+		writeFile(t, dir, "components/backend/deployment.yaml", componentsBackendDeployment)
+		writeFile(t, dir, "components/backend/service.yaml", componentsBackendService)
+		writeFile(t, dir, "kustomization.yaml", componentsMainKustomization)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).Should(HaveLen(3))
+
+		// Map to track found resources
+		foundResources := make(map[string]string)
+
+		for _, obj := range objects {
+			annotations := obj.GetAnnotations()
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, dir))
+			g.Expect(annotations).Should(HaveKey(types.AnnotationSourceFile))
+
+			sourceFile := annotations[types.AnnotationSourceFile]
+			key := obj.GetKind() + "/" + obj.GetName()
+			foundResources[key] = sourceFile
+		}
+
+		// Verify we found all expected resources with correct source files
+		g.Expect(foundResources).Should(HaveKeyWithValue("Deployment/frontend", "components/frontend/deployment.yaml"))
+		g.Expect(foundResources).Should(HaveKeyWithValue("Deployment/backend", "components/backend/deployment.yaml"))
+		g.Expect(foundResources).Should(HaveKeyWithValue("Service/backend", "components/backend/service.yaml"))
+	})
+}
+
+func TestOnlyFromPath(t *testing.T) {
+	setup := func(t *testing.T) string {
+		t.Helper()
+
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- configmap.yaml\ncomponents:\n- components/extra\n")
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+		writeFile(t, dir, "components/extra/kustomization.yaml", `apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+resources:
+- secret.yaml
+configMapGenerator:
+- name: generated
+  literals:
+  - key=value
+`)
+		writeFile(t, dir, "components/extra/secret.yaml", "apiVersion: v1\nkind: Secret\nmetadata:\n  name: secret\n")
+
+		return dir
+	}
+
+	names := func(objects []unstructured.Unstructured) []string {
+		result := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			result = append(result, obj.GetName())
+		}
+
+		return result
+	}
+
+	t.Run("should keep only the objects originating from the path", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir, OnlyFromPath: "components/extra"}},
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)).To(ConsistOf("secret", HavePrefix("generated-")))
+	})
+
+	t.Run("should match a single file", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir, OnlyFromPath: "./configmap.yaml"}},
+			kustomize.WithSourceInfoAsLabels(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)).To(Equal([]string{"configmap"}))
+	})
+
+	t.Run("should not match sibling paths sharing a prefix", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir, OnlyFromPath: "components/ext"}},
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(BeEmpty())
+	})
+
+	t.Run("should fail without source tracking", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+
+		_, err := kustomize.New([]kustomize.Source{{Path: dir, OnlyFromPath: "components/extra"}})
+		g.Expect(err).To(MatchError(kustomize.ErrOriginTrackingRequired))
+	})
+
+	t.Run("should fail without recorded origins when build metadata is unmanaged", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir, OnlyFromPath: "components/extra"}},
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithManagedBuildMetadata(false),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrOriginTrackingRequired))
+	})
+}
+
+func TestLoadRestrictions(t *testing.T) {
+
+	t.Run("should use default LoadRestrictionsRootOnly", func(t *testing.T) {
+		g := NewWithT(t)
+		parentDir := t.TempDir()
+		childDir := filepath.Join(parentDir, "child")
+
+		// Create configmap in parent dir
+		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+
+		// Create kustomization in child that tries to reference parent
+		writeFile(t, childDir, "kustomization.yaml", kustomizationWithParent)
+
+		renderer, err := kustomize.New([]kustomize.Source{
+			{Path: childDir},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// Should fail because default is LoadRestrictionsRootOnly
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).Should(HaveOccurred())
+		g.Expect(err.Error()).Should(ContainSubstring("failed to run kustomize"))
+	})
+
+	t.Run("should allow LoadRestrictionsNone via renderer option", func(t *testing.T) {
+		g := NewWithT(t)
+		parentDir := t.TempDir()
+		childDir := filepath.Join(parentDir, "child")
+
+		// Create configmap in parent dir
+		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+
+		// Create kustomization in child that references parent
+		writeFile(t, childDir, "kustomization.yaml", kustomizationWithParent)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{Path: childDir},
+			},
+			kustomize.WithLoadRestrictions(kustomizetypes.LoadRestrictionsNone),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// Should succeed with LoadRestrictionsNone
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).Should(HaveLen(1))
+		g.Expect(objects[0].GetKind()).Should(Equal("ConfigMap"))
+	})
+
+	t.Run("should allow per-Source LoadRestrictions override", func(t *testing.T) {
+		g := NewWithT(t)
+		parentDir := t.TempDir()
+		child1Dir := filepath.Join(parentDir, "child1")
+		child2Dir := filepath.Join(parentDir, "child2")
+
+		// Create configmap in parent dir
+		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+
+		// Create kustomization in child1 that references parent (will use None)
+		writeFile(t, child1Dir, "kustomization.yaml", kustomizationWithParent)
+
+		// Create basic kustomization in child2 (will use RootOnly)
+		writeFile(t, child2Dir, "kustomization.yaml", basicKustomization)
+		writeFile(t, child2Dir, "configmap.yaml", basicConfigMap)
+		writeFile(t, child2Dir, "pod.yaml", basicPod)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{
+					Path:             child1Dir,
+					LoadRestrictions: kustomizetypes.LoadRestrictionsNone,
+				},
+				{
+					Path:             child2Dir,
+					LoadRestrictions: kustomizetypes.LoadRestrictionsRootOnly,
+				},
+			},
+			kustomize.WithLoadRestrictions(kustomizetypes.LoadRestrictionsRootOnly),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// Should succeed: child1 uses None, child2 uses RootOnly
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).Should(HaveLen(3)) // 1 from child1, 2 from child2
+	})
+
+	t.Run("should respect Source override over renderer-wide default", func(t *testing.T) {
+		g := NewWithT(t)
+		parentDir := t.TempDir()
+		childDir := filepath.Join(parentDir, "child")
+
+		// Create configmap in parent dir
+		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+
+		// Create kustomization in child that references parent
+		writeFile(t, childDir, "kustomization.yaml", kustomizationWithParent)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{
+					Path:             childDir,
+					LoadRestrictions: kustomizetypes.LoadRestrictionsRootOnly,
+				},
+			},
+			kustomize.WithLoadRestrictions(kustomizetypes.LoadRestrictionsNone),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// Should fail because Source overrides to RootOnly
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).Should(HaveOccurred())
+		g.Expect(err.Error()).Should(ContainSubstring("failed to run kustomize"))
+	})
+
+	t.Run("should use custom cache key function", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		// Use FastCacheKey which ignores values
+		renderer, err := kustomize.New([]kustomize.Source{
+			{
+				Path: dir,
+				Values: kustomize.Values(map[string]string{
+					"key1": "value1",
+				}),
+			},
+		},
+			kustomize.WithCache(
+				cache.WithKeyFunc(func(key any) string {
+					if spec, ok := key.(kustomize.KustomizationSpec); ok {
+						return spec.Path
+					}
+
+					return cache.DefaultKeyFunc(key)
+				}),
+			),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// First render - cache miss
+		result1, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result1).To(HaveLen(2))
+
+		// Second render with DIFFERENT values but using FastCacheKey
+		// ignores values, so this should be a cache hit
+		result2, err := renderer.Process(t.Context(), map[string]any{
+			"key1": "different-value",
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result2).To(HaveLen(len(result1)))
+
+		// Results should be identical despite different values (cache hit)
+		for i := range result1 {
+			g.Expect(result2[i]).To(Equal(result1[i]))
+		}
+	})
+}
+
+func TestConcurrency(t *testing.T) {
+
+	t.Run("should preserve source order when rendering in parallel", func(t *testing.T) {
+		g := NewWithT(t)
+
+		sources := make([]kustomize.Source, 0)
+		expected := make([]string, 0)
+		for i := range 8 {
+			dir := t.TempDir()
+			name := "config-" + strconv.Itoa(i)
+			writeFile(t, dir, "kustomization.yaml", "resources:\n- configmap.yaml\n")
+			writeFile(t, dir, "configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+name+"\n")
+
+			sources = append(sources, kustomize.Source{Path: dir})
+			expected = append(expected, name)
+		}
+
+		renderer, err := kustomize.New(sources, kustomize.WithConcurrency(3))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(len(expected)))
+
+		for i := range objects {
+			g.Expect(objects[i].GetName()).To(Equal(expected[i]))
+		}
+	})
+
+	t.Run("should aggregate errors from all failing sources", func(t *testing.T) {
+		g := NewWithT(t)
+		okDir := setupBasicKustomization(t)
+		missing1 := filepath.Join(t.TempDir(), "missing1")
+		missing2 := filepath.Join(t.TempDir(), "missing2")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{Path: missing1},
+				{Path: okDir},
+				{Path: missing2},
+			},
+			kustomize.WithConcurrency(2),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring(missing1))
+		g.Expect(err.Error()).To(ContainSubstring(missing2))
+		g.Expect(err.Error()).ToNot(ContainSubstring(okDir))
+	})
+
+	t.Run("should render independent requests through a shared renderer", func(t *testing.T) {
+		g := NewWithT(t)
+
+		sources := make([]kustomize.Source, 0)
+		for i := range 4 {
+			dir := t.TempDir()
+			writeFile(t, dir, "kustomization.yaml", "namePrefix: s"+strconv.Itoa(i)+"-\nresources:\n- values.yaml\n")
+			sources = append(sources, kustomize.Source{Path: dir})
+		}
+
+		renderer, err := kustomize.New(sources, kustomize.WithConcurrency(2))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		const requests = 16
+
+		results := make([][]unstructured.Unstructured, requests)
+		errs := make([]error, requests)
+
+		var wg sync.WaitGroup
+		for i := range requests {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				results[i], errs[i] = renderer.Process(t.Context(), map[string]any{"request": strconv.Itoa(i)})
+			}()
+		}
+
+		wg.Wait()
+
+		for i := range requests {
+			g.Expect(errs[i]).ToNot(HaveOccurred())
+			g.Expect(results[i]).To(HaveLen(len(sources)))
+
+			for j, obj := range results[i] {
+				g.Expect(obj.GetName()).To(Equal("s" + strconv.Itoa(j) + "-values"))
+				g.Expect(obj.Object).To(jqmatcher.Match(`.data.request == "%d"`, i))
+			}
+		}
+	})
+
+	t.Run("should only redirect stderr for builds printing warnings", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for kustomization, redirected := range map[string]bool{
+			"resources:\n- configmap.yaml\n": false,
+			deprecatedKustomization:          true,
+		} {
+			dir := t.TempDir()
+			writeFile(t, dir, "kustomization.yaml", kustomization)
+			writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+			probe := &stderrProbeFs{FileSystem: fs.NewFsOnDisk(), name: "configmap.yaml"}
+
+			renderer, err := kustomize.New(
+				[]kustomize.Source{{Path: dir}},
+				kustomize.WithFileSystem(probe),
+				kustomize.WithWarningHandler(kustomize.WarningIgnore()),
+			)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			stderr := os.Stderr
+
+			_, err = renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(probe.stderr).ToNot(BeNil())
+			g.Expect(probe.stderr != stderr).To(Equal(redirected))
+		}
+	})
+}
+
+// stderrProbeFs wraps a filesystem and records os.Stderr when a given file is read.
+type stderrProbeFs struct {
+	filesys.FileSystem
+
+	name   string
+	stderr *os.File
+}
+
+func (p *stderrProbeFs) ReadFile(path string) ([]byte, error) {
+	if filepath.Base(path) == p.name {
+		p.stderr = os.Stderr
+	}
+
+	return p.FileSystem.ReadFile(path)
+}
+
+// blockingFs wraps a filesystem and blocks reads of a given file until released.
+type blockingFs struct {
+	filesys.FileSystem
+
+	name    string
+	release chan struct{}
+}
+
+func (b *blockingFs) ReadFile(path string) ([]byte, error) {
+	if filepath.Base(path) == b.name {
+		<-b.release
+	}
+
+	return b.FileSystem.ReadFile(path)
+}
+
+func TestContinueOnError(t *testing.T) {
+	for name, concurrency := range map[string]int{"sequential": 1, "parallel": 4} {
+		t.Run(name, func(t *testing.T) {
+			t.Run("should return the objects of successful sources with all errors", func(t *testing.T) {
+				g := NewWithT(t)
+				goodDir := setupBasicKustomization(t)
+				firstBrokenDir := t.TempDir()
+				secondBrokenDir := t.TempDir()
+
+				renderer, err := kustomize.New(
+					[]kustomize.Source{{Path: firstBrokenDir}, {Path: goodDir}, {Path: secondBrokenDir}},
+					kustomize.WithConcurrency(concurrency),
+					kustomize.WithContinueOnError(true),
+				)
+				g.Expect(err).ToNot(HaveOccurred())
+
+				objects, err := renderer.Process(t.Context(), nil)
+				g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+				g.Expect(err.Error()).To(ContainSubstring(firstBrokenDir))
+				g.Expect(err.Error()).To(ContainSubstring(secondBrokenDir))
+				g.Expect(objects).To(HaveLen(2))
+
+				result, err := renderer.RenderDetailed(t.Context(), nil)
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(result.Sources).To(HaveLen(3))
+				g.Expect(result.Sources[0].Err).To(MatchError(kustomize.ErrNoKustomizationFile))
+				g.Expect(result.Sources[0].Path).To(Equal(firstBrokenDir))
+				g.Expect(result.Sources[1].Err).ToNot(HaveOccurred())
+				g.Expect(result.Sources[1].Objects).To(HaveLen(2))
+				g.Expect(result.Sources[2].Err).To(MatchError(kustomize.ErrNoKustomizationFile))
+			})
+
+			t.Run("should abort on the first error by default", func(t *testing.T) {
+				g := NewWithT(t)
+
+				renderer, err := kustomize.New(
+					[]kustomize.Source{{Path: t.TempDir()}, {Path: setupBasicKustomization(t)}},
+					kustomize.WithConcurrency(concurrency),
+				)
+				g.Expect(err).ToNot(HaveOccurred())
+
+				objects, err := renderer.Process(t.Context(), nil)
+				g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+				g.Expect(objects).To(BeNil())
+			})
+		})
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+
+	t.Run("should not render with an already cancelled context", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		_, err = renderer.Process(ctx, nil)
+		g.Expect(err).To(MatchError(context.Canceled))
+	})
+
+	t.Run("should unblock caller when context expires during build", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		blocking := &blockingFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "pod.yaml",
+			release:    make(chan struct{}),
+		}
+		t.Cleanup(func() { close(blocking.release) })
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(blocking),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err = renderer.Process(ctx, nil)
+		g.Expect(err).To(MatchError(context.DeadlineExceeded))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+}
+
+func TestTimeout(t *testing.T) {
+
+	t.Run("should fail with source path when render exceeds timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		blocking := &blockingFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "pod.yaml",
+			release:    make(chan struct{}),
+		}
+		t.Cleanup(func() { close(blocking.release) })
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(blocking),
+			kustomize.WithTimeout(50*time.Millisecond),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(context.Background(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrRenderTimeout))
+		g.Expect(err).To(MatchError(context.DeadlineExceeded))
+		g.Expect(err.Error()).To(ContainSubstring(dir))
+		g.Expect(err.Error()).To(ContainSubstring("50ms"))
+	})
+
+	t.Run("should render normally within timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithTimeout(time.Minute),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+}
+
+// flakyFs fails the first reads of the named file with err.
+type flakyFs struct {
+	filesys.FileSystem
+
+	name     string
+	err      error
+	failures int32
+	reads    atomic.Int32
+}
+
+func (f *flakyFs) ReadFile(path string) ([]byte, error) {
+	if filepath.Base(path) == f.name && f.reads.Add(1) <= f.failures {
+		return nil, f.err
+	}
+
+	return f.FileSystem.ReadFile(path)
+}
+
+func TestRetry(t *testing.T) {
+
+	t.Run("should retry transient errors until the render succeeds", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		flaky := &flakyFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "kustomization.yaml",
+			err:        syscall.ECONNRESET,
+			failures:   2,
+		}
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(flaky),
+			kustomize.WithRetry(3, time.Millisecond),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+		g.Expect(flaky.reads.Load()).To(BeNumerically(">=", 3))
+	})
+
+	t.Run("should return the last error once attempts are exhausted", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		flaky := &flakyFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "kustomization.yaml",
+			err:        syscall.ECONNRESET,
+			failures:   10,
+		}
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(flaky),
+			kustomize.WithRetry(3, time.Millisecond),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(syscall.ECONNRESET))
+		g.Expect(flaky.reads.Load()).To(BeEquivalentTo(3))
+	})
+
+	t.Run("should not retry permanent errors", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "resources: [\n")
+
+		flaky := &flakyFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "kustomization.yaml",
+		}
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(flaky),
+			kustomize.WithRetry(3, time.Millisecond),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(flaky.reads.Load()).To(BeEquivalentTo(1))
+	})
+
+	t.Run("should use the configured classifier", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+		errFlaky := errors.New("flaky")
+
+		flaky := &flakyFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "kustomization.yaml",
+			err:        errFlaky,
+			failures:   1,
+		}
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(flaky),
+			kustomize.WithRetry(2, 0),
+			kustomize.WithRetryClassifier(func(err error) bool {
+				return errors.Is(err, errFlaky)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+
+	t.Run("should stop waiting when the context is done", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		flaky := &flakyFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "kustomization.yaml",
+			err:        syscall.ECONNRESET,
+			failures:   10,
+		}
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(flaky),
+			kustomize.WithRetry(5, time.Minute),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err = renderer.Process(ctx, nil)
+		g.Expect(err).To(MatchError(context.DeadlineExceeded))
+		g.Expect(err).To(MatchError(syscall.ECONNRESET))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		g.Expect(flaky.reads.Load()).To(BeEquivalentTo(1))
+	})
+}
+
+// logRecords decodes the records written by a slog JSON handler.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+
+	for line := range strings.Lines(buf.String()) {
+		record := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("failed to decode log record %q: %v", line, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestLogger(t *testing.T) {
+
+	t.Run("should log warnings with their source path", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupDeprecatedKustomization(t)
+
+		buf := &bytes.Buffer{}
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithLogger(slog.New(slog.NewJSONHandler(buf, nil))),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		records := logRecords(t, buf)
+		g.Expect(records).To(HaveLen(1))
+		g.Expect(records[0]).To(HaveKeyWithValue("level", "WARN"))
+		g.Expect(records[0]).To(HaveKeyWithValue("source", dir))
+		g.Expect(records[0]).To(HaveKeyWithValue("field", "commonLabels"))
+		g.Expect(records[0]).To(HaveKeyWithValue("msg", ContainSubstring("commonLabels")))
+	})
+
+	t.Run("should prefer an explicit warning handler", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupDeprecatedKustomization(t)
+
+		buf := &bytes.Buffer{}
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithLogger(slog.New(slog.NewJSONHandler(buf, nil))),
+			kustomize.WithWarningHandler(kustomize.WarningIgnore()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(buf.String()).To(BeEmpty())
+	})
+
+	t.Run("should log retries and rendered sources", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		flaky := &flakyFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "kustomization.yaml",
+			err:        syscall.ECONNRESET,
+			failures:   1,
+		}
+
+		buf := &bytes.Buffer{}
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(flaky),
+			kustomize.WithRetry(2, time.Millisecond),
+			kustomize.WithLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		records := logRecords(t, buf)
+		g.Expect(records).To(HaveLen(2))
+		g.Expect(records[0]).To(HaveKeyWithValue("level", "INFO"))
+		g.Expect(records[0]).To(HaveKeyWithValue("msg", "retrying kustomize render"))
+		g.Expect(records[0]).To(HaveKeyWithValue("source", dir))
+		g.Expect(records[0]).To(HaveKeyWithValue("attempt", BeNumerically("==", 2)))
+		g.Expect(records[1]).To(HaveKeyWithValue("level", "DEBUG"))
+		g.Expect(records[1]).To(HaveKeyWithValue("msg", "rendered kustomize source"))
+		g.Expect(records[1]).To(HaveKeyWithValue("objects", BeNumerically("==", 2)))
+	})
+}
+
+func TestIsTransientError(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(kustomize.IsTransientError(fmt.Errorf("read: %w", syscall.ECONNRESET))).To(BeTrue())
+	g.Expect(kustomize.IsTransientError(errors.New("git clone: dial tcp: i/o timeout"))).To(BeTrue())
+	g.Expect(kustomize.IsTransientError(errors.New("invalid YAML"))).To(BeFalse())
+	g.Expect(kustomize.IsTransientError(context.Canceled)).To(BeFalse())
+	g.Expect(kustomize.IsTransientError(nil)).To(BeFalse())
+}
+
+func TestResourceLimits(t *testing.T) {
+	t.Run("should fail when a source renders too many resources", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithMaxResources(1))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrResourceLimitExceeded))
+		g.Expect(err.Error()).To(ContainSubstring(dir))
+		g.Expect(err.Error()).To(ContainSubstring("rendered 2 resources, exceeding the limit of 1"))
+	})
+
+	t.Run("should fail when a source renders too much output", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithMaxOutputBytes(64))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrResourceLimitExceeded))
+		g.Expect(err.Error()).To(ContainSubstring(dir))
+		g.Expect(err.Error()).To(ContainSubstring("more than 64 bytes"))
+	})
+
+	t.Run("should render normally within the limits", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithMaxResources(2),
+			kustomize.WithMaxOutputBytes(1<<20),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+}
+
+func TestOutputOrdering(t *testing.T) {
+	files := map[string][]byte{
+		"kustomization.yaml": []byte(`
+sortOptions:
+  order: fifo
+resources:
+- resources.yaml
+`),
+		"resources.yaml": []byte(`
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+  namespace: app
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: b-deployment
+  namespace: app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: app
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: a-deployment
+  namespace: app
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`),
+	}
+
+	names := func(objects []unstructured.Unstructured) []string {
+		result := make([]string, len(objects))
+		for i := range objects {
+			result[i] = objects[i].GetKind() + "/" + objects[i].GetName()
+		}
+
+		return result
+	}
+
+	t.Run("should keep kustomize order by default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)).To(Equal([]string{
+			"Widget/widget",
+			"Deployment/b-deployment",
+			"ConfigMap/config",
+			"Deployment/a-deployment",
+			"CustomResourceDefinition/widgets.example.com",
+			"Namespace/app",
+		}))
+	})
+
+	t.Run("should sort in apply order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithOutputOrdering(kustomize.OrderApply),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)).To(Equal([]string{
+			"Namespace/app",
+			"CustomResourceDefinition/widgets.example.com",
+			"ConfigMap/config",
+			// stable: equal priority keeps rendered order
+			"Deployment/b-deployment",
+			"Deployment/a-deployment",
+			"Widget/widget",
+		}))
+	})
+
+	t.Run("should sort alphabetically", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithOutputOrdering(kustomize.OrderAlphabetical),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)).To(Equal([]string{
+			"ConfigMap/config",
+			"CustomResourceDefinition/widgets.example.com",
+			"Deployment/a-deployment",
+			"Deployment/b-deployment",
+			"Namespace/app",
+			"Widget/widget",
+		}))
+	})
+
+	t.Run("should apply a custom sort function last", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithOutputOrdering(kustomize.OrderApply),
+			kustomize.WithSortFunc(func(a unstructured.Unstructured, b unstructured.Unstructured) int {
+				return cmp.Compare(a.GetNamespace(), b.GetNamespace())
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)).To(Equal([]string{
+			// cluster-scoped first, each group keeping the apply order
+			"Namespace/app",
+			"CustomResourceDefinition/widgets.example.com",
+			"ConfigMap/config",
+			"Deployment/b-deployment",
+			"Deployment/a-deployment",
+			"Widget/widget",
+		}))
+
+		objects, err = kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithSortFunc(func(a unstructured.Unstructured, b unstructured.Unstructured) int {
+				return cmp.Or(
+					cmp.Compare(a.GetNamespace(), b.GetNamespace()),
+					cmp.Compare(a.GetName(), b.GetName()),
+					cmp.Compare(a.GetKind(), b.GetKind()),
+				)
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)).To(Equal([]string{
+			"Namespace/app",
+			"CustomResourceDefinition/widgets.example.com",
+			"Deployment/a-deployment",
+			"Deployment/b-deployment",
+			"ConfigMap/config",
+			"Widget/widget",
+		}))
+	})
+
+	t.Run("should reject unknown order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.New(
+			[]kustomize.Source{{Path: "/app"}},
+			kustomize.WithOutputOrdering("random"),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidOutputOrder))
+	})
+
+	unsorted := maps.Clone(files)
+	unsorted["kustomization.yaml"] = []byte("resources:\n- resources.yaml\n")
+
+	t.Run("should keep input order in builds by default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), unsorted)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)[0]).To(Equal("Widget/widget"))
+	})
+
+	t.Run("should reorder builds the legacy way", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), unsorted,
+			kustomize.WithReorder(krusty.ReorderOptionLegacy),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)).To(Equal([]string{
+			"Namespace/app",
+			"CustomResourceDefinition/widgets.example.com",
+			"ConfigMap/config",
+			"Deployment/a-deployment",
+			"Deployment/b-deployment",
+			"Widget/widget",
+		}))
+	})
+
+	t.Run("should let kustomization sortOptions win over reorder", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithReorder(krusty.ReorderOptionLegacy),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)[0]).To(Equal("Widget/widget"))
+	})
+
+	t.Run("should reject unknown reorder option", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.New(
+			[]kustomize.Source{{Path: "/app"}},
+			kustomize.WithReorder("random"),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrInvalidReorder))
+	})
+
+	t.Run("should reorder builds like kustomize build in compatibility mode", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, version := range []string{"v3", "v4.5.7", "5.0"} {
+			objects, err := kustomize.RenderBytes(t.Context(), unsorted,
+				kustomize.WithCompatibilityMode(version),
+			)
+			g.Expect(err).ToNot(HaveOccurred(), version)
+			g.Expect(names(objects)[0]).To(Equal("Namespace/app"), version)
+		}
+	})
+
+	t.Run("should let a later reorder option win over compatibility mode", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), unsorted,
+			kustomize.WithCompatibilityMode("v4"),
+			kustomize.WithReorder(krusty.ReorderOptionNone),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(names(objects)[0]).To(Equal("Widget/widget"))
+	})
+
+	t.Run("should reject unsupported compatibility modes", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, version := range []string{"v2", "v6.0", "latest", "v4.x"} {
+			_, err := kustomize.New(
+				[]kustomize.Source{{Path: "/app"}},
+				kustomize.WithCompatibilityMode(version),
+			)
+			g.Expect(err).To(MatchError(kustomize.ErrInvalidCompatibilityMode), version)
+		}
+	})
+}
+
+const compatDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      topologySpreadConstraints:
+      - maxSkew: 1
+        topologyKey: zone
+        whenUnsatisfiable: DoNotSchedule
+        labelSelector:
+          matchLabels:
+            app: app
+      containers:
+      - name: app
+        image: app
+`
+
+const compatCronJob = `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: job
+spec:
+  schedule: "* * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: Never
+          containers:
+          - name: job
+            image: job
+`
+
+func TestCompatibilityMode(t *testing.T) {
+	spreadSelector := `.spec.template.spec.topologySpreadConstraints[0].labelSelector.matchLabels`
+
+	commonLabels := func(prefix string) map[string][]byte {
+		return map[string][]byte{
+			"kustomization.yaml":      []byte("resources:\n- base\n"),
+			"base/kustomization.yaml": []byte(prefix + "resources:\n- deployment.yaml\n"),
+			"base/deployment.yaml":    []byte(compatDeployment),
+		}
+	}
+
+	t.Run("should propagate commonLabels like releases before v3.9.1", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), commonLabels("commonLabels:\n  team: a\n"),
+			kustomize.WithCompatibilityMode("v3.9.0"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object).To(And(
+			jqmatcher.Match(`.metadata.labels.team == "a"`),
+			jqmatcher.Match(`.spec.selector.matchLabels.team == "a"`),
+			jqmatcher.Match(`.spec.template.metadata.labels.team == "a"`),
+			jqmatcher.Match(`%s | has("team") | not`, spreadSelector),
+		))
+	})
+
+	t.Run("should propagate commonLabels like the vendored kustomize since v3.9.1", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, version := range []string{"v3.9.1", "v4", "v5.4.3"} {
+			objects, err := kustomize.RenderBytes(t.Context(), commonLabels("commonLabels:\n  team: a\n"),
+				kustomize.WithCompatibilityMode(version),
+			)
+			g.Expect(err).ToNot(HaveOccurred(), version)
+			g.Expect(objects[0].Object).To(jqmatcher.Match(`%s.team == "a"`, spreadSelector), version)
+		}
+	})
+
+	t.Run("should label only existing pod templates with includeTemplates before v5", func(t *testing.T) {
+		g := NewWithT(t)
+
+		files := map[string][]byte{
+			"kustomization.yaml": []byte("resources:\n- cronjob.yaml\n" +
+				"labels:\n- pairs:\n    team: a\n  includeTemplates: true\n"),
+			"cronjob.yaml": []byte(compatCronJob),
+		}
+
+		objects, err := kustomize.RenderBytes(t.Context(), files, kustomize.WithCompatibilityMode("v4.5.7"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].Object).To(And(
+			jqmatcher.Match(`.metadata.labels.team == "a"`),
+			jqmatcher.Match(`.spec.jobTemplate.spec.template.metadata.labels == null`),
+		))
+
+		objects, err = kustomize.RenderBytes(t.Context(), files, kustomize.WithCompatibilityMode("v5.0.0"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].Object).To(jqmatcher.Match(`.spec.jobTemplate.spec.template.metadata.labels.team == "a"`))
+	})
+
+	t.Run("should reject label fields unknown to the release", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.RenderBytes(t.Context(), commonLabels("labels:\n- pairs:\n    team: a\n"),
+			kustomize.WithCompatibilityMode("v4.0.5"),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrIncompatibleKustomization))
+
+		_, err = kustomize.RenderBytes(t.Context(),
+			commonLabels("labels:\n- pairs:\n    team: a\n  includeTemplates: true\n"),
+			kustomize.WithCompatibilityMode("v4.5.4"),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrIncompatibleKustomization))
+	})
+}
+
+func TestConflictCheck(t *testing.T) {
+	newSource := func(t *testing.T, env string) kustomize.Source {
+		t.Helper()
+
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, dir, "configmap.yaml",
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: app\ndata:\n  env: "+env+"\n")
+
+		return kustomize.Source{Path: dir}
+	}
+
+	t.Run("should return conflicting copies by default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{newSource(t, "dev"), newSource(t, "prod")})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+
+	t.Run("should report conflicts with source paths", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dev := newSource(t, "dev")
+		prod := newSource(t, "prod")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{dev, prod},
+			kustomize.WithConflictCheck(true),
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrResourceConflict))
+
+		var conflictErr *kustomize.ConflictError
+		g.Expect(errors.As(err, &conflictErr)).To(BeTrue())
+		g.Expect(conflictErr.Conflicts).To(HaveLen(1))
+
+		conflict := conflictErr.Conflicts[0]
+		g.Expect(conflict.ID.String()).To(Equal("/v1, Kind=ConfigMap app/config"))
+		g.Expect(conflict.First.SourcePath).To(Equal(dev.Path))
+		g.Expect(conflict.First.SourceFile).To(Equal("configmap.yaml"))
+		g.Expect(conflict.Second.SourcePath).To(Equal(prod.Path))
+		g.Expect(err.Error()).To(ContainSubstring(dev.Path))
+		g.Expect(err.Error()).To(ContainSubstring(prod.Path))
+	})
+
+	t.Run("should enumerate every conflicting pair", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{newSource(t, "dev"), newSource(t, "staging"), newSource(t, "prod")},
+			kustomize.WithConflictCheck(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+
+		var conflictErr *kustomize.ConflictError
+		g.Expect(errors.As(err, &conflictErr)).To(BeTrue())
+		g.Expect(conflictErr.Conflicts).To(HaveLen(3))
+	})
+
+	t.Run("should accept identical copies", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{newSource(t, "dev"), newSource(t, "dev")},
+			kustomize.WithConflictCheck(true),
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+
+	t.Run("should accept identical copies with custom source annotation keys", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{newSource(t, "dev"), newSource(t, "dev")},
+			kustomize.WithConflictCheck(true),
+			kustomize.WithSourceAnnotationConfig(kustomize.SourceAnnotationConfig{Prefix: "example.com"}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+}
+
+func TestBuildMetadata(t *testing.T) {
+	files := map[string][]byte{
+		"kustomization.yaml": []byte("namePrefix: test-\nresources:\n- configmap.yaml\n"),
+		"configmap.yaml":     []byte(basicConfigMap),
+	}
+
+	const transformationsAnnotation = "alpha.config.kubernetes.io/transformations"
+	const managedByLabel = "app.kubernetes.io/managed-by"
+
+	t.Run("should not add build metadata by default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetAnnotations()).ToNot(HaveKey(transformationsAnnotation))
+		g.Expect(objects[0].GetLabels()).ToNot(HaveKey(managedByLabel))
+	})
+
+	t.Run("should add transformer annotations when enabled", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files, kustomize.WithTransformerAnnotations(true))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(transformationsAnnotation, ContainSubstring("PrefixTransformer")))
+	})
+
+	t.Run("should add managed-by label alongside source annotations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithManagedByLabel(true),
+			kustomize.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetLabels()).To(HaveKeyWithValue(managedByLabel, HavePrefix("kustomize-")))
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourceFile, "configmap.yaml"))
+		g.Expect(objects[0].GetAnnotations()).ToNot(HaveKey("config.kubernetes.io/origin"))
+	})
+
+	t.Run("should keep origin annotations declared by the kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), map[string][]byte{
+			"kustomization.yaml": []byte("buildMetadata:\n- originAnnotations\nresources:\n- configmap.yaml\n"),
+			"configmap.yaml":     []byte(basicConfigMap),
+		},
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithTransformerAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetAnnotations()).To(HaveKey("config.kubernetes.io/origin"))
+	})
+
+	t.Run("should keep origin annotations added for source tracking when requested", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithKeepOriginAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourceFile, "configmap.yaml"))
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(
+			"config.kubernetes.io/origin",
+			ContainSubstring("path: configmap.yaml"),
+		))
+	})
+
+	t.Run("should leave build metadata unmanaged when requested", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "buildMetadata:\n- managedByLabel\nresources:\n- configmap.yaml\n")
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithManagedBuildMetadata(false),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := renderer.DebugKustomization(kustomize.Source{Path: dir})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).ToNot(ContainSubstring("originAnnotations"))
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourcePath, dir))
+		g.Expect(objects[0].GetAnnotations()).ToNot(HaveKey(types.AnnotationSourceFile))
+		g.Expect(objects[0].GetLabels()).To(HaveKey(managedByLabel))
+	})
+
+	t.Run("should track source files declared by unmanaged build metadata", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), map[string][]byte{
+			"kustomization.yaml": []byte("buildMetadata:\n- originAnnotations\nresources:\n- configmap.yaml\n"),
+			"configmap.yaml":     []byte(basicConfigMap),
+		},
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithManagedBuildMetadata(false),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourceFile, "configmap.yaml"))
+		g.Expect(objects[0].GetAnnotations()).To(HaveKey("config.kubernetes.io/origin"))
+	})
+
+	t.Run("should disable the name suffix hash of generated objects", func(t *testing.T) {
+		g := NewWithT(t)
+
+		generator := map[string][]byte{
+			"kustomization.yaml": []byte("configMapGenerator:\n- name: generated\n  literals:\n  - key=value\n"),
+		}
+
+		objects, err := kustomize.RenderBytes(t.Context(), generator)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetName()).To(HavePrefix("generated-"))
+
+		objects, err = kustomize.RenderBytes(t.Context(), generator, kustomize.WithDisableNameSuffixHash(true))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("generated"))
+	})
+}
+
+func TestOverrides(t *testing.T) {
+	files := func(kustomization string) map[string][]byte {
+		return map[string][]byte{
+			"kustomization.yaml": []byte(kustomization + "resources:\n- configmap.yaml\n"),
+			"configmap.yaml":     []byte(basicConfigMap),
+		}
+	}
+
+	t.Run("should merge prefix and suffix with the kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files("namePrefix: app-\nnameSuffix: -v1\n"),
+			kustomize.WithNamePrefix("prod-"),
+			kustomize.WithNameSuffix("-eu"),
+			kustomize.WithNamespaceOverride("prod"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("prod-app-configmap-v1-eu"))
+		g.Expect(objects[0].GetNamespace()).To(Equal("prod"))
+	})
+
+	t.Run("should replace the kustomization fields when requested", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files("namespace: dev\nnamePrefix: app-\nnameSuffix: -v1\n"),
+			kustomize.WithNamePrefix("prod-"),
+			kustomize.WithNameSuffix("-eu"),
+			kustomize.WithNamespaceOverride("prod"),
+			kustomize.WithReplaceOverrides(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetName()).To(Equal("prod-configmap-eu"))
+		g.Expect(objects[0].GetNamespace()).To(Equal("prod"))
+	})
+
+	t.Run("should accept a namespace override matching the kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files("namespace: prod\n"),
+			kustomize.WithNamespaceOverride("prod"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetNamespace()).To(Equal("prod"))
+	})
+
+	t.Run("should reject a conflicting namespace override", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.RenderBytes(t.Context(), files("namespace: dev\n"),
+			kustomize.WithNamespaceOverride("prod"),
+		)
+		g.Expect(err).To(MatchError(kustomize.ErrOverrideConflict))
+	})
+}
+
+func TestSourceAnnotationConfig(t *testing.T) {
+	files := map[string][]byte{
+		"kustomization.yaml": []byte("resources:\n- configmap.yaml\n"),
+		"configmap.yaml":     []byte(basicConfigMap),
+	}
+
+	t.Run("should use custom annotation keys", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithSourceAnnotationConfig(kustomize.SourceAnnotationConfig{Prefix: "example.com/"}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+
+		annotations := objects[0].GetAnnotations()
+		g.Expect(annotations).To(HaveKeyWithValue("example.com/source.type", "kustomize"))
+		g.Expect(annotations).To(HaveKey("example.com/source.path"))
+		g.Expect(annotations).To(HaveKeyWithValue("example.com/source.file", "configmap.yaml"))
+		g.Expect(annotations).ToNot(HaveKey(types.AnnotationSourceType))
+		g.Expect(annotations).ToNot(HaveKey(types.AnnotationSourcePath))
+		g.Expect(annotations).ToNot(HaveKey(types.AnnotationSourceFile))
+		g.Expect(annotations).ToNot(HaveKey("config.kubernetes.io/origin"))
+	})
+
+	t.Run("should add extra annotations without overriding source annotations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithSourceAnnotationConfig(kustomize.SourceAnnotationConfig{
+				Extra: map[string]string{
+					"example.com/pipeline":     "release",
+					types.AnnotationSourceType: "other",
+				},
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		annotations := objects[0].GetAnnotations()
+		g.Expect(annotations).To(HaveKeyWithValue("example.com/pipeline", "release"))
+		g.Expect(annotations).To(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
+		g.Expect(annotations).To(HaveKeyWithValue(types.AnnotationSourceFile, "configmap.yaml"))
+		g.Expect(annotations).ToNot(HaveKey("config.kubernetes.io/origin"))
+	})
+
+	t.Run("should not annotate when source annotations are disabled afterwards", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithSourceAnnotationConfig(kustomize.SourceAnnotationConfig{Prefix: "example.com"}),
+			kustomize.WithSourceAnnotations(false),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetAnnotations()).ToNot(HaveKey("example.com/source.type"))
+	})
+
+	t.Run("should use the configured source type", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), files,
+			kustomize.WithSourceAnnotations(true),
+			kustomize.WithSourceInfoAsLabels(true),
+			kustomize.WithSourceType("acme-platform"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourceType, "acme-platform"))
+		g.Expect(objects[0].GetLabels()).To(HaveKeyWithValue(types.AnnotationSourceType, "acme-platform"))
+	})
+}
+
+func TestSourceInfoAsLabels(t *testing.T) {
+	newSource := func(t *testing.T, subdir string) kustomize.Source {
+		t.Helper()
+
+		dir := filepath.Join(t.TempDir(), subdir)
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+		return kustomize.Source{Path: dir}
+	}
+
+	render := func(t *testing.T, source kustomize.Source, opts ...kustomize.RendererOption) []unstructured.Unstructured {
+		t.Helper()
+
+		renderer, err := kustomize.New([]kustomize.Source{source}, opts...)
+		NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		NewWithT(t).Expect(err).ToNot(HaveOccurred())
+		NewWithT(t).Expect(objects).To(HaveLen(1))
+
+		return objects
+	}
+
+	t.Run("should mirror source info into sanitized labels", func(t *testing.T) {
+		g := NewWithT(t)
+
+		source := newSource(t, "overlays/prod")
+		objects := render(t, source,
+			kustomize.WithSourceInfoAsLabels(true),
+			kustomize.WithSourceAnnotations(true),
+		)
+
+		objLabels := objects[0].GetLabels()
+		g.Expect(objLabels).To(HaveKeyWithValue(types.AnnotationSourceType, "kustomize"))
+		g.Expect(objLabels).To(HaveKeyWithValue(types.AnnotationSourceFile, "configmap.yaml"))
+		g.Expect(objLabels).To(HaveKeyWithValue(types.AnnotationSourcePath, HaveSuffix("overlays_prod")))
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourcePath, source.Path))
+
+		for _, v := range objLabels {
+			g.Expect(validation.IsValidLabelValue(v)).To(BeEmpty())
+		}
+	})
+
+	t.Run("should hash long paths", func(t *testing.T) {
+		g := NewWithT(t)
+
+		source := newSource(t, strings.Repeat("nested-directory/", 6)+"prod")
+		objects := render(t, source, kustomize.WithSourceInfoAsLabels(true))
+
+		path := objects[0].GetLabels()[types.AnnotationSourcePath]
+		g.Expect(validation.IsValidLabelValue(path)).To(BeEmpty())
+		g.Expect(path).To(HaveLen(63))
+		g.Expect(path).To(HaveSuffix("nested-directory_prod"))
+
+		again := render(t, source, kustomize.WithSourceInfoAsLabels(true))
+		g.Expect(again[0].GetLabels()).To(HaveKeyWithValue(types.AnnotationSourcePath, path))
+	})
+
+	t.Run("should add labels without annotations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects := render(t, newSource(t, "base"), kustomize.WithSourceInfoAsLabels(true))
+
+		g.Expect(objects[0].GetLabels()).To(HaveKeyWithValue(types.AnnotationSourceFile, "configmap.yaml"))
+		g.Expect(objects[0].GetAnnotations()).ToNot(HaveKey(types.AnnotationSourceType))
+		g.Expect(objects[0].GetAnnotations()).ToNot(HaveKey("config.kubernetes.io/origin"))
+	})
+
+	t.Run("should use custom keys for labels", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects := render(t, newSource(t, "base"),
+			kustomize.WithSourceInfoAsLabels(true),
+			kustomize.WithSourceAnnotationConfig(kustomize.SourceAnnotationConfig{Prefix: "example.com"}),
+		)
+
+		g.Expect(objects[0].GetLabels()).To(HaveKeyWithValue("example.com/source.type", "kustomize"))
+	})
+}
+
+func TestKustomizationFileNames(t *testing.T) {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		t.Run("should override "+name+" in place", func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			writeFile(t, dir, name, "namePrefix: test-\nresources:\n- configmap.yaml\n- values.yaml\n")
+			writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+			renderer, err := kustomize.New(
+				[]kustomize.Source{{
+					Path:   dir,
+					Values: kustomize.Values(map[string]string{"key": "value"}),
+				}},
+				kustomize.WithSourceAnnotations(true),
+				kustomize.WithManagedByLabel(true),
+			)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			objects, err := renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(objects).To(HaveLen(2))
+
+			for _, obj := range objects {
+				g.Expect(obj.GetName()).To(HavePrefix("test-"))
+				g.Expect(obj.GetLabels()).To(HaveKey("app.kubernetes.io/managed-by"))
+				g.Expect(obj.GetAnnotations()).To(HaveKey(types.AnnotationSourceFile))
+				g.Expect(obj.GetAnnotations()).ToNot(HaveKey("config.kubernetes.io/origin"))
+			}
+
+			entries, err := os.ReadDir(dir)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(entries).To(HaveLen(2))
+		})
+	}
+
+	t.Run("should validate every file name form", func(t *testing.T) {
+		for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			writeFile(t, dir, name, "resources:\n- configmap.yaml\n")
+			writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+			renderer, err := kustomize.New([]kustomize.Source{{Path: dir}})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			issues, err := renderer.Validate(t.Context(), kustomize.Source{Path: dir})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(issues).To(BeEmpty(), name)
+		}
+	})
+
+	t.Run("should ignore directories named like kustomization files", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.Mkdir(filepath.Join(dir, "kustomization.yaml"), 0750)).To(Succeed())
+		writeFile(t, dir, "Kustomization", "resources:\n- configmap.yaml\n")
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithSourceAnnotations(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourceFile, "configmap.yaml"))
+	})
+
+	t.Run("should name the directory and the expected files when none is found", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+		g.Expect(err.Error()).To(ContainSubstring(dir))
+		g.Expect(err.Error()).To(ContainSubstring("kustomization.yaml kustomization.yml Kustomization"))
+	})
+
+	t.Run("should report a missing source directory", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := filepath.Join(t.TempDir(), "missing")
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+		g.Expect(err.Error()).To(ContainSubstring("directory does not exist"))
+	})
+
+	t.Run("should report a source path that is not a kustomization file", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: filepath.Join(dir, "configmap.yaml")}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+		g.Expect(err.Error()).To(ContainSubstring("not a directory"))
+	})
+
+	t.Run("should render a source path that is a kustomization file", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{
+			Path:   filepath.Join(dir, "kustomization.yaml"),
+			Values: kustomize.Values(map[string]string{"key": "value"}),
+		}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := renderer.RenderDetailed(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Sources).To(HaveLen(1))
+		g.Expect(result.Sources[0].Path).To(Equal(dir))
+		g.Expect(result.Sources[0].Objects).To(HaveLen(2))
+	})
+
+	t.Run("should accept a kustomization file in every source method", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+		source := kustomize.Source{Path: filepath.Join(dir, "kustomization.yaml")}
+
+		renderer, err := kustomize.New(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		issues, err := renderer.Validate(t.Context(), source)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(issues).To(BeEmpty())
+
+		inputs, err := renderer.ListInputs(source)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(inputs).To(ContainElement("kustomization.yaml"))
+
+		_, err = renderer.DebugKustomization(source)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		diff, err := renderer.Diff(t.Context(), source, kustomize.Source{Path: dir})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(diff.Empty()).To(BeTrue())
+	})
+}
+
+func TestFinalizer(t *testing.T) {
+	t.Run("should see the merged output of all sources once", func(t *testing.T) {
+		g := NewWithT(t)
+
+		calls := 0
+		var seen int
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}, {Path: setupBasicKustomization(t)}},
+			kustomize.WithConcurrency(2),
+			kustomize.WithFinalizer(func(_ context.Context, objects []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+				calls++
+				seen = len(objects)
+
+				return objects, nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(calls).To(Equal(1))
+		g.Expect(seen).To(Equal(4))
+		g.Expect(objects).To(HaveLen(4))
+	})
+
+	t.Run("should chain finalizers in order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithFinalizer(func(_ context.Context, objects []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+				return objects[:1], nil
+			}),
+			kustomize.WithFinalizer(func(_ context.Context, objects []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+				for i := range objects {
+					objects[i].SetLabels(map[string]string{"count": strconv.Itoa(len(objects))})
+				}
+
+				return objects, nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetLabels()).To(HaveKeyWithValue("count", "1"))
+	})
+
+	t.Run("should abort the render on error", func(t *testing.T) {
+		g := NewWithT(t)
+
+		errInvariant := errors.New("invariant violated")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupBasicKustomization(t)}},
+			kustomize.WithFinalizer(func(_ context.Context, _ []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+				return nil, errInvariant
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(errInvariant))
+		g.Expect(objects).To(BeNil())
+	})
+}
+
+func TestAferoFs(t *testing.T) {
+	t.Run("should render from an afero.Fs", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: "/"}},
+			kustomize.WithAferoFs(afero.NewReadOnlyFs(afero.NewBasePathFs(afero.NewOsFs(), dir))),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+		g.Expect([]string{objects[0].GetName(), objects[1].GetName()}).To(ConsistOf("test-configmap", "test-pod"))
+	})
+
+	t.Run("should inject values without writing to the afero.Fs", func(t *testing.T) {
+		g := NewWithT(t)
+
+		afs := afero.NewMemMapFs()
+		g.Expect(afero.WriteFile(afs, "/app/kustomization.yaml", []byte(basicKustomization), 0o644)).To(Succeed())
+		g.Expect(afero.WriteFile(afs, "/app/configmap.yaml", []byte(basicConfigMap), 0o644)).To(Succeed())
+		g.Expect(afero.WriteFile(afs, "/app/pod.yaml", []byte(basicPod), 0o644)).To(Succeed())
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{
+				Path: "/app",
+				Values: func(_ context.Context) (map[string]string, error) {
+					return map[string]string{"replicas": "3"}, nil
+				},
+			}},
+			kustomize.WithAferoFs(afs),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).ToNot(BeEmpty())
+
+		exists, err := afero.Exists(afs, "/app/values.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(exists).To(BeFalse())
+	})
+}
+
+func TestOverlayOverrides(t *testing.T) {
+	const patchKustomization = "resources:\n- configmap.yaml\npatchesStrategicMerge:\n- patch.yaml\n"
+	const patch = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: configmap\ndata:\n  patched: \"yes\"\n"
+
+	expectPatched := func(g *WithT, objects []unstructured.Unstructured) {
+		g.Expect(objects).To(HaveLen(1))
+
+		data, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(Equal(map[string]string{"key": "value", "patched": "yes"}))
+	}
+
+	t.Run("should resolve patches from overrides", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", patchKustomization)
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+		fsys, err := union.NewFs(fs.NewFsOnDisk(), union.WithOverride(filepath.Join(dir, "patch.yaml"), []byte(patch)))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// values make the renderer stack its own overrides on top of the caller's
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir, Values: kustomize.Values(map[string]string{"key": "value"})}},
+			kustomize.WithFileSystem(fsys),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		expectPatched(g, objects)
+	})
+
+	t.Run("should resolve overrides through symlinked source paths", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges on windows")
+		}
+
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", patchKustomization)
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+
+		link := filepath.Join(t.TempDir(), "link")
+		g.Expect(os.Symlink(dir, link)).To(Succeed())
+
+		// the override is placed through the symlink, the source path too
+		fsys, err := union.NewFs(fs.NewFsOnDisk(), union.WithOverride(filepath.Join(link, "patch.yaml"), []byte(patch)))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: link}},
+			kustomize.WithFileSystem(fsys),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		expectPatched(g, objects)
+	})
+
+	t.Run("should inject values into symlinked source paths", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges on windows")
+		}
+
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", valuesKustomization)
+
+		link := filepath.Join(t.TempDir(), "link")
+		g.Expect(os.Symlink(dir, link)).To(Succeed())
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: link, Values: kustomize.Values(map[string]string{"key": "value"})}},
+			kustomize.WithNamePrefix("app-"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("app-values"))
+	})
+}
+
+func TestDeterministic(t *testing.T) {
+	// epochFunction is an exec KRM function naming the "original" ConfigMap after
+	// $SOURCE_DATE_EPOCH.
+	const epochFunction = `#!/bin/sh
+sed "s/name: original/name: epoch-${SOURCE_DATE_EPOCH:-unset}/"
+`
+
+	setupEpochFunction := func(t *testing.T) string {
+		t.Helper()
+
+		dir := setupExecFunctionKustomization(t)
+		if err := os.WriteFile(filepath.Join(dir, "rename.sh"), []byte(epochFunction), 0o755); err != nil { //nolint:gosec
+			t.Fatal(err)
+		}
+
+		return dir
+	}
+
+	t.Run("should render byte-identical output across renderers", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- deployment.yaml\n- values.yaml\n")
+		writeFile(t, dir, "deployment.yaml", checksumDeployment)
+
+		render := func() []byte {
+			renderer, err := kustomize.New(
+				[]kustomize.Source{{
+					Path:   dir,
+					Values: kustomize.Values(map[string]string{"replicas": "3", "image": "app:1.0", "env": "prod"}),
+				}},
+				kustomize.WithDeterministic(true),
+				kustomize.WithSourceAnnotations(true),
+				kustomize.WithValuesChecksumAnnotation("checksum/values"),
+			)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			objects, err := renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(objects).To(HaveLen(2))
+
+			data, err := kustomize.ToYAML(objects)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			return data
+		}
+
+		first := render()
+
+		later := time.Now().Add(time.Hour)
+		g.Expect(os.Chtimes(filepath.Join(dir, "deployment.yaml"), later, later)).To(Succeed())
+
+		g.Expect(render()).To(Equal(first))
+	})
+
+	t.Run("should fix the clock of KRM functions", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupEpochFunction(t)}},
+			kustomize.WithExecPlugins(true),
+			kustomize.WithDeterministic(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("epoch-0"))
+	})
+
+	t.Run("should keep the clock set with WithFunctionEnv", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupEpochFunction(t)}},
+			kustomize.WithExecPlugins(true),
+			kustomize.WithKRMFunctions(kustomize.WithFunctionEnv("SOURCE_DATE_EPOCH=1700000000")),
+			kustomize.WithDeterministic(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].GetName()).To(Equal("epoch-1700000000"))
+	})
+
+	t.Run("should ignore modification times in content cache keys", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+		spec := kustomize.KustomizationSpec{Path: dir}
+
+		newRenderer := func(deterministic bool) *kustomize.Renderer {
+			renderer, err := kustomize.New(
+				[]kustomize.Source{{Path: dir}},
+				kustomize.WithCacheKeyFunc(kustomize.ContentCacheKey()),
+				kustomize.WithDeterministic(deterministic),
+			)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			return renderer
+		}
+
+		deterministic := newRenderer(true).ComputeCacheKey(spec)
+		plain := newRenderer(false).ComputeCacheKey(spec)
+
+		later := time.Now().Add(time.Hour)
+		g.Expect(os.Chtimes(filepath.Join(dir, "kustomization.yaml"), later, later)).To(Succeed())
+
+		g.Expect(newRenderer(true).ComputeCacheKey(spec)).To(Equal(deterministic))
+		g.Expect(newRenderer(false).ComputeCacheKey(spec)).ToNot(Equal(plain))
 	})
 }
@@ -0,0 +1,59 @@
+package kustomize
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Names of the spans created by the engine when a tracer is set with WithTracer. SpanRun
+// covers a whole render attempt of a source; the others are its children.
+const (
+	SpanRun               = "kustomize.Run"
+	SpanReadKustomization = "kustomize.ReadKustomization"
+	SpanPrepareFilesystem = "kustomize.PrepareFilesystem"
+	SpanBuild             = "kustomize.Build"
+	SpanPlugins           = "kustomize.Plugins"
+	SpanConvertResources  = "kustomize.ConvertResources"
+)
+
+// Attributes of the spans created by the engine.
+const (
+	// AttributeSourcePath is the path of the rendered source, set on every span.
+	AttributeSourcePath = "kustomize.source.path"
+
+	// AttributeObjectCount is the number of objects produced, set on SpanRun, SpanBuild,
+	// SpanPlugins and SpanConvertResources.
+	AttributeObjectCount = "kustomize.objects"
+
+	// AttributePluginCount is the number of plugin transformers applied, set on SpanPlugins.
+	AttributePluginCount = "kustomize.plugins"
+)
+
+// noopSpan is returned by startSpan when no tracer is set.
+//
+//nolint:gochecknoglobals
+var noopSpan trace.Span = noop.Span{}
+
+// startSpan starts a span tagged with the source path, child of the span of ctx. Without
+// tracer, it returns ctx unchanged and a span doing nothing.
+func (e *Engine) startSpan(ctx context.Context, name string, path string) (context.Context, trace.Span) {
+	if e.opts.Tracer == nil {
+		return ctx, noopSpan
+	}
+
+	return e.opts.Tracer.Start(ctx, name, trace.WithAttributes(attribute.String(AttributeSourcePath, path)))
+}
+
+// endSpan ends span, recording err if set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
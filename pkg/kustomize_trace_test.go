@@ -0,0 +1,130 @@
+package kustomize_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+// recordingTracer records the spans it starts, in start order.
+type recordingTracer struct {
+	noop.Tracer
+
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	noop.Span
+
+	name   string
+	parent *recordedSpan
+	attrs  map[attribute.Key]attribute.Value
+	err    error
+	ended  bool
+}
+
+func (t *recordingTracer) Start(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	span := &recordedSpan{name: name, attrs: make(map[attribute.Key]attribute.Value)}
+	cfg := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(cfg.Attributes()...)
+
+	if parent, ok := trace.SpanFromContext(ctx).(*recordedSpan); ok {
+		span.parent = parent
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = append(t.spans, span)
+
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.err = err
+}
+
+func (s *recordedSpan) End(_ ...trace.SpanEndOption) {
+	s.ended = true
+}
+
+func TestTracer(t *testing.T) {
+
+	t.Run("should create a span per render phase", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupBasicKustomization(t)
+		tracer := &recordingTracer{}
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithTracer(tracer))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		names := make([]string, 0, len(tracer.spans))
+		for _, span := range tracer.spans {
+			names = append(names, span.name)
+		}
+
+		g.Expect(names).To(Equal([]string{
+			kustomize.SpanRun,
+			kustomize.SpanReadKustomization,
+			kustomize.SpanPrepareFilesystem,
+			kustomize.SpanBuild,
+			kustomize.SpanPlugins,
+			kustomize.SpanConvertResources,
+		}))
+
+		run := tracer.spans[0]
+		g.Expect(run.parent).To(BeNil())
+		g.Expect(run.attrs[kustomize.AttributeObjectCount].AsInt64()).To(BeEquivalentTo(2))
+
+		for _, span := range tracer.spans {
+			g.Expect(span.ended).To(BeTrue(), span.name)
+			g.Expect(span.err).ToNot(HaveOccurred(), span.name)
+			g.Expect(span.attrs[kustomize.AttributeSourcePath].AsString()).To(Equal(dir), span.name)
+
+			if span != run {
+				g.Expect(span.parent).To(BeIdenticalTo(run), span.name)
+			}
+		}
+	})
+
+	t.Run("should record the error of the failing phase", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := filepath.Join(t.TempDir(), "missing")
+		tracer := &recordingTracer{}
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: dir}}, kustomize.WithTracer(tracer))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+
+		g.Expect(tracer.spans).To(HaveLen(2))
+		g.Expect(tracer.spans[0].name).To(Equal(kustomize.SpanRun))
+		g.Expect(tracer.spans[0].err).To(MatchError(kustomize.ErrNoKustomizationFile))
+		g.Expect(tracer.spans[1].name).To(Equal(kustomize.SpanReadKustomization))
+		g.Expect(tracer.spans[1].err).To(MatchError(kustomize.ErrNoKustomizationFile))
+	})
+}
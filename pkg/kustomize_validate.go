@@ -0,0 +1,170 @@
+package kustomize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+var (
+	// ErrMissingReference is reported when a kustomization references a path that does not exist.
+	ErrMissingReference = errors.New("referenced path does not exist")
+
+	// ErrLoadRestrictionViolation is reported when a kustomization references a file outside its
+	// root while LoadRestrictionsRootOnly is in effect.
+	ErrLoadRestrictionViolation = errors.New("reference violates load restrictions")
+
+	// ErrInvalidReference is reported when a reference resolves to the wrong type of entry,
+	// e.g. a directory where a file is expected.
+	ErrInvalidReference = errors.New("invalid reference")
+)
+
+// ValidationIssue describes a single problem found while validating a kustomization.
+type ValidationIssue struct {
+	// Kustomization is the directory of the kustomization declaring the reference.
+	Kustomization string
+
+	// Field is the kustomization field holding the reference (e.g. "resources").
+	// Empty when the issue concerns the kustomization itself.
+	Field string
+
+	// Reference is the referenced path as written in the kustomization.
+	Reference string
+
+	// Err describes the problem. It wraps one of ErrMissingReference,
+	// ErrLoadRestrictionViolation, ErrInvalidReference or ErrNoKustomizationFile,
+	// or a parse error.
+	Err error
+}
+
+// Error implements the error interface.
+func (i ValidationIssue) Error() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s: %v", i.Kustomization, i.Err)
+	}
+
+	return fmt.Sprintf("%s: %s %q: %v", i.Kustomization, i.Field, i.Reference, i.Err)
+}
+
+// Unwrap returns the underlying error.
+func (i ValidationIssue) Unwrap() error {
+	return i.Err
+}
+
+// Validate checks that a kustomization is well-formed without running a build: the
+// kustomization file parses, every local path it references (resources, components,
+// patches, generator sources, ...) exists, and no file reference escapes the root when
+// LoadRestrictionsRootOnly applies. Local bases are validated recursively; remote bases
+// are not fetched.
+//
+// All problems are reported in a single pass as a list of issues, in discovery order.
+// The returned error is non-nil only if validation could not be performed at all,
+// e.g. when the source itself has no readable kustomization.
+func (r *Renderer) Validate(ctx context.Context, source Source) ([]ValidationIssue, error) {
+	source = kustomizationDir(r.engine.fileSystem(source), source)
+
+	holder := &sourceHolder{Source: source}
+	if err := holder.Validate(); err != nil {
+		return nil, err
+	}
+
+	restrictions := r.engine.loadRestrictions(source)
+	sourceFs := r.engine.fileSystem(source)
+
+	kust, _, err := readKustomization(sourceFs, source.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read kustomization from path %q: %w", source.Path, err)
+	}
+
+	v := &validator{
+		fs:           sourceFs,
+		restrictions: restrictions,
+		visited:      make(map[string]bool),
+		issues:       make([]ValidationIssue, 0),
+	}
+
+	if err := v.validate(ctx, source.Path, kust); err != nil {
+		return nil, err
+	}
+
+	return v.issues, nil
+}
+
+// validator accumulates issues while walking a kustomization tree.
+type validator struct {
+	fs           filesys.FileSystem
+	restrictions kustomizetypes.LoadRestrictions
+	visited      map[string]bool
+	issues       []ValidationIssue
+}
+
+func (v *validator) report(dir string, ref reference, err error) {
+	v.issues = append(v.issues, ValidationIssue{
+		Kustomization: dir,
+		Field:         ref.Field,
+		Reference:     ref.Value,
+		Err:           err,
+	})
+}
+
+func (v *validator) validate(ctx context.Context, dir string, kust *kustomizetypes.Kustomization) error {
+	v.visited[dir] = true
+
+	for _, ref := range collectReferences(kust) {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("validation of %q aborted: %w", dir, err)
+		}
+
+		if isRemoteReference(ref.Value) {
+			continue
+		}
+
+		target := resolveReference(dir, ref.Value)
+
+		if !v.fs.Exists(target) {
+			v.report(dir, ref, ErrMissingReference)
+
+			continue
+		}
+
+		if !v.fs.IsDir(target) {
+			if v.restrictions == kustomizetypes.LoadRestrictionsRootOnly && !isWithinDir(dir, target) {
+				v.report(dir, ref, fmt.Errorf("%w: file is outside of kustomization root", ErrLoadRestrictionViolation))
+			}
+
+			continue
+		}
+
+		switch ref.Kind {
+		case referenceFile:
+			v.report(dir, ref, fmt.Errorf("%w: expected a file, got a directory", ErrInvalidReference))
+		case referenceFileOrAnyDir:
+			// any directory is acceptable (e.g. generator file sources)
+		case referenceFileOrDir:
+			if err := v.validateNested(ctx, dir, ref, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateNested validates a directory reference that must hold a kustomization.
+func (v *validator) validateNested(ctx context.Context, dir string, ref reference, target string) error {
+	if v.visited[target] {
+		return nil
+	}
+
+	nested, _, err := readKustomization(v.fs, target)
+	if err != nil {
+		v.report(dir, ref, err)
+
+		return nil
+	}
+
+	return v.validate(ctx, target, nested)
+}
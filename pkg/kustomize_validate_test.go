@@ -0,0 +1,268 @@
+package kustomize_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+const brokenKustomization = `
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+
+resources:
+- configmap.yaml
+- missing.yaml
+- ../outside.yaml
+- ../base
+- https://github.com/example/repo//base?ref=v1
+
+patches:
+- path: missing-patch.yaml
+- patch: |-
+    - op: replace
+      path: /data/key
+      value: inline
+
+configMapGenerator:
+- name: generated
+  files:
+  - config=missing.properties
+`
+
+const brokenBaseKustomization = `
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+
+resources:
+- missing-base-file.yaml
+`
+
+func TestValidate(t *testing.T) {
+
+	t.Run("should report no issues for a valid kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupOverlayKustomization(t)
+
+		renderer, err := kustomize.New(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		issues, err := renderer.Validate(t.Context(), kustomize.Source{Path: filepath.Join(dir, "overlay")})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(issues).To(BeEmpty())
+	})
+
+	t.Run("should report all problems in one pass", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+		dir := filepath.Join(root, "app")
+		baseDir := filepath.Join(root, "base")
+
+		writeFile(t, dir, "kustomization.yaml", brokenKustomization)
+		writeFile(t, dir, "configmap.yaml", basicConfigMap)
+		writeFile(t, root, "outside.yaml", basicConfigMap)
+		writeFile(t, baseDir, "kustomization.yaml", brokenBaseKustomization)
+
+		renderer, err := kustomize.New(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		issues, err := renderer.Validate(t.Context(), kustomize.Source{Path: dir})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(issues).To(HaveLen(5))
+
+		g.Expect(issues[0].Reference).To(Equal("missing.yaml"))
+		g.Expect(issues[0].Err).To(MatchError(kustomize.ErrMissingReference))
+
+		g.Expect(issues[1].Reference).To(Equal("../outside.yaml"))
+		g.Expect(issues[1].Err).To(MatchError(kustomize.ErrLoadRestrictionViolation))
+
+		g.Expect(issues[2].Kustomization).To(Equal(baseDir))
+		g.Expect(issues[2].Reference).To(Equal("missing-base-file.yaml"))
+		g.Expect(issues[2].Err).To(MatchError(kustomize.ErrMissingReference))
+
+		g.Expect(issues[3].Field).To(Equal("patches"))
+		g.Expect(issues[3].Reference).To(Equal("missing-patch.yaml"))
+
+		g.Expect(issues[4].Field).To(Equal("configMapGenerator"))
+		g.Expect(issues[4].Reference).To(Equal("missing.properties"))
+		g.Expect(issues[4].Error()).To(ContainSubstring("missing.properties"))
+	})
+
+	t.Run("should allow files outside root with LoadRestrictionsNone", func(t *testing.T) {
+		g := NewWithT(t)
+		parentDir := t.TempDir()
+		childDir := filepath.Join(parentDir, "child")
+
+		writeFile(t, parentDir, "configmap.yaml", basicConfigMap)
+		writeFile(t, childDir, "kustomization.yaml", kustomizationWithParent)
+
+		renderer, err := kustomize.New(nil, kustomize.WithLoadRestrictions(kustomizetypes.LoadRestrictionsNone))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		issues, err := renderer.Validate(t.Context(), kustomize.Source{Path: childDir})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(issues).To(BeEmpty())
+	})
+
+	t.Run("should report nested directory without kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		writeFile(t, dir, "kustomization.yaml", "resources:\n- sub\n")
+		writeFile(t, dir, "sub/configmap.yaml", basicConfigMap)
+
+		renderer, err := kustomize.New(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		issues, err := renderer.Validate(t.Context(), kustomize.Source{Path: dir})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(issues).To(HaveLen(1))
+		g.Expect(issues[0].Err).To(MatchError(kustomize.ErrNoKustomizationFile))
+	})
+
+	t.Run("should fail when source has no kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Validate(t.Context(), kustomize.Source{Path: t.TempDir()})
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+	})
+}
+
+func TestCyclicReferences(t *testing.T) {
+	setup := func(t *testing.T) string {
+		t.Helper()
+
+		root := t.TempDir()
+		writeFile(t, root, "a/kustomization.yaml", "resources:\n- ../b\n")
+		writeFile(t, root, "b/kustomization.yaml", "resources:\n- configmap.yaml\ncomponents:\n- ../c\n")
+		writeFile(t, root, "b/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "c/kustomization.yaml",
+			"apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nresources:\n- ../a\n")
+
+		return root
+	}
+
+	t.Run("should reject cycles before building", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setup(t)
+		a := filepath.Join(root, "a")
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: a}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrCyclicReference))
+		g.Expect(err.Error()).To(ContainSubstring(
+			a + " -> " + filepath.Join(root, "b") + " -> " + filepath.Join(root, "c") + " -> " + a,
+		))
+
+		_, err = renderer.ListInputs(kustomize.Source{Path: a})
+		g.Expect(err).To(MatchError(kustomize.ErrCyclicReference))
+	})
+
+	t.Run("should accept shared bases", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "base/kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, root, "base/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "x/kustomization.yaml", "resources:\n- ../base\nnamePrefix: x-\n")
+		writeFile(t, root, "y/kustomization.yaml", "resources:\n- ../base\nnamePrefix: y-\n")
+		writeFile(t, root, "app/kustomization.yaml", "resources:\n- ../x\n- ../y\n")
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: filepath.Join(root, "app")}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+	})
+}
+
+func TestMaxDepth(t *testing.T) {
+	// app -> l1 -> l2 -> base, three levels of references
+	setup := func(t *testing.T) string {
+		t.Helper()
+
+		root := t.TempDir()
+		writeFile(t, root, "app/kustomization.yaml", "resources:\n- ../l1\n")
+		writeFile(t, root, "l1/kustomization.yaml", "resources:\n- ../l2\n")
+		writeFile(t, root, "l2/kustomization.yaml", "components:\n- ../base\n")
+		writeFile(t, root, "base/kustomization.yaml",
+			"apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nresources:\n- configmap.yaml\n")
+		writeFile(t, root, "base/configmap.yaml", basicConfigMap)
+
+		return root
+	}
+
+	t.Run("should render chains within the limit", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setup(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: filepath.Join(root, "app")}},
+			kustomize.WithMaxDepth(3),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+	})
+
+	t.Run("should reject deeper chains before building", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setup(t)
+		app := filepath.Join(root, "app")
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: app}}, kustomize.WithMaxDepth(2))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrMaxDepthExceeded))
+		g.Expect(err.Error()).To(ContainSubstring(
+			app + " -> " + filepath.Join(root, "l1") + " -> " + filepath.Join(root, "l2") + " -> " +
+				filepath.Join(root, "base"),
+		))
+
+		_, err = renderer.ListInputs(kustomize.Source{Path: app})
+		g.Expect(err).To(MatchError(kustomize.ErrMaxDepthExceeded))
+	})
+
+	t.Run("should measure shared bases by their deepest reference", func(t *testing.T) {
+		g := NewWithT(t)
+		root := t.TempDir()
+		writeFile(t, root, "base/kustomization.yaml", "resources:\n- configmap.yaml\n")
+		writeFile(t, root, "base/configmap.yaml", basicConfigMap)
+		writeFile(t, root, "mid/kustomization.yaml", "resources:\n- ../base\nnamePrefix: mid-\n")
+		writeFile(t, root, "app/kustomization.yaml", "resources:\n- ../base\n- ../mid\n")
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: filepath.Join(root, "app")}},
+			kustomize.WithMaxDepth(1),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrMaxDepthExceeded))
+		g.Expect(err.Error()).To(ContainSubstring(filepath.Join(root, "mid") + " -> " + filepath.Join(root, "base")))
+	})
+
+	t.Run("should not limit by default", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setup(t)
+
+		renderer, err := kustomize.New([]kustomize.Source{{Path: filepath.Join(root, "app")}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
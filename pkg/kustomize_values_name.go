@@ -0,0 +1,37 @@
+package kustomize
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// valuesNameAnnotation marks the injected values object with the name it was generated
+// with, so that its rendered name can be found after kustomize applied name prefixes,
+// suffixes and hashes. It never reaches the output.
+const valuesNameAnnotation = "internal.renderer-kustomize.k8s-manifest-kit.io/values-name"
+
+// takeValuesNames removes the marks of the values objects from objects and returns the name
+// every marked object was generated with, by object index. Other objects get "".
+func takeValuesNames(objects []unstructured.Unstructured) []string {
+	names := make([]string, len(objects))
+
+	for i := range objects {
+		annotations := objects[i].GetAnnotations()
+
+		name, found := annotations[valuesNameAnnotation]
+		if !found {
+			continue
+		}
+
+		delete(annotations, valuesNameAnnotation)
+
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+
+		objects[i].SetAnnotations(annotations)
+
+		names[i] = name
+	}
+
+	return names
+}
@@ -0,0 +1,86 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	goyaml "gopkg.in/yaml.v3"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+// valuesPatchAnnotation marks the ConfigMap patched by WithValuesPatch, so that a patch
+// matching nothing can be detected after the build. It never reaches the output.
+const valuesPatchAnnotation = "internal.renderer-kustomize.k8s-manifest-kit.io/values-patch"
+
+// ErrValuesPatchTarget is returned when the ConfigMap patched by WithValuesPatch is not part
+// of the rendered objects.
+var ErrValuesPatchTarget = errors.New("values patch target not found")
+
+// applyValuesPatch adds the values set with WithValuesPatch to the kustomization as a patch
+// of the data of the values ConfigMap, reporting whether the kustomization was modified.
+// Patching in the kustomization rather than in the output lets kustomize recompute the name
+// hash of generated ConfigMaps and the references to them.
+func (e *Engine) applyValuesPatch(kust *kustomizetypes.Kustomization) (bool, error) {
+	if len(e.opts.ValuesPatch) == 0 {
+		return false, nil
+	}
+
+	data, err := goyaml.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":        e.opts.ValuesConfigMapName,
+			"annotations": map[string]any{valuesPatchAnnotation: "true"},
+		},
+		"data": e.opts.ValuesPatch,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal values patch: %w", err)
+	}
+
+	kust.Patches = append(kust.Patches, kustomizetypes.Patch{
+		Patch: string(data),
+		Target: &kustomizetypes.Selector{
+			ResId: resid.ResId{
+				Gvk:  resid.Gvk{Version: "v1", Kind: "ConfigMap"},
+				Name: regexp.QuoteMeta(e.opts.ValuesConfigMapName),
+			},
+		},
+	})
+
+	return true, nil
+}
+
+// checkValuesPatchTarget removes the marker added by the values patch from m, failing with
+// ErrValuesPatchTarget if WithValuesPatch is set and no object carries it: kustomize ignores
+// patches matching nothing.
+func (e *Engine) checkValuesPatchTarget(m resMap, path string) error {
+	if len(e.opts.ValuesPatch) == 0 {
+		return nil
+	}
+
+	found := false
+
+	for _, res := range m.Resources() {
+		annotations := res.GetAnnotations()
+		if _, marked := annotations[valuesPatchAnnotation]; !marked {
+			continue
+		}
+
+		delete(annotations, valuesPatchAnnotation)
+
+		if err := res.SetAnnotations(annotations); err != nil {
+			return fmt.Errorf("failed to remove values patch marker: %w", err)
+		}
+
+		found = true
+	}
+
+	if !found {
+		return fmt.Errorf("%w: no ConfigMap %q in path %q", ErrValuesPatchTarget, e.opts.ValuesConfigMapName, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package kustomize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+)
+
+// ErrNoFiles is returned by RenderBytes when the file map is empty.
+var ErrNoFiles = errors.New("no files to render")
+
+// bytesRoot is the directory the files passed to RenderBytes are rooted at.
+const bytesRoot = "/"
+
+// RenderBytes renders a kustomization held entirely in memory, without touching disk.
+// Keys of files are paths relative to the kustomization root (a leading "/" is accepted),
+// and one of them must be the kustomization file itself, e.g. "kustomization.yaml".
+// Nested paths such as "base/deployment.yaml" are supported.
+//
+// All renderer options apply, except WithFileSystem: the files are always served from an
+// in-memory filesystem. Filters and transformers run as in Process.
+//
+// Example:
+//
+//	objects, err := kustomize.RenderBytes(ctx, map[string][]byte{
+//	    "kustomization.yaml": []byte("resources:\n- deployment.yaml\n"),
+//	    "deployment.yaml":    deployment,
+//	})
+func RenderBytes(
+	ctx context.Context,
+	files map[string][]byte,
+	opts ...RendererOption,
+) ([]unstructured.Unstructured, error) {
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+
+	memFs := fs.NewMemoryFs()
+
+	for name, content := range files {
+		target := path.Join(bytesRoot, name)
+		if target == bytesRoot {
+			return nil, fmt.Errorf("invalid file name %q", name) //nolint:err113
+		}
+
+		if err := memFs.WriteFile(target, content); err != nil {
+			return nil, fmt.Errorf("unable to write %q to in-memory filesystem: %w", name, err)
+		}
+	}
+
+	if _, found := findKustomizationFile(memFs, bytesRoot); !found {
+		return nil, fmt.Errorf("%w among provided files", ErrNoKustomizationFile)
+	}
+
+	renderOpts := make([]RendererOption, 0, len(opts)+1)
+	renderOpts = append(renderOpts, opts...)
+	renderOpts = append(renderOpts, WithFileSystem(memFs))
+
+	renderer, err := New([]Source{{Path: bytesRoot}}, renderOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kustomize renderer: %w", err)
+	}
+
+	return renderer.Process(ctx, nil)
+}
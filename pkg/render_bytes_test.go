@@ -0,0 +1,74 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"github.com/k8s-manifest-kit/engine/pkg/filter/meta/gvk"
+
+	corev1 "k8s.io/api/core/v1"
+
+	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRenderBytes(t *testing.T) {
+
+	t.Run("should render in-memory kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), map[string][]byte{
+			"kustomization.yaml": []byte(basicKustomization),
+			"configmap.yaml":     []byte(basicConfigMap),
+			"pod.yaml":           []byte(basicPod),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+		g.Expect(objects[0].GetName()).To(HavePrefix("test-"))
+	})
+
+	t.Run("should render nested paths", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(), map[string][]byte{
+			"/kustomization.yaml":     []byte("resources:\n- base\nnamePrefix: prod-\n"),
+			"base/kustomization.yaml": []byte(baseKustomization),
+			"base/configmap.yaml":     []byte(baseConfigMap),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetName()).To(Equal("prod-app-config"))
+	})
+
+	t.Run("should apply renderer options", func(t *testing.T) {
+		g := NewWithT(t)
+
+		objects, err := kustomize.RenderBytes(t.Context(),
+			map[string][]byte{
+				"kustomization.yaml": []byte(basicKustomization),
+				"configmap.yaml":     []byte(basicConfigMap),
+				"pod.yaml":           []byte(basicPod),
+			},
+			kustomize.WithFilter(gvk.Filter(corev1.SchemeGroupVersion.WithKind("Pod"))),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].GetKind()).To(Equal("Pod"))
+	})
+
+	t.Run("should fail without kustomization file", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.RenderBytes(t.Context(), map[string][]byte{
+			"configmap.yaml": []byte(basicConfigMap),
+		})
+		g.Expect(err).To(MatchError(kustomize.ErrNoKustomizationFile))
+	})
+
+	t.Run("should fail without files", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := kustomize.RenderBytes(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrNoFiles))
+	})
+}
@@ -11,29 +11,69 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
+// Default permissions of the files and directories created by an Adapter, before umask.
+const (
+	defaultFileMode os.FileMode = 0666
+	defaultDirMode  os.FileMode = 0777
+)
+
 // Adapter wraps an afero.Fs to implement filesys.FileSystem.
 type Adapter struct {
-	fs afero.Fs
+	fs          afero.Fs
+	symlinks    SymlinkPolicy
+	symlinkRoot string
+	fileMode    os.FileMode
+	dirMode     os.FileMode
 }
 
 // New creates a filesys.FileSystem backed by the given afero.Fs.
-func New(afs afero.Fs) filesys.FileSystem {
-	return &Adapter{fs: afs}
+func New(afs afero.Fs, opts ...Option) filesys.FileSystem {
+	a := &Adapter{
+		fs:       afs,
+		fileMode: defaultFileMode,
+		dirMode:  defaultDirMode,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// WithFileMode sets the permissions of the files created by Create and WriteFile.
+// Existing files keep theirs. On the OS filesystem, the process umask still applies.
+// Default: 0666.
+//
+// Example:
+//
+//	fsys := adapter.New(afero.NewOsFs(), adapter.WithFileMode(0o644), adapter.WithDirMode(0o755))
+func WithFileMode(mode os.FileMode) Option {
+	return func(a *Adapter) {
+		a.fileMode = mode.Perm()
+	}
 }
 
-// Create creates a file at the specified path.
+// WithDirMode sets the permissions of the directories created by Mkdir and MkdirAll.
+// On the OS filesystem, the process umask still applies. Default: 0777.
+func WithDirMode(mode os.FileMode) Option {
+	return func(a *Adapter) {
+		a.dirMode = mode.Perm()
+	}
+}
+
+// Create creates a file at the specified path, truncating it if it exists.
 func (a *Adapter) Create(path string) (filesys.File, error) {
-	return a.fs.Create(path)
+	return a.fs.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, a.fileMode)
 }
 
 // Mkdir creates a directory.
 func (a *Adapter) Mkdir(path string) error {
-	return a.fs.Mkdir(path, 0777|os.ModeDir)
+	return a.fs.Mkdir(path, a.dirMode|os.ModeDir)
 }
 
 // MkdirAll creates a directory and all parent directories.
 func (a *Adapter) MkdirAll(path string) error {
-	return a.fs.MkdirAll(path, 0777|os.ModeDir)
+	return a.fs.MkdirAll(path, a.dirMode|os.ModeDir)
 }
 
 // RemoveAll removes a path and all children.
@@ -63,6 +103,12 @@ func (a *Adapter) IsDir(path string) bool {
 	return info.IsDir()
 }
 
+// Stat returns the FileInfo of path, following symlinks. Modification times are those of
+// the wrapped afero.Fs, which makes them available for union and read-only filesystems too.
+func (a *Adapter) Stat(path string) (os.FileInfo, error) {
+	return a.fs.Stat(path)
+}
+
 // ReadDir reads the directory entries.
 func (a *Adapter) ReadDir(path string) ([]string, error) {
 	entries, err := afero.ReadDir(a.fs, path)
@@ -85,22 +131,30 @@ func (a *Adapter) ReadFile(path string) ([]byte, error) {
 
 // WriteFile writes data to a file.
 func (a *Adapter) WriteFile(path string, data []byte) error {
-	return afero.WriteFile(a.fs, path, data, 0666)
+	return afero.WriteFile(a.fs, path, data, a.fileMode)
 }
 
-// Glob returns paths matching the pattern.
+// Glob returns paths matching the pattern, with the syntax of filepath.Match extended with
+// "**" path elements matching any number of directories, including none: "apps/**/*.yaml"
+// matches "apps/a.yaml" as well as "apps/x/y/a.yaml". Patterns without "**" keep the
+// semantics of filepath.Glob. Matches are returned in lexical order; symlinks to
+// directories are not descended into by "**".
 func (a *Adapter) Glob(pattern string) ([]string, error) {
-	return afero.Glob(a.fs, pattern)
+	return glob(a.fs, pattern)
 }
 
-// Walk walks the filesystem tree.
+// Walk walks the filesystem tree rooted at path exactly like filepath.Walk: nodes are
+// visited in lexical order without following symlinks, errors reading a node are passed to
+// walkFn for that node, and filepath.SkipDir and filepath.SkipAll are honored. This holds
+// for every afero.Fs, including read-only and union filesystems.
 func (a *Adapter) Walk(path string, walkFn filepath.WalkFunc) error {
-	return afero.Walk(a.fs, path, walkFn)
+	return walk(a.fs, path, walkFn)
 }
 
 // CleanedAbs converts the given path into a directory and a file name.
 // If the entire path is a directory, the file component is an empty string.
-// The directory is represented as a ConfirmedDir.
+// The directory is represented as a ConfirmedDir. Symlinks are treated according to the
+// adapter's SymlinkPolicy.
 func (a *Adapter) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
 	if path == "" {
 		path = "."
@@ -112,15 +166,10 @@ func (a *Adapter) CleanedAbs(path string) (filesys.ConfirmedDir, string, error)
 		return "", "", fmt.Errorf("abs path error on %q: %w", path, err)
 	}
 
-	// For OsFs, resolve symlinks. For other filesystems, use as-is.
-	// Check if the underlying fs supports EvalSymlinks
-	resolvedPath := absPath
-	if _, ok := a.fs.(*afero.OsFs); ok {
-		deLinked, err := filepath.EvalSymlinks(absPath)
-		if err != nil {
-			return "", "", fmt.Errorf("evalsymlink failure on %q: %w", path, err)
-		}
-		resolvedPath = deLinked
+	// Resolve symlinks according to the policy
+	resolvedPath, err := a.resolveSymlinks(absPath)
+	if err != nil {
+		return "", "", err
 	}
 
 	// Check if path exists
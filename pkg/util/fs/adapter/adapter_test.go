@@ -2,9 +2,13 @@ package adapter_test
 
 import (
 	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 
 	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
 
@@ -194,3 +198,547 @@ func TestAdapterImplementsInterface(t *testing.T) {
 
 	g.Expect(true).To(BeTrue()) // Compilation check
 }
+
+// setupSymlinks creates a tree with an in-tree symlink and symlinks escaping the tree:
+//
+//	root/app/kustomization.yaml
+//	root/app/local -> base
+//	root/app/base/
+//	root/app/overlays/prod/base -> ../../base
+//	root/app/absolute -> root/app/base
+//	root/app/escape -> ../outside
+//	root/app/secret.yaml -> ../outside/secret.yaml
+//	root/outside/secret.yaml
+func setupSymlinks(t *testing.T) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on windows")
+	}
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"app/base", "app/overlays/prod", "outside"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, file := range []string{"app/kustomization.yaml", "outside/secret.yaml"} {
+		if err := os.WriteFile(filepath.Join(root, file), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"app/local":              "base",
+		"app/overlays/prod/base": "../../base",
+		"app/absolute":           filepath.Join(root, "app", "base"),
+		"app/escape":             "../outside",
+		"app/secret.yaml":        "../outside/secret.yaml",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestCleanedAbs_SymlinkPolicy(t *testing.T) {
+	t.Run("should follow symlinks on the OS filesystem by default", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		dir, file, err := adapter.New(afero.NewOsFs()).CleanedAbs(filepath.Join(root, "app", "secret.yaml"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(dir)).To(Equal(filepath.Join(root, "outside")))
+		g.Expect(file).To(Equal("secret.yaml"))
+	})
+
+	t.Run("should keep the lexical path when ignoring symlinks", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		fsys := adapter.New(afero.NewOsFs(), adapter.WithSymlinkPolicy(adapter.SymlinkIgnore))
+
+		dir, file, err := fsys.CleanedAbs(filepath.Join(root, "app", "escape"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(dir)).To(Equal(filepath.Join(root, "app", "escape")))
+		g.Expect(file).To(BeEmpty())
+	})
+
+	t.Run("should follow symlinks within their directory when denying", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		fsys := adapter.New(afero.NewOsFs(), adapter.WithSymlinkPolicy(adapter.SymlinkDeny))
+
+		dir, file, err := fsys.CleanedAbs(filepath.Join(root, "app", "local"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(dir)).To(Equal(filepath.Join(root, "app", "base")))
+		g.Expect(file).To(BeEmpty())
+
+		dir, file, err = fsys.CleanedAbs(filepath.Join(root, "app", "kustomization.yaml"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(dir)).To(Equal(filepath.Join(root, "app")))
+		g.Expect(file).To(Equal("kustomization.yaml"))
+	})
+
+	t.Run("should reject escaping symlinks when denying", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		fsys := adapter.New(afero.NewOsFs(), adapter.WithSymlinkPolicy(adapter.SymlinkDeny))
+
+		for _, path := range []string{"escape", "secret.yaml", filepath.Join("escape", "secret.yaml")} {
+			_, _, err := fsys.CleanedAbs(filepath.Join(root, "app", path))
+			g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape), path)
+		}
+	})
+
+	t.Run("should follow symlinks to sibling directories within the root when denying", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		fsys := adapter.New(afero.NewOsFs(),
+			adapter.WithSymlinkPolicy(adapter.SymlinkDeny),
+			adapter.WithSymlinkRoot(filepath.Join(root, "app")),
+		)
+
+		for _, path := range []string{filepath.Join("overlays", "prod", "base"), "absolute"} {
+			dir, file, err := fsys.CleanedAbs(filepath.Join(root, "app", path))
+			g.Expect(err).To(Succeed(), path)
+			g.Expect(string(dir)).To(Equal(filepath.Join(root, "app", "base")), path)
+			g.Expect(file).To(BeEmpty(), path)
+		}
+
+		for _, path := range []string{"escape", "secret.yaml"} {
+			_, _, err := fsys.CleanedAbs(filepath.Join(root, "app", path))
+			g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape), path)
+		}
+	})
+
+	t.Run("should confine symlinks to their directory without a root when denying", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		fsys := adapter.New(afero.NewOsFs(), adapter.WithSymlinkPolicy(adapter.SymlinkDeny))
+
+		_, _, err := fsys.CleanedAbs(filepath.Join(root, "app", "overlays", "prod", "base"))
+		g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape))
+	})
+
+	t.Run("should follow symlinks above the root when denying", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		linked := filepath.Join(t.TempDir(), "linked")
+		g.Expect(os.Symlink(root, linked)).To(Succeed())
+
+		fsys := adapter.New(afero.NewOsFs(),
+			adapter.WithSymlinkPolicy(adapter.SymlinkDeny),
+			adapter.WithSymlinkRoot(filepath.Join(linked, "app")),
+		)
+
+		dir, _, err := fsys.CleanedAbs(filepath.Join(linked, "app", "overlays", "prod", "base"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(dir)).To(Equal(filepath.Join(root, "app", "base")))
+
+		_, _, err = fsys.CleanedAbs(filepath.Join(linked, "app", "escape"))
+		g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape))
+	})
+
+	t.Run("should reject escaping symlinks through wrappers when denying", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		fsys := adapter.New(
+			afero.NewReadOnlyFs(afero.NewOsFs()),
+			adapter.WithSymlinkPolicy(adapter.SymlinkDeny),
+		)
+
+		_, _, err := fsys.CleanedAbs(filepath.Join(root, "app", "escape"))
+		g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape))
+	})
+
+	t.Run("should report missing paths when denying", func(t *testing.T) {
+		g := NewWithT(t)
+		root := setupSymlinks(t)
+
+		fsys := adapter.New(afero.NewOsFs(), adapter.WithSymlinkPolicy(adapter.SymlinkDeny))
+
+		_, _, err := fsys.CleanedAbs(filepath.Join(root, "app", "missing", "file"))
+		g.Expect(err).To(MatchError(iofs.ErrNotExist))
+	})
+
+	t.Run("should accept filesystems without symlinks when denying", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys := adapter.New(afero.NewMemMapFs(), adapter.WithSymlinkPolicy(adapter.SymlinkDeny))
+		g.Expect(fsys.MkdirAll("/test/dir")).To(Succeed())
+
+		dir, _, err := fsys.CleanedAbs("/test/dir")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(dir)).To(Equal("/test/dir"))
+	})
+}
+
+// failingOpenFs fails to open the directory named dir, like a directory without read
+// permission.
+type failingOpenFs struct {
+	afero.Fs
+
+	dir string
+}
+
+func (f *failingOpenFs) Open(name string) (afero.File, error) {
+	if name == f.dir {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrPermission}
+	}
+
+	return f.Fs.Open(name)
+}
+
+// walkTree creates a/ (b.txt, c/d.txt) and e.txt below root.
+func walkTree(t *testing.T, fsys afero.Fs, root string) {
+	t.Helper()
+
+	for path, content := range map[string]string{"a/b.txt": "b", "a/c/d.txt": "d", "e.txt": "e"} {
+		full := filepath.Join(root, path)
+		if err := fsys.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := afero.WriteFile(fsys, full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConfigureSymlinks(t *testing.T) {
+	t.Run("should change the symlink handling only", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys := adapter.New(afero.NewMemMapFs(), adapter.WithFileMode(0o600), adapter.WithDirMode(0o750))
+
+		configured, err := adapter.ConfigureSymlinks(fsys, adapter.SymlinkDeny, "/repo")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		a := configured.(*adapter.Adapter) //nolint:forcetypeassert
+		g.Expect(a.SymlinkPolicy()).To(Equal(adapter.SymlinkDeny))
+		g.Expect(a.SymlinkRoot()).To(Equal(filepath.Clean("/repo")))
+		g.Expect(fsys.(*adapter.Adapter).SymlinkPolicy()).To(Equal(adapter.SymlinkFollow)) //nolint:forcetypeassert
+
+		g.Expect(configured.MkdirAll("/repo/app")).To(Succeed())
+		g.Expect(configured.WriteFile("/repo/app/kustomization.yaml", []byte("resources: []"))).To(Succeed())
+
+		for path, mode := range map[string]os.FileMode{
+			"/repo/app":                    0o750,
+			"/repo/app/kustomization.yaml": 0o600,
+		} {
+			info, err := a.Stat(path)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(info.Mode().Perm()).To(Equal(mode), path)
+		}
+	})
+
+	t.Run("should fail for filesystems not backed by an adapter", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := adapter.ConfigureSymlinks(filesys.MakeFsInMemory(), adapter.SymlinkDeny, "")
+		g.Expect(err).To(MatchError(adapter.ErrSymlinksNotConfigurable))
+	})
+}
+
+func TestWalkSemantics(t *testing.T) {
+	// each walk function records the visited paths, relative to root, and returns its
+	// decision for a path
+	decisions := map[string]func(rel string) error{
+		"all": func(_ string) error { return nil },
+		"skip dir": func(rel string) error {
+			if rel == "a/c" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		},
+		"skip dir on file": func(rel string) error {
+			if rel == "a/b.txt" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		},
+		"skip all": func(rel string) error {
+			if rel == "a/c" {
+				return filepath.SkipAll
+			}
+
+			return nil
+		},
+	}
+
+	for name, decide := range decisions {
+		t.Run("should walk like filepath.Walk: "+name, func(t *testing.T) {
+			g := NewWithT(t)
+			root := t.TempDir()
+			walkTree(t, afero.NewOsFs(), root)
+
+			record := func(visited *[]string) filepath.WalkFunc {
+				return func(path string, _ iofs.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+
+					rel, _ := filepath.Rel(root, path)
+					*visited = append(*visited, rel)
+
+					return decide(rel)
+				}
+			}
+
+			var expected []string
+			g.Expect(filepath.Walk(root, record(&expected))).To(Succeed())
+
+			var visited []string
+			g.Expect(adapter.New(afero.NewOsFs()).Walk(root, record(&visited))).To(Succeed())
+			g.Expect(visited).To(Equal(expected))
+
+			visited = nil
+			readOnly := adapter.New(afero.NewReadOnlyFs(afero.NewOsFs()))
+			g.Expect(readOnly.Walk(root, record(&visited))).To(Succeed())
+			g.Expect(visited).To(Equal(expected))
+		})
+	}
+
+	t.Run("should report a directory that cannot be read once", func(t *testing.T) {
+		g := NewWithT(t)
+		base := afero.NewMemMapFs()
+		walkTree(t, base, "/root")
+
+		fsys := adapter.New(&failingOpenFs{Fs: base, dir: "/root/a"})
+
+		var visited []string
+		var failed []string
+
+		err := fsys.Walk("/root", func(path string, _ iofs.FileInfo, err error) error {
+			visited = append(visited, path)
+			if err != nil {
+				failed = append(failed, path)
+			}
+
+			return nil
+		})
+		g.Expect(err).To(Succeed())
+		g.Expect(visited).To(Equal([]string{"/root", "/root/a", "/root/e.txt"}))
+		g.Expect(failed).To(Equal([]string{"/root/a"}))
+	})
+
+	t.Run("should stop with the error returned by walkFn", func(t *testing.T) {
+		g := NewWithT(t)
+		base := afero.NewMemMapFs()
+		walkTree(t, base, "/root")
+
+		fsys := adapter.New(afero.NewReadOnlyFs(&failingOpenFs{Fs: base, dir: "/root/a"}))
+
+		err := fsys.Walk("/root", func(_ string, _ iofs.FileInfo, err error) error {
+			return err
+		})
+		g.Expect(err).To(MatchError(iofs.ErrPermission))
+	})
+
+	t.Run("should report a missing root", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys := adapter.New(afero.NewMemMapFs())
+
+		err := fsys.Walk("/missing", func(_ string, info iofs.FileInfo, err error) error {
+			g.Expect(info).To(BeNil())
+
+			return err
+		})
+		g.Expect(err).To(MatchError(iofs.ErrNotExist))
+	})
+}
+
+func TestStat(t *testing.T) {
+	fsys := adapter.New(afero.NewMemMapFs())
+	stater := fsys.(*adapter.Adapter) //nolint:forcetypeassert
+
+	t.Run("should report the FileInfo of a file", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(fsys.WriteFile("/stat/file.txt", []byte("content"))).To(Succeed())
+
+		info, err := stater.Stat("/stat/file.txt")
+		g.Expect(err).To(Succeed())
+		g.Expect(info.Name()).To(Equal("file.txt"))
+		g.Expect(info.Size()).To(Equal(int64(len("content"))))
+		g.Expect(info.IsDir()).To(BeFalse())
+		g.Expect(info.ModTime()).ToNot(BeZero())
+
+		info, err = stater.Stat("/stat")
+		g.Expect(err).To(Succeed())
+		g.Expect(info.IsDir()).To(BeTrue())
+	})
+
+	t.Run("should fail for a missing path", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := stater.Stat("/missing")
+		g.Expect(err).To(MatchError(iofs.ErrNotExist))
+	})
+}
+
+func TestGlob_Doublestar(t *testing.T) {
+	fsys := adapter.New(afero.NewMemMapFs())
+
+	for _, path := range []string{
+		"/repo/apps/kustomization.yaml",
+		"/repo/apps/a/kustomization.yaml",
+		"/repo/apps/b/README.md",
+		"/repo/apps/b/overlays/prod/kustomization.yaml",
+		"/repo/apps-old/kustomization.yaml",
+		"/repo/other/kustomization.yaml",
+	} {
+		if err := fsys.WriteFile(path, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("should match any number of directories", func(t *testing.T) {
+		g := NewWithT(t)
+
+		matches, err := fsys.Glob("/repo/apps/**/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(Equal([]string{
+			"/repo/apps/a/kustomization.yaml",
+			"/repo/apps/b/overlays/prod/kustomization.yaml",
+			"/repo/apps/kustomization.yaml",
+		}))
+	})
+
+	t.Run("should combine doublestar with other patterns", func(t *testing.T) {
+		g := NewWithT(t)
+
+		matches, err := fsys.Glob("/repo/app*/**/prod/*.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(Equal([]string{"/repo/apps/b/overlays/prod/kustomization.yaml"}))
+
+		matches, err = fsys.Glob("/**/README.md")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(Equal([]string{"/repo/apps/b/README.md"}))
+
+		matches, err = fsys.Glob("/repo/apps/b/**")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(Equal([]string{
+			"/repo/apps/b",
+			"/repo/apps/b/README.md",
+			"/repo/apps/b/overlays",
+			"/repo/apps/b/overlays/prod",
+			"/repo/apps/b/overlays/prod/kustomization.yaml",
+		}))
+	})
+
+	t.Run("should keep standard glob semantics without doublestar", func(t *testing.T) {
+		g := NewWithT(t)
+
+		matches, err := fsys.Glob("/repo/apps/*/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(Equal([]string{"/repo/apps/a/kustomization.yaml"}))
+	})
+
+	t.Run("should match relative patterns", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		diskFs := adapter.New(afero.NewBasePathFs(afero.NewOsFs(), dir))
+		g.Expect(diskFs.MkdirAll("/apps/x")).To(Succeed())
+		g.Expect(diskFs.WriteFile("/apps/x/kustomization.yaml", []byte("x"))).To(Succeed())
+
+		matches, err := diskFs.Glob("apps/**/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(Equal([]string{filepath.Join("apps", "x", "kustomization.yaml")}))
+	})
+
+	t.Run("should reject malformed patterns", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := fsys.Glob("/repo/**/[")
+		g.Expect(err).To(MatchError(filepath.ErrBadPattern))
+	})
+}
+
+func TestFileModes(t *testing.T) {
+	t.Run("should use permissive defaults", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys := adapter.New(afero.NewMemMapFs())
+		stater := fsys.(*adapter.Adapter) //nolint:forcetypeassert
+		g.Expect(fsys.MkdirAll("/dir")).To(Succeed())
+		g.Expect(fsys.WriteFile("/dir/file.yaml", []byte("a: b\n"))).To(Succeed())
+
+		info, err := stater.Stat("/dir")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o777)))
+
+		info, err = stater.Stat("/dir/file.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o666)))
+	})
+
+	t.Run("should apply the configured modes", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys := adapter.New(
+			afero.NewMemMapFs(),
+			adapter.WithFileMode(0o600),
+			adapter.WithDirMode(0o750),
+		)
+		stater := fsys.(*adapter.Adapter) //nolint:forcetypeassert
+		g.Expect(fsys.MkdirAll("/a/b")).To(Succeed())
+		g.Expect(fsys.Mkdir("/c")).To(Succeed())
+		g.Expect(fsys.WriteFile("/a/b/written.yaml", []byte("a: b\n"))).To(Succeed())
+
+		file, err := fsys.Create("/a/created.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(file.Close()).To(Succeed())
+
+		for path, mode := range map[string]os.FileMode{
+			"/a":                0o750,
+			"/a/b":              0o750,
+			"/c":                0o750,
+			"/a/b/written.yaml": 0o600,
+			"/a/created.yaml":   0o600,
+		} {
+			info, err := stater.Stat(path)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(info.Mode().Perm()).To(Equal(mode), path)
+		}
+	})
+
+	t.Run("should apply the configured modes on disk", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("permissions are not supported on Windows")
+		}
+
+		g := NewWithT(t)
+		root := t.TempDir()
+
+		fsys := adapter.New(afero.NewOsFs(), adapter.WithFileMode(0o600), adapter.WithDirMode(0o700))
+		g.Expect(fsys.MkdirAll(filepath.Join(root, "out"))).To(Succeed())
+		g.Expect(fsys.WriteFile(filepath.Join(root, "out", "secret.yaml"), []byte("a: b\n"))).To(Succeed())
+
+		info, err := os.Stat(filepath.Join(root, "out"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o700)))
+
+		info, err = os.Stat(filepath.Join(root, "out", "secret.yaml"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+	})
+}
@@ -0,0 +1,119 @@
+//nolint:wrapcheck
+package adapter
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// doublestar is the pattern element matching any number of path elements.
+const doublestar = "**"
+
+// glob returns the paths of fsys matching pattern. Patterns without a "**" element are
+// matched by afero.Glob, with the semantics of filepath.Glob; the others are matched by
+// walking the tree below their longest literal prefix.
+func glob(fsys afero.Fs, pattern string) ([]string, error) {
+	elems := strings.Split(filepath.ToSlash(pattern), "/")
+	if !slices.Contains(elems, doublestar) {
+		return afero.Glob(fsys, pattern)
+	}
+
+	// validate every element up front, like filepath.Glob does for the whole pattern
+	for _, elem := range elems {
+		if _, err := filepath.Match(elem, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	literal := 0
+	for literal < len(elems) && !hasMeta(elems[literal]) {
+		literal++
+	}
+
+	root := filepath.FromSlash(strings.Join(elems[:literal], "/"))
+
+	switch {
+	case literal == 1 && elems[0] == "":
+		// the pattern is absolute and only its root is literal
+		root = string(filepath.Separator)
+	case root == "":
+		root = "."
+	}
+
+	rest := elems[literal:]
+
+	var matches []string
+
+	// like filepath.Glob, I/O errors are ignored: unreadable directories just yield no matches
+	_ = walk(fsys, root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			return nil
+		}
+
+		var relElems []string
+		if rel != "." {
+			relElems = strings.Split(filepath.ToSlash(rel), "/")
+		}
+
+		if matchElems(rest, relElems, false) {
+			matches = append(matches, path)
+		}
+
+		if info.IsDir() && !matchElems(rest, relElems, true) {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	slices.Sort(matches)
+
+	return matches, nil
+}
+
+// matchElems reports whether the path elements match the pattern elements, where "**"
+// matches any number of elements, including none. With prefix set, it reports instead
+// whether a path below the one made of the elements may match.
+func matchElems(pattern []string, path []string, prefix bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == doublestar {
+			if prefix {
+				return true
+			}
+
+			for i := range len(path) + 1 {
+				if matchElems(pattern[1:], path[i:], false) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(path) == 0 {
+			return prefix
+		}
+
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+
+		pattern, path = pattern[1:], path[1:]
+	}
+
+	return len(path) == 0 && !prefix
+}
+
+// hasMeta reports whether elem contains any of the special characters of filepath.Match.
+func hasMeta(elem string) bool {
+	return strings.ContainsAny(elem, `*?[\`)
+}
@@ -0,0 +1,277 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+var (
+	// ErrSymlinkEscape is returned by CleanedAbs under SymlinkDeny when a path traverses a
+	// symlink pointing outside of the symlink root.
+	ErrSymlinkEscape = errors.New("symlink escapes its root")
+
+	// ErrSymlinksNotConfigurable is returned by ConfigureSymlinks for filesystems which are
+	// not backed by an Adapter.
+	ErrSymlinksNotConfigurable = errors.New("symlink handling of filesystem cannot be configured")
+)
+
+// maxSymlinks bounds the number of symlinks followed while resolving a single path,
+// guarding against symlink loops (the limit used by Linux for a path lookup is 40).
+const maxSymlinks = 40
+
+// SymlinkPolicy controls how CleanedAbs treats symlinks.
+type SymlinkPolicy int
+
+const (
+	// SymlinkFollow resolves symlinks on the OS filesystem, as kustomize's own on-disk
+	// filesystem does. Paths on other filesystems are used as-is. This is the default.
+	SymlinkFollow SymlinkPolicy = iota
+
+	// SymlinkIgnore never resolves symlinks: paths are only made absolute and cleaned.
+	SymlinkIgnore
+
+	// SymlinkDeny resolves symlinks and fails with ErrSymlinkEscape when a symlink within the
+	// root set with WithSymlinkRoot points outside of it, so that a kustomization cannot
+	// reach files outside of its tree through symlinks. Symlinks staying within the root are
+	// followed, e.g. overlays/prod/base -> ../../base, and so are those outside of the root,
+	// such as a temporary directory linked elsewhere by the OS. Without a root, no tree is
+	// known and every symlink is confined to the directory containing it. Applies to every
+	// filesystem able to read links, including read-only and base path wrappers of the OS
+	// filesystem.
+	SymlinkDeny
+)
+
+// Option is a functional option for configuring an Adapter.
+type Option func(*Adapter)
+
+// WithSymlinkPolicy selects how CleanedAbs treats symlinks. Default: SymlinkFollow.
+//
+// Example:
+//
+//	fsys := adapter.New(afero.NewOsFs(), adapter.WithSymlinkPolicy(adapter.SymlinkDeny))
+func WithSymlinkPolicy(policy SymlinkPolicy) Option {
+	return func(a *Adapter) {
+		a.symlinks = policy
+	}
+}
+
+// WithSymlinkRoot sets the root of the tree symlinks must stay within under SymlinkDeny.
+// Relative roots are made absolute against the working directory; an empty root unsets it.
+//
+// Example:
+//
+//	fsys := adapter.New(afero.NewOsFs(),
+//	    adapter.WithSymlinkPolicy(adapter.SymlinkDeny),
+//	    adapter.WithSymlinkRoot("/repo"),
+//	)
+func WithSymlinkRoot(root string) Option {
+	return func(a *Adapter) {
+		if root == "" {
+			a.symlinkRoot = ""
+
+			return
+		}
+
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+
+		a.symlinkRoot = filepath.Clean(root)
+	}
+}
+
+// SymlinkPolicy returns the symlink policy of the adapter.
+func (a *Adapter) SymlinkPolicy() SymlinkPolicy {
+	return a.symlinks
+}
+
+// SymlinkRoot returns the symlink root of the adapter, empty if none is set.
+func (a *Adapter) SymlinkRoot() string {
+	return a.symlinkRoot
+}
+
+// SymlinkConfigurer is implemented by the filesystems backed by an Adapter, including the
+// wrappers of the fs package and union filesystems, to derive a filesystem treating
+// symlinks differently.
+type SymlinkConfigurer interface {
+	// ConfigureSymlinks returns a copy of the filesystem using the given symlink policy and
+	// root (see WithSymlinkRoot), with every other setting kept. The filesystem itself is
+	// left unchanged.
+	ConfigureSymlinks(policy SymlinkPolicy, root string) (filesys.FileSystem, error)
+}
+
+// ConfigureSymlinks returns a copy of fsys using the given symlink policy and root, e.g. to
+// deny escaping symlinks on a filesystem received from elsewhere. Filesystems not
+// implementing SymlinkConfigurer fail with ErrSymlinksNotConfigurable.
+//
+// Example:
+//
+//	confined, err := adapter.ConfigureSymlinks(fsys, adapter.SymlinkDeny, "/repo")
+func ConfigureSymlinks(fsys filesys.FileSystem, policy SymlinkPolicy, root string) (filesys.FileSystem, error) {
+	configurer, ok := fsys.(SymlinkConfigurer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrSymlinksNotConfigurable, fsys)
+	}
+
+	return configurer.ConfigureSymlinks(policy, root)
+}
+
+// ConfigureSymlinks returns a copy of the adapter using the given symlink policy and root,
+// keeping the wrapped afero.Fs and the file and directory modes.
+func (a *Adapter) ConfigureSymlinks(policy SymlinkPolicy, root string) (filesys.FileSystem, error) {
+	configured := *a
+
+	WithSymlinkPolicy(policy)(&configured)
+	WithSymlinkRoot(root)(&configured)
+
+	return &configured, nil
+}
+
+// resolveSymlinks applies the symlink policy to the absolute, clean path.
+func (a *Adapter) resolveSymlinks(path string) (string, error) {
+	switch a.symlinks {
+	case SymlinkIgnore:
+		return path, nil
+	case SymlinkDeny:
+		return a.resolveConfined(path)
+	default:
+		if _, ok := a.fs.(*afero.OsFs); !ok {
+			return path, nil
+		}
+
+		deLinked, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return "", fmt.Errorf("evalsymlink failure on %q: %w", path, err)
+		}
+
+		return deLinked, nil
+	}
+}
+
+// linkCheck validates the target of the symlink link, found in the resolved directory dir.
+type linkCheck func(dir string, link string, target string) error
+
+// resolveConfined resolves the symlinks of path, rejecting symlinks within the symlink root
+// whose target lies outside of it, or, without a root, outside of the directory containing
+// them. Filesystems unable to read links cannot contain symlinks, so their paths are
+// returned unchanged. Missing components are kept as-is, so that the subsequent Stat reports
+// them.
+func (a *Adapter) resolveConfined(path string) (string, error) {
+	lstater, ok := a.fs.(afero.Lstater)
+	if !ok {
+		return path, nil
+	}
+
+	reader, ok := a.fs.(afero.LinkReader)
+	if !ok {
+		return path, nil
+	}
+
+	if a.symlinkRoot == "" {
+		return resolvePath(lstater, reader, path, func(dir string, link string, target string) error {
+			if !isWithin(dir, target) {
+				return fmt.Errorf("%w: %q points to %q", ErrSymlinkEscape, link, target)
+			}
+
+			return nil
+		})
+	}
+
+	// the root itself may sit below symlinks, e.g. a temporary directory on macOS
+	root, err := resolvePath(lstater, reader, a.symlinkRoot, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return resolvePath(lstater, reader, path, func(_ string, link string, target string) error {
+		if !isWithin(root, link) || isWithin(root, target) || isWithin(a.symlinkRoot, target) {
+			return nil
+		}
+
+		return fmt.Errorf("%w: %q points to %q outside of %q", ErrSymlinkEscape, link, target, a.symlinkRoot)
+	})
+}
+
+// resolvePath resolves the symlinks of the absolute, clean path component by component,
+// validating every symlink with check, if not nil. The target of a symlink is resolved
+// again from the filesystem root, so that symlinks it traverses are checked too.
+func resolvePath(lstater afero.Lstater, reader afero.LinkReader, path string, check linkCheck) (string, error) {
+	volume := filepath.VolumeName(path)
+	top := volume + string(filepath.Separator)
+	resolved := top
+	pending := splitPath(path[len(volume):])
+	links := 0
+
+	for len(pending) > 0 {
+		candidate := filepath.Join(resolved, pending[0])
+		pending = pending[1:]
+
+		info, _, err := lstater.LstatIfPossible(candidate)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return filepath.Join(append([]string{candidate}, pending...)...), nil
+			}
+
+			return "", fmt.Errorf("lstat error on %q: %w", candidate, err)
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = candidate
+
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks resolving %q", path)
+		}
+
+		target, err := reader.ReadlinkIfPossible(candidate)
+		if err != nil {
+			return "", fmt.Errorf("readlink error on %q: %w", candidate, err)
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(resolved, target)
+		}
+
+		target = filepath.Clean(target)
+
+		if check != nil {
+			if err := check(resolved, candidate, target); err != nil {
+				return "", err
+			}
+		}
+
+		resolved = top
+		pending = append(splitPath(target[len(filepath.VolumeName(target)):]), pending...)
+	}
+
+	return resolved, nil
+}
+
+// isWithin reports whether path is dir or lies below it.
+func isWithin(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// splitPath returns the non-empty components of a clean path.
+func splitPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(path, string(filepath.Separator)) {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
@@ -0,0 +1,97 @@
+//nolint:wrapcheck
+package adapter
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/afero"
+)
+
+// walk walks the file tree rooted at root with the semantics of filepath.Walk, which
+// afero.Walk does not quite follow: walkFn is called once per node, with the error of
+// reading a directory reported in the call for that directory, and both filepath.SkipDir
+// and filepath.SkipAll are honored.
+func walk(fsys afero.Fs, root string, walkFn filepath.WalkFunc) error {
+	info, err := lstatIfPossible(fsys, root)
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		err = walkNode(fsys, root, info, walkFn)
+	}
+
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+
+	return err
+}
+
+// walkNode walks path, whose FileInfo is info, like the walk function of path/filepath.
+func walkNode(fsys afero.Fs, path string, info fs.FileInfo, walkFn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return walkFn(path, info, nil)
+	}
+
+	names, err := readDirNames(fsys, path)
+
+	// a directory that cannot be read is not descended into; walkFn decides whether the
+	// error aborts the walk
+	if fnErr := walkFn(path, info, err); err != nil || fnErr != nil {
+		return fnErr
+	}
+
+	for _, name := range names {
+		filename := filepath.Join(path, name)
+
+		fileInfo, err := lstatIfPossible(fsys, filename)
+		if err != nil {
+			if err := walkFn(filename, fileInfo, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+
+			continue
+		}
+
+		if err := walkNode(fsys, filename, fileInfo, walkFn); err != nil {
+			// SkipDir returned for a file skips the remaining entries of its directory
+			if !fileInfo.IsDir() || !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readDirNames returns the sorted entry names of the directory dirname.
+func readDirNames(fsys afero.Fs, dirname string) ([]string, error) {
+	f, err := fsys.Open(dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := f.Readdirnames(-1)
+	_ = f.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// lstatIfPossible uses Lstat when fsys supports it, so that symlinks are not followed.
+func lstatIfPossible(fsys afero.Fs, path string) (fs.FileInfo, error) {
+	if lstater, ok := fsys.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(path)
+
+		return info, err
+	}
+
+	return fsys.Stat(path)
+}
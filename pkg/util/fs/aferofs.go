@@ -0,0 +1,260 @@
+package fs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// fileSystemFs exposes a filesys.FileSystem as a read-only afero.Fs, so that non-Afero
+// filesystems can be composed with Afero-based wrappers such as CopyOnWriteFs or BasePathFs.
+// Files are read fully on Open; write operations fail with syscall.EPERM.
+type fileSystemFs struct {
+	base filesys.FileSystem
+}
+
+func (f *fileSystemFs) Name() string {
+	return "FileSystemFs"
+}
+
+func (f *fileSystemFs) Open(name string) (afero.File, error) {
+	if !f.base.Exists(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	info, err := f.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		names, err := f.base.ReadDir(name)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		return &fileSystemFile{fs: f, name: name, info: info, names: names, Reader: bytes.NewReader(nil)}, nil
+	}
+
+	data, err := f.base.ReadFile(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &fileSystemFile{fs: f, name: name, info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+func (f *fileSystemFs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EPERM}
+	}
+
+	return f.Open(name)
+}
+
+// stater is implemented by the filesystems of this module that report the FileInfo of a
+// path directly.
+type stater interface {
+	Stat(path string) (os.FileInfo, error)
+}
+
+// Stat uses the Stat method of the base filesystem or the FileInfo of the base file when
+// available, and synthesizes one otherwise.
+func (f *fileSystemFs) Stat(name string) (fs.FileInfo, error) {
+	if s, ok := f.base.(stater); ok {
+		return s.Stat(name) //nolint:wrapcheck
+	}
+
+	if !f.base.Exists(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if file, err := f.base.Open(name); err == nil {
+		info, err := file.Stat()
+		_ = file.Close()
+
+		if err == nil {
+			return info, nil
+		}
+	}
+
+	info := &fileSystemFileInfo{name: filepath.Base(name), dir: f.base.IsDir(name)}
+	if !info.dir {
+		data, err := f.base.ReadFile(name)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		info.size = int64(len(data))
+	}
+
+	return info, nil
+}
+
+func (f *fileSystemFs) Create(name string) (afero.File, error) {
+	return nil, &fs.PathError{Op: "create", Path: name, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFs) Mkdir(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFs) MkdirAll(path string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: path, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFs) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFs) RemoveAll(path string) error {
+	return &fs.PathError{Op: "remove", Path: path, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFs) Rename(oldname string, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFs) Chmod(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFs) Chown(name string, _ int, _ int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFs) Chtimes(name string, _ time.Time, _ time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: syscall.EPERM}
+}
+
+// fileSystemFile is a read-only afero.File holding the contents of a file, or the entry
+// names of a directory, of a fileSystemFs.
+type fileSystemFile struct {
+	*bytes.Reader
+
+	fs    *fileSystemFs
+	name  string
+	info  fs.FileInfo
+	names []string
+}
+
+func (f *fileSystemFile) Name() string {
+	return f.name
+}
+
+func (f *fileSystemFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fileSystemFile) Close() error {
+	return nil
+}
+
+// Readdir follows os.File.Readdir: with count > 0 it returns at most count entries and
+// io.EOF once the directory is exhausted, otherwise all remaining entries.
+func (f *fileSystemFile) Readdir(count int) ([]fs.FileInfo, error) {
+	names, err := f.Readdirnames(count)
+
+	infos := make([]fs.FileInfo, 0, len(names))
+	for _, name := range names {
+		info, err := f.fs.Stat(filepath.Join(f.name, name))
+		if err != nil {
+			return infos, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, err
+}
+
+func (f *fileSystemFile) Readdirnames(n int) ([]string, error) {
+	if !f.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+	}
+
+	if n <= 0 {
+		names := f.names
+		f.names = nil
+
+		return names, nil
+	}
+
+	if len(f.names) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(f.names))
+	names := f.names[:n]
+	f.names = f.names[n:]
+
+	return names, nil
+}
+
+func (f *fileSystemFile) Sync() error {
+	return nil
+}
+
+func (f *fileSystemFile) Truncate(_ int64) error {
+	return &fs.PathError{Op: "truncate", Path: f.name, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFile) Write(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFile) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: syscall.EPERM}
+}
+
+func (f *fileSystemFile) WriteString(_ string) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: syscall.EPERM}
+}
+
+// fileSystemFileInfo is the FileInfo of base files that cannot be stat'ed.
+type fileSystemFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i *fileSystemFileInfo) Name() string {
+	return i.name
+}
+
+func (i *fileSystemFileInfo) Size() int64 {
+	return i.size
+}
+
+func (i *fileSystemFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+
+	return 0o444
+}
+
+func (i *fileSystemFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (i *fileSystemFileInfo) IsDir() bool {
+	return i.dir
+}
+
+func (i *fileSystemFileInfo) Sys() any {
+	return nil
+}
+
+var (
+	_ afero.Fs    = (*fileSystemFs)(nil)
+	_ afero.File  = (*fileSystemFile)(nil)
+	_ fs.FileInfo = (*fileSystemFileInfo)(nil)
+)
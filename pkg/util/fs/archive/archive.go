@@ -0,0 +1,282 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/internal/unpack"
+)
+
+const (
+	// DefaultMaxSize is the default limit on the downloaded archive size.
+	DefaultMaxSize int64 = 64 << 20
+
+	// DefaultMaxExtractedSize is the default limit on the total size of extracted files.
+	DefaultMaxExtractedSize int64 = 256 << 20
+)
+
+var (
+	// ErrDownloadFailed is returned when the archive cannot be fetched.
+	ErrDownloadFailed = errors.New("archive download failed")
+
+	// ErrArchiveTooLarge is returned when the archive, or its extracted contents,
+	// exceed the configured size limits.
+	ErrArchiveTooLarge = errors.New("archive too large")
+
+	// ErrChecksumMismatch is returned when the downloaded archive does not match the expected sha256.
+	ErrChecksumMismatch = errors.New("archive checksum mismatch")
+
+	// ErrUnsupportedArchive is returned when the archive is neither a gzipped tarball nor a zip file.
+	ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+	// ErrExtractFailed is returned when the archive is corrupt or cannot be decompressed.
+	ErrExtractFailed = errors.New("archive extraction failed")
+
+	// ErrRootNotFound is returned when the configured root directory is missing from the archive.
+	ErrRootNotFound = errors.New("root directory not found in archive")
+)
+
+//nolint:gochecknoglobals
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// Option is a functional option for configuring an archive filesystem.
+type Option func(*config) error
+
+type config struct {
+	root             string
+	maxSize          int64
+	maxExtractedSize int64
+	sha256           string
+	client           *http.Client
+}
+
+// WithRoot roots the filesystem at the given directory inside the archive.
+// Release archives commonly wrap their contents in a top-level "<name>-<version>/" directory.
+func WithRoot(dir string) Option {
+	return func(cfg *config) error {
+		cfg.root = dir
+
+		return nil
+	}
+}
+
+// WithMaxSize limits the number of bytes downloaded. Defaults to DefaultMaxSize.
+func WithMaxSize(size int64) Option {
+	return func(cfg *config) error {
+		if size <= 0 {
+			return fmt.Errorf("max size must be positive, got %d", size) //nolint:err113
+		}
+
+		cfg.maxSize = size
+
+		return nil
+	}
+}
+
+// WithMaxExtractedSize limits the total size of the extracted files, guarding against
+// archives that decompress to far more than their download size.
+// Defaults to DefaultMaxExtractedSize.
+func WithMaxExtractedSize(size int64) Option {
+	return func(cfg *config) error {
+		if size <= 0 {
+			return fmt.Errorf("max extracted size must be positive, got %d", size) //nolint:err113
+		}
+
+		cfg.maxExtractedSize = size
+
+		return nil
+	}
+}
+
+// WithSHA256 verifies the downloaded archive against the given hex-encoded sha256
+// digest before anything is extracted.
+func WithSHA256(digest string) Option {
+	return func(cfg *config) error {
+		cfg.sha256 = strings.ToLower(strings.TrimSpace(digest))
+
+		return nil
+	}
+}
+
+// WithHTTPClient sets the client used to download the archive. Defaults to http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(cfg *config) error {
+		cfg.client = client
+
+		return nil
+	}
+}
+
+// NewHTTPFs downloads a .tar.gz/.tgz or .zip archive and returns its contents as a
+// read-only, in-memory filesys.FileSystem suitable for kustomize.WithFileSystem.
+//
+// The archive format is detected from its content rather than the URL. The download is
+// bounded by WithMaxSize and verified against WithSHA256 (when set) before extraction.
+// Extraction happens into a fresh in-memory filesystem that is only returned on success,
+// so a corrupt or oversized archive never yields a partially populated filesystem.
+//
+// Example:
+//
+//	archiveFs, err := archive.NewHTTPFs(ctx,
+//	    "https://github.com/org/manifests/archive/refs/tags/v1.2.0.tar.gz",
+//	    archive.WithRoot("manifests-1.2.0"),
+//	    archive.WithSHA256("9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"),
+//	)
+func NewHTTPFs(
+	ctx context.Context,
+	url string,
+	opts ...Option,
+) (filesys.FileSystem, error) {
+	cfg := &config{
+		maxSize:          DefaultMaxSize,
+		maxExtractedSize: DefaultMaxExtractedSize,
+		client:           http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := download(ctx, url, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.sha256 != "" {
+		sum := sha256.Sum256(data)
+		if actual := hex.EncodeToString(sum[:]); actual != cfg.sha256 {
+			return nil, fmt.Errorf("%w: %s: expected %s, got %s", ErrChecksumMismatch, url, cfg.sha256, actual)
+		}
+	}
+
+	tree, err := extract(data, cfg.maxExtractedSize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+
+	root := tree
+	if dir := strings.Trim(path.Clean("/"+cfg.root), "/"); dir != "" {
+		info, err := tree.Stat("/" + dir)
+		if err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("%w: %q in %s", ErrRootNotFound, cfg.root, url)
+		}
+
+		root = afero.NewBasePathFs(tree, "/"+dir)
+	}
+
+	return adapter.New(afero.NewReadOnlyFs(root)), nil
+}
+
+func download(ctx context.Context, url string, cfg *config) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s: unexpected status %s", ErrDownloadFailed, url, resp.Status)
+	}
+
+	if resp.ContentLength > cfg.maxSize {
+		return nil, fmt.Errorf("%w: %s: content length %d exceeds limit of %d bytes",
+			ErrArchiveTooLarge, url, resp.ContentLength, cfg.maxSize)
+	}
+
+	// read one byte past the limit to detect servers lying about (or omitting) the length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, cfg.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrDownloadFailed, url, err)
+	}
+
+	if int64(len(data)) > cfg.maxSize {
+		return nil, fmt.Errorf("%w: %s: exceeds limit of %d bytes", ErrArchiveTooLarge, url, cfg.maxSize)
+	}
+
+	return data, nil
+}
+
+// extract unpacks an archive into a new in-memory filesystem.
+func extract(data []byte, maxExtractedSize int64) (afero.Fs, error) {
+	tree := unpack.NewTree(maxExtractedSize)
+
+	var err error
+
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		err = tree.Tar(bytes.NewReader(data), nil)
+	case bytes.HasPrefix(data, zipMagic):
+		err = extractZip(data, tree)
+	default:
+		return nil, ErrUnsupportedArchive
+	}
+
+	switch {
+	case errors.Is(err, unpack.ErrTooLarge):
+		return nil, fmt.Errorf("%w: %w", ErrArchiveTooLarge, err)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", ErrExtractFailed, err)
+	default:
+		return tree.Fs(), nil
+	}
+}
+
+func extractZip(data []byte, tree *unpack.Tree) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			if err := tree.Mkdir(f.Name); err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			continue
+		}
+
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		if err := extractZipEntry(f, tree); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractZipEntry(f *zip.File, tree *unpack.Tree) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer func() { _ = rc.Close() }()
+
+	return tree.WriteFile(f.Name, rc) //nolint:wrapcheck
+}
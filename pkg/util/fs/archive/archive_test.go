@@ -0,0 +1,181 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/archive"
+
+	. "github.com/onsi/gomega"
+)
+
+//nolint:gochecknoglobals
+var testFiles = map[string]string{
+	"manifests-1.0.0/base/kustomization.yaml": "resources:\n- deployment.yaml\n",
+	"manifests-1.0.0/base/deployment.yaml":    "kind: Deployment\n",
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		NewWithT(t).Expect(err).ToNot(HaveOccurred())
+		_, err = tw.Write([]byte(content))
+		NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	}
+
+	NewWithT(t).Expect(tw.Close()).To(Succeed())
+	NewWithT(t).Expect(gz.Close()).To(Succeed())
+
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		w, err := zw.Create(name)
+		NewWithT(t).Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write([]byte(content))
+		NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	}
+
+	NewWithT(t).Expect(zw.Close()).To(Succeed())
+
+	return buf.Bytes()
+}
+
+func serve(t *testing.T, data []byte) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+func TestNewHTTPFs(t *testing.T) {
+	t.Run("should extract tar.gz archive", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := archive.NewHTTPFs(t.Context(), serve(t, tarGz(t, testFiles)))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := fsys.ReadFile("/manifests-1.0.0/base/deployment.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal("kind: Deployment\n"))
+	})
+
+	t.Run("should extract zip archive", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := archive.NewHTTPFs(t.Context(), serve(t, zipArchive(t, testFiles)))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(fsys.Exists("/manifests-1.0.0/base/kustomization.yaml")).To(BeTrue())
+	})
+
+	t.Run("should root at subdirectory", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := archive.NewHTTPFs(t.Context(), serve(t, tarGz(t, testFiles)),
+			archive.WithRoot("manifests-1.0.0"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(fsys.IsDir("/base")).To(BeTrue())
+		g.Expect(fsys.WriteFile("/base/new.yaml", []byte("x"))).ToNot(Succeed())
+	})
+
+	t.Run("should fail on missing root", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := archive.NewHTTPFs(t.Context(), serve(t, tarGz(t, testFiles)), archive.WithRoot("missing"))
+		g.Expect(err).To(MatchError(archive.ErrRootNotFound))
+	})
+
+	t.Run("should verify checksum", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data := tarGz(t, testFiles)
+		sum := sha256.Sum256(data)
+		url := serve(t, data)
+
+		_, err := archive.NewHTTPFs(t.Context(), url, archive.WithSHA256(hex.EncodeToString(sum[:])))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = archive.NewHTTPFs(t.Context(), url, archive.WithSHA256("deadbeef"))
+		g.Expect(err).To(MatchError(archive.ErrChecksumMismatch))
+	})
+
+	t.Run("should enforce download size limit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := archive.NewHTTPFs(t.Context(), serve(t, tarGz(t, testFiles)), archive.WithMaxSize(16))
+		g.Expect(err).To(MatchError(archive.ErrArchiveTooLarge))
+	})
+
+	t.Run("should enforce extracted size limit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		bomb := tarGz(t, map[string]string{"big.yaml": string(bytes.Repeat([]byte("a"), 1<<20))})
+
+		_, err := archive.NewHTTPFs(t.Context(), serve(t, bomb), archive.WithMaxExtractedSize(1024))
+		g.Expect(err).To(MatchError(archive.ErrArchiveTooLarge))
+	})
+
+	t.Run("should reject entries escaping the root", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for name, data := range map[string][]byte{
+			"tar": tarGz(t, map[string]string{"../../escape.yaml": "kind: ConfigMap\n"}),
+			"zip": zipArchive(t, map[string]string{"../../escape.yaml": "kind: ConfigMap\n"}),
+		} {
+			_, err := archive.NewHTTPFs(t.Context(), serve(t, data))
+			g.Expect(err).To(MatchError(archive.ErrExtractFailed), name)
+			g.Expect(err).To(MatchError(fs.ErrTarPathTraversal), name)
+		}
+	})
+
+	t.Run("should reject unsupported format", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := archive.NewHTTPFs(t.Context(), serve(t, []byte("not an archive")))
+		g.Expect(err).To(MatchError(archive.ErrUnsupportedArchive))
+	})
+
+	t.Run("should reject corrupt archive", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data := tarGz(t, testFiles)
+
+		_, err := archive.NewHTTPFs(t.Context(), serve(t, data[:len(data)/2]))
+		g.Expect(err).To(MatchError(archive.ErrExtractFailed))
+	})
+
+	t.Run("should fail on http error", func(t *testing.T) {
+		g := NewWithT(t)
+
+		srv := httptest.NewServer(http.NotFoundHandler())
+		t.Cleanup(srv.Close)
+
+		_, err := archive.NewHTTPFs(t.Context(), srv.URL)
+		g.Expect(err).To(MatchError(archive.ErrDownloadFailed))
+	})
+}
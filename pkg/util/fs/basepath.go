@@ -0,0 +1,270 @@
+package fs
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+)
+
+// basePathWrapper restricts any filesys.FileSystem to a base path by rewriting path
+// arguments, for filesystems that are not backed by Afero.
+//
+// Like afero.BasePathFs, paths are interpreted relative to the base path whether they are
+// absolute or not, and paths escaping it fail with fs.ErrNotExist. Paths returned by
+// CleanedAbs, Glob and Walk are translated back, so callers only see paths below "/".
+type basePathWrapper struct {
+	base filesys.FileSystem
+	root string
+
+	// resolved is root as reported by the base CleanedAbs, which may resolve symlinks.
+	resolved string
+}
+
+func newBasePathWrapper(base filesys.FileSystem, basePath string) *basePathWrapper {
+	root := filepath.Clean(basePath)
+
+	resolved := root
+	if dir, file, err := base.CleanedAbs(root); err == nil && file == "" {
+		resolved = string(dir)
+	}
+
+	return &basePathWrapper{
+		base:     base,
+		root:     root,
+		resolved: resolved,
+	}
+}
+
+// realPath maps a path of the wrapper to the corresponding path of the base filesystem.
+func (b *basePathWrapper) realPath(op string, name string) (string, error) {
+	path := filepath.Join(b.root, name)
+	if !isWithin(b.root, path) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return path, nil
+}
+
+// virtualPath maps a path of the base filesystem back to a path of the wrapper.
+func (b *basePathWrapper) virtualPath(op string, path string) (string, error) {
+	for _, root := range []string{b.root, b.resolved} {
+		if isWithin(root, path) {
+			rel, _ := filepath.Rel(root, path)
+
+			return filepath.Join(string(filepath.Separator), rel), nil
+		}
+	}
+
+	return "", &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+}
+
+func (b *basePathWrapper) Create(path string) (filesys.File, error) {
+	target, err := b.realPath("create", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.base.Create(target) //nolint:wrapcheck
+}
+
+func (b *basePathWrapper) Mkdir(path string) error {
+	target, err := b.realPath("mkdir", path)
+	if err != nil {
+		return err
+	}
+
+	return b.base.Mkdir(target) //nolint:wrapcheck
+}
+
+func (b *basePathWrapper) MkdirAll(path string) error {
+	target, err := b.realPath("mkdir", path)
+	if err != nil {
+		return err
+	}
+
+	return b.base.MkdirAll(target) //nolint:wrapcheck
+}
+
+func (b *basePathWrapper) RemoveAll(path string) error {
+	target, err := b.realPath("remove", path)
+	if err != nil {
+		return err
+	}
+
+	return b.base.RemoveAll(target) //nolint:wrapcheck
+}
+
+func (b *basePathWrapper) Open(path string) (filesys.File, error) {
+	target, err := b.realPath("open", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.base.Open(target) //nolint:wrapcheck
+}
+
+func (b *basePathWrapper) Exists(path string) bool {
+	target, err := b.realPath("stat", path)
+
+	return err == nil && b.base.Exists(target)
+}
+
+func (b *basePathWrapper) IsDir(path string) bool {
+	target, err := b.realPath("stat", path)
+
+	return err == nil && b.base.IsDir(target)
+}
+
+func (b *basePathWrapper) Stat(path string) (fs.FileInfo, error) {
+	target, err := b.realPath("stat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Stat(b.base, target)
+}
+
+func (b *basePathWrapper) ReadDir(path string) ([]string, error) {
+	target, err := b.realPath("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.base.ReadDir(target) //nolint:wrapcheck
+}
+
+func (b *basePathWrapper) ReadFile(path string) ([]byte, error) {
+	target, err := b.realPath("read", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.base.ReadFile(target) //nolint:wrapcheck
+}
+
+func (b *basePathWrapper) WriteFile(path string, data []byte) error {
+	target, err := b.realPath("write", path)
+	if err != nil {
+		return err
+	}
+
+	return b.base.WriteFile(target, data) //nolint:wrapcheck
+}
+
+// Glob matches pattern below the base path. Glob metacharacters in the base path itself
+// are escaped so they match literally.
+func (b *basePathWrapper) Glob(pattern string) ([]string, error) {
+	if _, err := b.realPath("glob", pattern); err != nil {
+		return nil, err
+	}
+
+	matches, err := b.base.Glob(filepath.Join(escapeGlob(b.root), pattern))
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	result := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		virtual, err := b.virtualPath("glob", match)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, virtual)
+	}
+
+	return result, nil
+}
+
+func (b *basePathWrapper) Walk(path string, walkFn filepath.WalkFunc) error {
+	target, err := b.realPath("walk", path)
+	if err != nil {
+		return err
+	}
+
+	return b.base.Walk(target, func(p string, info fs.FileInfo, err error) error { //nolint:wrapcheck
+		virtual, verr := b.virtualPath("walk", p)
+		if verr != nil {
+			return verr
+		}
+
+		return walkFn(virtual, info, err)
+	})
+}
+
+func (b *basePathWrapper) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
+	if path == "" {
+		path = "."
+	}
+
+	target, err := b.realPath("abs", path)
+	if err != nil {
+		return "", "", err
+	}
+
+	dir, file, err := b.base.CleanedAbs(target)
+	if err != nil {
+		return "", "", err //nolint:wrapcheck
+	}
+
+	virtual, err := b.virtualPath("abs", string(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	return filesys.ConfirmedDir(virtual), file, nil
+}
+
+// ConfigureSymlinks returns a wrapper restricting the base filesystem, configured with the
+// given symlink policy and root (see adapter.ConfigureSymlinks), to the same base path. The
+// root is a path of the wrapper, mapped to the corresponding path of the base filesystem.
+func (b *basePathWrapper) ConfigureSymlinks(policy adapter.SymlinkPolicy, root string) (filesys.FileSystem, error) {
+	if root != "" {
+		realRoot, err := b.realPath("configure", root)
+		if err != nil {
+			return nil, err
+		}
+
+		root = realRoot
+	}
+
+	base, err := adapter.ConfigureSymlinks(b.base, policy, root)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &basePathWrapper{
+		base:     base,
+		root:     b.root,
+		resolved: b.resolved,
+	}, nil
+}
+
+// isWithin reports whether path is root or below it.
+func isWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// escapeGlob escapes the glob metacharacters of a literal path.
+func escapeGlob(path string) string {
+	var sb strings.Builder
+
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) && filepath.Separator != '\\' {
+			sb.WriteRune('\\')
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+var _ filesys.FileSystem = (*basePathWrapper)(nil)
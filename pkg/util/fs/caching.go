@@ -0,0 +1,353 @@
+package fs
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+)
+
+// CachingOption is a functional option for configuring a caching filesystem.
+type CachingOption func(*CachingFs)
+
+// WithCacheTTL expires cached results after the given duration, so that changes to the
+// base filesystem are eventually picked up without calling Invalidate.
+// Default: no expiry.
+func WithCacheTTL(ttl time.Duration) CachingOption {
+	return func(c *CachingFs) {
+		c.ttl = ttl
+	}
+}
+
+// WithCacheMaxSize bounds the total size, in bytes, of the cached file contents. When the
+// bound is reached, the least recently used entries are evicted; files larger than the
+// bound are never cached. Directory listings and existence checks are not counted.
+// Default: unbounded.
+func WithCacheMaxSize(size int64) CachingOption {
+	return func(c *CachingFs) {
+		c.maxSize = size
+	}
+}
+
+// cacheOp identifies the memoized operation of a cache entry.
+type cacheOp int
+
+const (
+	opReadFile cacheOp = iota
+	opReadDir
+	opIsDir
+	opExists
+)
+
+type cacheKey struct {
+	op   cacheOp
+	path string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	data    []byte
+	names   []string
+	flag    bool
+	expires time.Time
+}
+
+// CachingFs memoizes the reads of a filesys.FileSystem: ReadFile, ReadDir, IsDir and
+// Exists results are served from memory after the first call. It is meant for slow
+// filesystems (NFS, git, OCI) read repeatedly, e.g. when many overlays share a base.
+//
+// Failed reads are not cached. Writes made through CachingFs invalidate the affected
+// paths; changes made to the base filesystem by other means require Invalidate, or a
+// TTL set with WithCacheTTL. Open, Stat, Glob, Walk and CleanedAbs are not cached. Paths are
+// cached as given, cleaned but not made absolute.
+//
+// CachingFs is safe for concurrent use.
+type CachingFs struct {
+	base    filesys.FileSystem
+	ttl     time.Duration
+	maxSize int64
+
+	*cacheStore
+}
+
+// cacheStore holds the cached results, shared by the copies made by ConfigureSymlinks.
+//
+// The generation is bumped by every invalidation: reads from the base filesystem are not
+// made under the lock, so a result is only stored if no invalidation happened since the
+// read started, as it may predate a concurrent write.
+type cacheStore struct {
+	mu         sync.Mutex
+	entries    map[cacheKey]*list.Element
+	lru        *list.List
+	size       int64
+	generation uint64
+}
+
+// NewCachingFs wraps base with a read cache. The result can be passed to
+// kustomize.WithFileSystem like any other filesystem.
+//
+// Example:
+//
+//	cached := fs.NewCachingFs(gitFs,
+//	    fs.WithCacheTTL(10*time.Minute),
+//	    fs.WithCacheMaxSize(64<<20),
+//	)
+func NewCachingFs(base filesys.FileSystem, opts ...CachingOption) *CachingFs {
+	c := &CachingFs{
+		base: base,
+		cacheStore: &cacheStore{
+			entries: make(map[cacheKey]*list.Element),
+			lru:     list.New(),
+		},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Invalidate drops the cached results for path and everything below it, along with the
+// listing of its parent directory.
+func (c *CachingFs) Invalidate(path string) {
+	path = filepath.Clean(path)
+	parent := filepath.Dir(path)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	for key, elem := range c.entries {
+		if isWithin(path, key.path) || (key.op == opReadDir && key.path == parent) {
+			c.remove(elem)
+		}
+	}
+}
+
+// get returns the live entry for key, marking it as recently used. On a miss, it returns
+// the current generation, to be passed to put along with the result read from the base.
+func (c *CachingFs) get(key cacheKey) (*cacheEntry, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, c.generation, false
+	}
+
+	entry := elem.Value.(*cacheEntry) //nolint:forcetypeassert
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(elem)
+
+		return nil, c.generation, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return entry, c.generation, true
+}
+
+// put stores entry, evicting the least recently used entries beyond the size bound. The
+// entry is dropped if the cache was invalidated since generation, returned by get.
+func (c *CachingFs) put(entry *cacheEntry, generation uint64) {
+	size := int64(len(entry.data))
+	if c.maxSize > 0 && size > c.maxSize {
+		return
+	}
+
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += size
+
+	for c.maxSize > 0 && c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops elem from the cache; the caller must hold mu.
+func (c *CachingFs) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry) //nolint:forcetypeassert
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.data))
+}
+
+// ReadFile returns the cached contents of path, reading them from the base filesystem on
+// a miss.
+func (c *CachingFs) ReadFile(path string) ([]byte, error) {
+	key := cacheKey{op: opReadFile, path: filepath.Clean(path)}
+	entry, generation, ok := c.get(key)
+	if ok {
+		return slices.Clone(entry.data), nil
+	}
+
+	data, err := c.base.ReadFile(path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.put(&cacheEntry{key: key, data: slices.Clone(data)}, generation)
+
+	return data, nil
+}
+
+// ReadDir returns the cached entry names of path, listing them from the base filesystem
+// on a miss.
+func (c *CachingFs) ReadDir(path string) ([]string, error) {
+	key := cacheKey{op: opReadDir, path: filepath.Clean(path)}
+	entry, generation, ok := c.get(key)
+	if ok {
+		return slices.Clone(entry.names), nil
+	}
+
+	names, err := c.base.ReadDir(path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.put(&cacheEntry{key: key, names: slices.Clone(names)}, generation)
+
+	return names, nil
+}
+
+// IsDir returns the cached result of the base IsDir.
+func (c *CachingFs) IsDir(path string) bool {
+	return c.cachedFlag(cacheKey{op: opIsDir, path: filepath.Clean(path)}, c.base.IsDir)
+}
+
+// Exists returns the cached result of the base Exists.
+func (c *CachingFs) Exists(path string) bool {
+	return c.cachedFlag(cacheKey{op: opExists, path: filepath.Clean(path)}, c.base.Exists)
+}
+
+func (c *CachingFs) cachedFlag(key cacheKey, fn func(string) bool) bool {
+	entry, generation, ok := c.get(key)
+	if ok {
+		return entry.flag
+	}
+
+	flag := fn(key.path)
+	c.put(&cacheEntry{key: key, flag: flag}, generation)
+
+	return flag
+}
+
+// Create creates a file in the base filesystem. Data written through the returned file
+// is not tracked: reads of path before the file is closed may cache partial contents.
+func (c *CachingFs) Create(path string) (filesys.File, error) {
+	c.Invalidate(path)
+
+	return c.base.Create(path) //nolint:wrapcheck
+}
+
+func (c *CachingFs) Mkdir(path string) error {
+	defer c.Invalidate(path)
+
+	return c.base.Mkdir(path) //nolint:wrapcheck
+}
+
+// MkdirAll creates path and its parents, invalidating the cached results of all of them.
+func (c *CachingFs) MkdirAll(path string) error {
+	defer func() {
+		path = filepath.Clean(path)
+		c.Invalidate(path)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+			for _, op := range []cacheOp{opReadFile, opReadDir, opIsDir, opExists} {
+				if elem, ok := c.entries[cacheKey{op: op, path: dir}]; ok {
+					c.remove(elem)
+				}
+			}
+
+			if dir == filepath.Dir(dir) {
+				return
+			}
+		}
+	}()
+
+	return c.base.MkdirAll(path) //nolint:wrapcheck
+}
+
+func (c *CachingFs) RemoveAll(path string) error {
+	defer c.Invalidate(path)
+
+	return c.base.RemoveAll(path) //nolint:wrapcheck
+}
+
+func (c *CachingFs) WriteFile(path string, data []byte) error {
+	defer c.Invalidate(path)
+
+	return c.base.WriteFile(path, data) //nolint:wrapcheck
+}
+
+func (c *CachingFs) Open(path string) (filesys.File, error) {
+	return c.base.Open(path) //nolint:wrapcheck
+}
+
+// Stat returns the FileInfo of path in the base filesystem. It is never cached, so that
+// modification times reflect the base.
+func (c *CachingFs) Stat(path string) (os.FileInfo, error) {
+	return Stat(c.base, path)
+}
+
+func (c *CachingFs) Glob(pattern string) ([]string, error) {
+	return c.base.Glob(pattern) //nolint:wrapcheck
+}
+
+func (c *CachingFs) Walk(path string, walkFn filepath.WalkFunc) error {
+	return c.base.Walk(path, walkFn) //nolint:wrapcheck
+}
+
+func (c *CachingFs) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
+	return c.base.CleanedAbs(path) //nolint:wrapcheck
+}
+
+// ConfigureSymlinks returns a copy of the caching filesystem whose base uses the given
+// symlink policy and root (see adapter.ConfigureSymlinks). The copy shares the cache: only
+// CleanedAbs depends on the symlink policy, and it is never cached.
+func (c *CachingFs) ConfigureSymlinks(policy adapter.SymlinkPolicy, root string) (filesys.FileSystem, error) {
+	base, err := adapter.ConfigureSymlinks(c.base, policy, root)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &CachingFs{
+		base:       base,
+		ttl:        c.ttl,
+		maxSize:    c.maxSize,
+		cacheStore: c.cacheStore,
+	}, nil
+}
+
+// Unwrap returns a read-only afero.Fs view of the cached filesystem, so that it can be
+// used as base of union.NewFs and NewBasePathFs.
+func (c *CachingFs) Unwrap() afero.Fs {
+	return afero.NewReadOnlyFs(&fileSystemFs{base: c})
+}
+
+var _ filesys.FileSystem = (*CachingFs)(nil)
@@ -2,7 +2,9 @@ package fs
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 
 	"github.com/spf13/afero"
@@ -25,38 +27,72 @@ func NewMemoryFs() filesys.FileSystem {
 
 // NewReadOnlyFs creates a read-only wrapper around the given filesys.FileSystem.
 // All write operations (Create, WriteFile, Mkdir, MkdirAll, RemoveAll) will return errors.
+//
+// The result can be used as base of union.NewFs and NewBasePathFs even when base is not
+// Afero-backed, e.g. to layer overrides onto a read-only filesystem.
 func NewReadOnlyFs(base filesys.FileSystem) filesys.FileSystem {
 	// If base is an Afero adapter, we can wrap its underlying Fs
-	if unwrapper, ok := base.(interface{ Unwrap() afero.Fs }); ok {
-		return adapter.New(afero.NewReadOnlyFs(unwrapper.Unwrap()))
+	if a, ok := base.(*adapter.Adapter); ok {
+		return adapter.New(afero.NewReadOnlyFs(a.Unwrap()))
 	}
 
-	// Otherwise, create a new memory filesystem and copy files on demand
-	// This is a simplified approach - for full read-only support of non-Afero filesystems,
-	// you'd need a more sophisticated wrapper
+	// Otherwise, forward reads to base directly, so that its CleanedAbs keeps resolving
+	// paths (e.g. a union through its base); Unwrap exposes it to Afero-based wrappers
 	return &readOnlyWrapper{base: base}
 }
 
+// Stat returns the FileInfo of path in fsys, e.g. for mtime-based cache invalidation.
+// Adapters, including union filesystems, implement it as a Stat method, and the read-only,
+// base path and caching wrappers forward it to their base. For other filesystems the
+// FileInfo of the opened file is used, or one without modification time is synthesized.
+func Stat(fsys filesys.FileSystem, path string) (os.FileInfo, error) {
+	return (&fileSystemFs{base: fsys}).Stat(path)
+}
+
+// ReadDirInfo returns the FileInfo of the entries of the directory path in fsys, sorted by
+// name, for tools inspecting source trees: ReadDir of filesys.FileSystem only returns names.
+// Afero-backed filesystems list the directory with afero.ReadDir; for others, every entry is
+// passed to Stat, which may synthesize FileInfos without modification time.
+func ReadDirInfo(fsys filesys.FileSystem, path string) ([]os.FileInfo, error) {
+	var aferoFs afero.Fs = &fileSystemFs{base: fsys}
+	if unwrapper, ok := fsys.(interface{ Unwrap() afero.Fs }); ok {
+		aferoFs = unwrapper.Unwrap()
+	}
+
+	infos, err := afero.ReadDir(aferoFs, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", path, err)
+	}
+
+	return infos, nil
+}
+
 // NewFromIOFS creates a filesys.FileSystem from an fs.FS (e.g., embed.FS).
 // The root parameter specifies the root directory within the fs.FS to use as the base.
 // If root is empty, the fs.FS root is used.
 //
+// Paths are resolved from root whether they are absolute or not, so "/app" and "app" both
+// refer to the directory app below root.
+//
 // Note: The resulting filesystem is read-only for the io.FS contents.
 // Write operations will fail unless you layer it with a union filesystem.
 func NewFromIOFS(fsys fs.FS, root string) (filesys.FileSystem, error) {
-	// Use Afero's IOFS adapter to bridge io.FS to afero.Fs
-	baseFs := afero.FromIOFS{FS: fsys}
-
-	// If a root is specified, create a BasePathFs
-	var resultFs afero.Fs = baseFs
-	if root != "" {
-		// Clean the root path
-		root = filepath.Clean(root)
-		resultFs = afero.NewBasePathFs(baseFs, root)
+	// If a root is specified, descend into it
+	if root = ioFSPath(root); root != "." {
+		sub, err := fs.Sub(fsys, root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to use %s as root: %w", root, err)
+		}
+
+		fsys = sub
 	}
 
+	// Use Afero's IOFS adapter to bridge io.FS to afero.Fs, mapping the absolute paths
+	// kustomize uses to the unrooted paths io.FS expects
+	baseFs := afero.FromIOFS{FS: rootedFS{fsys: fsys}}
+
 	// Wrap in read-only since io.FS is inherently read-only
-	readOnlyFs := afero.NewReadOnlyFs(resultFs)
+	readOnlyFs := afero.NewReadOnlyFs(baseFs)
 
 	return adapter.New(readOnlyFs), nil
 }
@@ -64,13 +100,17 @@ func NewFromIOFS(fsys fs.FS, root string) (filesys.FileSystem, error) {
 // NewBasePathFs creates a filesys.FileSystem that restricts operations to a base path.
 // All file operations are performed relative to the given base path.
 // This is useful for sandboxing operations to a specific directory.
+//
+// Any filesys.FileSystem can be used as base: adapters are wrapped with afero.NewBasePathFs,
+// others with a wrapper rewriting every path argument. Either way,
+// paths escaping the base path fail with fs.ErrNotExist.
 func NewBasePathFs(base filesys.FileSystem, basePath string) (filesys.FileSystem, error) {
 	// If base is an Afero adapter, wrap its underlying Fs
-	if unwrapper, ok := base.(interface{ Unwrap() afero.Fs }); ok {
-		return adapter.New(afero.NewBasePathFs(unwrapper.Unwrap(), basePath)), nil
+	if a, ok := base.(*adapter.Adapter); ok {
+		return adapter.New(afero.NewBasePathFs(a.Unwrap(), basePath)), nil
 	}
 
-	return nil, errors.New("base filesystem must be created with fs package functions") //nolint:err113
+	return newBasePathWrapper(base, basePath), nil
 }
 
 // readOnlyWrapper provides a simple read-only wrapper for non-Afero filesystems.
@@ -110,6 +150,10 @@ func (r *readOnlyWrapper) IsDir(path string) bool {
 	return r.base.IsDir(path)
 }
 
+func (r *readOnlyWrapper) Stat(path string) (os.FileInfo, error) {
+	return Stat(r.base, path)
+}
+
 func (r *readOnlyWrapper) ReadDir(path string) ([]string, error) {
 	return r.base.ReadDir(path) //nolint:wrapcheck
 }
@@ -130,4 +174,21 @@ func (r *readOnlyWrapper) CleanedAbs(path string) (filesys.ConfirmedDir, string,
 	return r.base.CleanedAbs(path) //nolint:wrapcheck
 }
 
+// ConfigureSymlinks returns a read-only wrapper around the base filesystem configured with
+// the given symlink policy and root (see adapter.ConfigureSymlinks).
+func (r *readOnlyWrapper) ConfigureSymlinks(policy adapter.SymlinkPolicy, root string) (filesys.FileSystem, error) {
+	base, err := adapter.ConfigureSymlinks(r.base, policy, root)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &readOnlyWrapper{base: base}, nil
+}
+
+// Unwrap returns a read-only afero.Fs view of the base filesystem, so that the wrapper can
+// be composed like the filesystems created with the Afero adapter.
+func (r *readOnlyWrapper) Unwrap() afero.Fs {
+	return afero.NewReadOnlyFs(&fileSystemFs{base: r.base})
+}
+
 var _ filesys.FileSystem = (*readOnlyWrapper)(nil)
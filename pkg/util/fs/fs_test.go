@@ -1,11 +1,27 @@
 package fs_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	iofs "io/fs"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 
 	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/union"
 
 	. "github.com/onsi/gomega"
 )
@@ -116,6 +132,56 @@ func TestNewFromIOFS_EmptyRoot(t *testing.T) {
 	g.Expect(data).NotTo(BeEmpty())
 }
 
+func TestNewFromIOFS_Paths(t *testing.T) {
+	testFS := fstest.MapFS{
+		"manifests/app/kustomization.yaml": &fstest.MapFile{Data: []byte("resources: []\n")},
+	}
+
+	for _, root := range []string{"", ".", "/"} {
+		t.Run("should resolve absolute paths with root "+strconv.Quote(root), func(t *testing.T) {
+			g := NewWithT(t)
+
+			fsys, err := fs.NewFromIOFS(testFS, root)
+			g.Expect(err).To(Succeed())
+
+			g.Expect(fsys.Exists("/manifests/app/kustomization.yaml")).To(BeTrue())
+			g.Expect(fsys.Exists("manifests/app/kustomization.yaml")).To(BeTrue())
+			g.Expect(fsys.IsDir("/")).To(BeTrue())
+
+			dir, file, err := fsys.CleanedAbs("/manifests/app/kustomization.yaml")
+			g.Expect(err).To(Succeed())
+			g.Expect(string(dir)).To(Equal("/manifests/app"))
+			g.Expect(file).To(Equal("kustomization.yaml"))
+		})
+	}
+
+	for _, root := range []string{"manifests", "/manifests", "./manifests/"} {
+		t.Run("should resolve paths below root "+strconv.Quote(root), func(t *testing.T) {
+			g := NewWithT(t)
+
+			fsys, err := fs.NewFromIOFS(testFS, root)
+			g.Expect(err).To(Succeed())
+
+			g.Expect(fsys.Exists("/app/kustomization.yaml")).To(BeTrue())
+			g.Expect(fsys.Exists("/manifests/app/kustomization.yaml")).To(BeFalse())
+
+			entries, err := fsys.ReadDir("/")
+			g.Expect(err).To(Succeed())
+			g.Expect(entries).To(ConsistOf("app"))
+		})
+	}
+
+	t.Run("should not escape the root", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := fs.NewFromIOFS(testFS, "manifests/app")
+		g.Expect(err).To(Succeed())
+
+		g.Expect(fsys.Exists("/../../manifests/app/kustomization.yaml")).To(BeFalse())
+		g.Expect(fsys.Exists("/../kustomization.yaml")).To(BeTrue())
+	})
+}
+
 func TestNewBasePathFs(t *testing.T) {
 	g := NewWithT(t)
 
@@ -134,6 +200,484 @@ func TestNewBasePathFs(t *testing.T) {
 	g.Expect(string(data)).To(Equal("content"))
 }
 
+func TestNewBasePathFsNonAfero(t *testing.T) {
+	newScoped := func(t *testing.T) (filesys.FileSystem, string) {
+		t.Helper()
+
+		dir := t.TempDir()
+		g := NewWithT(t)
+		g.Expect(os.MkdirAll(filepath.Join(dir, "root", "app", "base"), 0750)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "root", "app", "kustomization.yaml"), []byte("resources: []\n"), 0600)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "root", "app", "base", "cm.yaml"), []byte("kind: ConfigMap\n"), 0600)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0600)).To(Succeed())
+
+		// kustomize's on-disk filesystem is not Afero-backed
+		scoped, err := fs.NewBasePathFs(filesys.MakeFsOnDisk(), filepath.Join(dir, "root"))
+		g.Expect(err).To(Succeed())
+
+		return scoped, dir
+	}
+
+	t.Run("should read and write relative to the base path", func(t *testing.T) {
+		g := NewWithT(t)
+		scoped, dir := newScoped(t)
+
+		data, err := scoped.ReadFile("/app/base/cm.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("kind: ConfigMap\n"))
+		g.Expect(scoped.IsDir("app/base")).To(BeTrue())
+
+		g.Expect(scoped.WriteFile("/app/new.yaml", []byte("new"))).To(Succeed())
+		written, err := os.ReadFile(filepath.Join(dir, "root", "app", "new.yaml"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(written)).To(Equal("new"))
+	})
+
+	t.Run("should refuse paths escaping the base path", func(t *testing.T) {
+		g := NewWithT(t)
+		scoped, _ := newScoped(t)
+
+		_, err := scoped.ReadFile("/../secret.txt")
+		g.Expect(err).To(MatchError(iofs.ErrNotExist))
+		g.Expect(scoped.Exists("../secret.txt")).To(BeFalse())
+	})
+
+	t.Run("should translate CleanedAbs results", func(t *testing.T) {
+		g := NewWithT(t)
+		scoped, _ := newScoped(t)
+
+		dir, file, err := scoped.CleanedAbs("/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(dir)).To(Equal("/app"))
+		g.Expect(file).To(Equal("kustomization.yaml"))
+
+		dir, file, err = scoped.CleanedAbs("/")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(dir)).To(Equal("/"))
+		g.Expect(file).To(BeEmpty())
+	})
+
+	t.Run("should translate Glob and Walk paths", func(t *testing.T) {
+		g := NewWithT(t)
+		scoped, _ := newScoped(t)
+
+		matches, err := scoped.Glob("/app/*.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(ConsistOf("/app/kustomization.yaml"))
+
+		var walked []string
+		err = scoped.Walk("/app", func(path string, _ iofs.FileInfo, err error) error {
+			walked = append(walked, path)
+
+			return err
+		})
+		g.Expect(err).To(Succeed())
+		g.Expect(walked).To(ConsistOf("/app", "/app/base", "/app/base/cm.yaml", "/app/kustomization.yaml"))
+	})
+
+	t.Run("should sandbox other non-Afero wrappers", func(t *testing.T) {
+		g := NewWithT(t)
+		_, dir := newScoped(t)
+
+		readOnly := fs.NewReadOnlyFs(filesys.MakeFsOnDisk())
+		scoped, err := fs.NewBasePathFs(readOnly, filepath.Join(dir, "root"))
+		g.Expect(err).To(Succeed())
+
+		g.Expect(scoped.Exists("/app/kustomization.yaml")).To(BeTrue())
+		g.Expect(scoped.WriteFile("/app/new.yaml", []byte("new"))).ToNot(Succeed())
+	})
+}
+
+func TestNewReadOnlyFsNonAfero(t *testing.T) {
+	newBase := func(g *WithT) filesys.FileSystem {
+		base := filesys.MakeFsInMemory()
+		g.Expect(base.MkdirAll("/root/app")).To(Succeed())
+		g.Expect(base.WriteFile("/root/app/kustomization.yaml", []byte("resources: []"))).To(Succeed())
+
+		return base
+	}
+
+	t.Run("should reject writes", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+
+		readOnly := fs.NewReadOnlyFs(base)
+		g.Expect(readOnly.WriteFile("/root/app/new.yaml", []byte("new"))).ToNot(Succeed())
+		g.Expect(readOnly.MkdirAll("/root/other")).ToNot(Succeed())
+		g.Expect(base.Exists("/root/app/new.yaml")).To(BeFalse())
+	})
+
+	t.Run("should expose an Afero view for composition", func(t *testing.T) {
+		g := NewWithT(t)
+
+		readOnly := fs.NewReadOnlyFs(newBase(g))
+
+		unwrapper, ok := readOnly.(interface{ Unwrap() afero.Fs })
+		g.Expect(ok).To(BeTrue())
+
+		data, err := afero.ReadFile(unwrapper.Unwrap(), "/root/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("resources: []"))
+
+		err = afero.WriteFile(unwrapper.Unwrap(), "/root/app/new.yaml", []byte("new"), 0o600)
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("should be usable as base of NewBasePathFs", func(t *testing.T) {
+		g := NewWithT(t)
+
+		scoped, err := fs.NewBasePathFs(fs.NewReadOnlyFs(newBase(g)), "/root")
+		g.Expect(err).To(Succeed())
+
+		data, err := scoped.ReadFile("/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("resources: []"))
+		g.Expect(scoped.IsDir("/app")).To(BeTrue())
+		g.Expect(scoped.WriteFile("/app/new.yaml", []byte("new"))).ToNot(Succeed())
+	})
+}
+
+// countingFs counts the ReadFile, ReadDir, IsDir and Exists calls reaching a filesystem.
+type countingFs struct {
+	filesys.FileSystem
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newCountingFs(base filesys.FileSystem) *countingFs {
+	return &countingFs{FileSystem: base, calls: make(map[string]int)}
+}
+
+func (c *countingFs) count(op string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls[op]++
+}
+
+func (c *countingFs) Calls(op string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[op]
+}
+
+func (c *countingFs) ReadFile(path string) ([]byte, error) {
+	c.count("ReadFile")
+
+	return c.FileSystem.ReadFile(path) //nolint:wrapcheck
+}
+
+func (c *countingFs) ReadDir(path string) ([]string, error) {
+	c.count("ReadDir")
+
+	return c.FileSystem.ReadDir(path) //nolint:wrapcheck
+}
+
+func (c *countingFs) IsDir(path string) bool {
+	c.count("IsDir")
+
+	return c.FileSystem.IsDir(path)
+}
+
+func (c *countingFs) Exists(path string) bool {
+	c.count("Exists")
+
+	return c.FileSystem.Exists(path)
+}
+
+// racingFs runs a hook after reading from a filesystem and before returning the result,
+// simulating a write racing the read.
+type racingFs struct {
+	filesys.FileSystem
+
+	afterRead func()
+}
+
+func (r *racingFs) ReadFile(path string) ([]byte, error) {
+	data, err := r.FileSystem.ReadFile(path)
+	r.afterRead()
+
+	return data, err //nolint:wrapcheck
+}
+
+func (r *racingFs) Exists(path string) bool {
+	exists := r.FileSystem.Exists(path)
+	r.afterRead()
+
+	return exists
+}
+
+func TestNewCachingFs(t *testing.T) {
+	newBase := func(g *WithT) *countingFs {
+		base := filesys.MakeFsInMemory()
+		g.Expect(base.MkdirAll("/root/app")).To(Succeed())
+		g.Expect(base.WriteFile("/root/app/kustomization.yaml", []byte("resources: []"))).To(Succeed())
+		g.Expect(base.WriteFile("/root/app/large.yaml", make([]byte, 64))).To(Succeed())
+
+		return newCountingFs(base)
+	}
+
+	t.Run("should serve repeated reads from the cache", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+		cached := fs.NewCachingFs(base)
+
+		for range 3 {
+			data, err := cached.ReadFile("/root/app/kustomization.yaml")
+			g.Expect(err).To(Succeed())
+			g.Expect(string(data)).To(Equal("resources: []"))
+
+			names, err := cached.ReadDir("/root/app")
+			g.Expect(err).To(Succeed())
+			g.Expect(names).To(ConsistOf("kustomization.yaml", "large.yaml"))
+
+			g.Expect(cached.IsDir("/root/app")).To(BeTrue())
+			g.Expect(cached.Exists("/root/app/missing.yaml")).To(BeFalse())
+		}
+
+		for _, op := range []string{"ReadFile", "ReadDir", "IsDir", "Exists"} {
+			g.Expect(base.Calls(op)).To(Equal(1), op)
+		}
+	})
+
+	t.Run("should not cache failed reads", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+		cached := fs.NewCachingFs(base)
+
+		_, err := cached.ReadFile("/root/app/missing.yaml")
+		g.Expect(err).To(HaveOccurred())
+
+		g.Expect(base.WriteFile("/root/app/missing.yaml", []byte("found"))).To(Succeed())
+
+		data, err := cached.ReadFile("/root/app/missing.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("found"))
+	})
+
+	t.Run("should reload invalidated paths", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+		cached := fs.NewCachingFs(base)
+
+		_, err := cached.ReadFile("/root/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		_, err = cached.ReadDir("/root/app")
+		g.Expect(err).To(Succeed())
+
+		g.Expect(base.WriteFile("/root/app/kustomization.yaml", []byte("changed"))).To(Succeed())
+		g.Expect(base.WriteFile("/root/app/new.yaml", nil)).To(Succeed())
+
+		data, err := cached.ReadFile("/root/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("resources: []"))
+
+		cached.Invalidate("/root/app")
+
+		data, err = cached.ReadFile("/root/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("changed"))
+
+		names, err := cached.ReadDir("/root/app")
+		g.Expect(err).To(Succeed())
+		g.Expect(names).To(ContainElement("new.yaml"))
+	})
+
+	t.Run("should invalidate paths written through the cache", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+		cached := fs.NewCachingFs(base)
+
+		g.Expect(cached.Exists("/root/other/file.yaml")).To(BeFalse())
+		g.Expect(cached.Exists("/root/other")).To(BeFalse())
+		_, err := cached.ReadDir("/root")
+		g.Expect(err).To(Succeed())
+
+		g.Expect(cached.MkdirAll("/root/other")).To(Succeed())
+		g.Expect(cached.WriteFile("/root/other/file.yaml", []byte("new"))).To(Succeed())
+
+		g.Expect(cached.Exists("/root/other/file.yaml")).To(BeTrue())
+		g.Expect(cached.IsDir("/root/other")).To(BeTrue())
+
+		names, err := cached.ReadDir("/root")
+		g.Expect(err).To(Succeed())
+		g.Expect(names).To(ContainElement("other"))
+	})
+
+	t.Run("should not cache reads racing an invalidation", func(t *testing.T) {
+		g := NewWithT(t)
+		base := filesys.MakeFsInMemory()
+		g.Expect(base.WriteFile("/root/app.yaml", []byte("old"))).To(Succeed())
+
+		racing := &racingFs{FileSystem: base, afterRead: func() {}}
+		cached := fs.NewCachingFs(racing)
+
+		racing.afterRead = func() {
+			racing.afterRead = func() {}
+			g.Expect(cached.WriteFile("/root/app.yaml", []byte("new"))).To(Succeed())
+		}
+
+		data, err := cached.ReadFile("/root/app.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("old"))
+
+		data, err = cached.ReadFile("/root/app.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("new"))
+
+		racing.afterRead = func() {
+			racing.afterRead = func() {}
+			g.Expect(base.WriteFile("/root/other.yaml", nil)).To(Succeed())
+			cached.Invalidate("/root/other.yaml")
+		}
+
+		g.Expect(cached.Exists("/root/other.yaml")).To(BeFalse())
+		g.Expect(cached.Exists("/root/other.yaml")).To(BeTrue())
+	})
+
+	t.Run("should expire entries after the TTL", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+		cached := fs.NewCachingFs(base, fs.WithCacheTTL(time.Millisecond))
+
+		_, err := cached.ReadFile("/root/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+
+		time.Sleep(5 * time.Millisecond)
+
+		_, err = cached.ReadFile("/root/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(base.Calls("ReadFile")).To(Equal(2))
+	})
+
+	t.Run("should evict least recently used contents beyond the size bound", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+		cached := fs.NewCachingFs(base, fs.WithCacheMaxSize(70))
+
+		read := func(path string) {
+			_, err := cached.ReadFile(path)
+			g.Expect(err).To(Succeed())
+		}
+
+		// 13 + 64 bytes exceed the bound, evicting kustomization.yaml
+		read("/root/app/kustomization.yaml")
+		read("/root/app/large.yaml")
+		read("/root/app/large.yaml")
+		g.Expect(base.Calls("ReadFile")).To(Equal(2))
+
+		read("/root/app/kustomization.yaml")
+		g.Expect(base.Calls("ReadFile")).To(Equal(3))
+	})
+
+	t.Run("should be safe for concurrent reads", func(t *testing.T) {
+		g := NewWithT(t)
+		cached := fs.NewCachingFs(newBase(g), fs.WithCacheMaxSize(70))
+
+		var wg sync.WaitGroup
+		for i := range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				path := []string{"/root/app/kustomization.yaml", "/root/app/large.yaml"}[i%2]
+				for range 100 {
+					if _, err := cached.ReadFile(path); err != nil {
+						t.Error(err)
+					}
+
+					cached.Exists(path)
+				}
+			}()
+		}
+
+		wg.Wait()
+	})
+
+	t.Run("should be usable as base of union filesystems", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+
+		unionFs, err := union.NewFs(fs.NewCachingFs(base),
+			union.WithOverride("/root/app/values.yaml", []byte("values")),
+		)
+		g.Expect(err).To(Succeed())
+
+		for range 2 {
+			data, err := unionFs.ReadFile("/root/app/kustomization.yaml")
+			g.Expect(err).To(Succeed())
+			g.Expect(string(data)).To(Equal("resources: []"))
+		}
+
+		g.Expect(base.Calls("ReadFile")).To(Equal(1))
+		g.Expect(unionFs.Exists("/root/app/values.yaml")).To(BeTrue())
+	})
+}
+
+func TestConfigureSymlinks(t *testing.T) {
+	t.Run("should deny escaping symlinks through the wrappers", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges on windows")
+		}
+
+		g := NewWithT(t)
+		dir := t.TempDir()
+
+		for _, sub := range []string{"root/tree/app", "root/tree/base", "root/outside"} {
+			g.Expect(os.MkdirAll(filepath.Join(dir, sub), 0o755)).To(Succeed())
+		}
+
+		g.Expect(os.Symlink(filepath.Join("..", "base"), filepath.Join(dir, "root", "tree", "app", "sibling"))).To(Succeed())
+		g.Expect(os.Symlink(filepath.Join("..", "..", "outside"), filepath.Join(dir, "root", "tree", "app", "escape"))).To(Succeed())
+
+		readOnly := fs.NewReadOnlyFs(fs.NewCachingFs(fs.NewFsOnDisk()))
+		scoped, err := fs.NewBasePathFs(readOnly, filepath.Join(dir, "root"))
+		g.Expect(err).To(Succeed())
+
+		confined, err := adapter.ConfigureSymlinks(scoped, adapter.SymlinkDeny, "/tree")
+		g.Expect(err).To(Succeed())
+
+		resolved, _, err := confined.CleanedAbs("/tree/app/sibling")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(resolved)).To(Equal("/tree/base"))
+
+		_, _, err = confined.CleanedAbs("/tree/app/escape")
+		g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape))
+
+		_, _, err = scoped.CleanedAbs("/tree/app/escape")
+		g.Expect(err).To(Succeed())
+	})
+
+	t.Run("should share the cache of the caching filesystem", func(t *testing.T) {
+		g := NewWithT(t)
+		base := fs.NewMemoryFs()
+		g.Expect(base.WriteFile("/app/kustomization.yaml", []byte("resources: []"))).To(Succeed())
+
+		cached := fs.NewCachingFs(base)
+		_, err := cached.ReadFile("/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+
+		confined, err := adapter.ConfigureSymlinks(cached, adapter.SymlinkDeny, "")
+		g.Expect(err).To(Succeed())
+
+		// changed behind the back of the cache, so only cached contents are stale
+		g.Expect(base.WriteFile("/app/kustomization.yaml", []byte("resources: [a.yaml]"))).To(Succeed())
+
+		data, err := confined.ReadFile("/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("resources: []"))
+	})
+
+	t.Run("should fail for wrappers of filesystems not backed by an adapter", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := adapter.ConfigureSymlinks(fs.NewCachingFs(filesys.MakeFsInMemory()), adapter.SymlinkDeny, "")
+		g.Expect(err).To(MatchError(adapter.ErrSymlinksNotConfigurable))
+	})
+}
+
 // Ensure the constructors return filesys.FileSystem.
 func TestConstructorsReturnFilesysFileSystem(t *testing.T) {
 	g := NewWithT(t)
@@ -143,3 +687,266 @@ func TestConstructorsReturnFilesysFileSystem(t *testing.T) {
 
 	g.Expect(true).To(BeTrue()) // Compilation check
 }
+
+func TestStat(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	newDir := func(g *WithT) string {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app", "kustomization.yaml")
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(path, []byte("resources: []"), 0o600)).To(Succeed())
+		g.Expect(os.Chtimes(path, modTime, modTime)).To(Succeed())
+
+		return dir
+	}
+
+	t.Run("should forward the modification time through wrappers", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := newDir(g)
+
+		basePath, err := fs.NewBasePathFs(fs.NewFsOnDisk(), dir)
+		g.Expect(err).To(Succeed())
+
+		nonAferoBasePath, err := fs.NewBasePathFs(filesys.MakeFsOnDisk(), dir)
+		g.Expect(err).To(Succeed())
+
+		unionFs, err := union.NewFs(fs.NewReadOnlyFs(filesys.MakeFsOnDisk()))
+		g.Expect(err).To(Succeed())
+
+		filesystems := map[string]struct {
+			fsys filesys.FileSystem
+			path string
+		}{
+			"disk":                {fs.NewFsOnDisk(), filepath.Join(dir, "app", "kustomization.yaml")},
+			"read-only":           {fs.NewReadOnlyFs(fs.NewFsOnDisk()), filepath.Join(dir, "app", "kustomization.yaml")},
+			"read-only non-Afero": {fs.NewReadOnlyFs(filesys.MakeFsOnDisk()), filepath.Join(dir, "app", "kustomization.yaml")},
+			"base path":           {basePath, "/app/kustomization.yaml"},
+			"base path non-Afero": {nonAferoBasePath, "/app/kustomization.yaml"},
+			"caching":             {fs.NewCachingFs(fs.NewFsOnDisk()), filepath.Join(dir, "app", "kustomization.yaml")},
+			"union":               {unionFs, filepath.Join(dir, "app", "kustomization.yaml")},
+		}
+
+		for name, tc := range filesystems {
+			info, err := fs.Stat(tc.fsys, tc.path)
+			g.Expect(err).To(Succeed(), name)
+			g.Expect(info.ModTime().Equal(modTime)).To(BeTrue(), name)
+			g.Expect(info.Size()).To(Equal(int64(len("resources: []"))), name)
+		}
+	})
+
+	t.Run("should see changes through the caching filesystem", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := newDir(g)
+		path := filepath.Join(dir, "app", "kustomization.yaml")
+
+		cached := fs.NewCachingFs(fs.NewFsOnDisk())
+
+		_, err := cached.ReadFile(path)
+		g.Expect(err).To(Succeed())
+
+		later := modTime.Add(time.Hour)
+		g.Expect(os.Chtimes(path, later, later)).To(Succeed())
+
+		info, err := cached.Stat(path)
+		g.Expect(err).To(Succeed())
+		g.Expect(info.ModTime().Equal(later)).To(BeTrue())
+	})
+
+	t.Run("should synthesize a FileInfo for other filesystems", func(t *testing.T) {
+		g := NewWithT(t)
+
+		base := filesys.MakeFsInMemory()
+		g.Expect(base.WriteFile("/app/kustomization.yaml", []byte("resources: []"))).To(Succeed())
+
+		info, err := fs.Stat(fs.NewReadOnlyFs(base), "/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(info.IsDir()).To(BeFalse())
+		g.Expect(info.Size()).To(Equal(int64(len("resources: []"))))
+
+		info, err = fs.Stat(base, "/app")
+		g.Expect(err).To(Succeed())
+		g.Expect(info.IsDir()).To(BeTrue())
+
+		_, err = fs.Stat(base, "/missing")
+		g.Expect(err).To(MatchError(iofs.ErrNotExist))
+	})
+}
+
+func TestReadDirInfo(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("should list entries with their FileInfo", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		g.Expect(os.Mkdir(filepath.Join(dir, "base"), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: []"), 0o600)).To(Succeed())
+		g.Expect(os.Chtimes(filepath.Join(dir, "kustomization.yaml"), modTime, modTime)).To(Succeed())
+
+		unionFs, err := union.NewFs(fs.NewReadOnlyFs(filesys.MakeFsOnDisk()))
+		g.Expect(err).To(Succeed())
+
+		filesystems := map[string]filesys.FileSystem{
+			"disk":                fs.NewFsOnDisk(),
+			"read-only non-Afero": fs.NewReadOnlyFs(filesys.MakeFsOnDisk()),
+			"caching":             fs.NewCachingFs(fs.NewFsOnDisk()),
+			"union":               unionFs,
+		}
+
+		for name, fsys := range filesystems {
+			infos, err := fs.ReadDirInfo(fsys, dir)
+			g.Expect(err).To(Succeed(), name)
+			g.Expect(infos).To(HaveLen(2), name)
+
+			g.Expect(infos[0].Name()).To(Equal("base"), name)
+			g.Expect(infos[0].IsDir()).To(BeTrue(), name)
+
+			g.Expect(infos[1].Name()).To(Equal("kustomization.yaml"), name)
+			g.Expect(infos[1].IsDir()).To(BeFalse(), name)
+			g.Expect(infos[1].Size()).To(Equal(int64(len("resources: []"))), name)
+			g.Expect(infos[1].ModTime().Equal(modTime)).To(BeTrue(), name)
+		}
+	})
+
+	t.Run("should list other filesystems", func(t *testing.T) {
+		g := NewWithT(t)
+
+		base := filesys.MakeFsInMemory()
+		g.Expect(base.WriteFile("/app/kustomization.yaml", []byte("resources: []"))).To(Succeed())
+		g.Expect(base.MkdirAll("/app/base")).To(Succeed())
+
+		infos, err := fs.ReadDirInfo(base, "/app")
+		g.Expect(err).To(Succeed())
+		g.Expect(infos).To(HaveLen(2))
+		g.Expect(infos[0].Name()).To(Equal("base"))
+		g.Expect(infos[0].IsDir()).To(BeTrue())
+		g.Expect(infos[1].Size()).To(Equal(int64(len("resources: []"))))
+	})
+
+	t.Run("should fail for a missing directory", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := fs.ReadDirInfo(fs.NewMemoryFs(), "/missing")
+		g.Expect(err).To(MatchError(iofs.ErrNotExist))
+	})
+}
+
+// tarStream builds a tar archive of the given entries, in order, gzipped if requested.
+// Names ending with "/" are directories.
+func tarStream(t *testing.T, compress bool, entries ...[2]string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	var w io.Writer = &buf
+
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry[0], Mode: 0o644, Size: int64(len(entry[1])), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(entry[0], "/") {
+			hdr = &tar.Header{Name: entry[0], Mode: 0o755, Typeflag: tar.TypeDir}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(entry[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &buf
+}
+
+func TestNewTarFs(t *testing.T) {
+	entries := [][2]string{
+		{"./app/", ""},
+		{"./app/kustomization.yaml", "resources:\n- cm.yaml\n"},
+		{"app/cm.yaml", "kind: ConfigMap\n"},
+	}
+
+	for _, compress := range []bool{false, true} {
+		t.Run("should read the archive (gzip: "+strconv.FormatBool(compress)+")", func(t *testing.T) {
+			g := NewWithT(t)
+
+			tarFs, err := fs.NewTarFs(tarStream(t, compress, entries...))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			g.Expect(tarFs.IsDir("/app")).To(BeTrue())
+
+			data, err := tarFs.ReadFile("/app/kustomization.yaml")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(data)).To(Equal("resources:\n- cm.yaml\n"))
+
+			names, err := tarFs.ReadDir("/app")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(names).To(ConsistOf("kustomization.yaml", "cm.yaml"))
+
+			g.Expect(tarFs.WriteFile("/app/new.yaml", []byte("new"))).ToNot(Succeed())
+		})
+	}
+
+	t.Run("should be usable as base of a union filesystem", func(t *testing.T) {
+		g := NewWithT(t)
+
+		tarFs, err := fs.NewTarFs(tarStream(t, true, entries...))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		unionFs, err := union.NewFs(tarFs, union.WithOverride("/app/values.yaml", []byte("replicas: 3")))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(unionFs.Exists("/app/values.yaml")).To(BeTrue())
+		g.Expect(unionFs.Exists("/app/cm.yaml")).To(BeTrue())
+	})
+
+	t.Run("should reject path traversal", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, name := range []string{"../evil.yaml", "app/../../evil.yaml", "/../evil.yaml", `..\evil.yaml`} {
+			_, err := fs.NewTarFs(tarStream(t, true, [2]string{name, "evil"}))
+			g.Expect(err).To(MatchError(fs.ErrTarPathTraversal), name)
+		}
+	})
+
+	t.Run("should enforce the size limit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		stream := func() io.Reader {
+			return tarStream(t, true, [2]string{"a.yaml", strings.Repeat("a", 10)}, [2]string{"b.yaml", strings.Repeat("b", 10)})
+		}
+
+		_, err := fs.NewTarFs(stream(), fs.WithTarMaxSize(15))
+		g.Expect(err).To(MatchError(fs.ErrTarTooLarge))
+
+		_, err = fs.NewTarFs(stream(), fs.WithTarMaxSize(20))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should reject invalid archives", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := fs.NewTarFs(strings.NewReader("not a tar archive, but long enough to hold a tar header block"))
+		g.Expect(err).To(MatchError(fs.ErrTarInvalid))
+
+		corrupt := tarStream(t, true, entries...).Bytes()
+		_, err = fs.NewTarFs(bytes.NewReader(corrupt[:len(corrupt)/2]))
+		g.Expect(err).To(MatchError(fs.ErrTarInvalid))
+	})
+}
@@ -0,0 +1,556 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+)
+
+var (
+	// ErrCloneFailed is returned when a repository cannot be cloned.
+	ErrCloneFailed = errors.New("git clone failed")
+
+	// ErrAuthFailed is returned when the remote rejects the provided (or missing) credentials.
+	ErrAuthFailed = errors.New("git authentication failed")
+
+	// ErrRefNotFound is returned when the requested branch, tag or commit does not exist.
+	ErrRefNotFound = errors.New("git reference not found")
+
+	// ErrSubdirectoryNotFound is returned when the configured subdirectory is missing
+	// from the checked out tree.
+	ErrSubdirectoryNotFound = errors.New("subdirectory not found in repository")
+
+	// ErrInvalidSymlink is returned when a symlink of the repository points outside of it
+	// or is part of a cycle.
+	ErrInvalidSymlink = errors.New("invalid symlink in repository")
+)
+
+const (
+	// fullHashLength is the length of a hex-encoded SHA-1 commit hash.
+	fullHashLength = 40
+
+	// maxSymlinkHops bounds the number of symlinks followed to resolve a single path.
+	maxSymlinkHops = 40
+)
+
+// Option is a functional option for configuring a git filesystem.
+type Option func(*config) error
+
+type config struct {
+	subdirectory string
+	shallow      bool
+	auth         transport.AuthMethod
+	cache        *Cache
+}
+
+// WithSubdirectory roots the filesystem at the given directory inside the repository,
+// so that "/" refers to that directory rather than the repository root.
+func WithSubdirectory(dir string) Option {
+	return func(cfg *config) error {
+		cfg.subdirectory = dir
+
+		return nil
+	}
+}
+
+// WithShallow controls whether branches and tags are fetched with a depth of one.
+// Shallow clones are enabled by default. Commit references always require a full
+// clone, since arbitrary commits cannot be fetched shallowly from every server.
+func WithShallow(shallow bool) Option {
+	return func(cfg *config) error {
+		cfg.shallow = shallow
+
+		return nil
+	}
+}
+
+// WithAuth sets the credentials used to access the remote, e.g. an
+// *http.BasicAuth token or an *ssh.PublicKeys key pair from go-git's transport packages.
+func WithAuth(auth transport.AuthMethod) Option {
+	return func(cfg *config) error {
+		cfg.auth = auth
+
+		return nil
+	}
+}
+
+// WithCache reuses clones across NewFs calls sharing the same cache.
+// Without a cache, every call clones the repository.
+func WithCache(cache *Cache) Option {
+	return func(cfg *config) error {
+		cfg.cache = cache
+
+		return nil
+	}
+}
+
+// NewFs clones a git repository and returns its tree at the given ref as a read-only
+// filesys.FileSystem suitable for kustomize.WithFileSystem.
+//
+// The ref may be a branch, a tag, or a (possibly abbreviated) commit hash; an empty ref
+// selects the remote's default branch. The clone is held in memory, so the returned
+// filesystem does not depend on any on-disk state. Since it is read-only, it can be
+// layered with union.NewFs to inject additional files.
+//
+// Symlinks of the repository are replaced by copies of the files or directories they point
+// to, and dangling ones are left out. Cloning fails with ErrInvalidSymlink if a symlink
+// points outside of the repository or is part of a cycle.
+//
+// Example:
+//
+//	gitFs, err := git.NewFs(ctx, "https://github.com/org/manifests.git", "v1.2.0",
+//	    git.WithSubdirectory("deploy"),
+//	)
+//	renderer, err := kustomize.New(
+//	    []kustomize.Source{{Path: "/overlays/prod"}},
+//	    kustomize.WithFileSystem(gitFs),
+//	)
+func NewFs(
+	ctx context.Context,
+	url string,
+	ref string,
+	opts ...Option,
+) (filesys.FileSystem, error) {
+	cfg := &config{
+		shallow: true,
+	}
+
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var tree afero.Fs
+	var err error
+
+	if cfg.cache != nil {
+		tree, err = cfg.cache.get(ctx, url, ref, cfg)
+	} else {
+		tree, err = clone(ctx, url, ref, cfg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	root := tree
+	if subdir := strings.Trim(path.Clean("/"+cfg.subdirectory), "/"); subdir != "" {
+		info, err := tree.Stat("/" + subdir)
+		if err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("%w: %q in %s@%s", ErrSubdirectoryNotFound, cfg.subdirectory, url, ref)
+		}
+
+		root = afero.NewBasePathFs(tree, "/"+subdir)
+	}
+
+	return adapter.New(afero.NewReadOnlyFs(root)), nil
+}
+
+// Cache holds cloned repository trees keyed by (url, ref).
+// Concurrent NewFs calls for the same key share a single clone.
+// A Cache is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+}
+
+type cacheKey struct {
+	url string
+	ref string
+}
+
+type cacheEntry struct {
+	done chan struct{}
+	tree afero.Fs
+	err  error
+}
+
+// NewCache creates an empty clone cache.
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[cacheKey]*cacheEntry),
+	}
+}
+
+// Clear drops all cached clones.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[cacheKey]*cacheEntry)
+}
+
+// Len returns the number of cached clones.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+func (c *Cache) get(
+	ctx context.Context,
+	url string,
+	ref string,
+	cfg *config,
+) (afero.Fs, error) {
+	key := cacheKey{url: url, ref: ref}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{done: make(chan struct{})}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-entry.done:
+			if entry.err == nil {
+				return entry.tree, nil
+			}
+			// a previous attempt failed and has been evicted, retry below
+			return c.get(ctx, url, ref, cfg)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for clone of %s@%s: %w", url, ref, ctx.Err())
+		}
+	}
+
+	entry.tree, entry.err = clone(ctx, url, ref, cfg)
+	if entry.err != nil {
+		// failures are not cached so that transient errors can be retried
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+
+	close(entry.done)
+
+	return entry.tree, entry.err
+}
+
+// clone fetches the repository at ref and copies its worktree into an in-memory afero.Fs.
+func clone(
+	ctx context.Context,
+	url string,
+	ref string,
+	cfg *config,
+) (afero.Fs, error) {
+	var worktree billy.Filesystem
+	var err error
+
+	switch {
+	case ref == "":
+		worktree, err = cloneRef(ctx, url, "", cfg)
+	case isFullHash(ref):
+		worktree, err = cloneCommit(ctx, url, ref, cfg)
+	default:
+		worktree, err = cloneNamedRef(ctx, url, ref, cfg)
+		if errors.Is(err, ErrRefNotFound) && isHexString(ref) {
+			// not a branch or tag, try it as an abbreviated commit hash
+			worktree, err = cloneCommit(ctx, url, ref, cfg)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	tree := afero.NewMemMapFs()
+	if err := copyTree(worktree, tree); err != nil {
+		return nil, fmt.Errorf("%w: copying worktree of %s@%s: %w", ErrCloneFailed, url, ref, err)
+	}
+
+	return tree, nil
+}
+
+// cloneNamedRef clones a branch or a tag, in that order of preference.
+// Fully qualified names (refs/...) are used as-is.
+func cloneNamedRef(
+	ctx context.Context,
+	url string,
+	ref string,
+	cfg *config,
+) (billy.Filesystem, error) {
+	if strings.HasPrefix(ref, "refs/") {
+		return cloneRef(ctx, url, plumbing.ReferenceName(ref), cfg)
+	}
+
+	worktree, err := cloneRef(ctx, url, plumbing.NewBranchReferenceName(ref), cfg)
+	if !errors.Is(err, ErrRefNotFound) {
+		return worktree, err
+	}
+
+	worktree, err = cloneRef(ctx, url, plumbing.NewTagReferenceName(ref), cfg)
+	if errors.Is(err, ErrRefNotFound) {
+		return nil, fmt.Errorf("%w: no branch or tag %q in %s", ErrRefNotFound, ref, url)
+	}
+
+	return worktree, err
+}
+
+func cloneRef(
+	ctx context.Context,
+	url string,
+	name plumbing.ReferenceName,
+	cfg *config,
+) (billy.Filesystem, error) {
+	worktree := memfs.New()
+
+	cloneOpts := &gogit.CloneOptions{
+		URL:           url,
+		Auth:          cfg.auth,
+		ReferenceName: name,
+		SingleBranch:  true,
+		Tags:          gogit.NoTags,
+	}
+
+	if cfg.shallow {
+		cloneOpts.Depth = 1
+	}
+
+	if _, err := gogit.CloneContext(ctx, memory.NewStorage(), worktree, cloneOpts); err != nil {
+		return nil, wrapCloneError(url, name.Short(), err)
+	}
+
+	return worktree, nil
+}
+
+// cloneCommit performs a full clone and checks out the given (possibly abbreviated) commit.
+func cloneCommit(
+	ctx context.Context,
+	url string,
+	ref string,
+	cfg *config,
+) (billy.Filesystem, error) {
+	worktree := memfs.New()
+
+	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), worktree, &gogit.CloneOptions{
+		URL:        url,
+		Auth:       cfg.auth,
+		NoCheckout: true,
+	})
+	if err != nil {
+		return nil, wrapCloneError(url, ref, err)
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("%w: no commit %q in %s: %w", ErrRefNotFound, ref, url, err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s@%s: %w", ErrCloneFailed, url, ref, err)
+	}
+
+	if err := wt.Checkout(&gogit.CheckoutOptions{Hash: *hash, Force: true}); err != nil {
+		return nil, fmt.Errorf("%w: checking out %s@%s: %w", ErrCloneFailed, url, ref, err)
+	}
+
+	return worktree, nil
+}
+
+// wrapCloneError classifies go-git errors into the package's sentinel errors.
+func wrapCloneError(url string, ref string, err error) error {
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod):
+		return fmt.Errorf("%w: %s: %w", ErrAuthFailed, url, err)
+	case errors.Is(err, gogit.NoMatchingRefSpecError{}),
+		errors.Is(err, plumbing.ErrReferenceNotFound):
+		return fmt.Errorf("%w: %q in %s: %w", ErrRefNotFound, ref, url, err)
+	default:
+		return fmt.Errorf("%w: %s@%s: %w", ErrCloneFailed, url, ref, err)
+	}
+}
+
+// copyTree copies all regular files of a billy filesystem into an afero filesystem.
+// The .git directory is not part of a worktree backed by separate storage, so it is
+// never copied.
+//
+// Symlinks are resolved within src and the files or directories they point to are copied
+// in their place; dangling symlinks are skipped. Symlinks pointing outside of src, by an
+// absolute path or by climbing above its root, and symlinks to a directory containing
+// them fail with ErrInvalidSymlink.
+func copyTree(src billy.Filesystem, dst afero.Fs) error {
+	return copyDir(src, dst, "/", "/", nil)
+}
+
+// copyDir copies the directory at the resolved path dir of src to target in dst. Parents
+// holds the resolved paths of the directories being copied, to detect symlink cycles.
+func copyDir(src billy.Filesystem, dst afero.Fs, dir string, target string, parents []string) error {
+	if err := dst.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+
+	entries, err := src.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	parents = append(parents, dir)
+
+	for _, info := range entries {
+		p := path.Join(dir, info.Name())
+		entryTarget := path.Join(target, info.Name())
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			resolved, err := resolveSymlink(src, p)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			if err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrInvalidSymlink, p, err)
+			}
+
+			if info, err = src.Lstat(resolved); err != nil {
+				return err
+			}
+
+			if info.IsDir() && slices.ContainsFunc(parents, func(parent string) bool {
+				return isAncestor(resolved, parent)
+			}) {
+				return fmt.Errorf("%w: %s: cycle through %s", ErrInvalidSymlink, p, resolved)
+			}
+
+			p = resolved
+		}
+
+		switch {
+		case info.IsDir():
+			if err := copyDir(src, dst, p, entryTarget, parents); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := copyFile(src, dst, p, entryTarget); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the regular file at p of src to target in dst.
+func copyFile(src billy.Filesystem, dst afero.Fs, p string, target string) error {
+	in, err := src.Open(p)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := dst.Create(target)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+
+	return err
+}
+
+// resolveSymlink returns the path p resolves to in src, following symlinks in any of its
+// elements. Since billy filesystems do not resolve symlinks in parent directories, every
+// element is resolved here, which also confines the result to src.
+func resolveSymlink(src billy.Filesystem, p string) (string, error) {
+	resolved := "/"
+	pending := strings.Split(p, "/")
+	hops := 0
+
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "/" {
+				return "", errors.New("points outside of the repository")
+			}
+
+			resolved = path.Dir(resolved)
+
+			continue
+		}
+
+		next := path.Join(resolved, name)
+
+		info, err := src.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", errors.New("too many levels of symlinks")
+		}
+
+		link, err := src.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+
+		if path.IsAbs(link) {
+			return "", fmt.Errorf("absolute target %q points outside of the repository", link)
+		}
+
+		pending = append(strings.Split(link, "/"), pending...)
+	}
+
+	return resolved, nil
+}
+
+// isAncestor reports whether dir is p or one of its ancestors.
+func isAncestor(dir string, p string) bool {
+	return dir == "/" || dir == p || strings.HasPrefix(p, dir+"/")
+}
+
+func isFullHash(ref string) bool {
+	return len(ref) == fullHashLength && isHexString(ref)
+}
+
+func isHexString(ref string) bool {
+	if len(ref) < 4 {
+		return false
+	}
+
+	for _, c := range ref {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,276 @@
+package git_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/git"
+
+	. "github.com/onsi/gomega"
+)
+
+// testRepo is a local repository with two commits:
+//   - the first commit (tagged v1) contains app/config.yaml with "version: 1"
+//   - the second commit (on main) changes it to "version: 2"
+type testRepo struct {
+	url   string
+	first plumbing.Hash
+}
+
+func newTestRepo(t *testing.T) testRepo {
+	t.Helper()
+
+	g := NewWithT(t)
+	dir := t.TempDir()
+
+	repo, err := gogit.PlainInitWithOptions(dir, &gogit.PlainInitOptions{
+		InitOptions: gogit.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	commit := func(content string) plumbing.Hash {
+		g.Expect(os.MkdirAll(filepath.Join(dir, "app"), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "app", "config.yaml"), []byte(content), 0o600)).To(Succeed())
+		_, err := wt.Add("app/config.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		hash, err := wt.Commit("update", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		return hash
+	}
+
+	first := commit("version: 1\n")
+	_, err = repo.CreateTag("v1", first, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	commit("version: 2\n")
+
+	return testRepo{url: "file://" + dir, first: first}
+}
+
+// newSymlinkRepo returns the URL of a local repository with a single commit containing
+// base/config.yaml and the given symlinks, keyed by path.
+func newSymlinkRepo(t *testing.T, links map[string]string) string {
+	t.Helper()
+
+	g := NewWithT(t)
+	dir := t.TempDir()
+
+	repo, err := gogit.PlainInit(dir, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(os.MkdirAll(filepath.Join(dir, "base"), 0o755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "base", "config.yaml"), []byte("version: 1\n"), 0o600)).To(Succeed())
+
+	for link, target := range links {
+		g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, link)), 0o755)).To(Succeed())
+		g.Expect(os.Symlink(target, filepath.Join(dir, link))).To(Succeed())
+	}
+
+	g.Expect(wt.AddWithOptions(&gogit.AddOptions{All: true})).To(Succeed())
+
+	_, err = wt.Commit("init", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	return "file://" + dir
+}
+
+func TestNewFs(t *testing.T) {
+	repo := newTestRepo(t)
+
+	t.Run("should check out default branch", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := git.NewFs(t.Context(), repo.url, "")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := fsys.ReadFile("/app/config.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal("version: 2\n"))
+	})
+
+	t.Run("should check out branch", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := git.NewFs(t.Context(), repo.url, "main")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := fsys.ReadFile("/app/config.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal("version: 2\n"))
+	})
+
+	t.Run("should check out tag", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := git.NewFs(t.Context(), repo.url, "v1")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := fsys.ReadFile("/app/config.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal("version: 1\n"))
+	})
+
+	t.Run("should check out commit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, ref := range []string{repo.first.String(), repo.first.String()[:8]} {
+			fsys, err := git.NewFs(t.Context(), repo.url, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			data, err := fsys.ReadFile("/app/config.yaml")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(data)).To(Equal("version: 1\n"))
+		}
+	})
+
+	t.Run("should root at subdirectory", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := git.NewFs(t.Context(), repo.url, "main",
+			git.WithSubdirectory("app"),
+			git.WithShallow(false),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(fsys.Exists("/config.yaml")).To(BeTrue())
+		g.Expect(fsys.Exists("/app")).To(BeFalse())
+	})
+
+	t.Run("should resolve symlinks within the repository", func(t *testing.T) {
+		g := NewWithT(t)
+
+		url := newSymlinkRepo(t, map[string]string{
+			"app/config.yaml":   "../base/config.yaml",
+			"app/base":          "../base",
+			"app/chained.yaml":  "config.yaml",
+			"app/dangling.yaml": "missing.yaml",
+		})
+
+		fsys, err := git.NewFs(t.Context(), url, "", git.WithSubdirectory("app"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		for _, name := range []string{"/config.yaml", "/base/config.yaml", "/chained.yaml"} {
+			data, err := fsys.ReadFile(name)
+			g.Expect(err).ToNot(HaveOccurred(), name)
+			g.Expect(string(data)).To(Equal("version: 1\n"), name)
+		}
+
+		g.Expect(fsys.Exists("/dangling.yaml")).To(BeFalse())
+	})
+
+	t.Run("should fail on symlinks escaping the repository", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, target := range []string{"../../etc/passwd", "/etc/passwd"} {
+			url := newSymlinkRepo(t, map[string]string{"app/passwd": target})
+
+			_, err := git.NewFs(t.Context(), url, "")
+			g.Expect(err).To(MatchError(git.ErrInvalidSymlink), target)
+		}
+	})
+
+	t.Run("should fail on symlink cycles", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, links := range []map[string]string{
+			{"app/loop": ".."},
+			{"app/a": "b", "app/b": "a"},
+			{"a/b": "../c", "c/d": "../a"},
+		} {
+			url := newSymlinkRepo(t, links)
+
+			_, err := git.NewFs(t.Context(), url, "")
+			g.Expect(err).To(MatchError(git.ErrInvalidSymlink), links)
+		}
+	})
+
+	t.Run("should be read-only", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fsys, err := git.NewFs(t.Context(), repo.url, "main")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(fsys.WriteFile("/app/config.yaml", []byte("x"))).ToNot(Succeed())
+	})
+
+	t.Run("should fail on unknown ref", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := git.NewFs(t.Context(), repo.url, "does-not-exist")
+		g.Expect(err).To(MatchError(git.ErrRefNotFound))
+	})
+
+	t.Run("should fail on missing subdirectory", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := git.NewFs(t.Context(), repo.url, "main", git.WithSubdirectory("missing"))
+		g.Expect(err).To(MatchError(git.ErrSubdirectoryNotFound))
+	})
+
+	t.Run("should fail on unreachable repository", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := git.NewFs(t.Context(), "file://"+filepath.Join(t.TempDir(), "missing"), "main")
+		g.Expect(err).To(MatchError(git.ErrCloneFailed))
+	})
+
+	t.Run("should honor cancelled context", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		_, err := git.NewFs(ctx, repo.url, "main")
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestCache(t *testing.T) {
+	repo := newTestRepo(t)
+
+	t.Run("should reuse clones per url and ref", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cache := git.NewCache()
+
+		_, err := git.NewFs(t.Context(), repo.url, "main", git.WithCache(cache))
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = git.NewFs(t.Context(), repo.url, "main", git.WithCache(cache), git.WithSubdirectory("app"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cache.Len()).To(Equal(1))
+
+		_, err = git.NewFs(t.Context(), repo.url, "v1", git.WithCache(cache))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cache.Len()).To(Equal(2))
+
+		cache.Clear()
+		g.Expect(cache.Len()).To(Equal(0))
+	})
+
+	t.Run("should not cache failures", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cache := git.NewCache()
+
+		_, err := git.NewFs(t.Context(), repo.url, "does-not-exist", git.WithCache(cache))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(cache.Len()).To(Equal(0))
+	})
+}
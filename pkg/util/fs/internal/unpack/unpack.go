@@ -0,0 +1,164 @@
+// Package unpack extracts tar and zip archives into in-memory trees, with the traversal
+// policy and size budget shared by the tar, archive and OCI filesystems.
+package unpack
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	// ErrPathTraversal is returned for an entry whose path escapes the root of the archive.
+	ErrPathTraversal = errors.New("archive entry escapes the archive root")
+
+	// ErrTooLarge is returned when the extracted files exceed the size budget of the tree.
+	ErrTooLarge = errors.New("archive contents too large")
+
+	// ErrInvalid is returned for a corrupt archive, or a stream that is not a valid,
+	// optionally gzipped, tar archive.
+	ErrInvalid = errors.New("invalid archive")
+)
+
+//nolint:gochecknoglobals
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Tree is an in-memory directory tree populated from archive entries. The size budget is
+// shared by every archive extracted into the tree, e.g. the layers of an OCI artifact.
+type Tree struct {
+	fs        afero.Fs
+	maxSize   int64
+	remaining int64
+}
+
+// NewTree creates an empty tree accepting up to maxSize bytes of file contents.
+func NewTree(maxSize int64) *Tree {
+	return &Tree{
+		fs:        afero.NewMemMapFs(),
+		maxSize:   maxSize,
+		remaining: maxSize,
+	}
+}
+
+// Fs returns the tree as an afero.Fs.
+func (t *Tree) Fs() afero.Fs {
+	return t.fs
+}
+
+// Mkdir creates the directory of the entry name and its parents.
+func (t *Tree) Mkdir(name string) error {
+	target, err := entryPath(name)
+	if err != nil {
+		return err
+	}
+
+	if err := t.fs.MkdirAll(target, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", target, err)
+	}
+
+	return nil
+}
+
+// WriteFile copies the contents of the file entry name from r into the tree, charging
+// them against the size budget. The bytes actually read are counted, since headers can
+// understate entry sizes.
+func (t *Tree) WriteFile(name string, r io.Reader) error {
+	target, err := entryPath(name)
+	if err != nil {
+		return err
+	}
+
+	if err := t.fs.MkdirAll(path.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", path.Dir(target), err)
+	}
+
+	out, err := t.fs.Create(target)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	defer func() { _ = out.Close() }()
+
+	// read one byte past the budget to detect entries exceeding it
+	n, err := io.Copy(out, io.LimitReader(r, t.remaining+1))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalid, name, err)
+	}
+
+	if n > t.remaining {
+		return fmt.Errorf("%w: exceeds limit of %d bytes", ErrTooLarge, t.maxSize)
+	}
+
+	t.remaining -= n
+
+	return nil
+}
+
+// Tar extracts a tar stream, gzipped or not, into the tree. Only directories and regular
+// files are extracted: symlinks, devices and other special entries are not needed to
+// render kustomizations and are skipped rather than followed. Entries for which skip, if
+// not nil, returns true are skipped as well.
+func (t *Tree) Tar(r io.Reader, skip func(name string) bool) error {
+	br := bufio.NewReader(r)
+
+	var tr *tar.Reader
+
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+		defer func() { _ = gz.Close() }()
+
+		tr = tar.NewReader(gz)
+	} else {
+		tr = tar.NewReader(br)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+
+		if skip != nil && skip(hdr.Name) {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := t.Mkdir(hdr.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := t.WriteFile(hdr.Name, tr); err != nil {
+				return err
+			}
+		default:
+			// skipped, see above
+		}
+	}
+}
+
+// entryPath maps an archive entry name to an absolute path of the tree: "app/a.yaml",
+// "./app/a.yaml" and "/app/a.yaml" all become "/app/a.yaml". Names with ".." elements fail
+// with ErrPathTraversal rather than being confined, since no well-formed archive holds them.
+func entryPath(name string) (string, error) {
+	if slices.Contains(strings.Split(strings.ReplaceAll(name, "\\", "/"), "/"), "..") {
+		return "", fmt.Errorf("%w: %q", ErrPathTraversal, name)
+	}
+
+	return path.Clean("/" + name), nil
+}
@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// rootedFS adapts an fs.FS to the OS-style paths used by kustomize and Afero. Absolute and
+// relative paths are both resolved from the root of the fs.FS, e.g. "/app/kustomization.yaml"
+// opens "app/kustomization.yaml". Paths are cleaned first, so ".." never leaves the root.
+type rootedFS struct {
+	fsys fs.FS
+}
+
+func (r rootedFS) Open(name string) (fs.File, error) {
+	return r.fsys.Open(ioFSPath(name)) //nolint:wrapcheck
+}
+
+func (r rootedFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(r.fsys, ioFSPath(name)) //nolint:wrapcheck
+}
+
+// ioFSPath converts an OS-style path to a valid fs.FS path.
+func ioFSPath(name string) string {
+	p := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if p == "" {
+		return "."
+	}
+
+	return p
+}
+
+var _ fs.StatFS = rootedFS{}
@@ -0,0 +1,421 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/internal/unpack"
+)
+
+// DefaultMaxSize is the default limit on the total size of the pulled layers, and
+// separately on the total size of the files extracted from them.
+const DefaultMaxSize int64 = 256 << 20
+
+// maxManifestSize bounds manifest downloads.
+const maxManifestSize int64 = 4 << 20
+
+// Media types of manifests and of the layers extracted by default.
+const (
+	MediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	MediaTypeOCILayer         = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeOCILayerGzip     = "application/vnd.oci.image.layer.v1.tar+gzip"
+	MediaTypeDockerLayerGzip  = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	MediaTypeFluxContentLayer = "application/vnd.cncf.flux.content.v1.tar+gzip"
+)
+
+var (
+	// ErrInvalidReference is returned when the artifact reference cannot be parsed.
+	ErrInvalidReference = errors.New("invalid OCI reference")
+
+	// ErrPullFailed is returned when the manifest or a layer cannot be fetched.
+	ErrPullFailed = errors.New("OCI pull failed")
+
+	// ErrAuthFailed is returned when the registry rejects the provided (or missing) credentials.
+	ErrAuthFailed = errors.New("OCI registry authentication failed")
+
+	// ErrDigestMismatch is returned when the manifest or a layer does not match its expected digest.
+	ErrDigestMismatch = errors.New("OCI digest mismatch")
+
+	// ErrUnsupportedManifest is returned for manifest types other than image manifests,
+	// such as multi-platform indexes.
+	ErrUnsupportedManifest = errors.New("unsupported OCI manifest")
+
+	// ErrNoLayers is returned when no layer of the artifact matches the media type filter.
+	ErrNoLayers = errors.New("no matching layers in OCI artifact")
+
+	// ErrArtifactTooLarge is returned when the layers, or their extracted contents,
+	// exceed the configured size limit.
+	ErrArtifactTooLarge = errors.New("OCI artifact too large")
+
+	// ErrExtractFailed is returned when a layer is corrupt or cannot be decompressed.
+	ErrExtractFailed = errors.New("OCI layer extraction failed")
+
+	// ErrVerificationFailed wraps errors returned by a Verifier.
+	ErrVerificationFailed = errors.New("OCI artifact verification failed")
+)
+
+//nolint:gochecknoglobals
+var (
+	defaultMediaTypes = []string{
+		MediaTypeOCILayer,
+		MediaTypeOCILayerGzip,
+		MediaTypeDockerLayerGzip,
+		MediaTypeFluxContentLayer,
+	}
+
+	manifestMediaTypes = []string{
+		MediaTypeOCIManifest,
+		MediaTypeDockerManifest,
+		MediaTypeOCIIndex,
+		MediaTypeDockerList,
+	}
+)
+
+// Descriptor describes a blob referenced by a manifest.
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Artifact is the pulled manifest of an artifact, passed to verifiers before any layer
+// is downloaded.
+type Artifact struct {
+	// Reference is the parsed artifact reference.
+	Reference Reference
+
+	// Digest is the digest of the manifest, computed from its content.
+	Digest string
+
+	// Manifest is the raw manifest, as signed by tools such as cosign or notation.
+	Manifest []byte
+
+	// Layers are the layers selected by the media type filter, in application order.
+	Layers []Descriptor
+}
+
+// Verifier checks an artifact before its layers are pulled, e.g. by validating a
+// signature for Artifact.Digest. Returning an error aborts NewFs.
+type Verifier func(ctx context.Context, artifact Artifact) error
+
+// Option is a functional option for configuring an OCI filesystem.
+type Option func(*config) error
+
+type config struct {
+	creds        *credentials
+	dockerConfig *string
+	digest       string
+	mediaTypes   []string
+	verifiers    []Verifier
+	client       *http.Client
+	plainHTTP    bool
+	maxSize      int64
+}
+
+// WithCredentials authenticates with the given username and password (or token).
+// Takes precedence over WithDockerConfig.
+func WithCredentials(username string, password string) Option {
+	return func(cfg *config) error {
+		cfg.creds = &credentials{username: username, password: password}
+
+		return nil
+	}
+}
+
+// WithDockerConfig reads the registry credentials from a docker config file, as written by
+// `docker login`. An empty path uses $DOCKER_CONFIG/config.json or ~/.docker/config.json.
+// Only static credentials ("auths") are supported; credential helpers are not invoked.
+// Registries without an entry are accessed anonymously.
+func WithDockerConfig(path string) Option {
+	return func(cfg *config) error {
+		cfg.dockerConfig = &path
+
+		return nil
+	}
+}
+
+// WithDigest pins the artifact to a manifest digest ("sha256:..."). The pull fails with
+// ErrDigestMismatch if the manifest behind the reference has a different digest.
+// Equivalent to a reference of the form "repo@sha256:...".
+func WithDigest(digest string) Option {
+	return func(cfg *config) error {
+		if err := validateDigest(digest); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidReference, err)
+		}
+
+		cfg.digest = digest
+
+		return nil
+	}
+}
+
+// WithMediaTypes selects the layers to extract by media type, replacing the default set
+// (OCI and Docker tar layers, and Flux content layers). Other layers, such as configs or
+// attached documentation, are ignored.
+func WithMediaTypes(mediaTypes ...string) Option {
+	return func(cfg *config) error {
+		cfg.mediaTypes = mediaTypes
+
+		return nil
+	}
+}
+
+// WithVerifier adds a hook that checks the artifact, e.g. its signature, before any layer
+// is downloaded. Verifiers run in order; the first error aborts the pull.
+func WithVerifier(verifier Verifier) Option {
+	return func(cfg *config) error {
+		cfg.verifiers = append(cfg.verifiers, verifier)
+
+		return nil
+	}
+}
+
+// WithHTTPClient sets the client used to talk to the registry. Defaults to http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(cfg *config) error {
+		cfg.client = client
+
+		return nil
+	}
+}
+
+// WithPlainHTTP talks to the registry over plain HTTP instead of HTTPS, e.g. for local
+// development registries.
+func WithPlainHTTP(enabled bool) Option {
+	return func(cfg *config) error {
+		cfg.plainHTTP = enabled
+
+		return nil
+	}
+}
+
+// WithMaxSize limits both the total size of the pulled layers and the total size of the
+// files extracted from them. Defaults to DefaultMaxSize.
+func WithMaxSize(size int64) Option {
+	return func(cfg *config) error {
+		if size <= 0 {
+			return fmt.Errorf("max size must be positive, got %d", size) //nolint:err113
+		}
+
+		cfg.maxSize = size
+
+		return nil
+	}
+}
+
+// NewFs pulls an OCI artifact (such as one pushed with `flux push artifact` or
+// `oras push`) and returns the contents of its layers as a read-only, in-memory
+// filesys.FileSystem suitable for kustomize.WithFileSystem.
+//
+// The manifest is fetched first and checked against the pinned digest, if any, and the
+// configured verifiers. The selected layers are then downloaded, each verified against
+// its digest, and extracted in manifest order, later layers overwriting earlier ones.
+// Multi-platform indexes are not supported.
+//
+// Example:
+//
+//	ociFs, err := oci.NewFs(ctx, "ghcr.io/org/manifests:v1.2.0",
+//	    oci.WithDockerConfig(""),
+//	    oci.WithDigest("sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"),
+//	)
+//	renderer, err := kustomize.New(
+//	    []kustomize.Source{{Path: "/overlays/prod"}},
+//	    kustomize.WithFileSystem(ociFs),
+//	)
+func NewFs(ctx context.Context, ref string, opts ...Option) (filesys.FileSystem, error) {
+	cfg := &config{
+		mediaTypes: defaultMediaTypes,
+		client:     http.DefaultClient,
+		maxSize:    DefaultMaxSize,
+	}
+
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	parsed, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.digest != "" {
+		if parsed.Digest != "" && parsed.Digest != cfg.digest {
+			return nil, fmt.Errorf("%w: reference %s conflicts with pinned digest %s",
+				ErrInvalidReference, parsed, cfg.digest)
+		}
+
+		parsed.Digest = cfg.digest
+	}
+
+	if cfg.creds == nil && cfg.dockerConfig != nil {
+		configPath := *cfg.dockerConfig
+		if configPath == "" {
+			if configPath, err = defaultDockerConfigPath(); err != nil {
+				return nil, err
+			}
+		}
+
+		if cfg.creds, err = dockerConfigCredentials(configPath, parsed.Registry); err != nil {
+			return nil, err
+		}
+	}
+
+	c := newClient(parsed, cfg)
+
+	artifact, err := pullManifest(ctx, c, cfg.mediaTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, verify := range cfg.verifiers {
+		if err := verify(ctx, artifact); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrVerificationFailed, parsed, err)
+		}
+	}
+
+	tree := unpack.NewTree(cfg.maxSize)
+	downloadBudget := &sizeBudget{remaining: cfg.maxSize}
+
+	for _, layer := range artifact.Layers {
+		if err := downloadBudget.consume(layer.Size); err != nil {
+			return nil, fmt.Errorf("%s: %w", parsed, err)
+		}
+
+		data, _, err := c.get(ctx, "/blobs/"+layer.Digest, nil, layer.Size)
+		if err != nil {
+			return nil, err
+		}
+
+		if actual := digestOf(data); actual != layer.Digest {
+			return nil, fmt.Errorf("%w: %s: layer %s has digest %s", ErrDigestMismatch, parsed, layer.Digest, actual)
+		}
+
+		if err := extractLayer(data, tree); err != nil {
+			return nil, fmt.Errorf("%s: layer %s: %w", parsed, layer.Digest, err)
+		}
+	}
+
+	return adapter.New(afero.NewReadOnlyFs(tree.Fs())), nil
+}
+
+// pullManifest fetches and validates the manifest, and selects the layers to extract.
+func pullManifest(ctx context.Context, c *client, mediaTypes []string) (Artifact, error) {
+	tagOrDigest := c.ref.Tag
+	if c.ref.Digest != "" {
+		tagOrDigest = c.ref.Digest
+	}
+
+	data, contentType, err := c.get(ctx, "/manifests/"+tagOrDigest, manifestMediaTypes, maxManifestSize)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	digest := digestOf(data)
+	if c.ref.Digest != "" && digest != c.ref.Digest {
+		return Artifact{}, fmt.Errorf("%w: %s: manifest has digest %s", ErrDigestMismatch, c.ref, digest)
+	}
+
+	var manifest struct {
+		MediaType string       `json:"mediaType"`
+		Layers    []Descriptor `json:"layers"`
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Artifact{}, fmt.Errorf("%w: %s: malformed manifest: %w", ErrPullFailed, c.ref, err)
+	}
+
+	mediaType := manifest.MediaType
+	if mediaType == "" {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+	}
+
+	switch mediaType {
+	case MediaTypeOCIManifest, MediaTypeDockerManifest, "":
+	default:
+		return Artifact{}, fmt.Errorf("%w: %s: %s", ErrUnsupportedManifest, c.ref, mediaType)
+	}
+
+	layers := make([]Descriptor, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		if !slices.Contains(mediaTypes, layer.MediaType) {
+			continue
+		}
+
+		if err := validateDigest(layer.Digest); err != nil {
+			return Artifact{}, fmt.Errorf("%w: %s: %w", ErrPullFailed, c.ref, err)
+		}
+
+		if layer.Size < 0 {
+			return Artifact{}, fmt.Errorf("%w: %s: layer %s has negative size", ErrPullFailed, c.ref, layer.Digest)
+		}
+
+		layers = append(layers, layer)
+	}
+
+	if len(layers) == 0 {
+		return Artifact{}, fmt.Errorf("%w: %s: accepted media types %v", ErrNoLayers, c.ref, mediaTypes)
+	}
+
+	return Artifact{
+		Reference: c.ref,
+		Digest:    digest,
+		Manifest:  data,
+		Layers:    layers,
+	}, nil
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// extractLayer unpacks a (possibly gzipped) tar layer into the tree. Only directories and
+// regular files are extracted; whiteouts, links and special files are skipped.
+func extractLayer(data []byte, tree *unpack.Tree) error {
+	err := tree.Tar(bytes.NewReader(data), func(name string) bool {
+		return strings.HasPrefix(path.Base(name), ".wh.")
+	})
+
+	switch {
+	case errors.Is(err, unpack.ErrTooLarge):
+		return fmt.Errorf("%w: %w", ErrArtifactTooLarge, err)
+	case err != nil:
+		return fmt.Errorf("%w: %w", ErrExtractFailed, err)
+	default:
+		return nil
+	}
+}
+
+type sizeBudget struct {
+	remaining int64
+}
+
+func (b *sizeBudget) consume(n int64) error {
+	if n > b.remaining {
+		return fmt.Errorf("%w: exceeds size limit", ErrArtifactTooLarge)
+	}
+
+	b.remaining -= n
+
+	return nil
+}
@@ -0,0 +1,361 @@
+package oci_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/oci"
+
+	. "github.com/onsi/gomega"
+)
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		NewWithT(t).Expect(err).ToNot(HaveOccurred())
+		_, err = tw.Write([]byte(content))
+		NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	}
+
+	NewWithT(t).Expect(tw.Close()).To(Succeed())
+	NewWithT(t).Expect(gz.Close()).To(Succeed())
+
+	return buf.Bytes()
+}
+
+// registry is a fake OCI registry serving a single artifact.
+type registry struct {
+	manifest []byte
+	blobs    map[string][]byte
+
+	// token, when set, requires bearer authentication obtained from /token with the
+	// given basic credentials.
+	token    string
+	username string
+	password string
+}
+
+func newRegistry(t *testing.T, layers ...[]byte) *registry {
+	t.Helper()
+
+	r := &registry{blobs: make(map[string][]byte)}
+
+	descriptors := make([]oci.Descriptor, 0, len(layers)+1)
+	for _, layer := range layers {
+		r.blobs[digestOf(layer)] = layer
+		descriptors = append(descriptors, oci.Descriptor{
+			MediaType: oci.MediaTypeFluxContentLayer,
+			Digest:    digestOf(layer),
+			Size:      int64(len(layer)),
+		})
+	}
+
+	// layers of other media types are ignored by default
+	readme := []byte("# docs")
+	r.blobs[digestOf(readme)] = readme
+	descriptors = append(descriptors, oci.Descriptor{
+		MediaType: "text/markdown",
+		Digest:    digestOf(readme),
+		Size:      int64(len(readme)),
+	})
+
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     oci.MediaTypeOCIManifest,
+		"layers":        descriptors,
+	})
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	r.manifest = manifest
+
+	return r
+}
+
+func (r *registry) serve(t *testing.T) string {
+	t.Helper()
+
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			user, pass, ok := req.BasicAuth()
+			if !ok || user != r.username || pass != r.password {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": r.token})
+
+			return
+		}
+
+		if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+			w.Header().Set("WWW-Authenticate",
+				`Bearer realm="`+srv.URL+`/token",service="test",scope="repository:org/manifests:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(req.URL.Path, "/v2/org/manifests/manifests/"):
+			w.Header().Set("Content-Type", oci.MediaTypeOCIManifest)
+			_, _ = w.Write(r.manifest)
+		case strings.HasPrefix(req.URL.Path, "/v2/org/manifests/blobs/"):
+			blob, ok := r.blobs[strings.TrimPrefix(req.URL.Path, "/v2/org/manifests/blobs/")]
+			if !ok {
+				http.NotFound(w, req)
+
+				return
+			}
+
+			_, _ = w.Write(blob)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://") + "/org/manifests"
+}
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	t.Run("should parse references", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for ref, expected := range map[string]oci.Reference{
+			"ghcr.io/org/manifests:v1.0.0": {Registry: "ghcr.io", Repository: "org/manifests", Tag: "v1.0.0"},
+			"oci://localhost:5000/manifests@" + digest: {
+				Registry: "localhost:5000", Repository: "manifests", Digest: digest,
+			},
+			"localhost/manifests":        {Registry: "localhost", Repository: "manifests", Tag: "latest"},
+			"org/manifests:v1":           {Registry: "docker.io", Repository: "org/manifests", Tag: "v1"},
+			"manifests":                  {Registry: "docker.io", Repository: "library/manifests", Tag: "latest"},
+			"ghcr.io/org/m:v1@" + digest: {Registry: "ghcr.io", Repository: "org/m", Tag: "v1", Digest: digest},
+		} {
+			parsed, err := oci.ParseReference(ref)
+			g.Expect(err).ToNot(HaveOccurred(), ref)
+			g.Expect(parsed).To(Equal(expected), ref)
+		}
+	})
+
+	t.Run("should reject invalid references", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, ref := range []string{"", "ghcr.io/org/manifests:", "ghcr.io/org/manifests@md5:abc", "ghcr.io/org/m@sha256:zz"} {
+			_, err := oci.ParseReference(ref)
+			g.Expect(err).To(MatchError(oci.ErrInvalidReference), ref)
+		}
+	})
+}
+
+func TestNewFs(t *testing.T) {
+	base := tarGz(t, map[string]string{
+		"base/kustomization.yaml": "resources:\n- deployment.yaml\n",
+		"base/deployment.yaml":    "kind: Deployment\n",
+	})
+	patch := tarGz(t, map[string]string{
+		"base/deployment.yaml": "kind: Deployment\nmetadata:\n  name: patched\n",
+	})
+
+	t.Run("should extract layers in order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ref := newRegistry(t, base, patch).serve(t)
+
+		fsys, err := oci.NewFs(t.Context(), ref+":v1", oci.WithPlainHTTP(true))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(fsys.Exists("/base/kustomization.yaml")).To(BeTrue())
+
+		data, err := fsys.ReadFile("/base/deployment.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(ContainSubstring("patched"))
+		g.Expect(fsys.WriteFile("/base/new.yaml", []byte("x"))).ToNot(Succeed())
+	})
+
+	t.Run("should pin manifest digest", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reg := newRegistry(t, base)
+		ref := reg.serve(t)
+
+		_, err := oci.NewFs(t.Context(), ref+"@"+digestOf(reg.manifest), oci.WithPlainHTTP(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = oci.NewFs(t.Context(), ref+":v1",
+			oci.WithPlainHTTP(true),
+			oci.WithDigest("sha256:"+strings.Repeat("0", 64)),
+		)
+		g.Expect(err).To(MatchError(oci.ErrDigestMismatch))
+	})
+
+	t.Run("should detect tampered layers", func(t *testing.T) {
+		g := NewWithT(t)
+
+		tampered := bytes.Clone(base)
+		tampered[len(tampered)-1] ^= 0xff
+
+		reg := newRegistry(t, base)
+		reg.blobs[digestOf(base)] = tampered
+		ref := reg.serve(t)
+
+		_, err := oci.NewFs(t.Context(), ref+":v1", oci.WithPlainHTTP(true))
+		g.Expect(err).To(MatchError(oci.ErrDigestMismatch))
+	})
+
+	t.Run("should filter layers by media type", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ref := newRegistry(t, base).serve(t)
+
+		_, err := oci.NewFs(t.Context(), ref+":v1",
+			oci.WithPlainHTTP(true),
+			oci.WithMediaTypes(oci.MediaTypeOCILayer),
+		)
+		g.Expect(err).To(MatchError(oci.ErrNoLayers))
+	})
+
+	t.Run("should run verifiers before pulling layers", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reg := newRegistry(t, base)
+		ref := reg.serve(t)
+
+		var verified oci.Artifact
+
+		_, err := oci.NewFs(t.Context(), ref+":v1",
+			oci.WithPlainHTTP(true),
+			oci.WithVerifier(func(_ context.Context, artifact oci.Artifact) error {
+				verified = artifact
+
+				return nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(verified.Digest).To(Equal(digestOf(reg.manifest)))
+		g.Expect(verified.Layers).To(HaveLen(1))
+
+		_, err = oci.NewFs(t.Context(), ref+":v1",
+			oci.WithPlainHTTP(true),
+			oci.WithVerifier(func(context.Context, oci.Artifact) error {
+				return errors.New("unsigned")
+			}),
+		)
+		g.Expect(err).To(MatchError(oci.ErrVerificationFailed))
+		g.Expect(err).To(MatchError(ContainSubstring("unsigned")))
+	})
+
+	t.Run("should authenticate with bearer tokens", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reg := newRegistry(t, base)
+		reg.token, reg.username, reg.password = "secret-token", "user", "pass"
+		ref := reg.serve(t)
+
+		_, err := oci.NewFs(t.Context(), ref+":v1", oci.WithPlainHTTP(true))
+		g.Expect(err).To(MatchError(oci.ErrAuthFailed))
+
+		_, err = oci.NewFs(t.Context(), ref+":v1",
+			oci.WithPlainHTTP(true),
+			oci.WithCredentials("user", "pass"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should read credentials from docker config", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reg := newRegistry(t, base)
+		reg.token, reg.username, reg.password = "secret-token", "user", "pass"
+		ref := reg.serve(t)
+
+		registryHost, _, _ := strings.Cut(ref, "/")
+		config, err := json.Marshal(map[string]any{
+			"auths": map[string]any{
+				registryHost: map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte("user:pass"))},
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		configPath := filepath.Join(t.TempDir(), "config.json")
+		g.Expect(os.WriteFile(configPath, config, 0o600)).To(Succeed())
+
+		_, err = oci.NewFs(t.Context(), ref+":v1",
+			oci.WithPlainHTTP(true),
+			oci.WithDockerConfig(configPath),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should enforce size limit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ref := newRegistry(t, base).serve(t)
+
+		_, err := oci.NewFs(t.Context(), ref+":v1", oci.WithPlainHTTP(true), oci.WithMaxSize(16))
+		g.Expect(err).To(MatchError(oci.ErrArtifactTooLarge))
+	})
+
+	t.Run("should share the extracted size limit between layers", func(t *testing.T) {
+		g := NewWithT(t)
+
+		large := strings.Repeat("a", 48<<10)
+		ref := newRegistry(t,
+			tarGz(t, map[string]string{"a.yaml": large}),
+			tarGz(t, map[string]string{"b.yaml": large}),
+		).serve(t)
+
+		_, err := oci.NewFs(t.Context(), ref+":v1", oci.WithPlainHTTP(true), oci.WithMaxSize(64<<10))
+		g.Expect(err).To(MatchError(oci.ErrArtifactTooLarge))
+	})
+
+	t.Run("should reject entries escaping the root", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ref := newRegistry(t, tarGz(t, map[string]string{"../escape.yaml": "kind: ConfigMap\n"})).serve(t)
+
+		_, err := oci.NewFs(t.Context(), ref+":v1", oci.WithPlainHTTP(true))
+		g.Expect(err).To(MatchError(oci.ErrExtractFailed))
+		g.Expect(err).To(MatchError(fs.ErrTarPathTraversal))
+	})
+
+	t.Run("should fail on missing artifact", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ref := newRegistry(t, base).serve(t)
+
+		_, err := oci.NewFs(t.Context(), strings.Replace(ref, "org/manifests", "org/missing", 1)+":v1",
+			oci.WithPlainHTTP(true),
+		)
+		g.Expect(err).To(MatchError(oci.ErrPullFailed))
+	})
+}
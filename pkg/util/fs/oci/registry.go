@@ -0,0 +1,399 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dockerHubRegistry = "docker.io"
+	dockerHubEndpoint = "registry-1.docker.io"
+)
+
+// Reference is a parsed OCI artifact reference.
+type Reference struct {
+	// Registry is the registry host, optionally with a port (e.g. "ghcr.io").
+	Registry string
+
+	// Repository is the repository path within the registry (e.g. "org/manifests").
+	Repository string
+
+	// Tag is the tag to pull. Empty when the reference is pinned by digest only.
+	Tag string
+
+	// Digest is the manifest digest (e.g. "sha256:..."), if pinned.
+	Digest string
+}
+
+// String returns the reference in "registry/repository[:tag][@digest]" form.
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+
+	return s
+}
+
+// ParseReference parses an artifact reference such as "ghcr.io/org/manifests:v1.0.0",
+// "oci://registry.local:5000/manifests@sha256:..." or "org/manifests" (Docker Hub).
+// A reference without tag or digest selects the "latest" tag.
+func ParseReference(ref string) (Reference, error) {
+	s := strings.TrimPrefix(ref, "oci://")
+	if s == "" {
+		return Reference{}, fmt.Errorf("%w: empty reference", ErrInvalidReference)
+	}
+
+	var result Reference
+
+	if name, digest, found := strings.Cut(s, "@"); found {
+		if err := validateDigest(digest); err != nil {
+			return Reference{}, fmt.Errorf("%w: %q: %w", ErrInvalidReference, ref, err)
+		}
+
+		s = name
+		result.Digest = digest
+	}
+
+	// a colon after the last slash separates the tag; earlier ones belong to a registry port
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		if i == len(s)-1 {
+			return Reference{}, fmt.Errorf("%w: %q: empty tag", ErrInvalidReference, ref)
+		}
+
+		result.Tag = s[i+1:]
+		s = s[:i]
+	}
+
+	registry, repository, found := strings.Cut(s, "/")
+	if !found || (!strings.ContainsAny(registry, ".:") && registry != "localhost") {
+		registry = dockerHubRegistry
+		repository = s
+	}
+
+	if registry == dockerHubRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+
+	if repository == "" {
+		return Reference{}, fmt.Errorf("%w: %q", ErrInvalidReference, ref)
+	}
+
+	if result.Tag == "" && result.Digest == "" {
+		result.Tag = "latest"
+	}
+
+	result.Registry = registry
+	result.Repository = repository
+
+	return result, nil
+}
+
+func validateDigest(digest string) error {
+	algorithm, encoded, found := strings.Cut(digest, ":")
+	if !found || algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest %q: only sha256 is supported", digest) //nolint:err113
+	}
+
+	if _, err := hex.DecodeString(encoded); err != nil || len(encoded) != sha256.Size*2 {
+		return fmt.Errorf("malformed digest %q", digest) //nolint:err113
+	}
+
+	return nil
+}
+
+// credentials are the username and password (or token) used to authenticate.
+type credentials struct {
+	username string
+	password string
+}
+
+// dockerConfig is the subset of ~/.docker/config.json needed to find static credentials.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// defaultDockerConfigPath returns $DOCKER_CONFIG/config.json or ~/.docker/config.json.
+func defaultDockerConfigPath() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate docker config: %w", err)
+	}
+
+	return filepath.Join(home, ".docker", "config.json"), nil
+}
+
+// dockerConfigCredentials looks up the static credentials of a registry in a docker config file.
+// It returns nil credentials if the registry has no entry.
+func dockerConfigCredentials(path string, registry string) (*credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read docker config %s: %w", path, err)
+	}
+
+	var cfg dockerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse docker config %s: %w", path, err)
+	}
+
+	keys := []string{registry, "https://" + registry, "http://" + registry}
+	if registry == dockerHubRegistry {
+		keys = append(keys, "https://index.docker.io/v1/", "index.docker.io")
+	}
+
+	for _, key := range keys {
+		entry, ok := cfg.Auths[key]
+		if !ok {
+			continue
+		}
+
+		if entry.Auth == "" {
+			return &credentials{username: entry.Username, password: entry.Password}, nil
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("malformed auth for %s in docker config %s: %w", key, path, err)
+		}
+
+		username, password, _ := strings.Cut(string(decoded), ":")
+
+		return &credentials{username: username, password: password}, nil
+	}
+
+	return nil, nil
+}
+
+// client is a minimal OCI distribution API client supporting anonymous, basic and
+// bearer token authentication for pulls.
+type client struct {
+	http     *http.Client
+	scheme   string
+	endpoint string
+	ref      Reference
+	creds    *credentials
+	token    string
+}
+
+func newClient(ref Reference, cfg *config) *client {
+	endpoint := ref.Registry
+	if endpoint == dockerHubRegistry {
+		endpoint = dockerHubEndpoint
+	}
+
+	scheme := "https"
+	if cfg.plainHTTP {
+		scheme = "http"
+	}
+
+	return &client{
+		http:     cfg.client,
+		scheme:   scheme,
+		endpoint: endpoint,
+		ref:      ref,
+		creds:    cfg.creds,
+	}
+}
+
+// get fetches a registry API path, authenticating on demand, and returns the body
+// (bounded by limit) and content type.
+func (c *client) get(ctx context.Context, apiPath string, accept []string, limit int64) ([]byte, string, error) {
+	resp, err := c.do(ctx, apiPath, accept)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+
+		if err := c.authenticate(ctx, challenge); err != nil {
+			return nil, "", err
+		}
+
+		resp, err = c.do(ctx, apiPath, accept)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, "", fmt.Errorf("%w: %s: %s", ErrAuthFailed, c.ref, resp.Status)
+	default:
+		return nil, "", fmt.Errorf("%w: %s: %s: unexpected status %s", ErrPullFailed, c.ref, apiPath, resp.Status)
+	}
+
+	if resp.ContentLength > limit {
+		return nil, "", fmt.Errorf("%w: %s: %s exceeds limit of %d bytes", ErrArtifactTooLarge, c.ref, apiPath, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s: %w", ErrPullFailed, c.ref, err)
+	}
+
+	if int64(len(data)) > limit {
+		return nil, "", fmt.Errorf("%w: %s: %s exceeds limit of %d bytes", ErrArtifactTooLarge, c.ref, apiPath, limit)
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+func (c *client) do(ctx context.Context, apiPath string, accept []string) (*http.Response, error) {
+	u := url.URL{Scheme: c.scheme, Host: c.endpoint, Path: "/v2/" + c.ref.Repository + apiPath}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrPullFailed, c.ref, err)
+	}
+
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.creds != nil:
+		req.SetBasicAuth(c.creds.username, c.creds.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrPullFailed, c.ref, err)
+	}
+
+	return resp, nil
+}
+
+// authenticate answers a WWW-Authenticate challenge. Basic challenges are answered with
+// the configured credentials on the retry; bearer challenges exchange them (or nothing,
+// for anonymous pulls) for a pull token.
+func (c *client) authenticate(ctx context.Context, challenge string) error {
+	scheme, params := parseChallenge(challenge)
+
+	switch scheme {
+	case "basic":
+		if c.creds == nil {
+			return fmt.Errorf("%w: %s: registry requires credentials", ErrAuthFailed, c.ref)
+		}
+
+		return nil
+	case "bearer":
+		return c.fetchToken(ctx, params)
+	default:
+		return fmt.Errorf("%w: %s: unsupported challenge %q", ErrAuthFailed, c.ref, challenge)
+	}
+}
+
+func (c *client) fetchToken(ctx context.Context, params map[string]string) error {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("%w: %s: invalid token realm %q", ErrAuthFailed, c.ref, params["realm"])
+	}
+
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.Repository + ":pull"
+	}
+
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrAuthFailed, c.ref, err)
+	}
+
+	if c.creds != nil {
+		req.SetBasicAuth(c.creds.username, c.creds.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrAuthFailed, c.ref, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: token request returned %s", ErrAuthFailed, c.ref, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"` //nolint:tagliatelle
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return fmt.Errorf("%w: %s: malformed token response: %w", ErrAuthFailed, c.ref, err)
+	}
+
+	c.token = body.Token
+	if c.token == "" {
+		c.token = body.AccessToken
+	}
+
+	if c.token == "" {
+		return fmt.Errorf("%w: %s: empty token", ErrAuthFailed, c.ref)
+	}
+
+	// the token replaces basic credentials for the retried request
+	c.creds = nil
+
+	return nil
+}
+
+// parseChallenge splits a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example.com/token",service="registry"` into its
+// lower-cased scheme and parameters.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+
+	for rest != "" {
+		var key, value string
+
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, ", "), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		if key = strings.TrimSpace(key); key != "" {
+			params[strings.ToLower(key)] = value
+		}
+	}
+
+	return strings.ToLower(scheme), params
+}
@@ -0,0 +1,77 @@
+package fs
+
+import (
+	"io"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/internal/unpack"
+)
+
+// DefaultTarMaxSize is the default limit on the total uncompressed size of the files read
+// by NewTarFs.
+const DefaultTarMaxSize int64 = 256 << 20
+
+var (
+	// ErrTarPathTraversal is returned by NewTarFs, and by the archive and OCI filesystems,
+	// for an entry whose path escapes the root of the archive.
+	ErrTarPathTraversal = unpack.ErrPathTraversal
+
+	// ErrTarTooLarge is returned by NewTarFs when the uncompressed files exceed the limit
+	// set with WithTarMaxSize.
+	ErrTarTooLarge = unpack.ErrTooLarge
+
+	// ErrTarInvalid is returned by NewTarFs for a stream that is not a valid, optionally
+	// gzipped, tar archive.
+	ErrTarInvalid = unpack.ErrInvalid
+)
+
+// TarOption is a functional option for configuring a tar filesystem.
+type TarOption func(*tarConfig)
+
+type tarConfig struct {
+	maxSize int64
+}
+
+// WithTarMaxSize limits the total uncompressed size of the files read from the archive,
+// guarding against archives that decompress to far more than their own size.
+// Default: DefaultTarMaxSize.
+func WithTarMaxSize(size int64) TarOption {
+	return func(c *tarConfig) {
+		c.maxSize = size
+	}
+}
+
+// NewTarFs reads a tar stream, gzipped or not, into a read-only in-memory
+// filesys.FileSystem, e.g. a kustomization bundle stored in a ConfigMap, so that it can be
+// rendered with kustomize.WithFileSystem without touching the disk. Entries are rooted at
+// "/": "app/kustomization.yaml" and "./app/kustomization.yaml" both become
+// "/app/kustomization.yaml".
+//
+// The stream is read fully before the filesystem is returned, so a corrupt or oversized
+// archive never yields a partially populated filesystem. Entries with ".." path elements
+// fail with ErrTarPathTraversal; symlinks and other special entries are skipped.
+//
+// Example:
+//
+//	bundle := bytes.NewReader(configMap.BinaryData["bundle.tar.gz"])
+//	tarFs, err := fs.NewTarFs(bundle, fs.WithTarMaxSize(16<<20))
+//	renderer, err := kustomize.New(sources, kustomize.WithFileSystem(tarFs))
+func NewTarFs(r io.Reader, opts ...TarOption) (filesys.FileSystem, error) {
+	cfg := &tarConfig{
+		maxSize: DefaultTarMaxSize,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	tree := unpack.NewTree(cfg.maxSize)
+	if err := tree.Tar(r, nil); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return adapter.New(afero.NewReadOnlyFs(tree.Fs())), nil
+}
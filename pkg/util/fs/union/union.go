@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"os"
+	"path/filepath"
+	"slices"
 
 	"github.com/spf13/afero"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -16,8 +19,10 @@ import (
 type Option func(*config) error
 
 type config struct {
-	overrides map[string][]byte
-	overlay   filesys.FileSystem
+	overrides     map[string][]byte
+	overlay       filesys.FileSystem
+	persistentDir string
+	layers        []filesys.FileSystem
 }
 
 // WithOverride adds a virtual file to the overlay layer.
@@ -57,10 +62,60 @@ func WithOverlayFs(overlay filesys.FileSystem) Option {
 	}
 }
 
+// WithPersistentOverlay backs the overlay layer with an on-disk directory instead of memory,
+// so that overrides and files written during a render survive it and can be inspected.
+// The directory is created if missing.
+//
+// The directory must be empty or missing, or have been prepared by a previous NewFs call:
+// prepared directories are marked with a .k8s-manifest-kit-overlay file, and their content
+// (e.g. left over from a previous run) is removed when the union filesystem is created, so
+// stale files never shadow the base. The files of the last run therefore remain available
+// until the next NewFs call. Non-empty directories without the marker are refused rather
+// than cleaned, so that a mistyped path never deletes unrelated files.
+//
+// It cannot be combined with WithOverlayFs.
+func WithPersistentOverlay(dir string) Option {
+	return func(cfg *config) error {
+		if dir == "" {
+			return errors.New("persistent overlay directory must not be empty") //nolint:err113
+		}
+
+		cfg.persistentDir = dir
+
+		return nil
+	}
+}
+
+// WithLayers stacks additional read-only filesystems between the base and the overlay.
+// Later layers shadow earlier ones, and all of them shadow the base; the overlay (overrides,
+// custom or persistent overlay) always stays on top and receives all writes.
+// Reads traverse the layers top-down, while directory listings (ReadDir, Glob, Walk)
+// merge the entries of every layer.
+//
+// Example with an embedded base, a team overlay and a per-environment overlay:
+//
+//	unionFs, err := union.NewFs(embedded,
+//	    union.WithLayers(teamFs, prodFs),
+//	)
+func WithLayers(layers ...filesys.FileSystem) Option {
+	return func(cfg *config) error {
+		cfg.layers = append(cfg.layers, layers...)
+
+		return nil
+	}
+}
+
 // NewFs creates a union filesystem that layers an overlay over a base filesystem.
 // Writes go to the overlay, reads check the overlay first then fall back to the base.
 // This uses Afero's CopyOnWriteFs for better union filesystem behavior.
 //
+// Paths are resolved like the base resolves them: CleanedAbs and absolute override paths
+// follow the symlinks the base follows (e.g. on disk), so that overrides placed in a
+// kustomization directory reached through a symlink are found by kustomize exactly as
+// files written to disk would be. Bases created by the adapter package lend their
+// SymlinkPolicy and symlink root to the union, and their resolution errors (e.g. ErrSymlinkEscape under
+// SymlinkDeny) are returned by CleanedAbs and NewFs.
+//
 // The base filesystem is typically read-only or represents the "source" files.
 // Options can be used to specify file overrides or a custom overlay filesystem.
 //
@@ -89,14 +144,42 @@ func NewFs(base filesys.FileSystem, opts ...Option) (filesys.FileSystem, error)
 		}
 	}
 
+	if cfg.overlay != nil && cfg.persistentDir != "" {
+		return nil, errors.New("WithOverlayFs and WithPersistentOverlay are mutually exclusive") //nolint:err113
+	}
+
 	// Determine overlay filesystem
 	overlay := cfg.overlay
 	if overlay == nil {
-		// Create an in-memory overlay filesystem
-		overlay = fs.NewMemoryFs()
+		if cfg.persistentDir != "" {
+			persistent, err := newPersistentOverlay(cfg.persistentDir)
+			if err != nil {
+				return nil, err
+			}
+
+			overlay = persistent
+		} else {
+			// Create an in-memory overlay filesystem
+			overlay = fs.NewMemoryFs()
+		}
 
-		// Write all overrides to the overlay
+		// Write all overrides to the overlay. Parent directories are created explicitly:
+		// disk-backed overlays don't create them implicitly, and CopyOnWriteFs only merges
+		// directory listings when the directory exists in both layers.
 		for path, content := range cfg.overrides {
+			if filepath.IsAbs(path) {
+				resolved, err := resolveInBase(base, path)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve override %s: %w", path, err)
+				}
+
+				path = resolved
+			}
+
+			if err := overlay.MkdirAll(filepath.Dir(path)); err != nil {
+				return nil, fmt.Errorf("failed to create directory for override %s: %w", path, err)
+			}
+
 			if err := overlay.WriteFile(path, content); err != nil {
 				return nil, fmt.Errorf("failed to write override %s: %w", path, err)
 			}
@@ -109,6 +192,17 @@ func NewFs(base filesys.FileSystem, opts ...Option) (filesys.FileSystem, error)
 	}
 	baseFs := baseUnwrapper.Unwrap()
 
+	// Chain a CopyOnWriteFs per intermediate layer. Only the outermost CopyOnWriteFs
+	// ever writes to its layer, so intermediate layers are never modified.
+	for i, layer := range cfg.layers {
+		layerUnwrapper, ok := layer.(interface{ Unwrap() afero.Fs })
+		if !ok {
+			return nil, fmt.Errorf("layer %d filesystem must be created with fs package functions", i) //nolint:err113
+		}
+
+		baseFs = afero.NewCopyOnWriteFs(baseFs, layerUnwrapper.Unwrap())
+	}
+
 	overlayUnwrapper, ok := overlay.(interface{ Unwrap() afero.Fs })
 	if !ok {
 		return nil, errors.New("overlay filesystem must be created with fs package functions") //nolint:err113
@@ -119,5 +213,158 @@ func NewFs(base filesys.FileSystem, opts ...Option) (filesys.FileSystem, error)
 	// CopyOnWriteFs writes go to the overlay, reads check overlay first then base
 	unionFs := afero.NewCopyOnWriteFs(baseFs, overlayFs)
 
-	return adapter.New(unionFs), nil
+	// The union confines symlinks like the base does, e.g. denying escaping symlinks in
+	// hermetic renders.
+	var adapterOpts []adapter.Option
+	if policy, ok := base.(interface{ SymlinkPolicy() adapter.SymlinkPolicy }); ok {
+		adapterOpts = append(adapterOpts, adapter.WithSymlinkPolicy(policy.SymlinkPolicy()))
+	}
+
+	if root, ok := base.(interface{ SymlinkRoot() string }); ok && root.SymlinkRoot() != "" {
+		adapterOpts = append(adapterOpts, adapter.WithSymlinkRoot(root.SymlinkRoot()))
+	}
+
+	unionAdapter, ok := adapter.New(unionFs, adapterOpts...).(*adapter.Adapter)
+	if !ok {
+		return nil, errors.New("adapter.New did not return an *adapter.Adapter") //nolint:err113
+	}
+
+	return &resolvingFs{Adapter: unionAdapter, base: base}, nil
+}
+
+// resolvingFs is the union filesystem. Its CleanedAbs resolves paths through the base,
+// since CopyOnWriteFs never resolves symlinks while the base may: without it, a path
+// through a symlink would resolve to one directory for the base and to another for the
+// union, and overrides written to the resolved directory would not be found.
+type resolvingFs struct {
+	*adapter.Adapter
+
+	base filesys.FileSystem
+}
+
+// CleanedAbs resolves path through the base, then confirms it in the union.
+func (u *resolvingFs) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
+	if path == "" {
+		path = "."
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", fmt.Errorf("abs path error on %q: %w", path, err)
+	}
+
+	resolved, err := resolveInBase(u.base, absPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return u.Adapter.CleanedAbs(resolved)
+}
+
+// ConfigureSymlinks returns a copy of the union whose base and union layers use the given
+// symlink policy and root (see adapter.ConfigureSymlinks). The copy shares the layers,
+// overrides and files written to the union included.
+func (u *resolvingFs) ConfigureSymlinks(policy adapter.SymlinkPolicy, root string) (filesys.FileSystem, error) {
+	base, err := adapter.ConfigureSymlinks(u.base, policy, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the symlinks of the base: %w", err)
+	}
+
+	configured, err := u.Adapter.ConfigureSymlinks(policy, root)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	unionAdapter, ok := configured.(*adapter.Adapter)
+	if !ok {
+		return nil, errors.New("adapter.ConfigureSymlinks did not return an *adapter.Adapter") //nolint:err113
+	}
+
+	return &resolvingFs{Adapter: unionAdapter, base: base}, nil
+}
+
+// resolveInBase resolves the longest prefix of the absolute path existing in base with the
+// CleanedAbs of base, e.g. following its symlinks, and appends the remaining elements,
+// which only exist in the overlay if at all. Errors of the base, such as ErrSymlinkEscape
+// of adapters denying symlinks, are returned as is so that the union is not more
+// permissive than its base.
+func resolveInBase(base filesys.FileSystem, path string) (string, error) {
+	path = filepath.Clean(path)
+
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if base.Exists(dir) {
+			resolvedDir, file, err := base.CleanedAbs(dir)
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve %q in base: %w", dir, err)
+			}
+
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve %q in base: %w", path, err)
+			}
+
+			return filepath.Join(string(resolvedDir), file, rel), nil
+		}
+
+		if dir == filepath.Dir(dir) {
+			return path, nil
+		}
+	}
+}
+
+// persistentOverlayMarker marks the directories prepared by newPersistentOverlay, the only
+// non-empty directories it cleans.
+const persistentOverlayMarker = ".k8s-manifest-kit-overlay"
+
+// newPersistentOverlay prepares an empty on-disk directory to be used as overlay layer.
+func newPersistentOverlay(dir string) (filesys.FileSystem, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve persistent overlay directory %s: %w", dir, err)
+	}
+
+	if absDir == filepath.Dir(absDir) {
+		return nil, fmt.Errorf("refusing to use filesystem root %s as persistent overlay", absDir) //nolint:err113
+	}
+
+	if err := os.MkdirAll(absDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create persistent overlay directory %s: %w", absDir, err)
+	}
+
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persistent overlay directory %s: %w", absDir, err)
+	}
+
+	marked := slices.ContainsFunc(entries, func(entry os.DirEntry) bool {
+		return entry.Name() == persistentOverlayMarker
+	})
+
+	if !marked && len(entries) > 0 {
+		return nil, fmt.Errorf( //nolint:err113
+			"refusing to use non-empty directory %s as persistent overlay: it was not prepared by WithPersistentOverlay",
+			absDir,
+		)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == persistentOverlayMarker {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(absDir, entry.Name())); err != nil {
+			return nil, fmt.Errorf("failed to clean persistent overlay directory %s: %w", absDir, err)
+		}
+	}
+
+	if !marked {
+		marker := filepath.Join(absDir, persistentOverlayMarker)
+		content := []byte("Persistent union overlay, emptied by every render using it.\n")
+
+		if err := os.WriteFile(marker, content, 0o644); err != nil { //nolint:gosec
+			return nil, fmt.Errorf("failed to mark persistent overlay directory %s: %w", absDir, err)
+		}
+	}
+
+	return fs.NewBasePathFs(fs.NewFsOnDisk(), absDir)
 }
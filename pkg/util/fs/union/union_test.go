@@ -1,9 +1,16 @@
 package union_test
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
+	"github.com/spf13/afero"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
 	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/adapter"
 	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs/union"
 
 	. "github.com/onsi/gomega"
@@ -129,3 +136,474 @@ func TestNewFsImplementsInterface(t *testing.T) {
 	_ = unionFs
 	g.Expect(true).To(BeTrue()) // Compilation check
 }
+
+func TestNewFs_WithPersistentOverlay(t *testing.T) {
+	t.Run("should persist overrides and writes on disk", func(t *testing.T) {
+		g := NewWithT(t)
+
+		base := fs.NewMemoryFs()
+		g.Expect(base.WriteFile("/base.txt", []byte("base"))).To(Succeed())
+
+		dir := filepath.Join(t.TempDir(), "overlay")
+
+		unionFs, err := union.NewFs(base,
+			union.WithPersistentOverlay(dir),
+			union.WithOverride("/override.txt", []byte("override")),
+		)
+		g.Expect(err).To(Succeed())
+
+		g.Expect(unionFs.MkdirAll("/generated")).To(Succeed())
+		g.Expect(unionFs.WriteFile("/generated/out.yaml", []byte("generated"))).To(Succeed())
+
+		data, err := unionFs.ReadFile("/base.txt")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("base"))
+
+		data, err = os.ReadFile(filepath.Join(dir, "override.txt"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("override"))
+
+		data, err = os.ReadFile(filepath.Join(dir, "generated", "out.yaml"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("generated"))
+
+		g.Expect(filepath.Join(dir, "base.txt")).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("should remove stale files from previous runs", func(t *testing.T) {
+		g := NewWithT(t)
+
+		base := fs.NewMemoryFs()
+		g.Expect(base.WriteFile("/config.txt", []byte("base"))).To(Succeed())
+
+		dir := t.TempDir()
+
+		previous, err := union.NewFs(base, union.WithPersistentOverlay(dir))
+		g.Expect(err).To(Succeed())
+		g.Expect(previous.WriteFile("/config.txt", []byte("stale"))).To(Succeed())
+		g.Expect(filepath.Join(dir, "config.txt")).To(BeAnExistingFile())
+
+		unionFs, err := union.NewFs(base, union.WithPersistentOverlay(dir))
+		g.Expect(err).To(Succeed())
+
+		data, err := unionFs.ReadFile("/config.txt")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("base"))
+		g.Expect(filepath.Join(dir, "config.txt")).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("should refuse non-empty directories it did not prepare", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0o600)).To(Succeed())
+
+		_, err := union.NewFs(fs.NewMemoryFs(), union.WithPersistentOverlay(dir))
+		g.Expect(err).To(MatchError(ContainSubstring("refusing to use non-empty directory")))
+
+		data, err := os.ReadFile(filepath.Join(dir, "notes.txt"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("keep me"))
+	})
+
+	t.Run("should reject combination with custom overlay", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := union.NewFs(fs.NewMemoryFs(),
+			union.WithPersistentOverlay(t.TempDir()),
+			union.WithOverlayFs(fs.NewMemoryFs()),
+		)
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestNewFs_WithLayers(t *testing.T) {
+	newLayer := func(g *WithT, files map[string]string) filesys.FileSystem {
+		layer := fs.NewMemoryFs()
+		for path, content := range files {
+			g.Expect(layer.WriteFile(path, []byte(content))).To(Succeed())
+		}
+
+		return layer
+	}
+
+	newStack := func(g *WithT) filesys.FileSystem {
+		base := newLayer(g, map[string]string{
+			"/app/deployment.yaml": "base",
+			"/app/service.yaml":    "base",
+			"/app/config.yaml":     "base",
+		})
+		team := newLayer(g, map[string]string{
+			"/app/config.yaml": "team",
+			"/app/team.yaml":   "team",
+			"/shared/a.yaml":   "team",
+		})
+		env := newLayer(g, map[string]string{
+			"/app/config.yaml": "env",
+			"/app/env.yaml":    "env",
+		})
+
+		unionFs, err := union.NewFs(base,
+			union.WithLayers(team, env),
+			union.WithOverride("/app/override.yaml", []byte("override")),
+		)
+		g.Expect(err).To(Succeed())
+
+		return unionFs
+	}
+
+	t.Run("should shadow earlier layers", func(t *testing.T) {
+		g := NewWithT(t)
+
+		unionFs := newStack(g)
+
+		for path, expected := range map[string]string{
+			"/app/config.yaml":     "env",
+			"/app/team.yaml":       "team",
+			"/app/deployment.yaml": "base",
+			"/app/override.yaml":   "override",
+		} {
+			data, err := unionFs.ReadFile(path)
+			g.Expect(err).To(Succeed())
+			g.Expect(string(data)).To(Equal(expected), path)
+		}
+	})
+
+	t.Run("should merge entries across all layers", func(t *testing.T) {
+		g := NewWithT(t)
+
+		unionFs := newStack(g)
+
+		g.Expect(unionFs.Exists("/shared/a.yaml")).To(BeTrue())
+		g.Expect(unionFs.IsDir("/shared")).To(BeTrue())
+
+		entries, err := unionFs.ReadDir("/app")
+		g.Expect(err).To(Succeed())
+		g.Expect(entries).To(ConsistOf(
+			"config.yaml", "deployment.yaml", "env.yaml", "override.yaml", "service.yaml", "team.yaml",
+		))
+
+		matches, err := unionFs.Glob("/app/*.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(HaveLen(6))
+	})
+
+	t.Run("should write to overlay only", func(t *testing.T) {
+		g := NewWithT(t)
+
+		team := newLayer(g, map[string]string{"/app/config.yaml": "team"})
+
+		unionFs, err := union.NewFs(fs.NewMemoryFs(), union.WithLayers(team))
+		g.Expect(err).To(Succeed())
+		g.Expect(unionFs.WriteFile("/app/config.yaml", []byte("written"))).To(Succeed())
+
+		data, err := team.ReadFile("/app/config.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("team"))
+	})
+}
+
+func TestNewFs_MergesDirectoryEntries(t *testing.T) {
+	newDiskBase := func(g *WithT) filesys.FileSystem {
+		dir := t.TempDir()
+		g.Expect(os.MkdirAll(filepath.Join(dir, "app"), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "app", "deployment.yaml"), []byte("base"), 0o600)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "app", "service.yaml"), []byte("base"), 0o600)).To(Succeed())
+
+		base, err := fs.NewBasePathFs(fs.NewFsOnDisk(), dir)
+		g.Expect(err).To(Succeed())
+
+		return base
+	}
+
+	newMemoryBase := func(g *WithT) filesys.FileSystem {
+		base := fs.NewMemoryFs()
+		g.Expect(base.WriteFile("/app/deployment.yaml", []byte("base"))).To(Succeed())
+		g.Expect(base.WriteFile("/app/service.yaml", []byte("base"))).To(Succeed())
+
+		return base
+	}
+
+	overlays := map[string]func() []union.Option{
+		"memory overlay": func() []union.Option {
+			return nil
+		},
+		"persistent overlay": func() []union.Option {
+			return []union.Option{union.WithPersistentOverlay(t.TempDir())}
+		},
+	}
+
+	bases := map[string]func(g *WithT) filesys.FileSystem{
+		"memory base": newMemoryBase,
+		"disk base":   newDiskBase,
+	}
+
+	for baseName, newBase := range bases {
+		for overlayName, overlayOpts := range overlays {
+			t.Run(baseName+" with "+overlayName, func(t *testing.T) {
+				g := NewWithT(t)
+
+				opts := append(overlayOpts(),
+					union.WithOverride("/app/service.yaml", []byte("overlay")),
+					union.WithOverride("/app/configmap.yaml", []byte("overlay")),
+					union.WithOverride("/app/extra/patch.yaml", []byte("overlay")),
+				)
+
+				unionFs, err := union.NewFs(newBase(g), opts...)
+				g.Expect(err).To(Succeed())
+
+				entries, err := unionFs.ReadDir("/app")
+				g.Expect(err).To(Succeed())
+				g.Expect(entries).To(Equal([]string{"configmap.yaml", "deployment.yaml", "extra", "service.yaml"}))
+
+				matches, err := unionFs.Glob("/app/*.yaml")
+				g.Expect(err).To(Succeed())
+				g.Expect(matches).To(Equal([]string{"/app/configmap.yaml", "/app/deployment.yaml", "/app/service.yaml"}))
+
+				matches, err = unionFs.Glob("/app/*/*.yaml")
+				g.Expect(err).To(Succeed())
+				g.Expect(matches).To(Equal([]string{"/app/extra/patch.yaml"}))
+
+				data, err := unionFs.ReadFile("/app/service.yaml")
+				g.Expect(err).To(Succeed())
+				g.Expect(string(data)).To(Equal("overlay"))
+			})
+		}
+	}
+}
+
+func TestNewFs_WithReadOnlyNonAferoBase(t *testing.T) {
+	newBase := func(g *WithT) filesys.FileSystem {
+		// kustomize's in-memory filesystem is not Afero-backed
+		base := filesys.MakeFsInMemory()
+		g.Expect(base.MkdirAll("/app/base")).To(Succeed())
+		g.Expect(base.WriteFile("/app/kustomization.yaml", []byte("resources: [base]"))).To(Succeed())
+		g.Expect(base.WriteFile("/app/base/cm.yaml", []byte("kind: ConfigMap"))).To(Succeed())
+
+		return base
+	}
+
+	t.Run("should layer overrides onto the read-only base", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+
+		unionFs, err := union.NewFs(fs.NewReadOnlyFs(base),
+			union.WithOverride("/app/kustomization.yaml", []byte("resources: []")),
+			union.WithOverride("/app/extra.yaml", []byte("kind: Secret")),
+		)
+		g.Expect(err).To(Succeed())
+
+		data, err := unionFs.ReadFile("/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("resources: []"))
+
+		data, err = unionFs.ReadFile("/app/base/cm.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("kind: ConfigMap"))
+
+		entries, err := unionFs.ReadDir("/app")
+		g.Expect(err).To(Succeed())
+		g.Expect(entries).To(ConsistOf("base", "extra.yaml", "kustomization.yaml"))
+
+		// The base is left untouched
+		data, err = base.ReadFile("/app/kustomization.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("resources: [base]"))
+		g.Expect(base.Exists("/app/extra.yaml")).To(BeFalse())
+	})
+
+	t.Run("should report paths of the base in Glob and Walk", func(t *testing.T) {
+		g := NewWithT(t)
+
+		unionFs, err := union.NewFs(fs.NewReadOnlyFs(newBase(g)))
+		g.Expect(err).To(Succeed())
+
+		matches, err := unionFs.Glob("/app/*.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(matches).To(ConsistOf("/app/kustomization.yaml"))
+
+		var walked []string
+		err = unionFs.Walk("/app", func(path string, _ os.FileInfo, err error) error {
+			walked = append(walked, path)
+
+			return err
+		})
+		g.Expect(err).To(Succeed())
+		g.Expect(walked).To(ConsistOf("/app", "/app/base", "/app/base/cm.yaml", "/app/kustomization.yaml"))
+	})
+
+	t.Run("should write to the overlay only", func(t *testing.T) {
+		g := NewWithT(t)
+		base := newBase(g)
+
+		unionFs, err := union.NewFs(fs.NewReadOnlyFs(base))
+		g.Expect(err).To(Succeed())
+
+		g.Expect(unionFs.WriteFile("/app/base/cm.yaml", []byte("changed"))).To(Succeed())
+
+		data, err := unionFs.ReadFile("/app/base/cm.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("changed"))
+
+		data, err = base.ReadFile("/app/base/cm.yaml")
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("kind: ConfigMap"))
+	})
+}
+
+func TestNewFs_Walk(t *testing.T) {
+	g := NewWithT(t)
+
+	base := fs.NewMemoryFs()
+	g.Expect(base.WriteFile("/app/base/cm.yaml", []byte("base"))).To(Succeed())
+	g.Expect(base.WriteFile("/app/base/nested/svc.yaml", []byte("base"))).To(Succeed())
+	g.Expect(base.WriteFile("/app/kustomization.yaml", []byte("base"))).To(Succeed())
+
+	unionFs, err := union.NewFs(base,
+		union.WithOverride("/app/base/override.yaml", []byte("overlay")),
+	)
+	g.Expect(err).To(Succeed())
+
+	t.Run("should visit each merged entry once in lexical order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var visited []string
+		err := unionFs.Walk("/app", func(path string, _ os.FileInfo, err error) error {
+			visited = append(visited, path)
+
+			return err
+		})
+		g.Expect(err).To(Succeed())
+		g.Expect(visited).To(Equal([]string{
+			"/app",
+			"/app/base",
+			"/app/base/cm.yaml",
+			"/app/base/nested",
+			"/app/base/nested/svc.yaml",
+			"/app/base/override.yaml",
+			"/app/kustomization.yaml",
+		}))
+	})
+
+	t.Run("should honor SkipDir and SkipAll", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var visited []string
+		err := unionFs.Walk("/app", func(path string, _ os.FileInfo, err error) error {
+			visited = append(visited, path)
+
+			switch path {
+			case "/app/base/nested":
+				return filepath.SkipDir
+			case "/app/base/override.yaml":
+				return filepath.SkipAll
+			}
+
+			return err
+		})
+		g.Expect(err).To(Succeed())
+		g.Expect(visited).To(Equal([]string{
+			"/app",
+			"/app/base",
+			"/app/base/cm.yaml",
+			"/app/base/nested",
+			"/app/base/override.yaml",
+		}))
+	})
+}
+
+func TestNewFs_Symlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on windows")
+	}
+
+	setup := func(t *testing.T) (string, string) {
+		t.Helper()
+
+		dir, err := filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		link := filepath.Join(t.TempDir(), "link")
+		if err := os.Symlink(dir, link); err != nil {
+			t.Fatal(err)
+		}
+
+		return dir, link
+	}
+
+	t.Run("should resolve paths like the base", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, link := setup(t)
+
+		unionFs, err := union.NewFs(fs.NewFsOnDisk())
+		g.Expect(err).To(Succeed())
+
+		resolved, file, err := unionFs.CleanedAbs(link)
+		g.Expect(err).To(Succeed())
+		g.Expect(string(resolved)).To(Equal(dir))
+		g.Expect(file).To(BeEmpty())
+	})
+
+	t.Run("should place overrides like writes on disk", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, link := setup(t)
+
+		unionFs, err := union.NewFs(fs.NewFsOnDisk(),
+			union.WithOverride(filepath.Join(link, "patch.yaml"), []byte("via link")),
+			union.WithOverride(filepath.Join(link, "new", "file.yaml"), []byte("nested")),
+		)
+		g.Expect(err).To(Succeed())
+
+		data, err := unionFs.ReadFile(filepath.Join(dir, "patch.yaml"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("via link"))
+
+		resolved, file, err := unionFs.CleanedAbs(filepath.Join(link, "new", "file.yaml"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(resolved)).To(Equal(filepath.Join(dir, "new")))
+		g.Expect(file).To(Equal("file.yaml"))
+
+		// the base is left untouched
+		g.Expect(filepath.Join(dir, "patch.yaml")).ToNot(BeAnExistingFile())
+	})
+	t.Run("should deny symlinks escaping their directory like the base", func(t *testing.T) {
+		g := NewWithT(t)
+		_, link := setup(t)
+
+		base := adapter.New(afero.NewOsFs(), adapter.WithSymlinkPolicy(adapter.SymlinkDeny))
+
+		unionFs, err := union.NewFs(base)
+		g.Expect(err).To(Succeed())
+
+		_, _, err = unionFs.CleanedAbs(link)
+		g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape))
+
+		_, err = union.NewFs(base, union.WithOverride(filepath.Join(link, "patch.yaml"), []byte("via link")))
+		g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape))
+	})
+
+	t.Run("should deny symlinks once configured to", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, link := setup(t)
+
+		unionFs, err := union.NewFs(fs.NewFsOnDisk(),
+			union.WithOverride(filepath.Join(dir, "patch.yaml"), []byte("override")),
+		)
+		g.Expect(err).To(Succeed())
+
+		confined, err := adapter.ConfigureSymlinks(unionFs, adapter.SymlinkDeny, "")
+		g.Expect(err).To(Succeed())
+
+		_, _, err = confined.CleanedAbs(link)
+		g.Expect(err).To(MatchError(adapter.ErrSymlinkEscape))
+
+		data, err := confined.ReadFile(filepath.Join(dir, "patch.yaml"))
+		g.Expect(err).To(Succeed())
+		g.Expect(string(data)).To(Equal("override"))
+
+		_, _, err = unionFs.CleanedAbs(link)
+		g.Expect(err).To(Succeed())
+	})
+}
@@ -4,36 +4,107 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
+//nolint:gochecknoglobals
+var suppressor stderrSuppressor
+
+// stderrSuppressor reference-counts stderr redirection so that overlapping calls
+// (concurrent or nested) share a single redirect. os.Stderr is swapped only when
+// the first caller enters and restored only when the last caller leaves, so one
+// caller can never restore stderr while another is still running.
+type stderrSuppressor struct {
+	mu       sync.Mutex
+	refs     int
+	original *os.File
+	r        *os.File
+	w        *os.File
+}
+
+func (s *stderrSuppressor) stderr() *os.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refs > 0 {
+		return s.original
+	}
+
+	return os.Stderr
+}
+
+func (s *stderrSuppressor) acquire() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refs == 0 {
+		// Create a pipe to capture (and discard) stderr output
+		r, w, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("failed to create pipe: %w", err)
+		}
+
+		s.original = os.Stderr
+		s.r = r
+		s.w = w
+
+		// Redirect stderr to the write end of the pipe
+		os.Stderr = w
+
+		// Discard any output written to the pipe
+		go func() {
+			_, _ = io.Copy(io.Discard, r)
+		}()
+	}
+
+	s.refs++
+
+	return nil
+}
+
+func (s *stderrSuppressor) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+
+	os.Stderr = s.original
+	_ = s.w.Close()
+	_ = s.r.Close()
+
+	s.original = nil
+	s.r = nil
+	s.w = nil
+}
+
 // SuppressStderr temporarily redirects stderr to /dev/null,
 // executes the provided function, then restores stderr.
 // Returns the function's error.
+//
+// It is safe to call concurrently and to nest calls: overlapping calls share
+// the same redirect and stderr is restored once the last of them returns,
+// even if fn panics.
 func SuppressStderr(fn func() error) error {
-	// Save original stderr
-	oldStderr := os.Stderr
-
-	// Create a pipe to capture (and discard) stderr output
-	r, w, err := os.Pipe()
-	if err != nil {
-		return fmt.Errorf("failed to create pipe: %w", err)
+	if err := suppressor.acquire(); err != nil {
+		return err
 	}
 
-	// Redirect stderr to the write end of the pipe
-	os.Stderr = w
-
 	// Ensure stderr is restored even if fn panics
-	defer func() {
-		os.Stderr = oldStderr
-		_ = w.Close()
-		_ = r.Close()
-	}()
-
-	// Discard any output written to the pipe
-	go func() {
-		_, _ = io.Copy(io.Discard, r)
-	}()
+	defer suppressor.release()
 
 	// Execute the function
 	return fn()
 }
+
+// Stderr returns the process stderr, bypassing any active SuppressStderr redirect.
+//
+// Code that must write to stderr while other goroutines may be inside
+// SuppressStderr should use this instead of reading os.Stderr directly:
+// reading the variable mid-swap races with the redirect and may silently
+// route output into the discarded pipe.
+func Stderr() *os.File {
+	return suppressor.stderr()
+}
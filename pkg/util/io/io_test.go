@@ -168,3 +168,82 @@ func TestSuppressStderr_NestedCalls(t *testing.T) {
 
 	g.Expect(err).ToNot(HaveOccurred())
 }
+
+func TestStderr_BypassesSuppression(t *testing.T) {
+	g := NewWithT(t)
+
+	originalStderr := os.Stderr
+
+	g.Expect(utilio.Stderr()).To(Equal(originalStderr))
+
+	err := utilio.SuppressStderr(func() error {
+		g.Expect(os.Stderr).ToNot(Equal(originalStderr))
+		g.Expect(utilio.Stderr()).To(Equal(originalStderr))
+
+		return nil
+	})
+
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(utilio.Stderr()).To(Equal(originalStderr))
+}
+
+func TestSuppressStderr_OverlappingCallsKeepRedirect(t *testing.T) {
+	g := NewWithT(t)
+
+	originalStderr := os.Stderr
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- utilio.SuppressStderr(func() error {
+			close(entered)
+			<-release
+
+			return nil
+		})
+	}()
+
+	<-entered
+
+	// A second, shorter call must not restore stderr while the first is still running
+	err := utilio.SuppressStderr(func() error {
+		return nil
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(os.Stderr).ToNot(Equal(originalStderr))
+
+	close(release)
+	g.Expect(<-done).ToNot(HaveOccurred())
+	g.Expect(os.Stderr).To(Equal(originalStderr))
+}
+
+func TestSuppressStderr_ConcurrentPanicRestoration(t *testing.T) {
+	g := NewWithT(t)
+
+	originalStderr := os.Stderr
+
+	const goroutines = 10
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+
+	for i := range goroutines {
+		go func(id int) {
+			defer wg.Done()
+			defer func() { _ = recover() }()
+
+			_ = utilio.SuppressStderr(func() error {
+				if id%2 == 0 {
+					panic("test panic")
+				}
+
+				return nil
+			})
+		}(i)
+	}
+
+	wg.Wait()
+
+	g.Expect(os.Stderr).To(Equal(originalStderr))
+}
@@ -4,21 +4,191 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 )
 
 // WarningHandler is called when kustomize emits deprecation warnings.
 // The handler receives a list of warning messages and should return an error
 // to fail the render, or nil to continue.
+//
+// The handler is called once per render (Process, RenderDetailed, or each side of Diff)
+// with the warnings of all sources, in source order, after the sources are built.
 type WarningHandler func(warnings []string) error
 
+// StructuredWarningHandler is called when kustomize emits deprecation warnings.
+// It receives typed warnings carrying the kind, field and source path, which allows
+// filtering without matching on message text.
+type StructuredWarningHandler func(warnings []Warning) error
+
+// WarningKind classifies a kustomize warning.
+type WarningKind string
+
+const (
+	// WarningKindDeprecatedField is used for warnings about deprecated kustomization fields.
+	WarningKindDeprecatedField WarningKind = "deprecated-field"
+)
+
+// Warning is a structured kustomize warning.
+type Warning struct {
+	// Kind classifies the warning (e.g. WarningKindDeprecatedField).
+	Kind WarningKind
+
+	// Field is the kustomization field the warning refers to (e.g. "bases", "commonLabels").
+	// Empty if it cannot be determined from the message.
+	Field string
+
+	// SourcePath is the path of the Source whose kustomization produced the warning.
+	SourcePath string
+
+	// Message is the original kustomize warning message.
+	Message string
+}
+
+// String returns the original warning message.
+func (w Warning) String() string {
+	return w.Message
+}
+
 var (
 	// ErrKustomizeWarnings is returned when kustomize warnings are detected and the handler fails.
 	ErrKustomizeWarnings = errors.New("kustomize warnings detected")
+
+	// deprecatedFieldPattern extracts the field name from kustomize deprecation messages,
+	// e.g. "# Warning: 'bases' is deprecated. Please use 'resources' instead.".
+	deprecatedFieldPattern = regexp.MustCompile(`'([^']+)' is deprecated`)
 )
 
+// newWarnings converts raw kustomize warning messages into structured warnings.
+func newWarnings(sourcePath string, messages []string) []Warning {
+	result := make([]Warning, len(messages))
+	for i, msg := range messages {
+		result[i] = Warning{
+			Kind:       WarningKindDeprecatedField,
+			SourcePath: sourcePath,
+			Message:    msg,
+		}
+
+		if m := deprecatedFieldPattern.FindStringSubmatch(msg); m != nil {
+			result[i].Field = m[1]
+		}
+	}
+
+	return result
+}
+
+// warningMessages returns the messages of the given warnings.
+func warningMessages(warnings []Warning) []string {
+	result := make([]string, len(warnings))
+	for i := range warnings {
+		result[i] = warnings[i].Message
+	}
+
+	return result
+}
+
+// AdaptWarningHandler converts a string-based WarningHandler into a StructuredWarningHandler.
+// The wrapped handler receives the original warning messages.
+func AdaptWarningHandler(handler WarningHandler) StructuredWarningHandler {
+	return func(warnings []Warning) error {
+		return handler(warningMessages(warnings))
+	}
+}
+
+// WarningFilter returns a handler that only forwards warnings matching the predicate to inner.
+// If no warning matches, inner is not called.
+//
+// Example (fail only on the deprecated 'bases' field):
+//
+//	kustomize.WithStructuredWarningHandler(kustomize.WarningFilter(
+//	    func(w kustomize.Warning) bool { return w.Field == "bases" },
+//	    kustomize.AdaptWarningHandler(kustomize.WarningFail()),
+//	))
+func WarningFilter(predicate func(Warning) bool, inner StructuredWarningHandler) StructuredWarningHandler {
+	return func(warnings []Warning) error {
+		filtered := make([]Warning, 0, len(warnings))
+		for _, w := range warnings {
+			if predicate(w) {
+				filtered = append(filtered, w)
+			}
+		}
+
+		if len(filtered) == 0 {
+			return nil
+		}
+
+		return inner(filtered)
+	}
+}
+
+// WarningDedup returns a handler that drops exact-duplicate messages, e.g. the same
+// deprecation reported by several overlays sharing a base, before passing the warnings of
+// a render to inner. The first occurrence of each message is kept, in order.
+//
+// Example:
+//
+//	kustomize.WithWarningHandler(kustomize.WarningDedup(
+//	    kustomize.WarningLimit(10, kustomize.WarningLog(os.Stderr)),
+//	))
+func WarningDedup(inner WarningHandler) WarningHandler {
+	return func(warnings []string) error {
+		seen := make(map[string]bool, len(warnings))
+		unique := make([]string, 0, len(warnings))
+
+		for _, msg := range warnings {
+			if !seen[msg] {
+				seen[msg] = true
+				unique = append(unique, msg)
+			}
+		}
+
+		return inner(unique)
+	}
+}
+
+// WarningLimit returns a handler that passes at most n warnings of a render to inner,
+// replacing the rest with a single "... and N more" message.
+func WarningLimit(n int, inner WarningHandler) WarningHandler {
+	return func(warnings []string) error {
+		n := max(n, 0)
+		if len(warnings) <= n {
+			return inner(warnings)
+		}
+
+		limited := slices.Clone(warnings[:n])
+		limited = append(limited, fmt.Sprintf("... and %d more", len(warnings)-n))
+
+		return inner(limited)
+	}
+}
+
+// CombineWarningHandlers returns a handler passing the warnings of a render to each of the
+// given handlers, in order. Every handler is called even if a previous one failed; their
+// errors are joined, so errors.Is matches any of them.
+//
+// Example (log the warnings, then fail):
+//
+//	kustomize.WithWarningHandler(kustomize.CombineWarningHandlers(
+//	    kustomize.WarningLog(os.Stderr),
+//	    kustomize.WarningFail(),
+//	))
+func CombineWarningHandlers(handlers ...WarningHandler) WarningHandler {
+	return func(warnings []string) error {
+		errs := make([]error, 0, len(handlers))
+		for _, handler := range handlers {
+			errs = append(errs, handler(warnings))
+		}
+
+		return errors.Join(errs...)
+	}
+}
+
 // WarningIgnore returns a handler that suppresses all warnings.
-// Use this when you want to silence kustomize deprecation warnings entirely.
+// Use this when you want to silence kustomize deprecation warnings. The warnings are
+// neither printed nor fail the render, but are still reported by RenderDetailed.
 //
 // Example:
 //
@@ -53,6 +223,30 @@ func WarningLog(w io.Writer) WarningHandler {
 	}
 }
 
+// WarningLogger returns a handler that logs each warning to logger at warn level, with the
+// source path, kind and field of the warning as attributes. It is the default handler when
+// WithLogger is set.
+//
+// Example:
+//
+//	renderer := kustomize.New(
+//	    []kustomize.Source{{Path: "/path/to/kustomization"}},
+//	    kustomize.WithStructuredWarningHandler(kustomize.WarningLogger(slog.Default())),
+//	)
+func WarningLogger(logger *slog.Logger) StructuredWarningHandler {
+	return func(warnings []Warning) error {
+		for _, w := range warnings {
+			logger.Warn(w.Message,
+				slog.String("source", w.SourcePath),
+				slog.String("kind", string(w.Kind)),
+				slog.String("field", w.Field),
+			)
+		}
+
+		return nil
+	}
+}
+
 // WarningFail returns a handler that fails the render when warnings are present.
 // All warnings are combined into a single error message.
 //
@@ -71,3 +265,91 @@ func WarningFail() WarningHandler {
 		return nil
 	}
 }
+
+// WarningCollector accumulates kustomize deprecation warnings per source so that callers
+// can inspect them programmatically after rendering. It is safe for concurrent use.
+//
+// A collector is independent of the WarningHandler: warnings are recorded first and then
+// passed to the handler, so a collector can be combined with WarningIgnore() to silence
+// output while still keeping the data.
+//
+// Warnings are recorded once per render of a source, whether it is built or served from the
+// render cache, which replays the warnings of the build it stored.
+//
+// Example:
+//
+//	collector := kustomize.NewWarningCollector()
+//	renderer, _ := kustomize.New(sources,
+//	    kustomize.WithWarningCollector(collector),
+//	    kustomize.WithWarningHandler(kustomize.WarningIgnore()),
+//	)
+//	_, _ = renderer.Process(ctx, nil)
+//	for _, path := range collector.Sources() {
+//	    fmt.Println(path, collector.WarningsFor(path))
+//	}
+type WarningCollector struct {
+	mu       sync.Mutex
+	sources  []string
+	warnings map[string][]string
+}
+
+// NewWarningCollector creates an empty WarningCollector.
+func NewWarningCollector() *WarningCollector {
+	return &WarningCollector{
+		warnings: make(map[string][]string),
+	}
+}
+
+// Record stores warnings attributed to the given source path.
+func (c *WarningCollector) Record(sourcePath string, warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.warnings[sourcePath]; !ok {
+		c.sources = append(c.sources, sourcePath)
+	}
+
+	c.warnings[sourcePath] = append(c.warnings[sourcePath], warnings...)
+}
+
+// Warnings returns all collected warnings, ordered by the first source that produced them.
+func (c *WarningCollector) Warnings() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]string, 0)
+	for _, source := range c.sources {
+		result = append(result, c.warnings[source]...)
+	}
+
+	return result
+}
+
+// WarningsFor returns the warnings collected for a single source path.
+func (c *WarningCollector) WarningsFor(sourcePath string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.warnings[sourcePath])
+}
+
+// Sources returns the paths of all sources that produced warnings, in first-seen order.
+func (c *WarningCollector) Sources() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.sources)
+}
+
+// Reset discards all collected warnings.
+func (c *WarningCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sources = nil
+	c.warnings = make(map[string][]string)
+}
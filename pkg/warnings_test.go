@@ -2,12 +2,16 @@ package kustomize_test
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	kustomize "github.com/k8s-manifest-kit/renderer-kustomize/pkg"
+	"github.com/k8s-manifest-kit/renderer-kustomize/pkg/util/fs"
 
 	. "github.com/onsi/gomega"
 )
@@ -205,6 +209,330 @@ func TestWarningHandlers(t *testing.T) {
 		// Handler should not be called for valid kustomizations
 		g.Expect(handlerCalled).To(BeFalse())
 	})
+
+	t.Run("WithWarningScan(false) should skip the deprecation check", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupDeprecatedKustomization(t)
+
+		collector := kustomize.NewWarningCollector()
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithWarningScan(false),
+			kustomize.WithWarningCollector(collector),
+			kustomize.WithWarningHandler(kustomize.WarningFail()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(collector.Warnings()).To(BeEmpty())
+	})
+}
+
+func TestWarningCollector(t *testing.T) {
+
+	t.Run("should collect warnings per source", func(t *testing.T) {
+		g := NewWithT(t)
+		deprecatedDir := setupDeprecatedKustomization(t)
+		cleanDir := setupBasicKustomization(t)
+
+		parentDir := t.TempDir()
+		baseDir := filepath.Join(parentDir, "base")
+		overlayDir := filepath.Join(parentDir, "overlay")
+		writeFile(t, baseDir, "kustomization.yaml", deprecatedKustomization)
+		writeFile(t, baseDir, "configmap.yaml", basicConfigMap)
+		writeFile(t, overlayDir, "kustomization.yaml", deprecatedBasesKustomization)
+
+		collector := kustomize.NewWarningCollector()
+		renderer, err := kustomize.New(
+			[]kustomize.Source{
+				{Path: deprecatedDir},
+				{Path: cleanDir},
+				{Path: overlayDir},
+			},
+			kustomize.WithWarningCollector(collector),
+			kustomize.WithWarningHandler(kustomize.WarningIgnore()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(collector.Sources()).To(Equal([]string{deprecatedDir, overlayDir}))
+		g.Expect(collector.WarningsFor(cleanDir)).To(BeEmpty())
+		g.Expect(strings.Join(collector.WarningsFor(deprecatedDir), " ")).To(ContainSubstring("commonLabels"))
+		g.Expect(strings.Join(collector.WarningsFor(overlayDir), " ")).To(ContainSubstring("bases"))
+		g.Expect(collector.Warnings()).To(HaveLen(
+			len(collector.WarningsFor(deprecatedDir)) + len(collector.WarningsFor(overlayDir)),
+		))
+
+		collector.Reset()
+		g.Expect(collector.Warnings()).To(BeEmpty())
+		g.Expect(collector.Sources()).To(BeEmpty())
+	})
+
+	t.Run("should collect warnings even when handler fails", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupDeprecatedKustomization(t)
+
+		collector := kustomize.NewWarningCollector()
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithWarningCollector(collector),
+			kustomize.WithWarningHandler(kustomize.WarningFail()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrKustomizeWarnings))
+		g.Expect(collector.WarningsFor(dir)).ToNot(BeEmpty())
+	})
+
+	t.Run("should collect warnings once when the render is retried", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupDeprecatedKustomization(t)
+
+		flaky := &flakyFs{
+			FileSystem: fs.NewFsOnDisk(),
+			name:       "configmap.yaml",
+			err:        syscall.ECONNRESET,
+			failures:   2,
+		}
+
+		handled := 0
+		collector := kustomize.NewWarningCollector()
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithFileSystem(flaky),
+			kustomize.WithRetry(3, time.Millisecond),
+			kustomize.WithWarningCollector(collector),
+			kustomize.WithStructuredWarningHandler(func(warnings []kustomize.Warning) error {
+				handled += len(warnings)
+
+				return nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(flaky.reads.Load()).To(BeNumerically(">=", 3))
+		g.Expect(collector.WarningsFor(dir)).To(HaveLen(1))
+		g.Expect(handled).To(Equal(1))
+	})
+}
+
+func TestStructuredWarnings(t *testing.T) {
+
+	t.Run("should pass typed warnings to structured handler", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupDeprecatedKustomization(t)
+
+		var received []kustomize.Warning
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithStructuredWarningHandler(func(warnings []kustomize.Warning) error {
+				received = warnings
+
+				return nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(received).To(HaveLen(1))
+		g.Expect(received[0].Kind).To(Equal(kustomize.WarningKindDeprecatedField))
+		g.Expect(received[0].Field).To(Equal("commonLabels"))
+		g.Expect(received[0].SourcePath).To(Equal(dir))
+		g.Expect(received[0].Message).To(ContainSubstring("commonLabels"))
+		g.Expect(received[0].String()).To(Equal(received[0].Message))
+	})
+
+	t.Run("WarningFilter should only forward matching warnings", func(t *testing.T) {
+		g := NewWithT(t)
+		parentDir := t.TempDir()
+		baseDir := filepath.Join(parentDir, "base")
+		overlayDir := filepath.Join(parentDir, "overlay")
+
+		writeFile(t, baseDir, "kustomization.yaml", deprecatedKustomization)
+		writeFile(t, baseDir, "configmap.yaml", basicConfigMap)
+		writeFile(t, overlayDir, "kustomization.yaml", deprecatedBasesKustomization)
+
+		failOnBases := kustomize.WarningFilter(
+			func(w kustomize.Warning) bool { return w.Field == "bases" },
+			kustomize.AdaptWarningHandler(kustomize.WarningFail()),
+		)
+
+		// base only uses commonLabels: filtered out, render succeeds
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: baseDir}},
+			kustomize.WithStructuredWarningHandler(failOnBases),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// overlay uses bases: render fails with only the bases warning
+		renderer, err = kustomize.New(
+			[]kustomize.Source{{Path: overlayDir}},
+			kustomize.WithStructuredWarningHandler(failOnBases),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrKustomizeWarnings))
+		g.Expect(err.Error()).To(ContainSubstring("bases"))
+		g.Expect(err.Error()).ToNot(ContainSubstring("commonLabels"))
+	})
+
+	t.Run("structured handler should take precedence over string handler", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setupDeprecatedKustomization(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: dir}},
+			kustomize.WithWarningHandler(kustomize.WarningFail()),
+			kustomize.WithStructuredWarningHandler(kustomize.AdaptWarningHandler(kustomize.WarningIgnore())),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
+
+func TestWarningCombinators(t *testing.T) {
+	t.Run("should call the handler once per render with all sources", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var calls [][]string
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupDeprecatedKustomization(t)}, {Path: setupDeprecatedKustomization(t)}},
+			kustomize.WithWarningHandler(func(warnings []string) error {
+				calls = append(calls, warnings)
+
+				return nil
+			}),
+			kustomize.WithConcurrency(2),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(calls).To(HaveLen(1))
+		g.Expect(calls[0]).To(HaveLen(2))
+	})
+
+	t.Run("CombineWarningHandlers should log and fail", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var buf bytes.Buffer
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupDeprecatedKustomization(t)}},
+			kustomize.WithWarningHandler(kustomize.CombineWarningHandlers(
+				kustomize.WarningLog(&buf),
+				kustomize.WarningFail(),
+			)),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrKustomizeWarnings))
+		g.Expect(buf.String()).To(ContainSubstring("commonLabels"))
+	})
+
+	t.Run("CombineWarningHandlers should call every handler and join their errors", func(t *testing.T) {
+		g := NewWithT(t)
+		errOther := errors.New("other")
+
+		var received []string
+		handler := kustomize.CombineWarningHandlers(
+			kustomize.WarningFail(),
+			func(warnings []string) error {
+				received = warnings
+
+				return errOther
+			},
+			kustomize.WarningIgnore(),
+		)
+
+		err := handler([]string{"a"})
+		g.Expect(err).To(MatchError(kustomize.ErrKustomizeWarnings))
+		g.Expect(err).To(MatchError(errOther))
+		g.Expect(received).To(Equal([]string{"a"}))
+
+		g.Expect(kustomize.CombineWarningHandlers()([]string{"a"})).To(Succeed())
+	})
+
+	t.Run("WarningDedup should drop duplicate messages across sources", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var buf bytes.Buffer
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupDeprecatedKustomization(t)}, {Path: setupDeprecatedKustomization(t)}},
+			kustomize.WithWarningHandler(kustomize.WarningDedup(kustomize.WarningLog(&buf))),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(strings.Count(buf.String(), "commonLabels")).To(Equal(1))
+
+		// duplicates are tracked per render
+		buf.Reset()
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(strings.Count(buf.String(), "commonLabels")).To(Equal(1))
+	})
+
+	t.Run("WarningDedup should keep the first occurrence of each message", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var received []string
+		handler := kustomize.WarningDedup(func(warnings []string) error {
+			received = warnings
+
+			return nil
+		})
+
+		g.Expect(handler([]string{"a", "b", "a", "c", "b"})).To(Succeed())
+		g.Expect(received).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	t.Run("WarningLimit should summarize warnings beyond the limit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var received []string
+		handler := kustomize.WarningLimit(2, func(warnings []string) error {
+			received = warnings
+
+			return nil
+		})
+
+		g.Expect(handler([]string{"a", "b", "c", "d"})).To(Succeed())
+		g.Expect(received).To(Equal([]string{"a", "b", "... and 2 more"}))
+
+		g.Expect(handler([]string{"a", "b"})).To(Succeed())
+		g.Expect(received).To(Equal([]string{"a", "b"}))
+	})
+
+	t.Run("should compose with failing handlers", func(t *testing.T) {
+		g := NewWithT(t)
+
+		renderer, err := kustomize.New(
+			[]kustomize.Source{{Path: setupDeprecatedKustomization(t)}, {Path: setupDeprecatedKustomization(t)}},
+			kustomize.WithWarningHandler(kustomize.WarningDedup(kustomize.WarningLimit(0, kustomize.WarningFail()))),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(MatchError(kustomize.ErrKustomizeWarnings))
+		g.Expect(err.Error()).To(ContainSubstring("... and 1 more"))
+	})
 }
 
 func setupDeprecatedKustomization(t *testing.T) string {